@@ -0,0 +1,37 @@
+// Command mockoidc runs a mock OpenID Connect provider to try social login
+// locally. Point a provider at it, for example:
+//
+//	OIDC_GOOGLE_ISSUER=http://localhost:9000
+//	OIDC_GOOGLE_CLIENT_ID=coffee-delivery
+//	OIDC_GOOGLE_REDIRECT_URL=http://localhost:3000/login/callback
+//
+// Every authorization request is granted at once. Add login_hint=<email> to
+// the authorization URL to sign in as someone other than -email.
+package main
+
+import (
+	"flag"
+	"log"
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/oidc/oidctest"
+)
+
+func main() {
+	addr := flag.String("addr", "localhost:9000", "address to listen on")
+	issuer := flag.String("issuer", "http://localhost:9000", "issuer URL the provider is reached at")
+	clientID := flag.String("client-id", "coffee-delivery", "client ID to accept")
+	email := flag.String("email", "", "email of the user who signs in")
+	flag.Parse()
+
+	p, err := oidctest.New(*issuer, *clientID)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if *email != "" {
+		p.User.Email = *email
+	}
+
+	log.Printf("mock OpenID Connect provider for client %q listening on %s", *clientID, *addr)
+	log.Fatal(http.ListenAndServe(*addr, p))
+}
@@ -6,14 +6,21 @@ import (
 	"net/http"
 	"os"
 	"os/signal"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/events"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/handlers"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/middlewares"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/notify"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/oidc"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/routes"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/workers"
 	"github.com/emmrys-jay/coffee-delivery-api/util"
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
@@ -36,26 +43,140 @@ func main() {
 	coffeeService := services.NewCoffeeService(coffeeRepo)
 	coffeeHandler := handlers.NewCoffeeHandler(coffeeService, validate)
 
+	rbacService := services.NewRBACService(repository.NewRoleRepository(db))
+	if err := rbacService.Seed(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+	roleHandler := handlers.NewRoleHandler(rbacService, validate)
+	middlewares.UsePermissions(rbacService)
+
 	userRepo := repository.NewUserRepository(db)
-	userService := services.NewUserService(userRepo)
-	userHandler := handlers.NewUserHandler(userService, validate)
+	apiKeyService := services.NewAPIKeyService(repository.NewAPIKeyRepository(db), userRepo, rbacService)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService, validate)
+	middlewares.UseAPIKeys(apiKeyService)
+	tokenRepo := repository.NewTokenRepository(db)
+	twoFactorService := services.NewTwoFactorService(userRepo, repository.NewTwoFactorRepository(db))
+	twoFactorHandler := handlers.NewTwoFactorHandler(twoFactorService, validate)
+	lockoutService := services.NewLockoutService(repository.NewLockoutRepository(db), userRepo)
+	lockoutHandler := handlers.NewLockoutHandler(lockoutService, validate)
+	authService := services.NewAuthService(userRepo, tokenRepo, twoFactorService, lockoutService)
+	authHandler := handlers.NewAuthHandler(authService, validate)
+	middlewares.UseRevocationList(authService)
+
+	oidcProviders, err := oidc.ProvidersFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+	socialRepo := repository.NewSocialRepository(db)
+	socialService := services.NewSocialLoginService(socialRepo, userRepo, authService, oidcProviders)
+	socialHandler := handlers.NewSocialLoginHandler(socialService, validate)
+
+	couponRepo := repository.NewCouponRepository(db)
+	couponService := services.NewCouponService(couponRepo)
+	couponHandler := handlers.NewCouponHandler(couponService, validate)
+
+	addressRepo := repository.NewAddressRepository(db)
+	addressService := services.NewAddressService(addressRepo)
+	addressHandler := handlers.NewAddressHandler(addressService, validate)
+
+	storeRepo := repository.NewStoreRepository(db)
+	storeService := services.NewStoreService(storeRepo)
+	storeHandler := handlers.NewStoreHandler(storeService, validate)
 
 	orderRepo := repository.NewOrderRepository(db)
 	reserveRepo := repository.NewReservationRepository(db)
-	orderService := services.NewOrderService(orderRepo, userRepo, coffeeRepo, reserveRepo)
-	orderHandler := handlers.NewOrderHandler(orderService, validate)
+	orderService := services.NewOrderService(orderRepo, userRepo, coffeeRepo, reserveRepo, couponRepo, addressRepo, storeRepo)
+	broker := events.NewBroker()
+	orderHandler := handlers.NewOrderHandler(orderService, broker, validate)
+
+	deliveryRepo := repository.NewDeliveryRepository(db)
+	deliveryService := services.NewDeliveryService(deliveryRepo, storeRepo, userRepo, orderService)
+	deliveryHandler := handlers.NewDeliveryHandler(deliveryService, validate)
 
 	trxRepo := repository.NewTransactionRepository(db)
-	trxService, err := services.NewTransactionService(os.Getenv("PAYMENT_PROVIDER"), orderRepo, userRepo, reserveRepo, trxRepo)
+	pmRepo := repository.NewPaymentMethodRepository(db)
+	trxService, err := services.NewTransactionService(os.Getenv("PAYMENT_PROVIDER"), orderRepo, userRepo, reserveRepo, trxRepo, pmRepo)
 	if err != nil {
 		log.Fatal(err)
 	}
 
 	trxHandler := handlers.NewTransactionHandler(trxService, validate)
 
+	kitchenService := services.NewKitchenService(orderRepo, trxRepo)
+	kitchenHandler := handlers.NewKitchenHandler(kitchenService, broker)
+
+	webhookRepo := repository.NewWebhookRepository(db)
+	webhookService := services.NewWebhookService(webhookRepo, orderRepo)
+	webhookHandler := handlers.NewWebhookHandler(webhookService, validate)
+
+	dispatcher := outbox.NewDispatcher(repository.NewOutboxRepository(db))
+	if os.Getenv("OUTBOX_LOG_SINK") == "true" {
+		dispatcher.AddSink(outbox.LogSink{})
+	}
+	services.PublishOrderEvents(broker, dispatcher)
+	services.OpenDeliveries(deliveryService, dispatcher)
+	services.PublishWebhookEvents(webhookService, dispatcher)
+
+	emailTemplates, err := notify.LoadTemplates()
+	if err != nil {
+		log.Fatal(err)
+	}
+	notificationService := services.NewNotificationService(repository.NewNotificationRepository(db), orderRepo, userRepo, trxRepo, emailTemplates, notify.FromEnv(), notify.SMSFromEnv(), notify.PushFromEnv())
+	services.PublishNotifications(notificationService, dispatcher)
+	notificationHandler := handlers.NewNotificationHandler(notificationService, validate)
+
+	userService := services.NewUserService(userRepo, tokenRepo, rbacService, authService, lockoutService, notificationService)
+	userHandler := handlers.NewUserHandler(userService, validate)
+	if err := userService.BootstrapAdmin(context.Background()); err != nil {
+		log.Fatal(err)
+	}
+
+	invoiceService := services.NewInvoiceService(repository.NewInvoiceRepository(db), orderRepo, userRepo, trxRepo, storeRepo)
+	services.PublishInvoices(invoiceService, dispatcher)
+	invoiceHandler := handlers.NewInvoiceHandler(invoiceService)
+
+	subRepo := repository.NewSubscriptionRepository(db)
+	subService := services.NewSubscriptionService(subRepo, orderService, trxService)
+	services.ActivateSubscriptions(subService, dispatcher)
+	subHandler := handlers.NewSubscriptionHandler(subService, validate)
+
+	privacyService := services.NewPrivacyService(userRepo, orderRepo, trxRepo, addressRepo, pmRepo, subRepo, socialRepo, authService)
+	privacyHandler := handlers.NewPrivacyHandler(privacyService, validate)
+
+	// Start background workers
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+
+	workers.Start(workerCtx, "scheduled-orders", time.Minute, workers.ReleaseScheduledOrders(orderService))
+	workers.Start(workerCtx, "subscriptions", 5*time.Minute, workers.RunSubscriptions(subService))
+	workers.Start(workerCtx, "dispatch", 30*time.Second, workers.DispatchDeliveries(deliveryService))
+	workers.Start(workerCtx, "outbox", time.Second, workers.DispatchOutbox(dispatcher))
+	workers.Start(workerCtx, "outbox-cleanup", time.Hour, workers.PruneOutbox(dispatcher))
+	workers.Start(workerCtx, "webhooks", 15*time.Second, workers.DeliverWebhooks(webhookService))
+	workers.Start(workerCtx, "token-cleanup", time.Hour, workers.DeleteExpiredTokens(authService))
+	workers.Start(workerCtx, "login-throttle-cleanup", time.Hour, workers.DeleteStaleLoginThrottles(lockoutService))
+
 	// Set up the Gin router
 	router := gin.Default()
-	routes.SetupRoutes(router, coffeeHandler, userHandler, orderHandler, trxHandler)
+
+	// The client IP that logins are throttled by is only taken from
+	// X-Forwarded-For when the request came through one of these proxies,
+	// e.g. the load balancer. TRUSTED_PLATFORM names a header set by the
+	// hosting platform instead, e.g. CF-Connecting-IP.
+	var trustedProxies []string
+	if v := os.Getenv("TRUSTED_PROXIES"); v != "" {
+		trustedProxies = strings.Split(v, ",")
+	}
+	if err := router.SetTrustedProxies(trustedProxies); err != nil {
+		log.Fatalf("invalid TRUSTED_PROXIES: %s\n", err)
+	}
+	router.TrustedPlatform = os.Getenv("TRUSTED_PLATFORM")
+	if len(trustedProxies) == 0 && router.TrustedPlatform == "" {
+		// Behind a proxy every client would then share the proxy's IP, and
+		// one client's failed logins would lock the others out.
+		log.Println("warning: TRUSTED_PROXIES and TRUSTED_PLATFORM are not set, clients are identified by the IP of the connection")
+	}
+	routes.SetupRoutes(router, coffeeHandler, userHandler, orderHandler, trxHandler, subHandler, couponHandler, addressHandler, storeHandler, deliveryHandler, kitchenHandler, webhookHandler, notificationHandler, invoiceHandler, authHandler, roleHandler, twoFactorHandler, lockoutHandler, privacyHandler, socialHandler, apiKeyHandler)
 
 	var port string
 	if os.Getenv("PORT") != "" {
@@ -80,6 +201,7 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	log.Println("Shutdown Server ...")
+	stopWorkers()
 
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
@@ -0,0 +1,158 @@
+// Package authz decides whether a caller may perform an action on a
+// resource. Each resource has a policy function that returns nil when the
+// action is allowed and ErrForbidden otherwise. Services call the policies
+// after loading a resource, so every handler and background job that goes
+// through a service is checked the same way.
+package authz
+
+import (
+	"errors"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+)
+
+var (
+	// ErrForbidden is returned when the caller may not perform an action.
+	ErrForbidden = errors.New("you are not allowed to access this resource")
+
+	// ErrNotFound is returned instead of ErrForbidden when the caller may not
+	// even see the resource, so that they can not tell it exists, e.g. by
+	// probing the ids of other customers' orders.
+	ErrNotFound = errors.New("resource not found")
+)
+
+// Action is something a caller wants to do with a resource.
+type Action string
+
+const (
+	ActionRead   Action = "read"
+	ActionUpdate Action = "update"
+	ActionDelete Action = "delete"
+	ActionCancel Action = "cancel"
+	ActionPay    Action = "pay"
+)
+
+// Principal is the authenticated caller an action is performed for, with
+// the permissions granted by their role.
+type Principal struct {
+	UserID      uint
+	Role        string
+	Permissions []string
+}
+
+// User returns the principal of a regular user acting on their own behalf.
+func User(userId uint) Principal {
+	return Principal{UserID: userId, Role: models.ROLE_USER}
+}
+
+// Has reports whether the principal holds a permission.
+func (p Principal) Has(permission string) bool {
+	for _, granted := range p.Permissions {
+		if granted == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// owns reports whether the principal is the user a resource belongs to.
+func (p Principal) owns(userId uint) bool {
+	return p.UserID != 0 && userId == p.UserID
+}
+
+// Order is the policy for orders. Customers may read, cancel and pay for
+// their own orders. Staff who manage orders may read and cancel any order,
+// but a payment is always made from the customer's own account, so only the
+// owner may pay. Orders the caller may not read are reported as not found.
+func Order(p Principal, action Action, order *models.Order) error {
+	owner := p.owns(order.UserID)
+	if !owner && !p.Has(models.PERM_ORDERS_MANAGE) {
+		return ErrNotFound
+	}
+
+	switch action {
+	case ActionRead, ActionCancel:
+		if owner || p.Has(models.PERM_ORDERS_MANAGE) {
+			return nil
+		}
+	case ActionPay:
+		if owner {
+			return nil
+		}
+	}
+
+	return ErrForbidden
+}
+
+// Receipt is the policy for the receipt of an order, which may be read by
+// anyone who may read the order.
+func Receipt(p Principal, order *models.Order) error {
+	return Order(p, ActionRead, order)
+}
+
+// Tracking is the policy for the live tracking of an order's delivery. It
+// may be read by anyone who may read the order, and by the courier carrying
+// it. delivery is nil until the order has gone out for delivery.
+func Tracking(p Principal, order *models.Order, delivery *models.Delivery) error {
+	if delivery != nil && delivery.CourierID != nil && p.owns(*delivery.CourierID) {
+		return nil
+	}
+	return Order(p, ActionRead, order)
+}
+
+// Subscription is the policy for subscriptions. Customers may do anything
+// with their own subscriptions. Staff who manage orders may read and cancel
+// any subscription, but not change its schedule.
+func Subscription(p Principal, action Action, sub *models.Subscription) error {
+	if p.owns(sub.UserID) {
+		return nil
+	}
+	if !p.Has(models.PERM_ORDERS_MANAGE) {
+		return ErrNotFound
+	}
+
+	switch action {
+	case ActionRead, ActionCancel:
+		return nil
+	}
+	return ErrForbidden
+}
+
+// Address is the policy for the addresses in a customer's address book.
+// Only the customer may change them; staff who can view user accounts may
+// read them.
+func Address(p Principal, action Action, address *models.Address) error {
+	if p.owns(address.UserID) {
+		return nil
+	}
+	if !p.Has(models.PERM_USERS_READ) {
+		return ErrNotFound
+	}
+
+	if action == ActionRead {
+		return nil
+	}
+	return ErrForbidden
+}
+
+// PaymentMethod is the policy for saved cards. Only the customer may pay
+// with a card. Staff who can change user accounts may remove it, e.g. when
+// it is reported stolen, and staff who can view them may read it.
+func PaymentMethod(p Principal, action Action, pm *models.PaymentMethod) error {
+	if p.owns(pm.UserID) {
+		return nil
+	}
+	if !p.Has(models.PERM_USERS_READ) && !p.Has(models.PERM_USERS_WRITE) {
+		return ErrNotFound
+	}
+
+	switch action {
+	case ActionRead:
+		return nil
+	case ActionDelete:
+		if p.Has(models.PERM_USERS_WRITE) {
+			return nil
+		}
+	}
+	return ErrForbidden
+}
@@ -0,0 +1,121 @@
+package authz
+
+import (
+	"testing"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderPolicy(t *testing.T) {
+	order := &models.Order{Id: 10, UserID: 1}
+
+	owner := User(1)
+	other := User(2)
+	admin := Principal{UserID: 3, Role: models.ROLE_ADMIN, Permissions: []string{models.PERM_ORDERS_MANAGE}}
+	courier := Principal{UserID: 4, Role: models.ROLE_COURIER, Permissions: []string{models.PERM_DELIVERIES_FULFIL}}
+	barista := Principal{UserID: 5, Role: models.ROLE_BARISTA, Permissions: []string{models.PERM_KITCHEN_OPERATE}}
+
+	tests := []struct {
+		name      string
+		principal Principal
+		action    Action
+		want      error
+	}{
+		{"owner reads", owner, ActionRead, nil},
+		{"owner cancels", owner, ActionCancel, nil},
+		{"owner pays", owner, ActionPay, nil},
+
+		{"other user reads", other, ActionRead, ErrNotFound},
+		{"other user cancels", other, ActionCancel, ErrNotFound},
+		{"other user pays", other, ActionPay, ErrNotFound},
+
+		{"admin reads", admin, ActionRead, nil},
+		{"admin cancels", admin, ActionCancel, nil},
+		{"admin pays", admin, ActionPay, ErrForbidden},
+
+		{"courier reads", courier, ActionRead, ErrNotFound},
+		{"barista cancels", barista, ActionCancel, ErrNotFound},
+
+		{"unknown action", owner, Action("delete"), ErrForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := Order(tt.principal, tt.action, order)
+			if tt.want == nil {
+				require.NoError(t, err)
+			} else {
+				require.ErrorIs(t, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestOrderPolicyZeroPrincipal(t *testing.T) {
+	// A principal whose claims could not be read must never match an
+	// order, even one with a zero user id.
+	require.ErrorIs(t, Order(Principal{}, ActionRead, &models.Order{UserID: 0}), ErrNotFound)
+}
+
+func TestResourcePolicies(t *testing.T) {
+	courierId := uint(4)
+	order := &models.Order{Id: 10, UserID: 1}
+	delivery := &models.Delivery{OrderID: 10, CourierID: &courierId}
+	sub := &models.Subscription{Id: 20, UserID: 1}
+	address := &models.Address{Id: 30, UserID: 1}
+	pm := &models.PaymentMethod{Id: 40, UserID: 1}
+
+	owner := User(1)
+	other := User(2)
+	manager := Principal{UserID: 3, Role: models.ROLE_ADMIN, Permissions: []string{models.PERM_ORDERS_MANAGE}}
+	courier := Principal{UserID: courierId, Role: models.ROLE_COURIER, Permissions: []string{models.PERM_DELIVERIES_FULFIL}}
+	support := Principal{UserID: 5, Permissions: []string{models.PERM_USERS_READ}}
+	accounts := Principal{UserID: 6, Permissions: []string{models.PERM_USERS_READ, models.PERM_USERS_WRITE}}
+
+	tests := []struct {
+		name string
+		err  error
+		want error
+	}{
+		{"owner reads receipt", Receipt(owner, order), nil},
+		{"manager reads receipt", Receipt(manager, order), nil},
+		{"other user reads receipt", Receipt(other, order), ErrNotFound},
+
+		{"owner tracks", Tracking(owner, order, delivery), nil},
+		{"owner tracks before dispatch", Tracking(owner, order, nil), nil},
+		{"assigned courier tracks", Tracking(courier, order, delivery), nil},
+		{"courier tracks before dispatch", Tracking(courier, order, nil), ErrNotFound},
+		{"other user tracks", Tracking(other, order, delivery), ErrNotFound},
+
+		{"owner pauses subscription", Subscription(owner, ActionUpdate, sub), nil},
+		{"manager reads subscription", Subscription(manager, ActionRead, sub), nil},
+		{"manager cancels subscription", Subscription(manager, ActionCancel, sub), nil},
+		{"manager pauses subscription", Subscription(manager, ActionUpdate, sub), ErrForbidden},
+		{"other user reads subscription", Subscription(other, ActionRead, sub), ErrNotFound},
+
+		{"owner updates address", Address(owner, ActionUpdate, address), nil},
+		{"support reads address", Address(support, ActionRead, address), nil},
+		{"support deletes address", Address(support, ActionDelete, address), ErrForbidden},
+		{"other user reads address", Address(other, ActionRead, address), ErrNotFound},
+		{"manager reads address", Address(manager, ActionRead, address), ErrNotFound},
+
+		{"owner pays with card", PaymentMethod(owner, ActionPay, pm), nil},
+		{"owner deletes card", PaymentMethod(owner, ActionDelete, pm), nil},
+		{"support deletes card", PaymentMethod(support, ActionDelete, pm), ErrForbidden},
+		{"accounts deletes card", PaymentMethod(accounts, ActionDelete, pm), nil},
+		{"accounts pays with card", PaymentMethod(accounts, ActionPay, pm), ErrForbidden},
+		{"other user pays with card", PaymentMethod(other, ActionPay, pm), ErrNotFound},
+		{"zero principal pays with card", PaymentMethod(Principal{}, ActionPay, &models.PaymentMethod{}), ErrNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.want == nil {
+				require.NoError(t, tt.err)
+			} else {
+				require.ErrorIs(t, tt.err, tt.want)
+			}
+		})
+	}
+}
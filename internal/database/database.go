@@ -27,19 +27,62 @@ func ConnectAndAutoMigrate() (*gorm.DB, error) {
 	}
 
 	// Run auto migrations
-	err = db.AutoMigrate(
+	if err := AutoMigrate(db); err != nil {
+		log.Fatalf("failed to run auto migrations: %v", err)
+		return nil, err
+	}
+
+	return db, nil
+}
+
+// AutoMigrate creates or updates the tables of every model.
+func AutoMigrate(db *gorm.DB) error {
+	if err := db.AutoMigrate(
 		models.Coffee{},
 		models.User{},
 		models.Order{},
 		models.OrderItem{},
 		models.Transaction{},
-	)
-	if err != nil {
-		log.Fatalf("failed to run auto migrations: %v", err)
-		return nil, err
+		models.PaymentMethod{},
+		models.Subscription{},
+		models.SubscriptionItem{},
+		models.Coupon{},
+		models.CouponRedemption{},
+		models.Address{},
+		models.Store{},
+		models.DeliveryZone{},
+		models.Delivery{},
+		models.CourierLocation{},
+		models.WebhookEndpoint{},
+		models.WebhookDelivery{},
+		models.OutboxEvent{},
+		models.OutboxDelivery{},
+		models.Notification{},
+		models.Invoice{},
+		models.InvoiceCounter{},
+		models.RefreshToken{},
+		models.RevokedSession{},
+		models.UserToken{},
+		models.RecoveryCode{},
+		models.UserIdentity{},
+		models.OAuthState{},
+		models.APIKey{},
+		models.LoginThrottle{},
+		models.AuditLog{},
+		models.Permission{},
+		models.Role{},
+	); err != nil {
+		return err
 	}
 
-	return db, nil
+	// Transactions used to keep the provider's reusable authorization code;
+	// it now lives on payment methods only.
+	if db.Migrator().HasColumn(&models.Transaction{}, "authorization") {
+		if err := db.Migrator().DropColumn(&models.Transaction{}, "authorization"); err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
 func Close(db *gorm.DB) {
@@ -0,0 +1,62 @@
+// Package dbtest opens a database for tests that need one. Each call gets a
+// fresh, migrated schema that is dropped when the test ends, so tests and
+// packages can run in parallel against the same server.
+//
+// Tests using it are skipped unless TEST_DATABASE_URL is set to the DSN of a
+// PostgreSQL database, e.g.
+//
+//	TEST_DATABASE_URL="host=localhost user=postgres dbname=coffee_test sslmode=disable" go test ./...
+package dbtest
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"testing"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// Open returns a connection to a new schema with every table migrated.
+func Open(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	dsn := os.Getenv("TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	b := make([]byte, 6)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	schema := "test_" + hex.EncodeToString(b)
+
+	admin := open(t, dsn)
+	if err := admin.Exec("CREATE SCHEMA " + schema).Error; err != nil {
+		t.Fatalf("error creating schema: %v", err)
+	}
+	t.Cleanup(func() {
+		admin.Exec("DROP SCHEMA " + schema + " CASCADE")
+		database.Close(admin)
+	})
+
+	db := open(t, dsn+" search_path="+schema)
+	t.Cleanup(func() { database.Close(db) })
+
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatalf("error migrating schema: %v", err)
+	}
+	return db
+}
+
+func open(t *testing.T, dsn string) *gorm.DB {
+	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		t.Fatalf("error connecting to the test database: %v", err)
+	}
+	return db
+}
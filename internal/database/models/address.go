@@ -0,0 +1,52 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+type Address struct {
+	Id         uint      `gorm:"primaryKey" json:"id"`
+	UserID     uint      `gorm:"not null;index" json:"user_id"`
+	Label      string    `gorm:"size:64" json:"label"`
+	Line1      string    `gorm:"size:255;not null" json:"line1"`
+	Line2      string    `gorm:"size:255" json:"line2"`
+	City       string    `gorm:"size:128;not null" json:"city"`
+	State      string    `gorm:"size:128" json:"state"`
+	Country    string    `gorm:"size:2;not null" json:"country"`
+	PostalCode string    `gorm:"size:32" json:"postal_code"`
+	Latitude   float64   `gorm:"not null" json:"latitude"`
+	Longitude  float64   `gorm:"not null" json:"longitude"`
+	CreatedAt  time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt  time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// String formats the address on a single line.
+func (a *Address) String() string {
+	parts := []string{a.Line1}
+	for _, p := range []string{a.Line2, a.City, a.State, a.PostalCode, a.Country} {
+		if p != "" {
+			parts = append(parts, p)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Location returns the address coordinates.
+func (a *Address) Location() util.GeoPoint {
+	return util.GeoPoint{Latitude: a.Latitude, Longitude: a.Longitude}
+}
+
+type AddressRequest struct {
+	Label      string  `validate:"max=64" json:"label"`
+	Line1      string  `validate:"required,max=255" json:"line1"`
+	Line2      string  `validate:"max=255" json:"line2"`
+	City       string  `validate:"required,max=128" json:"city"`
+	State      string  `validate:"max=128" json:"state"`
+	Country    string  `validate:"required,iso3166_1_alpha2" json:"country"`
+	PostalCode string  `validate:"max=32" json:"postal_code"`
+	Latitude   float64 `validate:"gte=-90,lte=90" json:"latitude"`
+	Longitude  float64 `validate:"gte=-180,lte=180" json:"longitude"`
+}
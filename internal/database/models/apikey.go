@@ -0,0 +1,70 @@
+package models
+
+import "time"
+
+const (
+	// API_KEY_PREFIX starts every API key, which tells them apart from
+	// access tokens. Keys look like "cdk_<prefix>_<secret>".
+	API_KEY_PREFIX = "cdk_"
+
+	// SCOPE_CUSTOMER lets an API key use the customer endpoints as its
+	// owner: browsing coffees, ordering, paying and subscriptions. The other
+	// scopes are permissions.
+	SCOPE_CUSTOMER = "customer"
+)
+
+// APIKey lets a machine client, such as a POS terminal or a partner's
+// ordering system, call the API as the user who owns it without their
+// password. The key is stored by its hash; Prefix identifies it.
+//
+// A key is granted the permissions in Scopes that the owner's role still
+// grants, so changing the role narrows every key of the owner.
+type APIKey struct {
+	Id      uint     `gorm:"primaryKey" json:"id"`
+	Name    string   `gorm:"size:100;not null" json:"name"`
+	Prefix  string   `gorm:"size:16;not null;uniqueIndex" json:"prefix"`
+	KeyHash string   `gorm:"size:64;not null" json:"-"`
+	UserID  uint     `gorm:"not null;index" json:"user_id"`
+	Scopes  []string `gorm:"serializer:json" json:"scopes"`
+
+	ExpiresAt  *time.Time `gorm:"index" json:"expires_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	LastUsedIP string     `gorm:"size:64" json:"last_used_ip,omitempty"`
+
+	// ReplacedByID is set when the key was rotated. The key keeps working
+	// until ExpiresAt, to give clients time to switch.
+	ReplacedByID *uint `json:"replaced_by_id,omitempty"`
+
+	CreatedByID uint      `gorm:"not null" json:"created_by_id"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// Active reports whether the key can be used at now.
+func (k *APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+// NewAPIKey is returned when a key is created or rotated. Key is shown only
+// this once.
+type NewAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+type CreateAPIKeyRequest struct {
+	Name   string   `validate:"required,max=100" json:"name"`
+	UserID uint     `validate:"required" json:"user_id"`
+	Scopes []string `validate:"required,min=1,dive,required" json:"scopes"`
+
+	// ExpiresInDays is how long the key can be used. Keys without it do
+	// not expire.
+	ExpiresInDays int `validate:"omitempty,min=1,max=3650" json:"expires_in_days"`
+}
+
+type RotateAPIKeyRequest struct {
+	// GracePeriodHours is how long the old key keeps working, 24 hours if
+	// not given. Zero revokes it at once.
+	GracePeriodHours *int `validate:"omitnil,min=0,max=168" json:"grace_period_hours"`
+}
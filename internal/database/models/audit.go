@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+const (
+	AUDIT_ACCOUNT_LOCKED   = "account_locked"
+	AUDIT_ACCOUNT_UNLOCKED = "account_unlocked"
+	AUDIT_IP_LOCKED        = "ip_locked"
+	AUDIT_IP_UNLOCKED      = "ip_unlocked"
+	AUDIT_ACCOUNT_DELETED  = "account_deleted"
+	AUDIT_API_KEY_CREATED  = "api_key_created"
+	AUDIT_API_KEY_ROTATED  = "api_key_rotated"
+	AUDIT_API_KEY_REVOKED  = "api_key_revoked"
+)
+
+// AuditLog records a security relevant event. ActorID is the user who
+// caused it, and is empty for events the system raised by itself.
+type AuditLog struct {
+	Id        uint      `gorm:"primaryKey" json:"id"`
+	Event     string    `gorm:"size:64;not null;index" json:"event"`
+	UserID    *uint     `gorm:"index" json:"user_id,omitempty"`
+	Email     string    `gorm:"size:255" json:"email,omitempty"`
+	IP        string    `gorm:"size:64" json:"ip,omitempty"`
+	ActorID   *uint     `json:"actor_id,omitempty"`
+	Detail    string    `json:"detail,omitempty"`
+	CreatedAt time.Time `gorm:"not null;index" json:"created_at"`
+}
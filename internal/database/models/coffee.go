@@ -7,7 +7,9 @@ type Coffee struct {
 	Brand       string    `json:"brand"`
 	Name        string    `json:"name"`
 	Description string    `json:"description"`
+	Category    string    `gorm:"index" json:"category"`
 	Price       string    `gorm:"type:decimal(10,2)" json:"price"`
+	Currency    string    `gorm:"size:3;not null;default:NGN" json:"currency"`
 	Quantity    uint      `json:"quantity"`
 	CreatedAt   time.Time `gorm:"not null,index" json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
@@ -17,7 +19,9 @@ type CreateCoffee struct {
 	Brand       string `validate:"required" json:"brand"`
 	Name        string `validate:"required" json:"name"`
 	Description string `validate:"required" json:"description"`
+	Category    string `json:"category"`
 	Price       string `validate:"required,sig" json:"price"`
+	Currency    string `validate:"omitempty,currency" json:"currency"`
 	Quantity    uint   `validate:"required,min=1" json:"quantity"`
 }
 
@@ -25,6 +29,8 @@ type UpdateCoffee struct {
 	Brand       string `validate:"required" json:"brand"`
 	Name        string `validate:"required" json:"name"`
 	Description string `validate:"required" json:"description"`
+	Category    string `json:"category"`
 	Price       string `validate:"required,sig" json:"price"`
+	Currency    string `validate:"omitempty,currency" json:"currency"`
 	Quantity    uint   `validate:"required,min=1" json:"quantity"`
 }
@@ -0,0 +1,58 @@
+package models
+
+import "time"
+
+const (
+	COUPON_PERCENTAGE = "PERCENTAGE"
+	COUPON_FIXED      = "FIXED"
+)
+
+func IsValidCouponType(t string) bool {
+	return t == COUPON_PERCENTAGE || t == COUPON_FIXED
+}
+
+// Coupon is an admin-managed promo code. Empty CoffeeIDs and Categories mean
+// the coupon applies to the whole basket; zero limits mean unlimited. Fixed
+// amount coupons only apply to orders in their Currency.
+type Coupon struct {
+	Id             uint       `gorm:"primaryKey" json:"id"`
+	Code           string     `gorm:"size:64;unique;not null" json:"code"`
+	Type           string     `gorm:"not null" json:"type"`
+	Value          string     `gorm:"type:decimal(10,2);not null" json:"value"`
+	Currency       string     `gorm:"size:3" json:"currency,omitempty"`
+	MinBasket      string     `gorm:"type:decimal(10,2);not null;default:0" json:"min_basket"`
+	MaxRedemptions uint       `gorm:"not null;default:0" json:"max_redemptions"`
+	PerUserLimit   uint       `gorm:"not null;default:0" json:"per_user_limit"`
+	Redemptions    uint       `gorm:"not null;default:0" json:"redemptions"`
+	StartsAt       *time.Time `json:"starts_at,omitempty"`
+	EndsAt         *time.Time `json:"ends_at,omitempty"`
+	CoffeeIDs      []uint     `gorm:"serializer:json" json:"coffee_ids"`
+	Categories     []string   `gorm:"serializer:json" json:"categories"`
+	Active         bool       `gorm:"not null;default:true" json:"active"`
+	CreatedAt      time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+type CouponRedemption struct {
+	Id        uint      `gorm:"primaryKey" json:"id"`
+	CouponID  uint      `gorm:"not null;index" json:"coupon_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	OrderID   uint      `gorm:"not null;index" json:"order_id"`
+	Discount  string    `gorm:"type:decimal(10,2)" json:"discount"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+}
+
+type CreateCoupon struct {
+	Code           string     `validate:"required,max=64" json:"code"`
+	Type           string     `validate:"required,oneof=PERCENTAGE FIXED" json:"type"`
+	Value          string     `validate:"required,sig" json:"value"`
+	Currency       string     `validate:"required_if=Type FIXED,omitempty,currency" json:"currency"`
+	MinBasket      string     `json:"min_basket"`
+	MaxRedemptions uint       `json:"max_redemptions"`
+	PerUserLimit   uint       `json:"per_user_limit"`
+	StartsAt       *time.Time `json:"starts_at"`
+	EndsAt         *time.Time `json:"ends_at"`
+	CoffeeIDs      []uint     `json:"coffee_ids"`
+	Categories     []string   `json:"categories"`
+	Active         *bool      `json:"active"`
+}
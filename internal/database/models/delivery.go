@@ -0,0 +1,60 @@
+package models
+
+import "time"
+
+const (
+	DELIVERY_UNASSIGNED = "UNASSIGNED"
+	DELIVERY_ASSIGNED   = "ASSIGNED"
+	DELIVERY_DELIVERED  = "DELIVERED"
+	DELIVERY_CANCELED   = "CANCELED"
+)
+
+// Delivery tracks an order that is out for delivery, the courier carrying it
+// and the courier's last known position. A courier carries one delivery at a
+// time.
+type Delivery struct {
+	Id          uint       `gorm:"primaryKey" json:"id"`
+	OrderID     uint       `gorm:"not null;uniqueIndex" json:"order_id"`
+	StoreID     *uint      `json:"store_id,omitempty"`
+	CourierID   *uint      `gorm:"index;uniqueIndex:idx_deliveries_active_courier,where:status = 'ASSIGNED'" json:"courier_id,omitempty"`
+	Status      string     `gorm:"not null;index" json:"status"`
+	AssignedAt  *time.Time `json:"assigned_at,omitempty"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+
+	LastLatitude  *float64   `json:"last_latitude,omitempty"`
+	LastLongitude *float64   `json:"last_longitude,omitempty"`
+	LastPingAt    *time.Time `json:"last_ping_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// CourierLocation is a single location ping sent by a courier.
+type CourierLocation struct {
+	Id         uint      `gorm:"primaryKey" json:"id"`
+	CourierID  uint      `gorm:"not null;index:idx_courier_recorded" json:"courier_id"`
+	Latitude   float64   `gorm:"not null" json:"latitude"`
+	Longitude  float64   `gorm:"not null" json:"longitude"`
+	RecordedAt time.Time `gorm:"not null;index:idx_courier_recorded" json:"recorded_at"`
+}
+
+type LocationPing struct {
+	Latitude  float64 `validate:"gte=-90,lte=90" json:"latitude"`
+	Longitude float64 `validate:"gte=-180,lte=180" json:"longitude"`
+}
+
+type AssignCourierRequest struct {
+	CourierID uint `validate:"required" json:"courier_id"`
+}
+
+// DeliveryTracking is what a customer sees about their order's delivery.
+type DeliveryTracking struct {
+	OrderID       uint       `json:"order_id"`
+	OrderStatus   string     `json:"order_status"`
+	Status        string     `json:"delivery_status"`
+	CourierName   string     `json:"courier_name,omitempty"`
+	Latitude      *float64   `json:"latitude,omitempty"`
+	Longitude     *float64   `json:"longitude,omitempty"`
+	LastUpdatedAt *time.Time `json:"last_updated_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Invoice is the VAT invoice issued for a paid order. Invoices are numbered
+// per store without gaps: a number is only taken in the same database
+// transaction that stores the invoice. Seller, buyer and amounts are copied
+// so the invoice never changes once issued.
+type Invoice struct {
+	Id            uint   `gorm:"primaryKey" json:"id"`
+	StoreID       uint   `gorm:"not null;uniqueIndex:idx_invoice_store_sequence" json:"store_id"`
+	Sequence      uint   `gorm:"not null;uniqueIndex:idx_invoice_store_sequence" json:"sequence"`
+	Number        string `gorm:"size:32;not null;uniqueIndex" json:"number"`
+	OrderID       uint   `gorm:"not null;uniqueIndex" json:"order_id"`
+	TransactionID uint   `gorm:"not null" json:"transaction_id"`
+	UserID        uint   `gorm:"not null;index" json:"user_id"`
+
+	SellerName      string `gorm:"size:255;not null" json:"seller_name"`
+	SellerAddress   string `gorm:"size:255" json:"seller_address"`
+	SellerVATNumber string `gorm:"size:64" json:"seller_vat_number"`
+
+	BuyerName    string `gorm:"size:255;not null" json:"buyer_name"`
+	BuyerEmail   string `gorm:"size:255;not null" json:"buyer_email"`
+	BuyerCompany string `gorm:"size:255" json:"buyer_company,omitempty"`
+	BuyerTaxID   string `gorm:"size:64" json:"buyer_tax_id,omitempty"`
+	BuyerAddress string `gorm:"size:255" json:"buyer_address,omitempty"`
+
+	Currency         string `gorm:"size:3;not null" json:"currency"`
+	Subtotal         string `gorm:"type:decimal(10,2)" json:"subtotal"`
+	DiscountAmount   string `gorm:"type:decimal(10,2)" json:"discount_amount"`
+	TaxRate          string `gorm:"type:decimal(5,2)" json:"tax_rate"`
+	TaxInclusive     bool   `gorm:"not null" json:"tax_inclusive"`
+	TaxAmount        string `gorm:"type:decimal(10,2)" json:"tax_amount"`
+	ServiceCharge    string `gorm:"type:decimal(10,2)" json:"service_charge"`
+	DeliveryFee      string `gorm:"type:decimal(10,2)" json:"delivery_fee"`
+	TotalAmount      string `gorm:"type:decimal(10,2)" json:"total_amount"`
+	PaymentReference string `gorm:"size:255" json:"payment_reference"`
+
+	IssuedAt  time.Time `gorm:"not null" json:"issued_at"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+}
+
+// InvoiceCounter holds the last invoice number taken for a store. StoreID 0
+// numbers the invoices of orders that are not tied to a store.
+type InvoiceCounter struct {
+	StoreID      uint `gorm:"primaryKey;autoIncrement:false"`
+	LastSequence uint `gorm:"not null"`
+}
+
+// InvoiceNumber formats the invoice number of a store's nth invoice.
+func InvoiceNumber(storeId, sequence uint) string {
+	return fmt.Sprintf("INV-%03d-%06d", storeId, sequence)
+}
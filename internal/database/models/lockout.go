@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// LoginThrottle counts recent login attempts that have not succeeded for an
+// account ("account:" followed by the email tried) or a client ("ip:"
+// followed by its address).
+// Password reset requests are counted the same way, under "reset:" followed
+// by a hash of the email and "reset-ip:" followed by the client's address.
+// Attempts older than the throttle window are forgotten.
+type LoginThrottle struct {
+	Key           string     `gorm:"primaryKey;size:320" json:"key"`
+	Failures      int        `gorm:"not null" json:"failures"`
+	LastFailureAt time.Time  `gorm:"not null;index" json:"last_failure_at"`
+	LockedUntil   *time.Time `json:"locked_until,omitempty"`
+}
+
+type UnlockIPRequest struct {
+	IP string `validate:"required,ip" json:"ip"`
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+const (
+	CHANNEL_EMAIL = "email"
+	CHANNEL_SMS   = "sms"
+	CHANNEL_PUSH  = "push"
+
+	NOTIFICATION_SENT   = "SENT"
+	NOTIFICATION_FAILED = "FAILED"
+
+	// NOTIFICATION_SUPPRESSED is logged when a message was not sent because
+	// of the user's quiet hours.
+	NOTIFICATION_SUPPRESSED = "SUPPRESSED"
+)
+
+// Notification logs a message sent to a user. Key identifies the reason for
+// the message (for example the outbox event it was sent for) so that the
+// same message is never sent twice.
+type Notification struct {
+	Id        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Key       string     `gorm:"not null;uniqueIndex" json:"key"`
+	Channel   string     `gorm:"not null" json:"channel"`
+	Template  string     `gorm:"not null" json:"template"`
+	Version   int        `gorm:"not null" json:"version"`
+	Recipient string     `gorm:"not null" json:"recipient"`
+	Status    string     `gorm:"not null" json:"status"`
+	Error     string     `json:"error,omitempty"`
+	SentAt    *time.Time `json:"sent_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time  `gorm:"not null" json:"updated_at"`
+}
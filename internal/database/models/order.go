@@ -10,9 +10,21 @@ const (
 	PAYMENT_COMPLETED  = "COMPLETED"
 	PAYMENT_FAILED     = "FAILED"
 
-	ORDER_STATUS_PENDING   = "PENDING"
-	ORDER_STATUS_COMPLETED = "COMPLETED"
-	ORDER_STATUS_CANCELED  = "CANCELED"
+	ORDER_STATUS_SCHEDULED        = "SCHEDULED"
+	ORDER_STATUS_PENDING          = "PENDING"
+	ORDER_STATUS_PREPARING        = "PREPARING"
+	ORDER_STATUS_READY            = "READY"
+	ORDER_STATUS_OUT_FOR_DELIVERY = "OUT_FOR_DELIVERY"
+	ORDER_STATUS_DELIVERED        = "DELIVERED"
+	ORDER_STATUS_COMPLETED        = "COMPLETED"
+	ORDER_STATUS_CANCELED         = "CANCELED"
+
+	FULFILMENT_PICKUP   = "PICKUP"
+	FULFILMENT_DELIVERY = "DELIVERY"
+
+	PREP_QUEUED  = "QUEUED"
+	PREP_STARTED = "STARTED"
+	PREP_DONE    = "DONE"
 )
 
 func IsValidPaymentStatus(status string) bool {
@@ -25,7 +37,21 @@ func IsValidPaymentStatus(status string) bool {
 func IsValidStatus(status string) bool {
 	return status == ORDER_STATUS_CANCELED ||
 		status == ORDER_STATUS_COMPLETED ||
-		status == ORDER_STATUS_PENDING
+		status == ORDER_STATUS_PENDING ||
+		status == ORDER_STATUS_SCHEDULED ||
+		status == ORDER_STATUS_PREPARING ||
+		status == ORDER_STATUS_READY ||
+		status == ORDER_STATUS_OUT_FOR_DELIVERY ||
+		status == ORDER_STATUS_DELIVERED
+}
+
+// IsFinalStatus reports whether an order in status has been fulfilled or
+// canceled. Delivered orders can still be completed; other final orders can
+// not be changed.
+func IsFinalStatus(status string) bool {
+	return status == ORDER_STATUS_DELIVERED ||
+		status == ORDER_STATUS_COMPLETED ||
+		status == ORDER_STATUS_CANCELED
 }
 
 type Order struct {
@@ -33,10 +59,43 @@ type Order struct {
 	UserID      uint        `gorm:"not null" json:"user_id"`
 	User        User        `gorm:"not null" json:"user"`
 	Status      string      `gorm:"not null" json:"status"`
+	Currency    string      `gorm:"size:3;not null;default:NGN" json:"currency"`
 	TotalAmount string      `gorm:"type:decimal(10,2)" json:"total_amount"`
 	OrderItems  []OrderItem `gorm:"not null" json:"order_items,omitempty"`
-	CreatedAt   time.Time   `gorm:"not null,index" json:"created_at"`
-	UpdatedAt   time.Time   `gorm:"not null" json:"updated_at"`
+
+	// Price breakdown. TotalAmount = Subtotal - DiscountAmount + TaxAmount
+	// (unless VAT is inclusive) + ServiceCharge + DeliveryFee.
+	Subtotal       string `gorm:"type:decimal(10,2);default:0" json:"subtotal"`
+	CouponCode     string `json:"coupon_code,omitempty"`
+	DiscountAmount string `gorm:"type:decimal(10,2);default:0" json:"discount_amount"`
+	TaxRate        string `gorm:"type:decimal(5,2);default:0" json:"tax_rate"`
+	TaxInclusive   bool   `gorm:"not null;default:false" json:"tax_inclusive"`
+	TaxAmount      string `gorm:"type:decimal(10,2);default:0" json:"tax_amount"`
+	ServiceCharge  string `gorm:"type:decimal(10,2);default:0" json:"service_charge"`
+	DeliveryFee    string `gorm:"type:decimal(10,2);default:0" json:"delivery_fee"`
+
+	// ScheduledFor is the requested fulfilment time of a pre-order. It is
+	// nil for orders that should be prepared immediately.
+	ScheduledFor *time.Time `gorm:"index" json:"scheduled_for,omitempty"`
+	ReleasedAt   *time.Time `json:"released_at,omitempty"`
+
+	// Fulfilment. The delivery address is copied onto the order so that it
+	// is unaffected by later changes to the user's address book.
+	FulfilmentType    string   `gorm:"not null;default:PICKUP" json:"fulfilment_type"`
+	StoreID           *uint    `gorm:"index" json:"store_id,omitempty"`
+	DeliveryZoneID    *uint    `json:"delivery_zone_id,omitempty"`
+	DeliveryAddressID *uint    `json:"delivery_address_id,omitempty"`
+	DeliveryAddress   string   `json:"delivery_address,omitempty"`
+	DeliveryLatitude  *float64 `json:"delivery_latitude,omitempty"`
+	DeliveryLongitude *float64 `json:"delivery_longitude,omitempty"`
+
+	// Billing details of corporate customers, printed on the invoice.
+	BillingCompany string `gorm:"size:255" json:"billing_company,omitempty"`
+	BillingTaxID   string `gorm:"size:64" json:"billing_tax_id,omitempty"`
+	BillingAddress string `gorm:"size:255" json:"billing_address,omitempty"`
+
+	CreatedAt time.Time `gorm:"not null,index" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
 }
 
 type OrderItem struct {
@@ -47,17 +106,29 @@ type OrderItem struct {
 	Name      string    `json:"name"`
 	Quantity  uint      `json:"quantity"`
 	UnitPrice string    `gorm:"type:decimal(10,2)" json:"unit_price"`
+	Discount  string    `gorm:"type:decimal(10,2);default:0" json:"discount"`
+	TaxAmount string    `gorm:"type:decimal(10,2);default:0" json:"tax_amount"`
+	LineTotal string    `gorm:"type:decimal(10,2);default:0" json:"line_total"`
 	CreatedAt time.Time `json:"created_at"`
+
+	// Preparation progress of the item, set by baristas.
+	PrepStatus string     `gorm:"not null;default:QUEUED" json:"prep_status"`
+	StartedAt  *time.Time `json:"started_at,omitempty"`
+	DoneAt     *time.Time `json:"done_at,omitempty"`
 }
 
 type OrderItemResponse struct {
-	Id        uint      `gorm:"primaryKey" json:"id"`
-	OrderID   uint      `gorm:"not null,index" json:"order_id"`
-	CoffeeID  uint      `json:"coffee_id"`
-	Name      string    `json:"name"`
-	Quantity  uint      `json:"quantity"`
-	UnitPrice string    `gorm:"type:decimal(10,2)" json:"unit_price"`
-	CreatedAt time.Time `json:"created_at"`
+	Id         uint      `gorm:"primaryKey" json:"id"`
+	OrderID    uint      `gorm:"not null,index" json:"order_id"`
+	CoffeeID   uint      `json:"coffee_id"`
+	Name       string    `json:"name"`
+	Quantity   uint      `json:"quantity"`
+	UnitPrice  string    `gorm:"type:decimal(10,2)" json:"unit_price"`
+	Discount   string    `json:"discount"`
+	TaxAmount  string    `json:"tax_amount"`
+	LineTotal  string    `json:"line_total"`
+	PrepStatus string    `json:"prep_status"`
+	CreatedAt  time.Time `json:"created_at"`
 }
 
 type CoffeeInfo struct {
@@ -66,7 +137,28 @@ type CoffeeInfo struct {
 }
 
 type CreateOrderRequest struct {
-	Coffees []CoffeeInfo `validate:"required" json:"coffees"`
+	Coffees    []CoffeeInfo `validate:"required" json:"coffees"`
+	CouponCode string       `json:"coupon_code,omitempty"`
+
+	// ScheduledFor optionally requests a future pickup/delivery time. It is
+	// placed in the 15-minute slot that contains it.
+	ScheduledFor *time.Time `json:"scheduled_for,omitempty"`
+
+	// FulfilmentType is PICKUP (the default) or DELIVERY. Deliveries need an
+	// AddressID from the user's address book inside a store's delivery zone.
+	FulfilmentType string `validate:"omitempty,oneof=PICKUP DELIVERY" json:"fulfilment_type,omitempty"`
+	AddressID      *uint  `validate:"required_if=FulfilmentType DELIVERY" json:"address_id,omitempty"`
+	StoreID        *uint  `json:"store_id,omitempty"`
+
+	// Billing optionally addresses the invoice to a company.
+	Billing *BillingDetails `validate:"omitempty" json:"billing,omitempty"`
+}
+
+// BillingDetails identifies the company an invoice is issued to.
+type BillingDetails struct {
+	Company string `validate:"required,max=255" json:"company"`
+	TaxID   string `validate:"max=64" json:"tax_id"`
+	Address string `validate:"max=255" json:"address"`
 }
 
 type UpdateOrderRequest struct {
@@ -74,24 +166,50 @@ type UpdateOrderRequest struct {
 }
 
 type OrderResponse struct {
-	Id          uint                `gorm:"primaryKey" json:"id"`
-	UserID      uint                `gorm:"not null" json:"user_id"`
-	Status      string              `gorm:"not null" json:"status"`
-	TotalAmount string              `gorm:"type:decimal(10,2)" json:"total_amount"`
-	OrderItems  []OrderItemResponse `gorm:"not null" json:"order_items,omitempty"`
-	CreatedAt   time.Time           `gorm:"not null,index" json:"created_at"`
-	UpdatedAt   time.Time           `gorm:"not null" json:"updated_at"`
+	Id              uint                `gorm:"primaryKey" json:"id"`
+	UserID          uint                `gorm:"not null" json:"user_id"`
+	Status          string              `gorm:"not null" json:"status"`
+	Currency        string              `json:"currency"`
+	Subtotal        string              `json:"subtotal"`
+	CouponCode      string              `json:"coupon_code,omitempty"`
+	DiscountAmount  string              `json:"discount_amount"`
+	TaxRate         string              `json:"tax_rate"`
+	TaxInclusive    bool                `json:"tax_inclusive"`
+	TaxAmount       string              `json:"tax_amount"`
+	ServiceCharge   string              `json:"service_charge"`
+	DeliveryFee     string              `json:"delivery_fee"`
+	TotalAmount     string              `gorm:"type:decimal(10,2)" json:"total_amount"`
+	OrderItems      []OrderItemResponse `gorm:"not null" json:"order_items,omitempty"`
+	ScheduledFor    *time.Time          `json:"scheduled_for,omitempty"`
+	FulfilmentType  string              `json:"fulfilment_type"`
+	StoreID         *uint               `json:"store_id,omitempty"`
+	DeliveryAddress string              `json:"delivery_address,omitempty"`
+	CreatedAt       time.Time           `gorm:"not null,index" json:"created_at"`
+	UpdatedAt       time.Time           `gorm:"not null" json:"updated_at"`
 }
 
 func (o *Order) ToOrderResponse() OrderResponse {
 	or := OrderResponse{
-		Id:          o.Id,
-		UserID:      o.UserID,
-		Status:      o.Status,
-		TotalAmount: o.TotalAmount,
-		OrderItems:  []OrderItemResponse{},
-		CreatedAt:   o.CreatedAt,
-		UpdatedAt:   o.UpdatedAt,
+		Id:              o.Id,
+		UserID:          o.UserID,
+		Status:          o.Status,
+		Currency:        o.Currency,
+		Subtotal:        o.Subtotal,
+		CouponCode:      o.CouponCode,
+		DiscountAmount:  o.DiscountAmount,
+		TaxRate:         o.TaxRate,
+		TaxInclusive:    o.TaxInclusive,
+		TaxAmount:       o.TaxAmount,
+		ServiceCharge:   o.ServiceCharge,
+		DeliveryFee:     o.DeliveryFee,
+		TotalAmount:     o.TotalAmount,
+		OrderItems:      []OrderItemResponse{},
+		ScheduledFor:    o.ScheduledFor,
+		FulfilmentType:  o.FulfilmentType,
+		StoreID:         o.StoreID,
+		DeliveryAddress: o.DeliveryAddress,
+		CreatedAt:       o.CreatedAt,
+		UpdatedAt:       o.UpdatedAt,
 	}
 
 	for _, v := range o.OrderItems {
@@ -103,12 +221,16 @@ func (o *Order) ToOrderResponse() OrderResponse {
 
 func (o *OrderItem) ToOrderItemResponse() OrderItemResponse {
 	return OrderItemResponse{
-		Id:        o.Id,
-		OrderID:   o.OrderID,
-		CoffeeID:  o.CoffeeID,
-		Name:      o.Name,
-		Quantity:  o.Quantity,
-		UnitPrice: o.UnitPrice,
-		CreatedAt: o.CreatedAt,
+		Id:         o.Id,
+		OrderID:    o.OrderID,
+		CoffeeID:   o.CoffeeID,
+		Name:       o.Name,
+		Quantity:   o.Quantity,
+		UnitPrice:  o.UnitPrice,
+		Discount:   o.Discount,
+		TaxAmount:  o.TaxAmount,
+		LineTotal:  o.LineTotal,
+		PrepStatus: o.PrepStatus,
+		CreatedAt:  o.CreatedAt,
 	}
 }
@@ -0,0 +1,79 @@
+package models
+
+import "time"
+
+const (
+	OUTBOX_ORDER_CREATED              = "order.created"
+	OUTBOX_ORDER_STATUS_CHANGED       = "order.status_changed"
+	OUTBOX_ORDER_ITEM_CHANGED         = "order.item_changed"
+	OUTBOX_TRANSACTION_CREATED        = "transaction.created"
+	OUTBOX_TRANSACTION_STATUS_CHANGED = "transaction.status_changed"
+	OUTBOX_COFFEE_STOCK_CHANGED       = "coffee.stock_changed"
+)
+
+// OutboxEvent is an event recorded in the same database transaction as the
+// state change it describes, so that it is published if and only if the
+// change is committed. DispatchedAt is set once every subscriber and sink
+// has accepted it, and DeadAt once the dispatcher has given up on it.
+type OutboxEvent struct {
+	Id            uint       `gorm:"primaryKey" json:"id"`
+	Type          string     `gorm:"not null;index" json:"type"`
+	AggregateType string     `gorm:"not null" json:"aggregate_type"`
+	AggregateID   uint       `gorm:"not null" json:"aggregate_id"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Attempts      uint       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt time.Time  `gorm:"not null;index" json:"next_attempt_at"`
+	DispatchedAt  *time.Time `gorm:"index" json:"dispatched_at,omitempty"`
+	DeadAt        *time.Time `gorm:"index" json:"dead_at,omitempty"`
+	LastError     string     `json:"last_error,omitempty"`
+	CreatedAt     time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// OutboxDelivery records that a subscriber or sink has accepted an outbox
+// event, so that retries of the event skip it.
+type OutboxDelivery struct {
+	EventID     uint      `gorm:"primaryKey;autoIncrement:false" json:"event_id"`
+	Subscriber  string    `gorm:"primaryKey" json:"subscriber"`
+	DeliveredAt time.Time `gorm:"not null" json:"delivered_at"`
+}
+
+// OrderStatusChanged is the payload of the order outbox events.
+// PreviousStatus is empty for OUTBOX_ORDER_CREATED.
+type OrderStatusChanged struct {
+	OrderID        uint   `json:"order_id"`
+	UserID         uint   `json:"user_id"`
+	Status         string `json:"status"`
+	PreviousStatus string `json:"previous_status"`
+}
+
+// OrderItemChanged is the payload of OUTBOX_ORDER_ITEM_CHANGED, recorded
+// when the kitchen starts or finishes an item. OrderStatus is the status of
+// the order at the time.
+type OrderItemChanged struct {
+	OrderID     uint   `json:"order_id"`
+	UserID      uint   `json:"user_id"`
+	ItemID      uint   `json:"item_id"`
+	PrepStatus  string `json:"prep_status"`
+	OrderStatus string `json:"order_status"`
+}
+
+// TransactionChanged is the payload of the transaction outbox events.
+type TransactionChanged struct {
+	TransactionID uint   `json:"transaction_id"`
+	OrderID       uint   `json:"order_id"`
+	UserID        uint   `json:"user_id"`
+	Reference     string `json:"reference"`
+	PaymentStatus string `json:"payment_status"`
+	Amount        string `json:"amount"`
+	Currency      string `json:"currency"`
+}
+
+// StockChanged is the payload of OUTBOX_COFFEE_STOCK_CHANGED, recorded when
+// an order takes a coffee's stock from Previous to Remaining.
+type StockChanged struct {
+	CoffeeID  uint   `json:"coffee_id"`
+	OrderID   uint   `json:"order_id"`
+	Name      string `json:"name"`
+	Previous  uint   `json:"previous"`
+	Remaining uint   `json:"remaining"`
+}
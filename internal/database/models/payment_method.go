@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// PaymentMethod is a reusable authorization returned by the payment provider
+// after a successful charge. Only masked card details are stored.
+type PaymentMethod struct {
+	Id                uint      `gorm:"primaryKey" json:"id"`
+	UserID            uint      `gorm:"not null;index" json:"user_id"`
+	Provider          string    `gorm:"not null" json:"provider"`
+	AuthorizationCode string    `gorm:"not null" json:"-"`
+	Signature         string    `gorm:"index" json:"-"`
+	Channel           string    `json:"channel"`
+	CardType          string    `json:"card_type"`
+	Brand             string    `json:"brand"`
+	Bank              string    `json:"bank"`
+	Last4             string    `gorm:"size:4" json:"last4"`
+	ExpMonth          string    `gorm:"size:2" json:"exp_month"`
+	ExpYear           string    `gorm:"size:4" json:"exp_year"`
+	CreatedAt         time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt         time.Time `gorm:"not null" json:"updated_at"`
+}
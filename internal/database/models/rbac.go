@@ -0,0 +1,83 @@
+package models
+
+import "time"
+
+// Permissions granted to roles. Routes require permissions rather than
+// roles, so new roles can be set up without code changes.
+const (
+	PERM_CATALOG_WRITE     = "catalog:write"
+	PERM_ORDERS_MANAGE     = "orders:manage"
+	PERM_REFUNDS_ISSUE     = "refunds:issue"
+	PERM_USERS_READ        = "users:read"
+	PERM_USERS_WRITE       = "users:write"
+	PERM_ROLES_MANAGE      = "roles:manage"
+	PERM_STORES_MANAGE     = "stores:manage"
+	PERM_WEBHOOKS_MANAGE   = "webhooks:manage"
+	PERM_DELIVERIES_FULFIL = "deliveries:fulfil"
+	PERM_KITCHEN_OPERATE   = "kitchen:operate"
+	PERM_API_KEYS_MANAGE   = "api_keys:manage"
+)
+
+// Permission is a single capability that can be granted to roles.
+type Permission struct {
+	Name        string `gorm:"primaryKey;size:64" json:"name"`
+	Description string `gorm:"size:255" json:"description"`
+}
+
+// Role is a named set of permissions. Users reference their role by name.
+// System roles are created on startup and cannot be removed.
+type Role struct {
+	Name        string       `gorm:"primaryKey;size:64" json:"name"`
+	Description string       `gorm:"size:255" json:"description"`
+	System      bool         `gorm:"not null;default:false" json:"system"`
+	Permissions []Permission `gorm:"many2many:role_permissions;constraint:OnDelete:CASCADE" json:"permissions"`
+	CreatedAt   time.Time    `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time    `gorm:"not null" json:"updated_at"`
+}
+
+// PermissionNames returns the names of the role's permissions.
+func (r *Role) PermissionNames() []string {
+	names := make([]string, 0, len(r.Permissions))
+	for _, p := range r.Permissions {
+		names = append(names, p.Name)
+	}
+	return names
+}
+
+// AllPermissions lists every permission the application checks.
+var AllPermissions = []Permission{
+	{Name: PERM_CATALOG_WRITE, Description: "Create, change and remove coffees and coupons"},
+	{Name: PERM_ORDERS_MANAGE, Description: "View and change any order and dispatch deliveries"},
+	{Name: PERM_REFUNDS_ISSUE, Description: "Refund payments"},
+	{Name: PERM_USERS_READ, Description: "View user accounts"},
+	{Name: PERM_USERS_WRITE, Description: "Change user accounts and create staff accounts"},
+	{Name: PERM_ROLES_MANAGE, Description: "Create roles and change their permissions"},
+	{Name: PERM_STORES_MANAGE, Description: "Manage stores and delivery zones"},
+	{Name: PERM_WEBHOOKS_MANAGE, Description: "Manage outbound webhooks"},
+	{Name: PERM_DELIVERIES_FULFIL, Description: "Carry out deliveries as a courier"},
+	{Name: PERM_KITCHEN_OPERATE, Description: "Work the kitchen queue as a barista"},
+	{Name: PERM_API_KEYS_MANAGE, Description: "Create, rotate and revoke API keys"},
+}
+
+// DefaultRoles are the system roles and the permissions they start with.
+// Admins always hold every permission.
+var DefaultRoles = map[string][]string{
+	ROLE_USER:    {},
+	ROLE_COURIER: {PERM_DELIVERIES_FULFIL},
+	ROLE_BARISTA: {PERM_KITCHEN_OPERATE},
+	ROLE_ADMIN:   {},
+}
+
+type CreateRoleRequest struct {
+	Name        string   `validate:"required,max=64,lowercase" json:"name"`
+	Description string   `validate:"max=255" json:"description"`
+	Permissions []string `validate:"dive,required" json:"permissions"`
+}
+
+type UpdateRolePermissionsRequest struct {
+	Permissions []string `validate:"dive,required" json:"permissions"`
+}
+
+type AssignRoleRequest struct {
+	Role string `validate:"required" json:"role"`
+}
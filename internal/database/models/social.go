@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// UserIdentity links a user to their account at a social login provider.
+// Subject is the provider's stable ID for the account; the email it had
+// when the identity was linked is kept for reference only.
+type UserIdentity struct {
+	Id          uint      `gorm:"primaryKey" json:"id"`
+	UserID      uint      `gorm:"not null;index" json:"user_id"`
+	Provider    string    `gorm:"size:32;not null;uniqueIndex:idx_identity_provider_subject" json:"provider"`
+	Subject     string    `gorm:"size:255;not null;uniqueIndex:idx_identity_provider_subject" json:"-"`
+	Email       string    `gorm:"size:255" json:"email"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	LastLoginAt time.Time `gorm:"not null" json:"last_login_at"`
+}
+
+// OAuthState is a social login in progress, stored by the hash of the state
+// parameter sent to the provider. It holds the PKCE code verifier and the
+// nonce expected in the ID token, and can only be used once.
+type OAuthState struct {
+	Id           uint      `gorm:"primaryKey" json:"id"`
+	StateHash    string    `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	Provider     string    `gorm:"size:32;not null" json:"provider"`
+	CodeVerifier string    `gorm:"size:128;not null" json:"-"`
+	Nonce        string    `gorm:"size:64;not null" json:"-"`
+	ExpiresAt    time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt    time.Time `gorm:"not null" json:"created_at"`
+
+	// UserID is set when a signed in user is signing in again to confirm a
+	// change, rather than logging in.
+	UserID *uint `json:"-"`
+}
+
+// SocialLoginStart is returned when a social login starts. The client sends
+// the user to AuthorizationURL.
+type SocialLoginStart struct {
+	AuthorizationURL string `json:"authorization_url"`
+	State            string `json:"state"`
+	ExpiresIn        int64  `json:"expires_in"`
+}
+
+// ReauthToken is returned when a signed in user has signed in again with a
+// provider. It can be passed once as the reauth_token of a Confirmation.
+type ReauthToken struct {
+	ReauthToken string `json:"reauth_token"`
+	ExpiresIn   int64  `json:"expires_in"`
+}
+
+// SocialLoginCallback carries what the provider sent back to the redirect
+// URL, either as query parameters, a form post or JSON.
+type SocialLoginCallback struct {
+	Code  string `form:"code" validate:"required_without=Error" json:"code"`
+	State string `form:"state" validate:"required" json:"state"`
+
+	// Error is set instead of Code when the user did not sign in.
+	Error string `form:"error" json:"error"`
+
+	// User is only posted by Apple, as JSON with the user's name, the first
+	// time they sign in.
+	User string `form:"user" json:"user"`
+}
@@ -0,0 +1,62 @@
+package models
+
+import (
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+// Store is a shop orders are prepared at and picked up or delivered from.
+type Store struct {
+	Id        uint           `gorm:"primaryKey" json:"id"`
+	Name      string         `gorm:"size:255;not null" json:"name"`
+	Address   string         `gorm:"size:255" json:"address"`
+	Latitude  float64        `gorm:"not null" json:"latitude"`
+	Longitude float64        `gorm:"not null" json:"longitude"`
+	Active    bool           `gorm:"not null;default:true" json:"active"`
+	Zones     []DeliveryZone `json:"zones,omitempty"`
+
+	// Seller details printed on the store's invoices.
+	LegalName string `gorm:"size:255" json:"legal_name,omitempty"`
+	VATNumber string `gorm:"size:64" json:"vat_number,omitempty"`
+
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// Location returns the store's coordinates.
+func (s *Store) Location() util.GeoPoint {
+	return util.GeoPoint{Latitude: s.Latitude, Longitude: s.Longitude}
+}
+
+// DeliveryZone is an area a store delivers to, given as a polygon. When
+// DeliveryFee is nil, the fee is computed from the delivery distance.
+// Currency is the currency of DeliveryFee; only orders in that currency can
+// be delivered to the zone.
+type DeliveryZone struct {
+	Id          uint            `gorm:"primaryKey" json:"id"`
+	StoreID     uint            `gorm:"not null;index" json:"store_id"`
+	Name        string          `gorm:"size:255;not null" json:"name"`
+	Polygon     []util.GeoPoint `gorm:"serializer:json;not null" json:"polygon"`
+	DeliveryFee *string         `gorm:"type:decimal(10,2)" json:"delivery_fee,omitempty"`
+	Currency    string          `gorm:"size:3" json:"currency,omitempty"`
+	Active      bool            `gorm:"not null;default:true" json:"active"`
+	CreatedAt   time.Time       `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time       `gorm:"not null" json:"updated_at"`
+}
+
+type CreateStore struct {
+	Name      string  `validate:"required,max=255" json:"name"`
+	Address   string  `validate:"max=255" json:"address"`
+	LegalName string  `validate:"max=255" json:"legal_name"`
+	VATNumber string  `validate:"max=64" json:"vat_number"`
+	Latitude  float64 `validate:"gte=-90,lte=90" json:"latitude"`
+	Longitude float64 `validate:"gte=-180,lte=180" json:"longitude"`
+}
+
+type CreateDeliveryZone struct {
+	Name        string          `validate:"required,max=255" json:"name"`
+	Polygon     []util.GeoPoint `validate:"required,min=3,dive" json:"polygon"`
+	DeliveryFee string          `validate:"omitempty,numeric" json:"delivery_fee"`
+	Currency    string          `validate:"omitempty,currency" json:"currency"`
+}
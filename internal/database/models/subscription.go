@@ -0,0 +1,54 @@
+package models
+
+import "time"
+
+const (
+	SUBSCRIPTION_INCOMPLETE = "INCOMPLETE"
+	SUBSCRIPTION_ACTIVE     = "ACTIVE"
+	SUBSCRIPTION_PAUSED     = "PAUSED"
+	SUBSCRIPTION_PAST_DUE   = "PAST_DUE"
+	SUBSCRIPTION_CANCELED   = "CANCELED"
+)
+
+// Subscription is a recurring order template. A new order is generated from
+// its items every IntervalDays and charged against a saved authorization.
+type Subscription struct {
+	Id           uint               `gorm:"primaryKey" json:"id"`
+	UserID       uint               `gorm:"not null;index" json:"user_id"`
+	Status       string             `gorm:"not null;index" json:"status"`
+	IntervalDays uint               `gorm:"not null" json:"interval_days"`
+	Items        []SubscriptionItem `json:"items"`
+	NextRunAt    time.Time          `gorm:"not null;index" json:"next_run_at"`
+
+	// FirstOrderID is the order whose payment provides the authorization
+	// used for recurring charges.
+	FirstOrderID      uint   `json:"first_order_id"`
+	AuthorizationCode string `json:"-"`
+
+	// PendingOrderID is the generated order of the current run. It is
+	// recorded before the order is charged and cleared once a charge of it
+	// succeeds, including while the subscription is being dunned.
+	PendingOrderID *uint      `json:"pending_order_id,omitempty"`
+	FailedAttempts uint       `gorm:"not null;default:0" json:"failed_attempts"`
+	LastFailureAt  *time.Time `json:"last_failure_at,omitempty"`
+
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt time.Time `gorm:"not null" json:"updated_at"`
+}
+
+type SubscriptionItem struct {
+	Id             uint `gorm:"primaryKey" json:"id"`
+	SubscriptionID uint `gorm:"not null;index" json:"subscription_id"`
+	CoffeeID       uint `gorm:"not null" json:"coffee_id"`
+	Quantity       uint `gorm:"not null" json:"quantity"`
+}
+
+type CreateSubscriptionRequest struct {
+	Coffees      []CoffeeInfo `validate:"required,min=1,dive" json:"coffees"`
+	IntervalDays uint         `validate:"required,gte=1,lte=90" json:"interval_days"`
+}
+
+type CreateSubscriptionResponse struct {
+	Subscription Subscription `json:"subscription"`
+	Transaction  Transaction  `json:"transaction"`
+}
@@ -0,0 +1,87 @@
+package models
+
+import "time"
+
+// RefreshToken is a refresh token stored by its hash. Every login starts a
+// session, and each refresh replaces the session's token with a new one.
+// Presenting a token that has already been rotated means it was copied, so
+// the whole session is revoked.
+type RefreshToken struct {
+	Id        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	SessionID string     `gorm:"size:36;not null;index" json:"session_id"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	RotatedAt *time.Time `json:"rotated_at,omitempty"`
+	RevokedAt *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// RevokedSession is an entry of the revocation list. Access tokens carry
+// their session ID and are rejected once the session is listed. Entries can
+// be dropped after ExpiresAt, when every access token of the session has
+// expired anyway.
+type RevokedSession struct {
+	SessionID string    `gorm:"primaryKey;size:36" json:"session_id"`
+	UserID    uint      `gorm:"not null;index" json:"user_id"`
+	Reason    string    `gorm:"size:64;not null" json:"reason"`
+	ExpiresAt time.Time `gorm:"not null;index" json:"expires_at"`
+	CreatedAt time.Time `gorm:"not null" json:"created_at"`
+}
+
+const (
+	REVOKE_LOGOUT     = "logout"
+	REVOKE_LOGOUT_ALL = "logout_all"
+	REVOKE_PASSWORD   = "password_changed"
+	REVOKE_REUSE      = "refresh_token_reuse"
+)
+
+// TokenPair is returned on login and refresh.
+type TokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+
+	// RecoveryCodes is only set when the login also finished enrolling the
+	// user in two-factor authentication.
+	RecoveryCodes []string `json:"recovery_codes,omitempty"`
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `validate:"required" json:"refresh_token"`
+}
+
+const (
+	TOKEN_EMAIL_VERIFICATION = "email_verification"
+	TOKEN_PASSWORD_RESET     = "password_reset"
+
+	// TOKEN_EMAIL_CHANGE is sent to the address a user wants to change to.
+	// The change is made when it is redeemed.
+	TOKEN_EMAIL_CHANGE = "email_change"
+
+	// TOKEN_TWO_FACTOR is handed out by a login that still needs a second
+	// factor, and is exchanged for a session with a TOTP or recovery code.
+	TOKEN_TWO_FACTOR = "two_factor"
+
+	// TOKEN_REAUTH is handed out when a signed in user signs in again with
+	// a linked provider, and confirms a sensitive change in place of their
+	// password.
+	TOKEN_REAUTH = "reauth"
+)
+
+// UserToken is a single-use token emailed to a user, stored by its hash.
+// Email is the address the token was sent to, so a verification token stops
+// working if the user's email changes before it is used. Issuing a new token
+// for a purpose invalidates the user's earlier ones.
+type UserToken struct {
+	Id        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	Purpose   string     `gorm:"size:32;not null" json:"purpose"`
+	Email     string     `gorm:"size:255;not null" json:"email"`
+	TokenHash string     `gorm:"size:64;not null;uniqueIndex" json:"-"`
+	ExpiresAt time.Time  `gorm:"not null;index" json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	Attempts  int        `gorm:"not null;default:0" json:"-"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+}
@@ -2,21 +2,37 @@ package models
 
 import "time"
 
+// Transaction is a payment of an order. An order has at most one pending or
+// processing transaction at a time, so it can not be charged twice at once.
 type Transaction struct {
 	ID               uint      `json:"id"`
 	UserID           uint      `gorm:"not null" json:"user_id"`
-	OrderID          uint      `json:"order_id"`
+	OrderID          uint      `gorm:"uniqueIndex:idx_transactions_open_order,where:payment_status <> 'COMPLETED' AND payment_status <> 'FAILED'" json:"order_id"`
 	Order            Order     `json:"-"`
-	Reference        string    `gorm:"not null" json:"reference"`
+	Reference        string    `gorm:"not null;index" json:"reference"`
 	PaymentID        string    `gorm:"not null" json:"-"`
 	PaymentReference string    `gorm:"not null" json:"payment_reference"`
 	PaymentStatus    string    `gorm:"not null" json:"payment_status"`
+	Currency         string    `gorm:"size:3;not null;default:NGN" json:"currency"`
 	TotalAmount      string    `gorm:"type:decimal(10,2)" json:"total_amount"`
 	CreatedAt        time.Time `json:"created_at"`
 	UpdatedAt        time.Time `json:"updated_at"`
 	AuthorizationURL string    `gorm:"not null" json:"authorization_url"`
+
+	// Masked details of the card a completed charge was paid with. The
+	// reusable authorization itself is only kept on the payment method
+	// saved from it, PaymentMethodID.
+	PaymentMethodID *uint  `gorm:"index" json:"payment_method_id,omitempty"`
+	CardBrand       string `json:"card_brand,omitempty"`
+	CardLast4       string `gorm:"size:4" json:"card_last4,omitempty"`
+	CardExpMonth    string `gorm:"size:2" json:"card_exp_month,omitempty"`
+	CardExpYear     string `gorm:"size:4" json:"card_exp_year,omitempty"`
 }
 
 type TransactionRequest struct {
 	OrderID uint `validate:"required" json:"order_id"`
+
+	// PaymentMethodID charges a saved payment method directly instead of
+	// redirecting the customer to the provider.
+	PaymentMethodID *uint `json:"payment_method_id,omitempty"`
 }
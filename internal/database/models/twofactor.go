@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// RecoveryCode is a single-use code, stored by its hash, that signs a user
+// in when they have lost their authenticator.
+type RecoveryCode struct {
+	Id        uint       `gorm:"primaryKey" json:"id"`
+	UserID    uint       `gorm:"not null;index" json:"user_id"`
+	CodeHash  string     `gorm:"size:64;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `gorm:"not null" json:"created_at"`
+}
+
+// TwoFactorEnrollment is the secret a user adds to their authenticator app,
+// either by typing it in or by scanning URI as a QR code.
+type TwoFactorEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
+
+// TwoFactorChallenge is returned by a login that needs a second step. If
+// SetupRequired is set the user must enroll first, because their role
+// requires two-factor authentication.
+type TwoFactorChallenge struct {
+	ChallengeToken string `json:"challenge_token"`
+	SetupRequired  bool   `json:"setup_required"`
+	ExpiresIn      int64  `json:"expires_in"`
+}
+
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+type TwoFactorLoginRequest struct {
+	ChallengeToken string `validate:"required" json:"challenge_token"`
+	Code           string `validate:"required_without=RecoveryCode" json:"code"`
+	RecoveryCode   string `json:"recovery_code"`
+}
+
+type TwoFactorSetupRequest struct {
+	ChallengeToken string `validate:"required" json:"challenge_token"`
+}
+
+type TwoFactorCodeRequest struct {
+	Code string `validate:"required" json:"code"`
+}
+
+type DisableTwoFactorRequest struct {
+	Confirmation
+}
@@ -2,6 +2,13 @@ package models
 
 import "time"
 
+const (
+	ROLE_USER    = "user"
+	ROLE_ADMIN   = "admin"
+	ROLE_COURIER = "courier"
+	ROLE_BARISTA = "barista"
+)
+
 type User struct {
 	Id        uint      `gorm:"primarykey" json:"id"`
 	FirstName string    `gorm:"size:255;not null" validate:"required" json:"first_name"`
@@ -11,14 +18,45 @@ type User struct {
 	Role      string    `gorm:"not null" validate:"required" json:"role"`
 	CreatedAt time.Time `gorm:"not null,index" json:"created_at"`
 	UpdatedAt time.Time `json:"updated_at"`
+
+	// EmailVerifiedAt is set once the user has followed a verification link
+	// sent to Email.
+	EmailVerifiedAt *time.Time `json:"email_verified_at,omitempty"`
+
+	// Two-factor authentication. TOTPSecret is set when enrollment starts
+	// and TwoFactorEnabled once the user has confirmed a code from it.
+	// TOTPLastStep is the time step of the last code accepted, so that a
+	// code can not be used twice.
+	TwoFactorEnabled bool   `gorm:"not null;default:false" json:"two_factor_enabled"`
+	TOTPSecret       string `gorm:"size:64" json:"-"`
+	TOTPLastStep     int64  `gorm:"not null;default:0" json:"-"`
+
+	// AnonymizedAt is set when the user deleted their account. The row is
+	// kept, stripped of personal data, because orders and transactions
+	// still refer to it.
+	AnonymizedAt *time.Time `json:"anonymized_at,omitempty"`
+
+	// Notification channels and preferences. Quiet hours are "HH:MM" in the
+	// user's TimeZone; SMS and push messages are not sent between them.
+	Phone           string `gorm:"size:32" json:"phone,omitempty"`
+	PushToken       string `json:"-"`
+	NotifyEmail     bool   `gorm:"not null;default:true" json:"notify_email"`
+	NotifySMS       bool   `gorm:"not null;default:false" json:"notify_sms"`
+	NotifyPush      bool   `gorm:"not null;default:true" json:"notify_push"`
+	QuietHoursStart string `gorm:"size:5" json:"quiet_hours_start,omitempty"`
+	QuietHoursEnd   string `gorm:"size:5" json:"quiet_hours_end,omitempty"`
+	TimeZone        string `gorm:"size:64" json:"time_zone,omitempty"`
 }
 
 type CreateUser struct {
 	FirstName string `validate:"required" json:"first_name"`
 	LastName  string `validate:"required" json:"last_name"`
-	Email     string `validate:"required" json:"email"`
+	Email     string `validate:"required,email" json:"email"`
 	Password  string `validate:"required" json:"password"`
-	Role      string `validate:"required" json:"role"`
+
+	// Role defaults to "user". Other roles can only be given by staff
+	// through POST /users/staff.
+	Role string `json:"role,omitempty"`
 }
 
 type UserUpdate struct {
@@ -26,7 +64,75 @@ type UserUpdate struct {
 	LastName  string `validate:"required" json:"last_name"`
 }
 
+// ProfileUpdate is used by users to change their own profile. Nil fields are
+// left unchanged.
+type ProfileUpdate struct {
+	FirstName *string `validate:"omitnil,min=1,max=255" json:"first_name"`
+	LastName  *string `validate:"omitnil,min=1,max=255" json:"last_name"`
+}
+
+// Confirmation proves that a signed in user is who they say they are before
+// a sensitive change. Any one of its fields is enough: the user's password,
+// a two-factor or recovery code, or a reauth token from signing in again
+// with a linked provider, which users without a password rely on.
+type Confirmation struct {
+	Password     string `json:"password"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+	ReauthToken  string `json:"reauth_token"`
+}
+
+// ChangePasswordRequest is confirmed with the current password, or like a
+// Confirmation if the user has none.
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Code            string `json:"code"`
+	RecoveryCode    string `json:"recovery_code"`
+	ReauthToken     string `json:"reauth_token"`
+	NewPassword     string `validate:"required" json:"new_password"`
+}
+
+// Confirmation returns how the request is confirmed.
+func (r *ChangePasswordRequest) Confirmation() Confirmation {
+	return Confirmation{Password: r.CurrentPassword, Code: r.Code, RecoveryCode: r.RecoveryCode, ReauthToken: r.ReauthToken}
+}
+
+type DeleteAccountRequest struct {
+	Confirmation
+}
+
+type ChangeEmailRequest struct {
+	Email string `validate:"required,email" json:"email"`
+	Confirmation
+}
+
+// NotificationPreferences is used to read and change a user's notification
+// channels and quiet hours. Nil fields are left unchanged on update.
+type NotificationPreferences struct {
+	Email           *bool   `json:"email"`
+	SMS             *bool   `json:"sms"`
+	Push            *bool   `json:"push"`
+	Phone           *string `validate:"omitempty,e164" json:"phone"`
+	PushToken       *string `json:"push_token,omitempty"`
+	QuietHoursStart *string `validate:"omitempty,datetime=15:04" json:"quiet_hours_start"`
+	QuietHoursEnd   *string `validate:"omitempty,datetime=15:04" json:"quiet_hours_end"`
+	TimeZone        *string `validate:"omitempty,timezone" json:"time_zone"`
+}
+
 type LoginRequest struct {
 	Email    string `validate:"required" json:"email"`
 	Password string `validate:"required" json:"password"`
 }
+
+type VerifyEmailRequest struct {
+	Token string `validate:"required" json:"token"`
+}
+
+type ForgotPasswordRequest struct {
+	Email string `validate:"required,email" json:"email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `validate:"required" json:"token"`
+	Password string `validate:"required" json:"password"`
+}
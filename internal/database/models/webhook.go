@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+const (
+	WEBHOOK_ORDER_CREATED   = "order.created"
+	WEBHOOK_ORDER_PAID      = "order.paid"
+	WEBHOOK_ORDER_CANCELLED = "order.cancelled"
+	WEBHOOK_STOCK_LOW       = "stock.low"
+
+	WEBHOOK_DELIVERY_PENDING   = "PENDING"
+	WEBHOOK_DELIVERY_SUCCEEDED = "SUCCEEDED"
+	WEBHOOK_DELIVERY_FAILED    = "FAILED"
+)
+
+func IsValidWebhookEvent(event string) bool {
+	return event == WEBHOOK_ORDER_CREATED ||
+		event == WEBHOOK_ORDER_PAID ||
+		event == WEBHOOK_ORDER_CANCELLED ||
+		event == WEBHOOK_STOCK_LOW
+}
+
+// WebhookEndpoint is a partner URL that is sent the events it subscribes to.
+// Secret signs every delivery and is only shown when the endpoint is created.
+type WebhookEndpoint struct {
+	Id          uint      `gorm:"primaryKey" json:"id"`
+	URL         string    `gorm:"not null" json:"url"`
+	Description string    `json:"description,omitempty"`
+	Events      []string  `gorm:"serializer:json" json:"events"`
+	Secret      string    `gorm:"not null" json:"-"`
+	Active      bool      `gorm:"not null;default:true" json:"active"`
+	CreatedAt   time.Time `gorm:"not null" json:"created_at"`
+	UpdatedAt   time.Time `gorm:"not null" json:"updated_at"`
+}
+
+// Subscribes reports whether the endpoint wants an event.
+func (e *WebhookEndpoint) Subscribes(event string) bool {
+	for _, v := range e.Events {
+		if v == event {
+			return true
+		}
+	}
+	return false
+}
+
+// WebhookDelivery is the delivery of an event to an endpoint. An event is
+// enqueued at most once per endpoint; manual redeliveries refer to the
+// delivery they repeat through RedeliveryOf.
+type WebhookDelivery struct {
+	Id             uint       `gorm:"primaryKey" json:"id"`
+	EndpointID     uint       `gorm:"not null;index;uniqueIndex:idx_webhook_deliveries_event,where:redelivery_of IS NULL" json:"endpoint_id"`
+	EventID        string     `gorm:"not null;index;uniqueIndex:idx_webhook_deliveries_event,where:redelivery_of IS NULL" json:"event_id"`
+	RedeliveryOf   *uint      `json:"redelivery_of,omitempty"`
+	Event          string     `gorm:"not null" json:"event"`
+	Payload        string     `gorm:"type:text;not null" json:"payload"`
+	Status         string     `gorm:"not null;index" json:"status"`
+	Attempts       uint       `gorm:"not null;default:0" json:"attempts"`
+	NextAttemptAt  *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	LastAttemptAt  *time.Time `json:"last_attempt_at,omitempty"`
+	ResponseStatus int        `json:"response_status,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `gorm:"not null" json:"created_at"`
+	UpdatedAt      time.Time  `gorm:"not null" json:"updated_at"`
+}
+
+type CreateWebhookEndpoint struct {
+	URL         string   `validate:"required,url" json:"url"`
+	Description string   `json:"description"`
+	Events      []string `validate:"required,min=1" json:"events"`
+}
+
+type UpdateWebhookEndpoint struct {
+	URL         string   `validate:"omitempty,url" json:"url"`
+	Description *string  `json:"description"`
+	Events      []string `json:"events"`
+	Active      *bool    `json:"active"`
+}
+
+// CreateWebhookEndpointResponse returns a new endpoint along with its signing
+// secret, which cannot be retrieved again.
+type CreateWebhookEndpointResponse struct {
+	WebhookEndpoint
+	Secret string `json:"secret"`
+}
@@ -0,0 +1,70 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type AddressRepository struct {
+	db *gorm.DB
+}
+
+func NewAddressRepository(db *gorm.DB) *AddressRepository {
+	return &AddressRepository{db: db}
+}
+
+func (r *AddressRepository) Create(ctx context.Context, address *models.Address) error {
+	if err := r.db.WithContext(ctx).Create(address).Error; err != nil {
+		return fmt.Errorf("error creating address: %w", err)
+	}
+	return nil
+}
+
+func (r *AddressRepository) GetByID(ctx context.Context, id uint) (*models.Address, error) {
+	var address models.Address
+	if err := r.db.WithContext(ctx).First(&address, id).Error; err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetUserAddress returns an address from the user's address book.
+func (r *AddressRepository) GetUserAddress(ctx context.Context, userId, id uint) (*models.Address, error) {
+	var address models.Address
+	if err := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).First(&address).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("address not found")
+		}
+		return nil, err
+	}
+	return &address, nil
+}
+
+func (r *AddressRepository) ListUserAddresses(ctx context.Context, userId uint) ([]models.Address, error) {
+	var addresses []models.Address
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("created_at").Find(&addresses).Error; err != nil {
+		return nil, err
+	}
+	return addresses, nil
+}
+
+func (r *AddressRepository) Update(ctx context.Context, address *models.Address) error {
+	return r.db.WithContext(ctx).Save(address).Error
+}
+
+func (r *AddressRepository) Delete(ctx context.Context, userId, id uint) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).Delete(&models.Address{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("address not found")
+	}
+
+	return nil
+}
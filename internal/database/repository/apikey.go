@@ -0,0 +1,142 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+var errAlreadyRotated = errors.New("api key was already rotated")
+
+type APIKeyRepository struct {
+	db *gorm.DB
+}
+
+func NewAPIKeyRepository(db *gorm.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+// CreateAPIKey stores key and records audit with it.
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, audit *models.AuditLog) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(key).Error; err != nil {
+			return err
+		}
+		return tx.Create(audit).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error creating api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKey returns the key with id, or nil if there is none.
+func (r *APIKeyRepository) GetAPIKey(ctx context.Context, id uint) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).First(&key, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching api key: %w", err)
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByPrefix returns the key with prefix, or nil if there is none.
+func (r *APIKeyRepository) GetAPIKeyByPrefix(ctx context.Context, prefix string) (*models.APIKey, error) {
+	var key models.APIKey
+	err := r.db.WithContext(ctx).Where("prefix = ?", prefix).First(&key).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching api key: %w", err)
+	}
+	return &key, nil
+}
+
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := r.db.WithContext(ctx).Order("id DESC").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("error listing api keys: %w", err)
+	}
+	return keys, nil
+}
+
+// RotateAPIKey stores next as the replacement of old, which stops working
+// at expiresAt. It returns false if old was already rotated or revoked.
+func (r *APIKeyRepository) RotateAPIKey(ctx context.Context, old *models.APIKey, next *models.APIKey, expiresAt time.Time, audit *models.AuditLog) (bool, error) {
+	rotated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(next).Error; err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.APIKey{}).
+			Where("id = ? AND replaced_by_id IS NULL AND revoked_at IS NULL", old.Id).
+			Updates(map[string]any{
+				"replaced_by_id": next.Id,
+				"expires_at":     expiresAt,
+				"updated_at":     next.CreatedAt,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Undo creating next
+			return errAlreadyRotated
+		}
+
+		rotated = true
+		return tx.Create(audit).Error
+	})
+	if errors.Is(err, errAlreadyRotated) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error rotating api key: %w", err)
+	}
+	return rotated, nil
+}
+
+// RevokeAPIKey revokes the key with id. It returns false if it was already
+// revoked.
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id uint, now time.Time, audit *models.AuditLog) (bool, error) {
+	revoked := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.APIKey{}).
+			Where("id = ? AND revoked_at IS NULL", id).
+			Updates(map[string]any{"revoked_at": now, "updated_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		revoked = true
+		return tx.Create(audit).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("error revoking api key: %w", err)
+	}
+	return revoked, nil
+}
+
+// TouchAPIKey records that the key was used from ip, unless that was
+// already recorded after since. This keeps busy keys from writing on every
+// request.
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id uint, ip string, now, since time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.APIKey{}).
+		Where("id = ? AND (last_used_at IS NULL OR last_used_at < ? OR last_used_ip <> ?)", id, since, ip).
+		UpdateColumns(map[string]any{"last_used_at": now, "last_used_ip": ip}).Error
+	if err != nil {
+		return fmt.Errorf("error recording api key use: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrCouponExhausted is returned when a coupon has reached its global
+	// redemption limit.
+	ErrCouponExhausted = errors.New("this coupon has been fully redeemed")
+
+	// ErrCouponUserLimit is returned when the user has already redeemed a
+	// coupon as many times as allowed.
+	ErrCouponUserLimit = errors.New("you have already used this coupon the maximum number of times")
+)
+
+type CouponRepository struct {
+	db *gorm.DB
+}
+
+func NewCouponRepository(db *gorm.DB) *CouponRepository {
+	return &CouponRepository{db: db}
+}
+
+func (r *CouponRepository) Create(ctx context.Context, coupon *models.Coupon) error {
+	if err := r.db.WithContext(ctx).Create(coupon).Error; err != nil {
+		return fmt.Errorf("error creating coupon: %w", err)
+	}
+	return nil
+}
+
+func (r *CouponRepository) GetByID(ctx context.Context, id uint) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.db.WithContext(ctx).First(&coupon, id).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *CouponRepository) GetByCode(ctx context.Context, code string) (*models.Coupon, error) {
+	var coupon models.Coupon
+	if err := r.db.WithContext(ctx).Where("code = ?", code).First(&coupon).Error; err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (r *CouponRepository) List(ctx context.Context) ([]models.Coupon, error) {
+	var coupons []models.Coupon
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&coupons).Error; err != nil {
+		return nil, err
+	}
+	return coupons, nil
+}
+
+// Update saves the editable fields of a coupon. The redemption counter is
+// left alone so that concurrent redemptions are not overwritten.
+func (r *CouponRepository) Update(ctx context.Context, coupon *models.Coupon) error {
+	return r.db.WithContext(ctx).Model(coupon).Select("*").Omit("redemptions", "created_at").Updates(coupon).Error
+}
+
+// releaseOrderRedemption gives back the redemption used by an order inside
+// tx, e.g. when the order is canceled. It does nothing if the order did not
+// use a coupon.
+func releaseOrderRedemption(tx *gorm.DB, orderId uint) error {
+	var redemption models.CouponRedemption
+	if err := tx.Where("order_id = ?", orderId).First(&redemption).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return err
+	}
+
+	if err := tx.Delete(&redemption).Error; err != nil {
+		return fmt.Errorf("error deleting redemption: %w", err)
+	}
+
+	return tx.Model(&models.Coupon{}).
+		Where("id = ? AND redemptions > 0", redemption.CouponID).
+		Update("redemptions", gorm.Expr("redemptions - 1")).Error
+}
+
+// redeemCoupon counts a redemption of a coupon inside tx. The coupon row is
+// locked for the rest of the transaction so concurrent orders using the same
+// code are serialised and the limits cannot be exceeded.
+func redeemCoupon(tx *gorm.DB, redemption *models.CouponRedemption) error {
+	var coupon models.Coupon
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&coupon, redemption.CouponID).Error; err != nil {
+		return fmt.Errorf("error locking coupon: %w", err)
+	}
+
+	if coupon.MaxRedemptions > 0 && coupon.Redemptions >= coupon.MaxRedemptions {
+		return ErrCouponExhausted
+	}
+
+	if coupon.PerUserLimit > 0 {
+		var used int64
+		if err := tx.Model(&models.CouponRedemption{}).
+			Where("coupon_id = ? AND user_id = ?", coupon.Id, redemption.UserID).
+			Count(&used).Error; err != nil {
+			return fmt.Errorf("error counting coupon redemptions: %w", err)
+		}
+
+		if used >= int64(coupon.PerUserLimit) {
+			return ErrCouponUserLimit
+		}
+	}
+
+	return tx.Model(&coupon).Update("redemptions", gorm.Expr("redemptions + 1")).Error
+}
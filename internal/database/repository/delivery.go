@@ -0,0 +1,197 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type DeliveryRepository struct {
+	db *gorm.DB
+}
+
+func NewDeliveryRepository(db *gorm.DB) *DeliveryRepository {
+	return &DeliveryRepository{db: db}
+}
+
+// CreateIfMissing creates a delivery for an order unless one already exists.
+func (r *DeliveryRepository) CreateIfMissing(ctx context.Context, delivery *models.Delivery) error {
+	return r.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "order_id"}},
+		DoNothing: true,
+	}).Create(delivery).Error
+}
+
+func (r *DeliveryRepository) GetByID(ctx context.Context, id uint) (*models.Delivery, error) {
+	var delivery models.Delivery
+	if err := r.db.WithContext(ctx).First(&delivery, id).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *DeliveryRepository) GetByOrderID(ctx context.Context, orderId uint) (*models.Delivery, error) {
+	var delivery models.Delivery
+	if err := r.db.WithContext(ctx).Where("order_id = ?", orderId).First(&delivery).Error; err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+func (r *DeliveryRepository) ListByStatus(ctx context.Context, status string) ([]models.Delivery, error) {
+	var deliveries []models.Delivery
+	if err := r.db.WithContext(ctx).Where("status = ?", status).Order("created_at").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+// ListCourierActive returns the deliveries currently assigned to a courier.
+func (r *DeliveryRepository) ListCourierActive(ctx context.Context, courierId uint) ([]models.Delivery, error) {
+	var deliveries []models.Delivery
+	if err := r.db.WithContext(ctx).
+		Where("courier_id = ? AND status = ?", courierId, models.DELIVERY_ASSIGNED).
+		Order("assigned_at").Find(&deliveries).Error; err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+var (
+	// ErrCourierBusy is returned when a delivery is assigned to a courier
+	// who is already carrying one.
+	ErrCourierBusy = errors.New("courier already has an active delivery")
+
+	// ErrDeliveryNotAssigned is returned when a courier completes a delivery
+	// that is not assigned to them.
+	ErrDeliveryNotAssigned = errors.New("delivery is not assigned to you")
+)
+
+// Assign gives an unassigned delivery to a courier without an active
+// delivery. It fails if another dispatcher assigned the delivery, or another
+// delivery to the courier, first.
+func (r *DeliveryRepository) Assign(ctx context.Context, id, courierId uint, at time.Time) error {
+	active := r.db.Model(&models.Delivery{}).Select("1").
+		Where("courier_id = ? AND status = ?", courierId, models.DELIVERY_ASSIGNED)
+
+	result := r.db.WithContext(ctx).Model(&models.Delivery{}).
+		Where("id = ? AND status = ? AND NOT EXISTS (?)", id, models.DELIVERY_UNASSIGNED, active).
+		Updates(map[string]interface{}{
+			"courier_id":  courierId,
+			"status":      models.DELIVERY_ASSIGNED,
+			"assigned_at": at,
+			"updated_at":  at,
+		})
+	if result.Error != nil || result.RowsAffected == 0 {
+		// Concurrent assignments to the same courier are stopped by the
+		// unique index on active deliveries
+		var busy int64
+		if err := r.db.WithContext(ctx).Model(&models.Delivery{}).
+			Where("courier_id = ? AND status = ?", courierId, models.DELIVERY_ASSIGNED).
+			Count(&busy).Error; err == nil && busy > 0 {
+			return ErrCourierBusy
+		}
+	}
+	if result.Error != nil {
+		return fmt.Errorf("error assigning delivery: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("delivery is not awaiting a courier")
+	}
+	return nil
+}
+
+// MarkDelivered completes a delivery assigned to the courier and marks its
+// order as delivered in the same transaction.
+func (r *DeliveryRepository) MarkDelivered(ctx context.Context, id, courierId uint, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var delivery models.Delivery
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("id = ? AND courier_id = ? AND status = ?", id, courierId, models.DELIVERY_ASSIGNED).
+			First(&delivery).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrDeliveryNotAssigned
+			}
+			return err
+		}
+
+		if err := tx.Model(&delivery).Updates(map[string]interface{}{
+			"status":       models.DELIVERY_DELIVERED,
+			"delivered_at": at,
+			"updated_at":   at,
+		}).Error; err != nil {
+			return fmt.Errorf("error updating delivery: %w", err)
+		}
+
+		order, err := lockOrder(tx, delivery.OrderID)
+		if err != nil {
+			return fmt.Errorf("error fetching order: %w", err)
+		}
+
+		return updateOrderStatus(tx, order, models.ORDER_STATUS_DELIVERED)
+	})
+}
+
+func (r *DeliveryRepository) UpdateStatus(ctx context.Context, id uint, status string, at time.Time) error {
+	updates := map[string]interface{}{
+		"status":     status,
+		"updated_at": at,
+	}
+	if status == models.DELIVERY_DELIVERED {
+		updates["delivered_at"] = at
+	}
+
+	return r.db.WithContext(ctx).Model(&models.Delivery{}).Where("id = ?", id).Updates(updates).Error
+}
+
+// RecordPing stores a courier location ping and updates the last known
+// position of the courier's active deliveries.
+func (r *DeliveryRepository) RecordPing(ctx context.Context, ping *models.CourierLocation) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(ping).Error; err != nil {
+			return fmt.Errorf("error recording location: %w", err)
+		}
+
+		return tx.Model(&models.Delivery{}).
+			Where("courier_id = ? AND status = ?", ping.CourierID, models.DELIVERY_ASSIGNED).
+			Updates(map[string]interface{}{
+				"last_latitude":  ping.Latitude,
+				"last_longitude": ping.Longitude,
+				"last_ping_at":   ping.RecordedAt,
+			}).Error
+	})
+}
+
+// ListAvailableCouriers returns couriers without an active delivery, along
+// with their latest location ping since the given time, if any.
+func (r *DeliveryRepository) ListAvailableCouriers(ctx context.Context, pingSince time.Time) ([]models.User, map[uint]models.CourierLocation, error) {
+	var couriers []models.User
+	busy := r.db.Model(&models.Delivery{}).Select("courier_id").
+		Where("status = ? AND courier_id IS NOT NULL", models.DELIVERY_ASSIGNED)
+	if err := r.db.WithContext(ctx).
+		Where("role = ? AND id NOT IN (?)", models.ROLE_COURIER, busy).
+		Find(&couriers).Error; err != nil {
+		return nil, nil, fmt.Errorf("error listing couriers: %w", err)
+	}
+
+	var pings []models.CourierLocation
+	if err := r.db.WithContext(ctx).
+		Raw(`SELECT DISTINCT ON (courier_id) * FROM courier_locations
+			WHERE recorded_at >= ? ORDER BY courier_id, recorded_at DESC`, pingSince).
+		Scan(&pings).Error; err != nil {
+		return nil, nil, fmt.Errorf("error fetching courier locations: %w", err)
+	}
+
+	latest := make(map[uint]models.CourierLocation, len(pings))
+	for _, p := range pings {
+		latest[p.CourierID] = p
+	}
+
+	return couriers, latest, nil
+}
@@ -0,0 +1,76 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type InvoiceRepository struct {
+	db *gorm.DB
+}
+
+func NewInvoiceRepository(db *gorm.DB) *InvoiceRepository {
+	return &InvoiceRepository{db: db}
+}
+
+// GetByOrderID returns the invoice issued for an order, or nil if none has
+// been issued.
+func (r *InvoiceRepository) GetByOrderID(ctx context.Context, orderId uint) (*models.Invoice, error) {
+	var invoice models.Invoice
+	err := r.db.WithContext(ctx).Where("order_id = ?", orderId).First(&invoice).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invoice, nil
+}
+
+// Issue numbers and stores an invoice. The store's counter row is locked
+// until the invoice is committed, so numbers are handed out in order and a
+// failed insert rolls the counter back instead of leaving a gap. If the
+// order was invoiced concurrently, the existing invoice is returned.
+func (r *InvoiceRepository) Issue(ctx context.Context, invoice *models.Invoice) (*models.Invoice, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		counter := models.InvoiceCounter{StoreID: invoice.StoreID}
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&counter).Error; err != nil {
+			return fmt.Errorf("error creating invoice counter: %w", err)
+		}
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).First(&counter, "store_id = ?", invoice.StoreID).Error; err != nil {
+			return fmt.Errorf("error locking invoice counter: %w", err)
+		}
+
+		var existing models.Invoice
+		err := tx.Where("order_id = ?", invoice.OrderID).First(&existing).Error
+		if err == nil {
+			*invoice = existing
+			return nil
+		}
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+
+		counter.LastSequence++
+		if err := tx.Model(&counter).Update("last_sequence", counter.LastSequence).Error; err != nil {
+			return fmt.Errorf("error updating invoice counter: %w", err)
+		}
+
+		invoice.Sequence = counter.LastSequence
+		invoice.Number = models.InvoiceNumber(invoice.StoreID, invoice.Sequence)
+		if err := tx.Create(invoice).Error; err != nil {
+			return fmt.Errorf("error creating invoice: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return invoice, nil
+}
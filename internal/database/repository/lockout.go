@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type LockoutRepository struct {
+	db *gorm.DB
+}
+
+func NewLockoutRepository(db *gorm.DB) *LockoutRepository {
+	return &LockoutRepository{db: db}
+}
+
+// GetThrottle returns the throttle for key, or nil if it has none.
+func (r *LockoutRepository) GetThrottle(ctx context.Context, key string) (*models.LoginThrottle, error) {
+	var throttle models.LoginThrottle
+	if err := r.db.WithContext(ctx).Where("key = ?", key).First(&throttle).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting login throttle: %w", err)
+	}
+	return &throttle, nil
+}
+
+// RecordAttempt counts a login attempt for key and returns the updated
+// throttle, or nil if the attempt must wait. It must wait while key is
+// locked, and once key has delayAfter attempts in the window, until
+// baseDelay has passed since the last one, doubling with every further
+// attempt up to maxDelay. The check and the count are one statement, so
+// concurrent attempts can not get past the limit together. Attempts from
+// before windowStart are dropped from the count.
+func (r *LockoutRepository) RecordAttempt(ctx context.Context, key string, now, windowStart time.Time, delayAfter int, baseDelay, maxDelay time.Duration) (*models.LoginThrottle, error) {
+	throttle := models.LoginThrottle{Key: key, Failures: 1, LastFailureAt: now}
+	result := r.db.WithContext(ctx).Clauses(
+		clause.OnConflict{
+			Columns: []clause.Column{{Name: "key"}},
+			DoUpdates: clause.Assignments(map[string]any{
+				"failures":        gorm.Expr("CASE WHEN login_throttles.last_failure_at < ? THEN 1 ELSE login_throttles.failures + 1 END", windowStart),
+				"last_failure_at": now,
+			}),
+			Where: clause.Where{Exprs: []clause.Expression{
+				gorm.Expr("login_throttles.locked_until IS NULL OR login_throttles.locked_until <= ?", now),
+				gorm.Expr(`login_throttles.last_failure_at < ? OR login_throttles.failures < ? OR
+					login_throttles.last_failure_at + make_interval(secs => LEAST(? * POWER(2, login_throttles.failures - ?), ?)) <= ?`,
+					windowStart, delayAfter, baseDelay.Seconds(), delayAfter, maxDelay.Seconds(), now),
+			}},
+		},
+		clause.Returning{},
+	).Create(&throttle)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error recording login attempt: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+	return &throttle, nil
+}
+
+// Forgive takes one attempt back from the count of key.
+func (r *LockoutRepository) Forgive(ctx context.Context, key string) error {
+	err := r.db.WithContext(ctx).Model(&models.LoginThrottle{}).
+		Where("key = ? AND failures > 0", key).
+		Update("failures", gorm.Expr("failures - 1")).Error
+	if err != nil {
+		return fmt.Errorf("error updating login throttle: %w", err)
+	}
+	return nil
+}
+
+// Lock locks key until the given time. It returns false if key was already
+// locked, so each lockout is only reported once.
+func (r *LockoutRepository) Lock(ctx context.Context, key string, now, until time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.LoginThrottle{}).
+		Where("key = ? AND (locked_until IS NULL OR locked_until <= ?)", key, now).
+		Update("locked_until", until)
+	if result.Error != nil {
+		return false, fmt.Errorf("error locking login: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// Reset forgets the failures and any lock of key. It returns false if there
+// was nothing to forget.
+func (r *LockoutRepository) Reset(ctx context.Context, key string) (bool, error) {
+	result := r.db.WithContext(ctx).Where("key = ?", key).Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		return false, fmt.Errorf("error resetting login throttle: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+// DeleteStale removes throttles with no failure since before and no lock in
+// force.
+func (r *LockoutRepository) DeleteStale(ctx context.Context, before, now time.Time) (int64, error) {
+	result := r.db.WithContext(ctx).
+		Where("last_failure_at < ? AND (locked_until IS NULL OR locked_until < ?)", before, now).
+		Delete(&models.LoginThrottle{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("error deleting stale login throttles: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func (r *LockoutRepository) CreateAuditLog(ctx context.Context, log *models.AuditLog) error {
+	if err := r.db.WithContext(ctx).Create(log).Error; err != nil {
+		return fmt.Errorf("error creating audit log: %w", err)
+	}
+	return nil
+}
+
+// ListAuditLogs returns the most recent audit logs of the given events.
+func (r *LockoutRepository) ListAuditLogs(ctx context.Context, events []string, limit int) ([]models.AuditLog, error) {
+	var logs []models.AuditLog
+	if err := r.db.WithContext(ctx).Where("event IN ?", events).Order("created_at DESC").Limit(limit).Find(&logs).Error; err != nil {
+		return nil, fmt.Errorf("error listing audit logs: %w", err)
+	}
+	return logs, nil
+}
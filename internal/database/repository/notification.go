@@ -0,0 +1,35 @@
+package repository
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type NotificationRepository struct {
+	db *gorm.DB
+}
+
+func NewNotificationRepository(db *gorm.DB) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// GetByKey returns the logged notification with the given key, or nil if
+// none was logged.
+func (r *NotificationRepository) GetByKey(ctx context.Context, key string) (*models.Notification, error) {
+	var notification models.Notification
+	err := r.db.WithContext(ctx).Where("key = ?", key).First(&notification).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &notification, nil
+}
+
+func (r *NotificationRepository) Save(ctx context.Context, notification *models.Notification) error {
+	return r.db.WithContext(ctx).Save(notification).Error
+}
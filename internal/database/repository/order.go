@@ -2,10 +2,15 @@ package repository
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
+	"slices"
+	"time"
 
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type OrderRepository struct {
@@ -16,15 +21,199 @@ func NewOrderRepository(db *gorm.DB) *OrderRepository {
 	return &OrderRepository{db: db}
 }
 
-func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order) (uint, error) {
+// CreateOrder creates an order. If redemption is not nil, the coupon it
+// refers to is redeemed in the same transaction.
+func (r *OrderRepository) CreateOrder(ctx context.Context, order *models.Order, redemption *models.CouponRedemption) (uint, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return createOrder(tx, order, redemption)
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return order.Id, nil
+}
+
+func createOrder(tx *gorm.DB, order *models.Order, redemption *models.CouponRedemption) error {
+	if redemption != nil {
+		if err := redeemCoupon(tx, redemption); err != nil {
+			return err
+		}
+	}
+
+	if err := tx.Create(order).Error; err != nil {
+		return fmt.Errorf("error creating order: %w", err)
+	}
+
+	if redemption != nil {
+		redemption.OrderID = order.Id
+		if err := tx.Create(redemption).Error; err != nil {
+			return fmt.Errorf("error recording coupon redemption: %w", err)
+		}
+	}
+
+	for _, item := range order.OrderItems {
+		if err := takeStock(tx, order.Id, item); err != nil {
+			return err
+		}
+	}
+
+	return writeOutbox(tx, models.OUTBOX_ORDER_CREATED, "order", order.Id, models.OrderStatusChanged{
+		OrderID: order.Id,
+		UserID:  order.UserID,
+		Status:  order.Status,
+	})
+}
+
+// ErrInsufficientStock is returned when an order asks for more of a coffee
+// than is left in stock.
+var ErrInsufficientStock = errors.New("the quantity ordered is more than the quantity in stock")
+
+// takeStock subtracts an order item from its coffee's stock inside tx and
+// records the change in the outbox. The update only applies while enough
+// stock is left, so concurrent orders can not oversell.
+func takeStock(tx *gorm.DB, orderId uint, item models.OrderItem) error {
+	var remaining []uint
+	if err := tx.Raw("UPDATE coffees SET quantity = quantity - ? WHERE id = ? AND quantity >= ? RETURNING quantity",
+		item.Quantity, item.CoffeeID, item.Quantity).Scan(&remaining).Error; err != nil {
+		return fmt.Errorf("error updating stock: %w", err)
+	}
+	if len(remaining) == 0 {
+		return fmt.Errorf("%w: %s", ErrInsufficientStock, item.Name)
+	}
+
+	return writeOutbox(tx, models.OUTBOX_COFFEE_STOCK_CHANGED, "coffee", item.CoffeeID, models.StockChanged{
+		CoffeeID:  item.CoffeeID,
+		OrderID:   orderId,
+		Name:      item.Name,
+		Previous:  remaining[0] + item.Quantity,
+		Remaining: remaining[0],
+	})
+}
+
+// restoreStock puts the items of a canceled order back in stock inside tx.
+func restoreStock(tx *gorm.DB, orderId uint) error {
+	var items []models.OrderItem
+	if err := tx.Select("coffee_id", "quantity").Where("order_id = ?", orderId).Find(&items).Error; err != nil {
+		return fmt.Errorf("error fetching order items: %w", err)
+	}
+
+	for _, item := range items {
+		if err := tx.Model(&models.Coffee{}).Where("id = ?", item.CoffeeID).
+			Update("quantity", gorm.Expr("quantity + ?", item.Quantity)).Error; err != nil {
+			return fmt.Errorf("error restoring stock: %w", err)
+		}
+	}
+	return nil
+}
 
-	if err := r.db.WithContext(ctx).Create(&order).Error; err != nil {
-		return 0, fmt.Errorf("error creating order: %w", err)
+// ErrSlotFull is returned when a scheduled order cannot be placed because its
+// fulfilment slot has already reached capacity.
+var ErrSlotFull = errors.New("the selected time slot is fully booked")
+
+// slotLockNamespace is the first key of the advisory locks taken on time
+// slots, so that they can not collide with other advisory locks.
+const slotLockNamespace int32 = 0x736c6f74 // "slot"
+
+// slotLockKey identifies the slot starting at slotStart at a store for an
+// advisory lock. Orders without a store share slots of their own.
+func slotLockKey(storeId *uint, slotStart time.Time) int32 {
+	store := "none"
+	if storeId != nil {
+		store = fmt.Sprint(*storeId)
+	}
+
+	h := fnv.New32a()
+	fmt.Fprintf(h, "%s:%d", store, slotStart.Unix())
+	return int32(h.Sum32())
+}
+
+// CreateScheduledOrder creates an order (redeeming its coupon, if any) for the
+// slot starting at slotStart, provided fewer than capacity active orders are
+// already booked in it at the order's store. A transaction-scoped advisory
+// lock on the store's slot serialises concurrent bookings so the capacity
+// check and the insert cannot interleave.
+func (r *OrderRepository) CreateScheduledOrder(ctx context.Context, order *models.Order, redemption *models.CouponRedemption, slotStart time.Time, slotLength time.Duration, capacity int64) (uint, error) {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(?, ?)", slotLockNamespace, slotLockKey(order.StoreID, slotStart)).Error; err != nil {
+			return fmt.Errorf("error locking time slot: %w", err)
+		}
+
+		query := tx.Model(&models.Order{}).
+			Where("scheduled_for >= ? AND scheduled_for < ? AND status <> ?", slotStart, slotStart.Add(slotLength), models.ORDER_STATUS_CANCELED)
+		if order.StoreID != nil {
+			query = query.Where("store_id = ?", *order.StoreID)
+		} else {
+			query = query.Where("store_id IS NULL")
+		}
+
+		var booked int64
+		if err := query.Count(&booked).Error; err != nil {
+			return fmt.Errorf("error counting slot bookings: %w", err)
+		}
+
+		if booked >= capacity {
+			return ErrSlotFull
+		}
+
+		return createOrder(tx, order, redemption)
+	})
+	if err != nil {
+		return 0, err
 	}
 
 	return order.Id, nil
 }
 
+// ReleaseScheduledOrders moves every scheduled order due at or before the
+// given time into the pending queue and returns the IDs of those released.
+func (r *OrderRepository) ReleaseScheduledOrders(ctx context.Context, dueBy time.Time) ([]uint, error) {
+	var ids []uint
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var orders []models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE", Options: "SKIP LOCKED"}).
+			Select("id", "user_id").
+			Where("status = ? AND scheduled_for <= ?", models.ORDER_STATUS_SCHEDULED, dueBy).
+			Find(&orders).Error; err != nil {
+			return err
+		}
+		if len(orders) == 0 {
+			return nil
+		}
+
+		ids = make([]uint, 0, len(orders))
+		for _, o := range orders {
+			ids = append(ids, o.Id)
+		}
+
+		if err := tx.Model(&models.Order{}).Where("id IN ?", ids).
+			Updates(map[string]interface{}{
+				"status":      models.ORDER_STATUS_PENDING,
+				"released_at": time.Now().UTC(),
+				"updated_at":  time.Now().UTC(),
+			}).Error; err != nil {
+			return err
+		}
+
+		for _, o := range orders {
+			if err := writeOutbox(tx, models.OUTBOX_ORDER_STATUS_CHANGED, "order", o.Id, models.OrderStatusChanged{
+				OrderID:        o.Id,
+				UserID:         o.UserID,
+				Status:         models.ORDER_STATUS_PENDING,
+				PreviousStatus: models.ORDER_STATUS_SCHEDULED,
+			}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error releasing scheduled orders: %w", err)
+	}
+
+	return ids, nil
+}
+
 func (r *OrderRepository) GetOrder(ctx context.Context, id uint) (*models.Order, error) {
 	var order models.Order
 	if err := r.db.WithContext(ctx).Preload("OrderItems").First(&order, id).Error; err != nil {
@@ -33,11 +222,72 @@ func (r *OrderRepository) GetOrder(ctx context.Context, id uint) (*models.Order,
 	return &order, nil
 }
 
-func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, id uint, status string) error {
-	if err := r.db.WithContext(ctx).Model(&models.Order{}).Where("id = ?", id).Update("status", status).Error; err != nil {
+// ErrOrderChanged is returned when an order's status changed after it was
+// read, so a change checked against the old status is not applied.
+var ErrOrderChanged = errors.New("the order was changed in the meantime; reload it and try again")
+
+// UpdateOrderStatus changes an order's status from from to status and
+// records the change in the outbox in the same transaction.
+func (r *OrderRepository) UpdateOrderStatus(ctx context.Context, id uint, from, status string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockOrder(tx, id)
+		if err != nil {
+			return err
+		}
+		if order.Status != from {
+			return ErrOrderChanged
+		}
+
+		return updateOrderStatus(tx, order, status)
+	})
+}
+
+// ErrOrderNotCancelable is returned when an order has moved past the
+// statuses it can be canceled from.
+var ErrOrderNotCancelable = errors.New("order can no longer be canceled")
+
+// CancelOrder cancels an order that is in one of the from statuses.
+func (r *OrderRepository) CancelOrder(ctx context.Context, id uint, from []string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var order models.Order
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "user_id", "status").First(&order, id).Error; err != nil {
+			return err
+		}
+
+		if !slices.Contains(from, order.Status) {
+			return ErrOrderNotCancelable
+		}
+
+		return updateOrderStatus(tx, &order, models.ORDER_STATUS_CANCELED)
+	})
+}
+
+// updateOrderStatus changes the status of order, which must be locked in tx,
+// and records the change in the outbox. Canceling an order gives back the
+// coupon redemption it used and, if it was not being prepared yet, puts its
+// items back in stock.
+func updateOrderStatus(tx *gorm.DB, order *models.Order, status string) error {
+	if err := tx.Model(&models.Order{}).Where("id = ?", order.Id).Update("status", status).Error; err != nil {
 		return err
 	}
-	return nil
+
+	if status == models.ORDER_STATUS_CANCELED && order.Status != models.ORDER_STATUS_CANCELED {
+		if order.Status == models.ORDER_STATUS_SCHEDULED || order.Status == models.ORDER_STATUS_PENDING {
+			if err := restoreStock(tx, order.Id); err != nil {
+				return err
+			}
+		}
+		if err := releaseOrderRedemption(tx, order.Id); err != nil {
+			return fmt.Errorf("error releasing coupon redemption: %w", err)
+		}
+	}
+
+	return writeOutbox(tx, models.OUTBOX_ORDER_STATUS_CHANGED, "order", order.Id, models.OrderStatusChanged{
+		OrderID:        order.Id,
+		UserID:         order.UserID,
+		Status:         status,
+		PreviousStatus: order.Status,
+	})
 }
 
 func (r *OrderRepository) DeleteOrder(ctx context.Context, id uint) error {
@@ -54,3 +304,154 @@ func (r *OrderRepository) ListUserOrders(ctx context.Context, id uint) ([]models
 	}
 	return order, nil
 }
+
+// ListKitchenQueue returns paid orders that are waiting to be prepared or
+// being prepared, soonest promised first. The promised time is the
+// scheduled slot of a pre-order, or the time an immediate order was placed.
+func (r *OrderRepository) ListKitchenQueue(ctx context.Context, storeId *uint) ([]models.Order, error) {
+	paid := r.db.Model(&models.Transaction{}).Select("1").
+		Where("transactions.order_id = orders.id AND transactions.payment_status = ?", models.PAYMENT_COMPLETED)
+
+	query := r.db.WithContext(ctx).Preload("OrderItems").
+		Where("orders.status IN ?", []string{models.ORDER_STATUS_PENDING, models.ORDER_STATUS_PREPARING}).
+		Where("EXISTS (?)", paid)
+	if storeId != nil {
+		query = query.Where("orders.store_id = ?", *storeId)
+	}
+
+	var orders []models.Order
+	if err := query.Order("COALESCE(orders.scheduled_for, orders.created_at), orders.id").Find(&orders).Error; err != nil {
+		return nil, fmt.Errorf("error listing kitchen queue: %w", err)
+	}
+	return orders, nil
+}
+
+var (
+	ErrItemAlreadyStarted = errors.New("item has already been started")
+	ErrItemAlreadyDone    = errors.New("item is already done")
+	ErrNotAwaitingPrep    = errors.New("order is not awaiting preparation")
+)
+
+// lockKitchenOrder locks an order whose items are being prepared. The status
+// is checked under the lock, so an order canceled since it was read can not
+// be moved on by the kitchen.
+func lockKitchenOrder(tx *gorm.DB, id uint) (*models.Order, error) {
+	order, err := lockOrder(tx, id)
+	if err != nil {
+		return nil, err
+	}
+	if order.Status != models.ORDER_STATUS_PENDING && order.Status != models.ORDER_STATUS_PREPARING {
+		return nil, ErrNotAwaitingPrep
+	}
+	return order, nil
+}
+
+// StartOrderItem marks a queued item of an order as started and moves the
+// order from PENDING to PREPARING, in one transaction. It returns the status
+// the order had before.
+func (r *OrderRepository) StartOrderItem(ctx context.Context, orderId, itemId uint, at time.Time) (string, error) {
+	var previous string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockKitchenOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+		previous = order.Status
+
+		result := tx.Model(&models.OrderItem{}).
+			Where("id = ? AND order_id = ? AND prep_status = ?", itemId, orderId, models.PREP_QUEUED).
+			Updates(map[string]interface{}{
+				"prep_status": models.PREP_STARTED,
+				"started_at":  at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrItemAlreadyStarted
+		}
+
+		if err := writeItemChanged(tx, order, itemId, models.PREP_STARTED); err != nil {
+			return err
+		}
+
+		if order.Status != models.ORDER_STATUS_PENDING {
+			return nil
+		}
+		return updateOrderStatus(tx, order, models.ORDER_STATUS_PREPARING)
+	})
+	if err != nil {
+		return "", err
+	}
+	return previous, nil
+}
+
+// CompleteOrderItem marks an item of an order as done and, if it was the
+// last one, moves the order to READY, in one transaction. It returns whether
+// the order is now ready.
+func (r *OrderRepository) CompleteOrderItem(ctx context.Context, orderId, itemId uint, at time.Time) (bool, error) {
+	var ready bool
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		order, err := lockKitchenOrder(tx, orderId)
+		if err != nil {
+			return err
+		}
+
+		result := tx.Model(&models.OrderItem{}).
+			Where("id = ? AND order_id = ? AND prep_status <> ?", itemId, orderId, models.PREP_DONE).
+			Updates(map[string]interface{}{
+				"prep_status": models.PREP_DONE,
+				"started_at":  gorm.Expr("COALESCE(started_at, ?)", at),
+				"done_at":     at,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrItemAlreadyDone
+		}
+
+		if err := writeItemChanged(tx, order, itemId, models.PREP_DONE); err != nil {
+			return err
+		}
+
+		var unfinished int64
+		if err := tx.Model(&models.OrderItem{}).
+			Where("order_id = ? AND prep_status <> ?", orderId, models.PREP_DONE).
+			Count(&unfinished).Error; err != nil {
+			return err
+		}
+		if unfinished > 0 {
+			return nil
+		}
+
+		ready = true
+		return updateOrderStatus(tx, order, models.ORDER_STATUS_READY)
+	})
+	if err != nil {
+		return false, err
+	}
+	return ready, nil
+}
+
+// writeItemChanged records a change to the preparation of an item of order
+// in the outbox.
+func writeItemChanged(tx *gorm.DB, order *models.Order, itemId uint, prepStatus string) error {
+	return writeOutbox(tx, models.OUTBOX_ORDER_ITEM_CHANGED, "order", order.Id, models.OrderItemChanged{
+		OrderID:     order.Id,
+		UserID:      order.UserID,
+		ItemID:      itemId,
+		PrepStatus:  prepStatus,
+		OrderStatus: order.Status,
+	})
+}
+
+// lockOrder loads the fields of an order needed to change its status and
+// locks it for the rest of tx.
+func lockOrder(tx *gorm.DB, id uint) (*models.Order, error) {
+	var order models.Order
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id", "user_id", "status").First(&order, id).Error; err != nil {
+		return nil, err
+	}
+	return &order, nil
+}
@@ -0,0 +1,163 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type OutboxRepository struct {
+	db *gorm.DB
+}
+
+func NewOutboxRepository(db *gorm.DB) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// writeOutbox records an event in tx. It must be called inside the database
+// transaction that makes the change the event describes.
+func writeOutbox(tx *gorm.DB, eventType, aggregateType string, aggregateId uint, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("error encoding outbox payload: %w", err)
+	}
+
+	now := time.Now().UTC()
+	event := models.OutboxEvent{
+		Type:          eventType,
+		AggregateType: aggregateType,
+		AggregateID:   aggregateId,
+		Payload:       string(body),
+		NextAttemptAt: now,
+		CreatedAt:     now,
+	}
+
+	if err := tx.Create(&event).Error; err != nil {
+		return fmt.Errorf("error writing outbox event: %w", err)
+	}
+	return nil
+}
+
+// ClaimDue leases up to limit undispatched, live events that are due, oldest
+// first, by pushing their next attempt out to lease. Rows locked by another
+// dispatcher are skipped.
+func (r *OutboxRepository) ClaimDue(ctx context.Context, now, lease time.Time, limit int) ([]models.OutboxEvent, error) {
+	var events []models.OutboxEvent
+	err := r.db.WithContext(ctx).Raw(`
+		UPDATE outbox_events SET next_attempt_at = ?
+		WHERE id IN (
+			SELECT id FROM outbox_events
+			WHERE dispatched_at IS NULL AND dead_at IS NULL AND next_attempt_at <= ?
+			ORDER BY id LIMIT ?
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING *`, lease, now, limit).
+		Scan(&events).Error
+	if err != nil {
+		return nil, fmt.Errorf("error claiming outbox events: %w", err)
+	}
+
+	sort.Slice(events, func(i, j int) bool { return events[i].Id < events[j].Id })
+	return events, nil
+}
+
+// ListDeliveries returns the subscribers and sinks that have accepted an
+// event.
+func (r *OutboxRepository) ListDeliveries(ctx context.Context, eventId uint) (map[string]bool, error) {
+	var names []string
+	if err := r.db.WithContext(ctx).Model(&models.OutboxDelivery{}).
+		Where("event_id = ?", eventId).Pluck("subscriber", &names).Error; err != nil {
+		return nil, fmt.Errorf("error fetching outbox deliveries: %w", err)
+	}
+
+	done := make(map[string]bool, len(names))
+	for _, name := range names {
+		done[name] = true
+	}
+	return done, nil
+}
+
+// RecordDeliveries records that the named subscribers and sinks have
+// accepted an event.
+func (r *OutboxRepository) RecordDeliveries(ctx context.Context, eventId uint, names []string, at time.Time) error {
+	if len(names) == 0 {
+		return nil
+	}
+
+	deliveries := make([]models.OutboxDelivery, 0, len(names))
+	for _, name := range names {
+		deliveries = append(deliveries, models.OutboxDelivery{EventID: eventId, Subscriber: name, DeliveredAt: at})
+	}
+
+	if err := r.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("error recording outbox deliveries: %w", err)
+	}
+	return nil
+}
+
+// MarkDispatched marks an event as dispatched. Its delivery records are no
+// longer needed once it is.
+func (r *OutboxRepository) MarkDispatched(ctx context.Context, id uint, at time.Time) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"dispatched_at": at,
+				"last_error":    "",
+			}).Error; err != nil {
+			return err
+		}
+
+		return tx.Where("event_id = ?", id).Delete(&models.OutboxDelivery{}).Error
+	})
+}
+
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id uint, attempts uint, next time.Time, lastError string) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":        attempts,
+			"next_attempt_at": next,
+			"last_error":      lastError,
+		}).Error
+}
+
+// MarkDead records that an event will not be retried. Its delivery records
+// are kept to show which subscribers and sinks did accept it.
+func (r *OutboxRepository) MarkDead(ctx context.Context, id uint, attempts uint, at time.Time, lastError string) error {
+	return r.db.WithContext(ctx).Model(&models.OutboxEvent{}).Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"attempts":   attempts,
+			"dead_at":    at,
+			"last_error": lastError,
+		}).Error
+}
+
+// DeleteDispatchedBefore removes events dispatched before the given time,
+// along with any delivery records left behind by a dispatcher whose lease
+// had expired. Dead events are kept. It returns how many events were
+// removed.
+func (r *OutboxRepository) DeleteDispatchedBefore(ctx context.Context, before time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		dispatched := tx.Model(&models.OutboxEvent{}).Select("id").Where("dispatched_at < ?", before)
+		if err := tx.Where("event_id IN (?)", dispatched).Delete(&models.OutboxDelivery{}).Error; err != nil {
+			return err
+		}
+
+		result := tx.Where("dispatched_at < ?", before).Delete(&models.OutboxEvent{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error deleting dispatched outbox events: %w", err)
+	}
+	return deleted, nil
+}
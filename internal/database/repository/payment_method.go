@@ -0,0 +1,73 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type PaymentMethodRepository struct {
+	db *gorm.DB
+}
+
+func NewPaymentMethodRepository(db *gorm.DB) *PaymentMethodRepository {
+	return &PaymentMethodRepository{db: db}
+}
+
+// Save stores a payment method, replacing the user's existing method for the
+// same card (identified by the provider's signature) if there is one.
+func (r *PaymentMethodRepository) Save(ctx context.Context, pm *models.PaymentMethod) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return savePaymentMethod(tx, pm)
+	})
+}
+
+func savePaymentMethod(tx *gorm.DB, pm *models.PaymentMethod) error {
+	if pm.Signature != "" {
+		var existing models.PaymentMethod
+		err := tx.Where("user_id = ? AND provider = ? AND signature = ?", pm.UserID, pm.Provider, pm.Signature).First(&existing).Error
+		if err == nil {
+			pm.Id = existing.Id
+			pm.CreatedAt = existing.CreatedAt
+		} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("error fetching payment method: %w", err)
+		}
+	}
+
+	if err := tx.Save(pm).Error; err != nil {
+		return fmt.Errorf("error saving payment method: %w", err)
+	}
+	return nil
+}
+
+func (r *PaymentMethodRepository) GetByID(ctx context.Context, id uint) (*models.PaymentMethod, error) {
+	var pm models.PaymentMethod
+	if err := r.db.WithContext(ctx).First(&pm, id).Error; err != nil {
+		return nil, err
+	}
+	return &pm, nil
+}
+
+func (r *PaymentMethodRepository) ListUserPaymentMethods(ctx context.Context, userId uint) ([]models.PaymentMethod, error) {
+	var pms []models.PaymentMethod
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("updated_at DESC").Find(&pms).Error; err != nil {
+		return nil, err
+	}
+	return pms, nil
+}
+
+func (r *PaymentMethodRepository) Delete(ctx context.Context, userId, id uint) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND user_id = ?", id, userId).Delete(&models.PaymentMethod{})
+	if result.Error != nil {
+		return result.Error
+	}
+
+	if result.RowsAffected == 0 {
+		return errors.New("payment method not found")
+	}
+
+	return nil
+}
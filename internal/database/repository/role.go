@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type RoleRepository struct {
+	db *gorm.DB
+}
+
+func NewRoleRepository(db *gorm.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// Seed stores the given permissions and creates the missing roles with their
+// default permissions. Roles that already exist keep their permissions,
+// except that the admin role is always granted every permission.
+func (r *RoleRepository) Seed(ctx context.Context, permissions []models.Permission, roles map[string][]string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"description"}),
+		}).Create(&permissions).Error; err != nil {
+			return fmt.Errorf("error seeding permissions: %w", err)
+		}
+
+		for name, granted := range roles {
+			now := tx.NowFunc()
+			role := models.Role{Name: name, System: true, CreatedAt: now, UpdatedAt: now}
+			result := tx.Clauses(clause.OnConflict{DoNothing: true}).Omit("Permissions").Create(&role)
+			if result.Error != nil {
+				return fmt.Errorf("error seeding role %s: %w", name, result.Error)
+			}
+
+			if name == models.ROLE_ADMIN {
+				granted = make([]string, 0, len(permissions))
+				for _, p := range permissions {
+					granted = append(granted, p.Name)
+				}
+			} else if result.RowsAffected == 0 {
+				// The role existed already; keep its permissions as configured
+				continue
+			}
+			if len(granted) == 0 {
+				continue
+			}
+
+			if err := tx.Model(&role).Association("Permissions").Append(permissionRefs(granted)); err != nil {
+				return fmt.Errorf("error granting permissions to %s: %w", name, err)
+			}
+		}
+		return nil
+	})
+}
+
+func (r *RoleRepository) ListRoles(ctx context.Context) ([]models.Role, error) {
+	var roles []models.Role
+	if err := r.db.WithContext(ctx).Preload("Permissions").Order("name").Find(&roles).Error; err != nil {
+		return nil, err
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	var role models.Role
+	if err := r.db.WithContext(ctx).Preload("Permissions").First(&role, "name = ?", name).Error; err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+func (r *RoleRepository) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	var permissions []models.Permission
+	if err := r.db.WithContext(ctx).Order("name").Find(&permissions).Error; err != nil {
+		return nil, err
+	}
+	return permissions, nil
+}
+
+func (r *RoleRepository) CreateRole(ctx context.Context, role *models.Role) error {
+	if err := r.db.WithContext(ctx).Create(role).Error; err != nil {
+		return fmt.Errorf("error creating role: %w", err)
+	}
+	return nil
+}
+
+// SetPermissions replaces the permissions of a role.
+func (r *RoleRepository) SetPermissions(ctx context.Context, role *models.Role, permissions []string) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(role).Association("Permissions").Replace(permissionRefs(permissions)); err != nil {
+			return err
+		}
+		return tx.Model(role).Update("updated_at", tx.NowFunc()).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error setting role permissions: %w", err)
+	}
+	return nil
+}
+
+func permissionRefs(names []string) []models.Permission {
+	refs := make([]models.Permission, 0, len(names))
+	for _, n := range names {
+		refs = append(refs, models.Permission{Name: n})
+	}
+	return refs
+}
@@ -0,0 +1,97 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type SocialRepository struct {
+	db *gorm.DB
+}
+
+func NewSocialRepository(db *gorm.DB) *SocialRepository {
+	return &SocialRepository{db: db}
+}
+
+func (r *SocialRepository) CreateState(ctx context.Context, state *models.OAuthState) error {
+	if err := r.db.WithContext(ctx).Create(state).Error; err != nil {
+		return fmt.Errorf("error creating login state: %w", err)
+	}
+	return nil
+}
+
+// TakeState deletes and returns the unexpired login state of provider with
+// the given hash, so that it can only be used once. It returns nil if there
+// is none.
+func (r *SocialRepository) TakeState(ctx context.Context, provider, stateHash string, now time.Time) (*models.OAuthState, error) {
+	var states []models.OAuthState
+	result := r.db.WithContext(ctx).Clauses(clause.Returning{}).
+		Where("state_hash = ? AND provider = ? AND expires_at > ?", stateHash, provider, now).
+		Delete(&states)
+	if result.Error != nil {
+		return nil, fmt.Errorf("error taking login state: %w", result.Error)
+	}
+	if len(states) == 0 {
+		return nil, nil
+	}
+	return &states[0], nil
+}
+
+// GetIdentity returns the identity with the provider's subject, or nil if
+// it is not linked to a user.
+func (r *SocialRepository) GetIdentity(ctx context.Context, provider, subject string) (*models.UserIdentity, error) {
+	var identity models.UserIdentity
+	err := r.db.WithContext(ctx).Where("provider = ? AND subject = ?", provider, subject).First(&identity).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error fetching identity: %w", err)
+	}
+	return &identity, nil
+}
+
+func (r *SocialRepository) CreateIdentity(ctx context.Context, identity *models.UserIdentity) error {
+	if err := r.db.WithContext(ctx).Create(identity).Error; err != nil {
+		return fmt.Errorf("error linking identity: %w", err)
+	}
+	return nil
+}
+
+// CreateUserWithIdentity creates a user signing in with a provider for the
+// first time, together with their identity.
+func (r *SocialRepository) CreateUserWithIdentity(ctx context.Context, user *models.User, identity *models.UserIdentity) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(user).Error; err != nil {
+			return err
+		}
+		identity.UserID = user.Id
+		return tx.Create(identity).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error creating user: %w", err)
+	}
+	return nil
+}
+
+func (r *SocialRepository) TouchIdentity(ctx context.Context, id uint, now time.Time) error {
+	if err := r.db.WithContext(ctx).Model(&models.UserIdentity{}).Where("id = ?", id).Update("last_login_at", now).Error; err != nil {
+		return fmt.Errorf("error updating identity: %w", err)
+	}
+	return nil
+}
+
+// ListUserIdentities returns the providers the user can sign in with.
+func (r *SocialRepository) ListUserIdentities(ctx context.Context, userId uint) ([]models.UserIdentity, error) {
+	var identities []models.UserIdentity
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Order("id").Find(&identities).Error; err != nil {
+		return nil, fmt.Errorf("error listing identities: %w", err)
+	}
+	return identities, nil
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type StoreRepository struct {
+	db *gorm.DB
+}
+
+func NewStoreRepository(db *gorm.DB) *StoreRepository {
+	return &StoreRepository{db: db}
+}
+
+func (r *StoreRepository) CreateStore(ctx context.Context, store *models.Store) error {
+	if err := r.db.WithContext(ctx).Create(store).Error; err != nil {
+		return fmt.Errorf("error creating store: %w", err)
+	}
+	return nil
+}
+
+func (r *StoreRepository) GetStore(ctx context.Context, id uint) (*models.Store, error) {
+	var store models.Store
+	if err := r.db.WithContext(ctx).Preload("Zones").First(&store, id).Error; err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+func (r *StoreRepository) ListStores(ctx context.Context) ([]models.Store, error) {
+	var stores []models.Store
+	if err := r.db.WithContext(ctx).Preload("Zones").Find(&stores).Error; err != nil {
+		return nil, err
+	}
+	return stores, nil
+}
+
+// ListActiveZones returns the active delivery zones of active stores, with
+// their store, optionally limited to a single store.
+func (r *StoreRepository) ListActiveZones(ctx context.Context, storeId *uint) ([]models.DeliveryZone, error) {
+	var zones []models.DeliveryZone
+	query := r.db.WithContext(ctx).
+		Joins("JOIN stores ON stores.id = delivery_zones.store_id AND stores.active = ?", true).
+		Where("delivery_zones.active = ?", true)
+	if storeId != nil {
+		query = query.Where("delivery_zones.store_id = ?", *storeId)
+	}
+
+	if err := query.Order("delivery_zones.id").Find(&zones).Error; err != nil {
+		return nil, err
+	}
+	return zones, nil
+}
+
+func (r *StoreRepository) CreateZone(ctx context.Context, zone *models.DeliveryZone) error {
+	if err := r.db.WithContext(ctx).Create(zone).Error; err != nil {
+		return fmt.Errorf("error creating delivery zone: %w", err)
+	}
+	return nil
+}
+
+// DeleteZone deletes a zone of a store. It returns gorm.ErrRecordNotFound if
+// the store has no such zone.
+func (r *StoreRepository) DeleteZone(ctx context.Context, storeId, zoneId uint) error {
+	result := r.db.WithContext(ctx).Where("id = ? AND store_id = ?", zoneId, storeId).Delete(&models.DeliveryZone{})
+	if result.Error != nil {
+		return fmt.Errorf("error deleting delivery zone: %w", result.Error)
+	}
+
+	if result.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+
+	return nil
+}
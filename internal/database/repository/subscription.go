@@ -0,0 +1,120 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type SubscriptionRepository struct {
+	db *gorm.DB
+}
+
+func NewSubscriptionRepository(db *gorm.DB) *SubscriptionRepository {
+	return &SubscriptionRepository{db: db}
+}
+
+func (r *SubscriptionRepository) Create(ctx context.Context, sub *models.Subscription) error {
+	if err := r.db.WithContext(ctx).Create(sub).Error; err != nil {
+		return fmt.Errorf("error creating subscription: %w", err)
+	}
+	return nil
+}
+
+func (r *SubscriptionRepository) GetByID(ctx context.Context, id uint) (*models.Subscription, error) {
+	var sub models.Subscription
+	if err := r.db.WithContext(ctx).Preload("Items").First(&sub, id).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+func (r *SubscriptionRepository) ListUserSubscriptions(ctx context.Context, userId uint) ([]models.Subscription, error) {
+	var subs []models.Subscription
+	if err := r.db.WithContext(ctx).Preload("Items").Where("user_id = ?", userId).Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Update writes the given columns of sub, and its update time.
+func (r *SubscriptionRepository) Update(ctx context.Context, sub *models.Subscription, columns ...string) error {
+	return r.db.WithContext(ctx).Model(sub).Select(append(columns, "updated_at")).Updates(sub).Error
+}
+
+// SetPendingOrder records the order generated for the current run of a
+// subscription, provided its status is still status. It reports whether the
+// subscription was updated.
+func (r *SubscriptionRepository) SetPendingOrder(ctx context.Context, id uint, status string, orderId uint) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("id = ? AND status = ?", id, status).
+		Updates(map[string]interface{}{
+			"pending_order_id": orderId,
+			"updated_at":       time.Now().UTC(),
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error recording pending order: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// UpdateRun stores the outcome of a run: the columns the subscription worker
+// owns. It only applies while the status is still status, so that a pause
+// or cancel made during the run is not overwritten. It reports whether the
+// subscription was updated.
+func (r *SubscriptionRepository) UpdateRun(ctx context.Context, sub *models.Subscription, status string) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("id = ? AND status = ?", sub.Id, status).
+		Updates(map[string]interface{}{
+			"status":           sub.Status,
+			"pending_order_id": sub.PendingOrderID,
+			"failed_attempts":  sub.FailedAttempts,
+			"last_failure_at":  sub.LastFailureAt,
+			"next_run_at":      sub.NextRunAt,
+			"updated_at":       sub.UpdatedAt,
+		})
+	if result.Error != nil {
+		return false, fmt.Errorf("error updating subscription run: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// GetAwaitingAuthorization returns the incomplete subscription whose first
+// order is orderId.
+func (r *SubscriptionRepository) GetAwaitingAuthorization(ctx context.Context, orderId uint) (*models.Subscription, error) {
+	var sub models.Subscription
+	if err := r.db.WithContext(ctx).
+		Where("first_order_id = ? AND status = ?", orderId, models.SUBSCRIPTION_INCOMPLETE).
+		First(&sub).Error; err != nil {
+		return nil, err
+	}
+	return &sub, nil
+}
+
+// ListDue returns active and past-due subscriptions whose next run is at or
+// before now.
+func (r *SubscriptionRepository) ListDue(ctx context.Context, now time.Time) ([]models.Subscription, error) {
+	var subs []models.Subscription
+	if err := r.db.WithContext(ctx).Preload("Items").
+		Where("status IN ? AND next_run_at <= ?", []string{models.SUBSCRIPTION_ACTIVE, models.SUBSCRIPTION_PAST_DUE}, now).
+		Find(&subs).Error; err != nil {
+		return nil, err
+	}
+	return subs, nil
+}
+
+// Claim moves the next run of a subscription from current to lease, and
+// reports whether this caller won the claim. It prevents two workers from
+// processing the same run.
+func (r *SubscriptionRepository) Claim(ctx context.Context, id uint, current, lease time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.Subscription{}).
+		Where("id = ? AND next_run_at = ?", id, current).
+		Update("next_run_at", lease)
+	if result.Error != nil {
+		return false, fmt.Errorf("error claiming subscription: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
@@ -0,0 +1,219 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type TokenRepository struct {
+	db *gorm.DB
+}
+
+func NewTokenRepository(db *gorm.DB) *TokenRepository {
+	return &TokenRepository{db: db}
+}
+
+func (r *TokenRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken) error {
+	if err := r.db.WithContext(ctx).Create(token).Error; err != nil {
+		return fmt.Errorf("error creating refresh token: %w", err)
+	}
+	return nil
+}
+
+func (r *TokenRepository) GetRefreshToken(ctx context.Context, tokenHash string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := r.db.WithContext(ctx).Where("token_hash = ?", tokenHash).First(&token).Error; err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// RotateRefreshToken marks old as used and stores next in its place. It
+// returns false without storing next if old was already used or revoked,
+// which is how a concurrent reuse of the same token is detected.
+func (r *TokenRepository) RotateRefreshToken(ctx context.Context, old, next *models.RefreshToken) (bool, error) {
+	rotated := false
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND rotated_at IS NULL AND revoked_at IS NULL", old.Id).
+			Update("rotated_at", next.CreatedAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		rotated = true
+		return tx.Create(next).Error
+	})
+	if err != nil {
+		return false, fmt.Errorf("error rotating refresh token: %w", err)
+	}
+	return rotated, nil
+}
+
+// RevokeSession revokes the refresh tokens of a session and adds it to the
+// revocation list until the given time.
+func (r *TokenRepository) RevokeSession(ctx context.Context, userId uint, sessionId, reason string, until time.Time) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		return revokeSessions(tx, userId, []string{sessionId}, reason, until)
+	})
+	if err != nil {
+		return fmt.Errorf("error revoking session: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserSessions revokes every session of a user that still has a usable
+// refresh token, except the session named by except if it is not empty, and
+// returns how many were revoked.
+func (r *TokenRepository) RevokeUserSessions(ctx context.Context, userId uint, except, reason string, until time.Time) (int, error) {
+	var sessions []string
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.RefreshToken{}).
+			Where("user_id = ? AND session_id <> ? AND revoked_at IS NULL AND expires_at > ?", userId, except, time.Now().UTC()).
+			Distinct().Pluck("session_id", &sessions).Error; err != nil {
+			return err
+		}
+		if len(sessions) == 0 {
+			return nil
+		}
+		return revokeSessions(tx, userId, sessions, reason, until)
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error revoking user sessions: %w", err)
+	}
+	return len(sessions), nil
+}
+
+func revokeSessions(tx *gorm.DB, userId uint, sessions []string, reason string, until time.Time) error {
+	now := time.Now().UTC()
+	if err := tx.Model(&models.RefreshToken{}).
+		Where("session_id IN ? AND revoked_at IS NULL", sessions).
+		Update("revoked_at", now).Error; err != nil {
+		return err
+	}
+
+	revoked := make([]models.RevokedSession, 0, len(sessions))
+	for _, s := range sessions {
+		revoked = append(revoked, models.RevokedSession{
+			SessionID: s,
+			UserID:    userId,
+			Reason:    reason,
+			ExpiresAt: until,
+			CreatedAt: now,
+		})
+	}
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&revoked).Error
+}
+
+// IsSessionRevoked reports whether a session is on the revocation list.
+func (r *TokenRepository) IsSessionRevoked(ctx context.Context, sessionId string) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.RevokedSession{}).Where("session_id = ?", sessionId).Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count > 0, nil
+}
+
+// DeleteExpired removes refresh tokens, revocation list entries, emailed
+// tokens and social login states that expired before now.
+func (r *TokenRepository) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	var deleted int64
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Where("expires_at < ?", now).Delete(&models.RefreshToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+
+		result = tx.Where("expires_at < ?", now).Delete(&models.RevokedSession{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+
+		result = tx.Where("expires_at < ?", now).Delete(&models.UserToken{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+
+		result = tx.Where("expires_at < ?", now).Delete(&models.OAuthState{})
+		if result.Error != nil {
+			return result.Error
+		}
+		deleted += result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, fmt.Errorf("error deleting expired tokens: %w", err)
+	}
+	return deleted, nil
+}
+
+// CreateUserToken stores token and marks the user's unused tokens for the
+// same purpose as used, so only the latest one emailed works.
+func (r *TokenRepository) CreateUserToken(ctx context.Context, token *models.UserToken) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.UserToken{}).
+			Where("user_id = ? AND purpose = ? AND used_at IS NULL", token.UserID, token.Purpose).
+			Update("used_at", token.CreatedAt).Error; err != nil {
+			return err
+		}
+		return tx.Create(token).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error creating user token: %w", err)
+	}
+	return nil
+}
+
+// GetUserToken returns the token for a purpose with the given hash, or nil
+// if there is none.
+func (r *TokenRepository) GetUserToken(ctx context.Context, purpose, tokenHash string) (*models.UserToken, error) {
+	var token models.UserToken
+	err := r.db.WithContext(ctx).Where("purpose = ? AND token_hash = ?", purpose, tokenHash).First(&token).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting user token: %w", err)
+	}
+	return &token, nil
+}
+
+// UseUserToken marks a token as used. It returns false if the token was
+// already used or has expired, so a token can only be redeemed once even by
+// concurrent requests.
+func (r *TokenRepository) UseUserToken(ctx context.Context, id uint, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", id, now).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, fmt.Errorf("error using user token: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// FailUserToken counts a failed attempt to redeem a token, and marks the
+// token as used once maxAttempts have failed.
+func (r *TokenRepository) FailUserToken(ctx context.Context, id uint, maxAttempts int, now time.Time) error {
+	err := r.db.WithContext(ctx).Model(&models.UserToken{}).
+		Where("id = ? AND used_at IS NULL", id).
+		Updates(map[string]any{
+			"attempts": gorm.Expr("attempts + 1"),
+			"used_at":  gorm.Expr("CASE WHEN attempts + 1 >= ? THEN ?::timestamptz ELSE NULL END", maxAttempts, now),
+		}).Error
+	if err != nil {
+		return fmt.Errorf("error recording failed token attempt: %w", err)
+	}
+	return nil
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
@@ -17,28 +18,99 @@ func NewTransactionRepository(db *gorm.DB) *TransactionRepository {
 	return &TransactionRepository{db: db}
 }
 
+// CreateTransaction creates a transaction and records it in the outbox in
+// the same database transaction.
 func (r *TransactionRepository) CreateTransaction(ctx context.Context, transaction *models.Transaction) error {
-	result := r.db.WithContext(ctx).Create(transaction)
-	return result.Error
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(transaction).Error; err != nil {
+			return err
+		}
+
+		return writeOutbox(tx, models.OUTBOX_TRANSACTION_CREATED, "transaction", transaction.ID, transactionChanged(transaction))
+	})
 }
 
-func (r *TransactionRepository) UpdateTransactionStatus(ctx context.Context, id int, status string) error {
-	result := r.db.WithContext(ctx).Model(&models.Transaction{}).
-		Where("id = ?", id).
-		Updates(map[string]interface{}{
-			"status":     status,
-			"updated_at": time.Now(),
-		})
+// UpdateTransactionStatus changes a transaction's payment status and records
+// the change in the outbox in the same database transaction.
+func (r *TransactionRepository) UpdateTransactionStatus(ctx context.Context, id uint, status string) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Transaction{}).
+			Where("id = ?", id).
+			Updates(map[string]interface{}{
+				"payment_status": status,
+				"updated_at":     time.Now(),
+			})
 
-	if result.Error != nil {
-		return result.Error
-	}
+		if result.Error != nil {
+			return result.Error
+		}
 
-	if result.RowsAffected == 0 {
-		return errors.New("transaction was not found")
-	}
+		if result.RowsAffected == 0 {
+			return errors.New("transaction was not found")
+		}
+
+		var transaction models.Transaction
+		if err := tx.First(&transaction, id).Error; err != nil {
+			return err
+		}
+
+		return writeOutbox(tx, models.OUTBOX_TRANSACTION_STATUS_CHANGED, "transaction", id, transactionChanged(&transaction))
+	})
+}
+
+// CompleteTransaction marks trx as paid with the masked card details set on
+// it, recording the change in the outbox. If pm is not nil, the reusable
+// authorization of the charge is saved as a payment method of the customer
+// in the same transaction. It does nothing if the transaction is already
+// completed, so a payment confirmed twice is only published once.
+func (r *TransactionRepository) CompleteTransaction(ctx context.Context, trx *models.Transaction, pm *models.PaymentMethod) error {
+	id := trx.ID
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Transaction{}).
+			Where("id = ? AND payment_status <> ?", id, models.PAYMENT_COMPLETED).
+			Updates(map[string]interface{}{
+				"payment_status": models.PAYMENT_COMPLETED,
+				"card_brand":     trx.CardBrand,
+				"card_last4":     trx.CardLast4,
+				"card_exp_month": trx.CardExpMonth,
+				"card_exp_year":  trx.CardExpYear,
+				"updated_at":     time.Now(),
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		if pm != nil {
+			if err := savePaymentMethod(tx, pm); err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Transaction{}).Where("id = ?", id).Update("payment_method_id", pm.Id).Error; err != nil {
+				return fmt.Errorf("error linking payment method: %w", err)
+			}
+		}
+
+		var transaction models.Transaction
+		if err := tx.First(&transaction, id).Error; err != nil {
+			return err
+		}
 
-	return nil
+		return writeOutbox(tx, models.OUTBOX_TRANSACTION_STATUS_CHANGED, "transaction", id, transactionChanged(&transaction))
+	})
+}
+
+func transactionChanged(t *models.Transaction) models.TransactionChanged {
+	return models.TransactionChanged{
+		TransactionID: t.ID,
+		OrderID:       t.OrderID,
+		UserID:        t.UserID,
+		Reference:     t.Reference,
+		PaymentStatus: t.PaymentStatus,
+		Amount:        t.TotalAmount,
+		Currency:      t.Currency,
+	}
 }
 
 func (r *TransactionRepository) GetTransactionById(ctx context.Context, id uint) (*models.Transaction, error) {
@@ -65,3 +137,41 @@ func (r *TransactionRepository) GetPendingTransaction(ctx context.Context, order
 
 	return &transaction, nil
 }
+
+// GetProcessingTransaction returns the latest charge of an order whose
+// outcome is not known yet.
+func (r *TransactionRepository) GetProcessingTransaction(ctx context.Context, orderId uint) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).Where("order_id = ? AND payment_status = ?", orderId, models.PAYMENT_PROCESSING).
+		Order("created_at DESC").First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *TransactionRepository) GetTransactionByReference(ctx context.Context, reference string) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).Where("reference = ?", reference).First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+// GetCompletedTransaction returns the successful payment of an order.
+func (r *TransactionRepository) GetCompletedTransaction(ctx context.Context, orderId uint) (*models.Transaction, error) {
+	var transaction models.Transaction
+	if err := r.db.WithContext(ctx).Where("order_id = ? AND payment_status = ?", orderId, models.PAYMENT_COMPLETED).
+		Order("updated_at").First(&transaction).Error; err != nil {
+		return nil, err
+	}
+	return &transaction, nil
+}
+
+func (r *TransactionRepository) HasCompletedTransaction(ctx context.Context, orderId uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Transaction{}).Where("order_id = ? AND payment_status = ?", orderId, models.PAYMENT_COMPLETED).Count(&count).Error; err != nil {
+		return false, err
+	}
+
+	return count > 0, nil
+}
@@ -0,0 +1,65 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"gorm.io/gorm"
+)
+
+type TwoFactorRepository struct {
+	db *gorm.DB
+}
+
+func NewTwoFactorRepository(db *gorm.DB) *TwoFactorRepository {
+	return &TwoFactorRepository{db: db}
+}
+
+// UseTOTPStep records step as the last time step a code was accepted for.
+// It returns false if a code from the same or a later step was already
+// accepted, which stops a code from being replayed.
+func (r *TwoFactorRepository) UseTOTPStep(ctx context.Context, userId uint, step int64) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.User{}).
+		Where("id = ? AND totp_last_step < ?", userId, step).
+		Update("totp_last_step", step)
+	if result.Error != nil {
+		return false, fmt.Errorf("error recording totp step: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// ReplaceRecoveryCodes deletes the user's recovery codes and stores codes
+// in their place.
+func (r *TwoFactorRepository) ReplaceRecoveryCodes(ctx context.Context, userId uint, codes []models.RecoveryCode) error {
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&codes).Error
+	})
+	if err != nil {
+		return fmt.Errorf("error replacing recovery codes: %w", err)
+	}
+	return nil
+}
+
+// UseRecoveryCode marks the user's unused recovery code with the given hash
+// as used. It returns false if there is none.
+func (r *TwoFactorRepository) UseRecoveryCode(ctx context.Context, userId uint, codeHash string, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).Model(&models.RecoveryCode{}).
+		Where("user_id = ? AND code_hash = ? AND used_at IS NULL", userId, codeHash).
+		Update("used_at", now)
+	if result.Error != nil {
+		return false, fmt.Errorf("error using recovery code: %w", result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r *TwoFactorRepository) DeleteRecoveryCodes(ctx context.Context, userId uint) error {
+	if err := r.db.WithContext(ctx).Where("user_id = ?", userId).Delete(&models.RecoveryCode{}).Error; err != nil {
+		return fmt.Errorf("error deleting recovery codes: %w", err)
+	}
+	return nil
+}
@@ -58,7 +58,7 @@ func (r *UserRepository) ListUsers(ctx context.Context) ([]*models.User, error)
 
 func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
 	var user models.User
-	if err := r.db.WithContext(ctx).Where("email = ?", email).First(&user).Error; err != nil {
+	if err := r.db.WithContext(ctx).Where("LOWER(email) = LOWER(?)", email).First(&user).Error; err != nil {
 		if errors.Is(err, gorm.ErrRecordNotFound) {
 			return nil, errors.New("user not found")
 		}
@@ -66,3 +66,75 @@ func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (*mod
 	}
 	return &user, nil
 }
+
+func (r *UserRepository) CountByRole(ctx context.Context, role string) (int64, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.User{}).Where("role = ?", role).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	return count, nil
+}
+
+// Anonymize saves user, which the caller has stripped of personal data, and
+// removes the personal data kept about them elsewhere. Orders and
+// transactions are kept as financial records, without their delivery
+// locations. oldEmail is the address the user had, which is scrubbed from
+// the audit log, and throttleKeys are the login throttles kept for it.
+func (r *UserRepository) Anonymize(ctx context.Context, user *models.User, oldEmail string, throttleKeys []string, audit *models.AuditLog) error {
+	return r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(user).Error; err != nil {
+			return err
+		}
+
+		for _, model := range []any{&models.Address{}, &models.PaymentMethod{}, &models.UserToken{}, &models.RecoveryCode{}, &models.UserIdentity{}, &models.APIKey{}} {
+			if err := tx.Where("user_id = ?", user.Id).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Model(&models.Subscription{}).
+			Where("user_id = ? AND status <> ?", user.Id, models.SUBSCRIPTION_CANCELED).
+			Updates(map[string]any{"status": models.SUBSCRIPTION_CANCELED, "authorization_code": "", "updated_at": user.UpdatedAt}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Order{}).Where("user_id = ?", user.Id).
+			Updates(map[string]any{
+				"delivery_address_id": nil,
+				"delivery_address":    "",
+				"delivery_latitude":   nil,
+				"delivery_longitude":  nil,
+			}).Error; err != nil {
+			return err
+		}
+
+		// Transactions are kept for the books, without the card they were
+		// paid with
+		if err := tx.Model(&models.Transaction{}).Where("user_id = ?", user.Id).
+			Updates(map[string]any{
+				"payment_method_id": nil,
+				"card_brand":        "",
+				"card_last4":        "",
+				"card_exp_month":    "",
+				"card_exp_year":     "",
+			}).Error; err != nil {
+			return err
+		}
+
+		if err := tx.Model(&models.Notification{}).Where("user_id = ?", user.Id).Update("recipient", "").Error; err != nil {
+			return err
+		}
+
+		// Failed logins are logged with the email tried, whether or not it
+		// matched the account
+		if err := tx.Model(&models.AuditLog{}).Where("user_id = ? OR LOWER(email) = LOWER(?)", user.Id, oldEmail).
+			Update("email", "").Error; err != nil {
+			return err
+		}
+		if err := tx.Where("key IN ?", throttleKeys).Delete(&models.LoginThrottle{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(audit).Error
+	})
+}
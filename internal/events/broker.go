@@ -0,0 +1,136 @@
+// Package events fans out order events to in-process subscribers such as
+// Server-Sent Event streams.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	ORDER_PLACED           = "placed"
+	ORDER_PAID             = "paid"
+	ORDER_RELEASED         = "released"
+	ORDER_PREPARING        = "preparing"
+	ORDER_READY            = "ready"
+	ORDER_OUT_FOR_DELIVERY = "out_for_delivery"
+	ORDER_DELIVERED        = "delivered"
+	ORDER_COMPLETED        = "completed"
+	ORDER_CANCELLED        = "cancelled"
+	ORDER_ITEM_STARTED     = "item_started"
+	ORDER_ITEM_DONE        = "item_done"
+)
+
+// allOrders is the subscription key of subscribers to every order.
+const allOrders = 0
+
+// subscriberBuffer is how many events may queue up for a subscriber before
+// further events to it are dropped. An order only has a handful of events in
+// its lifetime, so a subscriber only falls behind if it has stopped reading.
+const subscriberBuffer = 16
+
+// Event is a change to an order.
+type Event struct {
+	Type       string    `json:"type"`
+	OrderID    uint      `json:"order_id"`
+	UserID     uint      `json:"user_id"`
+	Status     string    `json:"status,omitempty"`
+	ItemID     *uint     `json:"item_id,omitempty"`
+	OccurredAt time.Time `json:"occurred_at"`
+}
+
+// Subscription receives the events of a single order on C until it is closed.
+type Subscription struct {
+	C <-chan Event
+
+	ch      chan Event
+	orderID uint
+	broker  *Broker
+	once    sync.Once
+}
+
+// send delivers an event without blocking, dropping it if the subscriber's
+// buffer is full.
+func (s *Subscription) send(event Event) {
+	select {
+	case s.ch <- event:
+	default:
+	}
+}
+
+// Close unsubscribes and closes C. It is safe to call more than once.
+func (s *Subscription) Close() {
+	s.once.Do(func() {
+		s.broker.unsubscribe(s)
+	})
+}
+
+// Broker delivers published events to the subscribers of the event's order.
+// It is safe for concurrent use by any number of publishers and subscribers;
+// a slow subscriber never blocks a publisher.
+type Broker struct {
+	mu   sync.RWMutex
+	subs map[uint]map[*Subscription]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{subs: make(map[uint]map[*Subscription]struct{})}
+}
+
+// Subscribe returns a subscription to the events of an order. The caller
+// must Close it when done.
+func (b *Broker) Subscribe(orderID uint) *Subscription {
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{C: ch, ch: ch, orderID: orderID, broker: b}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.subs[orderID] == nil {
+		b.subs[orderID] = make(map[*Subscription]struct{})
+	}
+	b.subs[orderID][sub] = struct{}{}
+
+	return sub
+}
+
+// SubscribeAll returns a subscription to the events of every order. The
+// caller must Close it when done.
+func (b *Broker) SubscribeAll() *Subscription {
+	return b.Subscribe(allOrders)
+}
+
+// Publish sends an event to every current subscriber of its order and to
+// the subscribers of all orders.
+func (b *Broker) Publish(event Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for sub := range b.subs[event.OrderID] {
+		sub.send(event)
+	}
+	for sub := range b.subs[allOrders] {
+		sub.send(event)
+	}
+}
+
+// Subscribers returns the number of open subscriptions to an order.
+func (b *Broker) Subscribers(orderID uint) int {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return len(b.subs[orderID])
+}
+
+func (b *Broker) unsubscribe(sub *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.subs[sub.orderID], sub)
+	if len(b.subs[sub.orderID]) == 0 {
+		delete(b.subs, sub.orderID)
+	}
+
+	// Channels are only sent to under the read lock, so closing under the
+	// write lock cannot race with Publish.
+	close(sub.ch)
+}
@@ -0,0 +1,86 @@
+package events
+
+import (
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBrokerDeliversToOrderSubscribers(t *testing.T) {
+	b := NewBroker()
+
+	sub := b.Subscribe(1)
+	defer sub.Close()
+	other := b.Subscribe(2)
+	defer other.Close()
+
+	b.Publish(Event{Type: ORDER_PAID, OrderID: 1})
+
+	select {
+	case ev := <-sub.C:
+		require.Equal(t, ORDER_PAID, ev.Type)
+	case <-time.After(time.Second):
+		t.Fatal("event not delivered")
+	}
+
+	select {
+	case ev := <-other.C:
+		t.Fatalf("unexpected event for other order: %+v", ev)
+	default:
+	}
+}
+
+func TestBrokerSlowSubscriberDoesNotBlock(t *testing.T) {
+	b := NewBroker()
+	sub := b.Subscribe(1)
+	defer sub.Close()
+
+	done := make(chan struct{})
+	go func() {
+		for i := 0; i < subscriberBuffer*4; i++ {
+			b.Publish(Event{Type: ORDER_PREPARING, OrderID: 1})
+		}
+		close(done)
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("publish blocked on a slow subscriber")
+	}
+	require.Len(t, sub.C, subscriberBuffer)
+}
+
+func TestBrokerConcurrentSubscribers(t *testing.T) {
+	b := NewBroker()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sub := b.Subscribe(7)
+			b.Publish(Event{Type: ORDER_READY, OrderID: 7})
+			<-sub.C
+			sub.Close()
+			sub.Close()
+		}()
+	}
+	wg.Wait()
+
+	require.Zero(t, b.Subscribers(7))
+}
+
+func TestBrokerSubscribeAll(t *testing.T) {
+	b := NewBroker()
+	all := b.SubscribeAll()
+	defer all.Close()
+
+	b.Publish(Event{Type: ORDER_PAID, OrderID: 3})
+	b.Publish(Event{Type: ORDER_PAID, OrderID: 4})
+
+	require.Equal(t, uint(3), (<-all.C).OrderID)
+	require.Equal(t, uint(4), (<-all.C).OrderID)
+}
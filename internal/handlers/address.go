@@ -0,0 +1,151 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AddressHandler represents the HTTP handler for the user's address book
+type AddressHandler struct {
+	service  *services.AddressService
+	validate *validator.Validate
+}
+
+// NewAddressHandler creates a new AddressHandler instance
+func NewAddressHandler(svc *services.AddressService, vld *validator.Validate) *AddressHandler {
+	return &AddressHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// CreateAddress handles adding an address to the current user's address book
+func (h *AddressHandler) CreateAddress(c *gin.Context) {
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	address, err := h.service.CreateAddress(c, userId, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Address created successfully", Data: address})
+}
+
+// ListAddresses handles fetching the current user's address book
+func (h *AddressHandler) ListAddresses(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	addresses, err := h.service.ListAddresses(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Addresses fetched successfully", Data: addresses})
+}
+
+// GetAddress handles fetching a single address by ID
+func (h *AddressHandler) GetAddress(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid address ID", Data: nil})
+		return
+	}
+
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	address, err := h.service.GetAddress(c, principal, id)
+	if err != nil {
+		status, message := resourceError(err, http.StatusInternalServerError, "address not found")
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Address fetched successfully", Data: address})
+}
+
+// UpdateAddress handles updating an address in the current user's address book
+func (h *AddressHandler) UpdateAddress(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid address ID", Data: nil})
+		return
+	}
+
+	var req models.AddressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	address, err := h.service.UpdateAddress(c, principal, id, &req)
+	if err != nil {
+		status, message := resourceError(err, http.StatusInternalServerError, "address not found")
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Address updated successfully", Data: address})
+}
+
+// DeleteAddress handles removing an address from the current user's address book
+func (h *AddressHandler) DeleteAddress(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid address ID", Data: nil})
+		return
+	}
+
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.service.DeleteAddress(c, principal, id); err != nil {
+		status, message := resourceError(err, http.StatusInternalServerError, "address not found")
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Address deleted successfully", Data: nil})
+}
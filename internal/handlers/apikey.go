@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// APIKeyHandler represents the HTTP handler for managing API keys
+type APIKeyHandler struct {
+	service  *services.APIKeyService
+	validate *validator.Validate
+}
+
+// NewAPIKeyHandler creates a new APIKeyHandler instance
+func NewAPIKeyHandler(svc *services.APIKeyService, vld *validator.Validate) *APIKeyHandler {
+	return &APIKeyHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// ListKeys handles listing every API key
+func (h *APIKeyHandler) ListKeys(c *gin.Context) {
+	keys, err := h.service.List(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "API keys retrieved successfully", Data: keys})
+}
+
+// GetKey handles fetching a single API key by ID
+func (h *APIKeyHandler) GetKey(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid API key ID", Data: nil})
+		return
+	}
+
+	key, err := h.service.Get(c, id)
+	if err != nil {
+		c.JSON(apiKeyErrorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "API key retrieved successfully", Data: key})
+}
+
+// CreateKey handles issuing an API key for a user. The key is only shown in
+// this response.
+func (h *APIKeyHandler) CreateKey(c *gin.Context) {
+	p, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.CreateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	key, err := h.service.Create(c, p, &req)
+	if err != nil {
+		c.JSON(apiKeyErrorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "API key created; store it now, it will not be shown again", Data: key})
+}
+
+// RotateKey handles replacing an API key. The old key keeps working for a
+// grace period.
+func (h *APIKeyHandler) RotateKey(c *gin.Context) {
+	p, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid API key ID", Data: nil})
+		return
+	}
+
+	// The body is optional
+	var req models.RotateAPIKeyRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	key, err := h.service.Rotate(c, p, id, req.GracePeriodHours)
+	if err != nil {
+		c.JSON(apiKeyErrorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "API key rotated; store the new key now, it will not be shown again", Data: key})
+}
+
+// RevokeKey handles revoking an API key
+func (h *APIKeyHandler) RevokeKey(c *gin.Context) {
+	p, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid API key ID", Data: nil})
+		return
+	}
+
+	if err := h.service.Revoke(c, p, id); err != nil {
+		c.JSON(apiKeyErrorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "API key revoked", Data: nil})
+}
+
+func apiKeyErrorStatus(err error, fallback int) int {
+	if errors.Is(err, services.ErrAPIKeyNotFound) {
+		return http.StatusNotFound
+	}
+	return errorStatus(err, fallback)
+}
@@ -0,0 +1,182 @@
+package handlers
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"strconv"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// AuthHandler represents the HTTP handler for logins and sessions
+type AuthHandler struct {
+	service  *services.AuthService
+	validate *validator.Validate
+}
+
+// NewAuthHandler creates a new AuthHandler instance
+func NewAuthHandler(svc *services.AuthService, vld *validator.Validate) *AuthHandler {
+	return &AuthHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// Login handles signing a user in with their email and password. Users with
+// two-factor authentication get a challenge for POST /login/2fa instead of
+// tokens.
+func (h *AuthHandler) Login(c *gin.Context) {
+	var credentials models.LoginRequest
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(credentials); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	tokens, challenge, err := h.service.Login(c, credentials.Email, credentials.Password, c.ClientIP())
+	if err != nil {
+		var throttled *services.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, models.Response{Status: false, Message: err.Error(), Data: nil})
+			return
+		}
+
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if challenge != nil {
+		c.JSON(http.StatusOK, models.Response{Status: true, Message: "Two-factor authentication required", Data: challenge})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Login successful", Data: tokens})
+}
+
+// LoginTwoFactor handles finishing a login with a TOTP or recovery code
+func (h *AuthHandler) LoginTwoFactor(c *gin.Context) {
+	var req models.TwoFactorLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	tokens, err := h.service.LoginTwoFactor(c, &req, c.ClientIP())
+	if err != nil {
+		var throttled *services.ThrottledError
+		if errors.As(err, &throttled) {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(throttled.RetryAfter.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, models.Response{Status: false, Message: err.Error(), Data: nil})
+			return
+		}
+
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidChallenge) || errors.Is(err, services.ErrInvalidTwoFactorCode) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Login successful", Data: tokens})
+}
+
+// SetupTwoFactor handles enrolling in two-factor authentication during a
+// login that requires it
+func (h *AuthHandler) SetupTwoFactor(c *gin.Context) {
+	var req models.TwoFactorSetupRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	enrollment, err := h.service.SetupTwoFactor(c, req.ChallengeToken)
+	if err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, services.ErrInvalidChallenge) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Add this secret to your authenticator app", Data: enrollment})
+}
+
+// RefreshToken handles exchanging a refresh token for a new token pair
+func (h *AuthHandler) RefreshToken(c *gin.Context) {
+	var req models.RefreshTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	tokens, err := h.service.Refresh(c, req.RefreshToken)
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrInvalidRefreshToken) || errors.Is(err, services.ErrRefreshTokenReused) {
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Token refreshed successfully", Data: tokens})
+}
+
+// Logout handles ending the session the request was made with
+func (h *AuthHandler) Logout(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.service.Logout(c, userId, getSessionIdFromClaims(c)); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Logged out successfully", Data: nil})
+}
+
+// LogoutAll handles ending every session of the logged in user
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	revoked, err := h.service.LogoutAll(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Logged out of all sessions", Data: gin.H{"sessions_revoked": revoked}})
+}
@@ -0,0 +1,118 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// CouponHandler represents the HTTP handler for admin coupon management
+type CouponHandler struct {
+	service  *services.CouponService
+	validate *validator.Validate
+}
+
+// NewCouponHandler creates a new CouponHandler instance
+func NewCouponHandler(svc *services.CouponService, vld *validator.Validate) *CouponHandler {
+	return &CouponHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// CreateCoupon handles the creation of a new coupon
+func (h *CouponHandler) CreateCoupon(c *gin.Context) {
+	var req models.CreateCoupon
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	coupon, err := h.service.CreateCoupon(c, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Coupon created successfully", Data: coupon})
+}
+
+// ListCoupons handles fetching all coupons
+func (h *CouponHandler) ListCoupons(c *gin.Context) {
+	coupons, err := h.service.ListCoupons(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Coupons retrieved successfully", Data: coupons})
+}
+
+// GetCoupon handles fetching a single coupon by ID
+func (h *CouponHandler) GetCoupon(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid coupon ID", Data: nil})
+		return
+	}
+
+	coupon, err := h.service.GetCoupon(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: "Coupon not found", Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Coupon retrieved successfully", Data: coupon})
+}
+
+// UpdateCoupon handles updating an existing coupon
+func (h *CouponHandler) UpdateCoupon(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid coupon ID", Data: nil})
+		return
+	}
+
+	var req models.CreateCoupon
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	coupon, err := h.service.UpdateCoupon(c, id, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Coupon updated successfully", Data: coupon})
+}
+
+// DeactivateCoupon handles disabling a coupon
+func (h *CouponHandler) DeactivateCoupon(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid coupon ID", Data: nil})
+		return
+	}
+
+	if err := h.service.DeactivateCoupon(c, id); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Coupon deactivated successfully", Data: nil})
+}
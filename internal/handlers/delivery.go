@@ -0,0 +1,154 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// DeliveryHandler represents the HTTP handler for courier dispatch and delivery tracking
+type DeliveryHandler struct {
+	service  *services.DeliveryService
+	validate *validator.Validate
+}
+
+// NewDeliveryHandler creates a new DeliveryHandler instance
+func NewDeliveryHandler(svc *services.DeliveryService, vld *validator.Validate) *DeliveryHandler {
+	return &DeliveryHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// ListUnassigned handles fetching deliveries that are waiting for a courier
+func (h *DeliveryHandler) ListUnassigned(c *gin.Context) {
+	deliveries, err := h.service.ListUnassigned(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Deliveries retrieved successfully", Data: deliveries})
+}
+
+// AssignCourier handles an admin assigning a delivery to a courier
+func (h *DeliveryHandler) AssignCourier(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid delivery ID", Data: nil})
+		return
+	}
+
+	var req models.AssignCourierRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	delivery, err := h.service.AssignCourier(c, id, req.CourierID)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Courier assigned successfully", Data: delivery})
+}
+
+// ListCourierDeliveries handles fetching the deliveries assigned to the calling courier
+func (h *DeliveryHandler) ListCourierDeliveries(c *gin.Context) {
+	courierId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	deliveries, err := h.service.ListCourierDeliveries(c, courierId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Deliveries retrieved successfully", Data: deliveries})
+}
+
+// RecordLocation handles a location ping from the calling courier
+func (h *DeliveryHandler) RecordLocation(c *gin.Context) {
+	courierId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.LocationPing
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.service.RecordLocation(c, courierId, &req); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Location recorded successfully", Data: nil})
+}
+
+// MarkDelivered handles a courier confirming that a delivery was handed over
+func (h *DeliveryHandler) MarkDelivered(c *gin.Context) {
+	courierId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid delivery ID", Data: nil})
+		return
+	}
+
+	delivery, err := h.service.MarkDelivered(c, courierId, id)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Delivery completed successfully", Data: delivery})
+}
+
+// TrackOrder handles fetching the delivery status and courier position of the user's order
+func (h *DeliveryHandler) TrackOrder(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid order ID", Data: nil})
+		return
+	}
+
+	tracking, err := h.service.Track(c, principal, id)
+	if err != nil {
+		status, message := orderError(err, http.StatusNotFound)
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Delivery status retrieved successfully", Data: tracking})
+}
@@ -0,0 +1,133 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+var errNoUserId = errors.New("could not get user id from request")
+
+// getUserIdFromClaims returns the id of the authenticated user from the JWT
+// claims set by the auth middlewares.
+func getUserIdFromClaims(c *gin.Context) (uint, error) {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return 0, errNoUserId
+	}
+
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return 0, errNoUserId
+	}
+
+	userId, ok := mapClaims["user_id"].(string)
+	if !ok {
+		return 0, errNoUserId
+	}
+
+	id, err := strconv.ParseUint(userId, 10, 64)
+	if err != nil {
+		return 0, errNoUserId
+	}
+
+	return uint(id), nil
+}
+
+// getIdParam parses the ":id" path parameter.
+func getIdParam(c *gin.Context) (uint, error) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return 0, errors.New("Invalid ID")
+	}
+	return uint(id), nil
+}
+
+// getRoleFromClaims returns the role of the authenticated user from the JWT
+// claims set by the auth middlewares.
+func getRoleFromClaims(c *gin.Context) string {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return ""
+	}
+
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	role, _ := mapClaims["role"].(string)
+	return role
+}
+
+// getSessionIdFromClaims returns the session the access token was issued
+// for.
+func getSessionIdFromClaims(c *gin.Context) string {
+	claims, ok := c.Get("claims")
+	if !ok {
+		return ""
+	}
+
+	mapClaims, ok := claims.(jwt.MapClaims)
+	if !ok {
+		return ""
+	}
+
+	sessionId, _ := mapClaims["sid"].(string)
+	return sessionId
+}
+
+// getPrincipal returns the authenticated caller that resource policies are
+// checked against.
+func getPrincipal(c *gin.Context) (authz.Principal, error) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		return authz.Principal{}, err
+	}
+	return authz.Principal{
+		UserID:      userId,
+		Role:        getRoleFromClaims(c),
+		Permissions: c.GetStringSlice("permissions"),
+	}, nil
+}
+
+// errorStatus returns 403 if err is an authorization failure, 404 if the
+// resource is hidden from the caller, 400 if it is a rejected password or
+// emailed token, 429 if the caller is rate limited, and fallback otherwise.
+func errorStatus(err error, fallback int) int {
+	var throttled *services.ThrottledError
+	switch {
+	case errors.Is(err, authz.ErrForbidden):
+		return http.StatusForbidden
+	case errors.Is(err, authz.ErrNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, services.ErrWeakPassword), errors.Is(err, services.ErrInvalidToken):
+		return http.StatusBadRequest
+	case errors.Is(err, services.ErrTooManyRequests), errors.As(err, &throttled):
+		return http.StatusTooManyRequests
+	}
+	return fallback
+}
+
+// orderError returns the status and message of an error from a request for
+// an order. Orders hidden from the caller are reported exactly like missing
+// ones, so that the ids of other customers' orders can not be probed.
+func orderError(err error, fallback int) (int, string) {
+	return resourceError(err, fallback, "order not found")
+}
+
+// resourceError returns the status and message of an error from a request
+// for a resource, reporting hidden resources with the notFound message used
+// for missing ones.
+func resourceError(err error, fallback int, notFound string) (int, string) {
+	if errors.Is(err, authz.ErrNotFound) || errors.Is(err, gorm.ErrRecordNotFound) {
+		return http.StatusNotFound, notFound
+	}
+	return errorStatus(err, fallback), err.Error()
+}
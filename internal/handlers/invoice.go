@@ -0,0 +1,45 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// InvoiceHandler represents the HTTP handler for invoices and receipts
+type InvoiceHandler struct {
+	service *services.InvoiceService
+}
+
+// NewInvoiceHandler creates a new InvoiceHandler instance
+func NewInvoiceHandler(svc *services.InvoiceService) *InvoiceHandler {
+	return &InvoiceHandler{service: svc}
+}
+
+// DownloadReceipt handles downloading the invoice of a paid order as a PDF
+func (h *InvoiceHandler) DownloadReceipt(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid order ID", Data: nil})
+		return
+	}
+
+	invoice, file, err := h.service.ReceiptPDF(c, principal, id)
+	if err != nil {
+		status, message := orderError(err, http.StatusNotFound)
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.pdf"`, invoice.Number))
+	c.Data(http.StatusOK, "application/pdf", file)
+}
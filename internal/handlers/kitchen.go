@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/events"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+)
+
+// KitchenHandler represents the HTTP handler for the barista preparation queue
+type KitchenHandler struct {
+	service *services.KitchenService
+	broker  *events.Broker
+}
+
+// NewKitchenHandler creates a new KitchenHandler instance
+func NewKitchenHandler(svc *services.KitchenService, broker *events.Broker) *KitchenHandler {
+	return &KitchenHandler{
+		service: svc,
+		broker:  broker,
+	}
+}
+
+// GetQueue handles fetching paid orders awaiting preparation, optionally for a single store
+func (h *KitchenHandler) GetQueue(c *gin.Context) {
+	var storeId *uint
+	if v := c.Query("store_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid store ID", Data: nil})
+			return
+		}
+		sid := uint(id)
+		storeId = &sid
+	}
+
+	tickets, err := h.service.Queue(c, storeId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Queue retrieved successfully", Data: tickets})
+}
+
+// StartItem handles a barista starting to prepare an order item
+func (h *KitchenHandler) StartItem(c *gin.Context) {
+	h.itemAction(c, h.service.StartItem, "Item started")
+}
+
+// CompleteItem handles a barista finishing an order item
+func (h *KitchenHandler) CompleteItem(c *gin.Context) {
+	h.itemAction(c, h.service.CompleteItem, "Item completed")
+}
+
+func (h *KitchenHandler) itemAction(c *gin.Context, action func(context.Context, uint, uint) (*models.Order, error), message string) {
+	orderId, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid order ID", Data: nil})
+		return
+	}
+
+	itemId, err := strconv.ParseUint(c.Param("itemId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid item ID", Data: nil})
+		return
+	}
+
+	order, err := action(c, orderId, uint(itemId))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: message, Data: order.ToOrderResponse()})
+}
+
+// StreamTickets handles streaming new tickets and preparation progress as Server-Sent Events
+func (h *KitchenHandler) StreamTickets(c *gin.Context) {
+	sub := h.broker.SubscribeAll()
+	defer sub.Close()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+
+			switch event.Type {
+			case events.ORDER_PAID, events.ORDER_RELEASED:
+				// A paid order is a new ticket, unless it is a pre-order
+				// that has not been released to the kitchen yet, in which
+				// case it becomes one when it is released.
+				if order, err := h.service.GetTicket(c, event.OrderID); err == nil {
+					c.SSEvent("ticket", order.ToOrderResponse())
+				}
+			case events.ORDER_PREPARING, events.ORDER_READY, events.ORDER_CANCELLED,
+				events.ORDER_ITEM_STARTED, events.ORDER_ITEM_DONE:
+				c.SSEvent(event.Type, event)
+			}
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", "")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// LockoutHandler represents the HTTP handler for login lockouts
+type LockoutHandler struct {
+	service  *services.LockoutService
+	validate *validator.Validate
+}
+
+// NewLockoutHandler creates a new LockoutHandler instance
+func NewLockoutHandler(svc *services.LockoutService, vld *validator.Validate) *LockoutHandler {
+	return &LockoutHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// UnlockUser handles clearing the failed logins and lockout of a user
+func (h *LockoutHandler) UnlockUser(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid user ID", Data: nil})
+		return
+	}
+
+	if err := h.service.UnlockUser(c, principal, id); err != nil {
+		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "User unlocked successfully", Data: nil})
+}
+
+// UnlockIP handles clearing the failed logins and lockout of a client IP
+func (h *LockoutHandler) UnlockIP(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.UnlockIPRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.service.UnlockIP(c, principal, req.IP); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "IP address unlocked successfully", Data: nil})
+}
+
+// ListLockouts handles listing the audit log of lockouts and unlocks
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	logs, err := h.service.ListLockouts(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Lockouts retrieved successfully", Data: logs})
+}
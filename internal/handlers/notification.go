@@ -0,0 +1,70 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// NotificationHandler represents the HTTP handler for notification preferences
+type NotificationHandler struct {
+	service  *services.NotificationService
+	validate *validator.Validate
+}
+
+// NewNotificationHandler creates a new NotificationHandler instance
+func NewNotificationHandler(svc *services.NotificationService, vld *validator.Validate) *NotificationHandler {
+	return &NotificationHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// GetPreferences handles fetching the logged in user's notification preferences
+func (h *NotificationHandler) GetPreferences(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	prefs, err := h.service.GetPreferences(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Notification preferences retrieved successfully", Data: prefs})
+}
+
+// UpdatePreferences handles changing the logged in user's notification
+// channels and quiet hours
+func (h *NotificationHandler) UpdatePreferences(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.NotificationPreferences
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	prefs, err := h.service.UpdatePreferences(c, userId, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Notification preferences updated successfully", Data: prefs})
+}
@@ -1,11 +1,14 @@
 package handlers
 
 import (
+	"io"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/dgrijalva/jwt-go"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/events"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
 	"github.com/go-playground/validator/v10"
 
@@ -15,13 +18,15 @@ import (
 // OrderHandler represents the HTTP handler for order-related requests
 type OrderHandler struct {
 	service  *services.OrderService
+	broker   *events.Broker
 	validate *validator.Validate
 }
 
 // NewOrderHandler creates a new OrderHandler instance
-func NewOrderHandler(svc *services.OrderService, vld *validator.Validate) *OrderHandler {
+func NewOrderHandler(svc *services.OrderService, broker *events.Broker, vld *validator.Validate) *OrderHandler {
 	return &OrderHandler{
 		svc,
+		broker,
 		vld,
 	}
 }
@@ -63,15 +68,22 @@ func (h *OrderHandler) CreateOrder(c *gin.Context) {
 
 // GetOrder handles fetching a single order by ID
 func (h *OrderHandler) GetOrder(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid order ID", Data: nil})
 		return
 	}
 
-	order, err := h.service.GetOrder(c, uint(id))
+	order, err := h.service.GetOrderFor(c, principal, id)
 	if err != nil {
-		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: err.Error(), Data: nil})
+		status, message := orderError(err, http.StatusNotFound)
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
 		return
 	}
 
@@ -132,17 +144,80 @@ func (h *OrderHandler) UpdateOrder(c *gin.Context) {
 
 // CancelOrder handles canceling an order by ID
 func (h *OrderHandler) CancelOrder(c *gin.Context) {
-	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
 	if err != nil {
 		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid order ID", Data: nil})
 		return
 	}
 
-	order, err := h.service.CancelOrder(c, uint(id))
+	order, err := h.service.CancelOrder(c, principal, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		status, message := orderError(err, http.StatusInternalServerError)
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Order canceled successfully", Data: order})
 }
+
+// StreamOrderEvents handles streaming an order's status changes as Server-Sent Events
+func (h *OrderHandler) StreamOrderEvents(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid order ID", Data: nil})
+		return
+	}
+
+	order, err := h.service.GetOrderFor(c, principal, id)
+	if err != nil {
+		status, message := orderError(err, http.StatusNotFound)
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	// Subscribe before sending the current status so no change is missed in between
+	sub := h.broker.Subscribe(order.Id)
+	defer sub.Close()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	c.SSEvent("status", events.Event{
+		Type:       "status",
+		OrderID:    order.Id,
+		UserID:     order.UserID,
+		Status:     order.Status,
+		OccurredAt: order.UpdatedAt,
+	})
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(15 * time.Second)
+	defer heartbeat.Stop()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				return false
+			}
+			c.SSEvent(event.Type, event)
+			return true
+		case <-heartbeat.C:
+			c.SSEvent("ping", "")
+			return true
+		case <-c.Request.Context().Done():
+			return false
+		}
+	})
+}
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/events"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/platform"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// testUserHeader carries the id of the caller in place of a signed token.
+const testUserHeader = "X-Test-User"
+
+func newOrderTestRouter(t *testing.T, db *gorm.DB) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	orderRepo := repository.NewOrderRepository(db)
+	userRepo := repository.NewUserRepository(db)
+	reserveRepo := repository.NewReservationRepository(db)
+	orderService := services.NewOrderService(orderRepo, userRepo, repository.NewCoffeeRepository(db), reserveRepo,
+		repository.NewCouponRepository(db), repository.NewAddressRepository(db), repository.NewStoreRepository(db))
+	trxService, err := services.NewTransactionService(platform.PAYSTACK.String(), orderRepo, userRepo, reserveRepo,
+		repository.NewTransactionRepository(db), repository.NewPaymentMethodRepository(db))
+	require.NoError(t, err)
+
+	subService := services.NewSubscriptionService(repository.NewSubscriptionRepository(db), orderService, trxService)
+	addressService := services.NewAddressService(repository.NewAddressRepository(db))
+
+	validate := util.NewValidator()
+	orderHandler := NewOrderHandler(orderService, events.NewBroker(), validate)
+	trxHandler := NewTransactionHandler(trxService, validate)
+	subHandler := NewSubscriptionHandler(subService, validate)
+	addressHandler := NewAddressHandler(addressService, validate)
+
+	router := gin.New()
+	// Stands in for the auth middleware
+	router.Use(func(c *gin.Context) {
+		c.Set("claims", jwt.MapClaims{"user_id": c.GetHeader(testUserHeader), "role": models.ROLE_USER})
+	})
+	router.GET("/orders/:id", orderHandler.GetOrder)
+	router.PATCH("/orders/:id/cancel", orderHandler.CancelOrder)
+	router.POST("/orders/pay", trxHandler.InitiatePayment)
+	router.DELETE("/me/payment-methods/:id", trxHandler.DeletePaymentMethod)
+	router.GET("/me/addresses/:id", addressHandler.GetAddress)
+	router.PUT("/me/addresses/:id", addressHandler.UpdateAddress)
+	router.DELETE("/me/addresses/:id", addressHandler.DeleteAddress)
+	router.GET("/subscriptions/:id", subHandler.GetSubscription)
+	router.PATCH("/subscriptions/:id/pause", subHandler.PauseSubscription)
+	router.PATCH("/subscriptions/:id/cancel", subHandler.CancelSubscription)
+	return router
+}
+
+func createTestUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+
+	user := &models.User{
+		FirstName: name,
+		LastName:  "Test",
+		Email:     name + "@example.com",
+		Password:  "!",
+		Role:      models.ROLE_USER,
+		CreatedAt: util.CurrentTime(),
+		UpdatedAt: util.CurrentTime(),
+	}
+	require.NoError(t, db.Create(user).Error)
+	return user
+}
+
+func serve(router *gin.Engine, userId uint, method, path, body string) (int, models.Response) {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(testUserHeader, strconv.FormatUint(uint64(userId), 10))
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+
+	var resp models.Response
+	_ = json.Unmarshal(rec.Body.Bytes(), &resp)
+	return rec.Code, resp
+}
+
+func TestOrderEndpointsHideOtherUsersOrders(t *testing.T) {
+	db := dbtest.Open(t)
+	router := newOrderTestRouter(t, db)
+
+	owner := createTestUser(t, db, "ordera")
+	other := createTestUser(t, db, "orderb")
+
+	order := &models.Order{
+		UserID:         owner.Id,
+		Status:         models.ORDER_STATUS_PENDING,
+		Currency:       "NGN",
+		TotalAmount:    "2500.00",
+		FulfilmentType: models.FULFILMENT_PICKUP,
+		CreatedAt:      util.CurrentTime(),
+		UpdatedAt:      util.CurrentTime(),
+	}
+	require.NoError(t, db.Create(order).Error)
+	orderPath := fmt.Sprintf("/orders/%d", order.Id)
+
+	status, _ := serve(router, owner.Id, http.MethodGet, orderPath, "")
+	require.Equal(t, http.StatusOK, status)
+
+	// Another user's order looks exactly like one that does not exist
+	status, missing := serve(router, other.Id, http.MethodGet, "/orders/999999", "")
+	require.Equal(t, http.StatusNotFound, status)
+
+	t.Run("read", func(t *testing.T) {
+		status, resp := serve(router, other.Id, http.MethodGet, orderPath, "")
+		require.Equal(t, http.StatusNotFound, status)
+		require.Equal(t, missing.Message, resp.Message)
+	})
+
+	t.Run("cancel", func(t *testing.T) {
+		status, resp := serve(router, other.Id, http.MethodPatch, orderPath+"/cancel", "")
+		require.Equal(t, http.StatusNotFound, status)
+		require.Equal(t, missing.Message, resp.Message)
+
+		var stored models.Order
+		require.NoError(t, db.First(&stored, order.Id).Error)
+		require.Equal(t, models.ORDER_STATUS_PENDING, stored.Status)
+	})
+
+	t.Run("pay", func(t *testing.T) {
+		body := fmt.Sprintf(`{"order_id": %d}`, order.Id)
+		status, resp := serve(router, other.Id, http.MethodPost, "/orders/pay", body)
+		require.Equal(t, http.StatusNotFound, status)
+		require.Equal(t, missing.Message, resp.Message)
+
+		var count int64
+		require.NoError(t, db.Model(&models.Transaction{}).Where("order_id = ?", order.Id).Count(&count).Error)
+		require.Zero(t, count)
+	})
+}
+
+func TestAccountEndpointsHideOtherUsersResources(t *testing.T) {
+	db := dbtest.Open(t)
+	router := newOrderTestRouter(t, db)
+
+	owner := createTestUser(t, db, "accounta")
+	other := createTestUser(t, db, "accountb")
+	now := util.CurrentTime()
+
+	address := &models.Address{UserID: owner.Id, Line1: "1 Marina", City: "Lagos", Country: "NG", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(address).Error)
+	pm := &models.PaymentMethod{UserID: owner.Id, Provider: platform.PAYSTACK.String(), AuthorizationCode: "AUTH_owner", Last4: "4081", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(pm).Error)
+	sub := &models.Subscription{UserID: owner.Id, Status: models.SUBSCRIPTION_ACTIVE, IntervalDays: 7, NextRunAt: now.Add(time.Hour), CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(sub).Error)
+
+	addressPath := fmt.Sprintf("/me/addresses/%d", address.Id)
+	subPath := fmt.Sprintf("/subscriptions/%d", sub.Id)
+
+	status, _ := serve(router, owner.Id, http.MethodGet, addressPath, "")
+	require.Equal(t, http.StatusOK, status)
+	status, _ = serve(router, owner.Id, http.MethodGet, subPath, "")
+	require.Equal(t, http.StatusOK, status)
+
+	tests := []struct {
+		name, method, path, body, missingPath string
+	}{
+		{"read address", http.MethodGet, addressPath, "", "/me/addresses/999999"},
+		{"update address", http.MethodPut, addressPath, `{"line1": "2 Marina", "city": "Lagos", "country": "NG"}`, "/me/addresses/999999"},
+		{"delete address", http.MethodDelete, addressPath, "", "/me/addresses/999999"},
+		{"delete card", http.MethodDelete, fmt.Sprintf("/me/payment-methods/%d", pm.Id), "", "/me/payment-methods/999999"},
+		{"read subscription", http.MethodGet, subPath, "", "/subscriptions/999999"},
+		{"pause subscription", http.MethodPatch, subPath + "/pause", "", "/subscriptions/999999/pause"},
+		{"cancel subscription", http.MethodPatch, subPath + "/cancel", "", "/subscriptions/999999/cancel"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// Another user's resource looks exactly like one that does not exist
+			_, missing := serve(router, other.Id, tt.method, tt.missingPath, tt.body)
+			status, resp := serve(router, other.Id, tt.method, tt.path, tt.body)
+			require.Equal(t, http.StatusNotFound, status)
+			require.Equal(t, missing.Message, resp.Message)
+		})
+	}
+
+	var storedAddress models.Address
+	require.NoError(t, db.First(&storedAddress, address.Id).Error)
+	require.Equal(t, "1 Marina", storedAddress.Line1)
+	require.NoError(t, db.First(&models.PaymentMethod{}, pm.Id).Error)
+	var storedSub models.Subscription
+	require.NoError(t, db.First(&storedSub, sub.Id).Error)
+	require.Equal(t, models.SUBSCRIPTION_ACTIVE, storedSub.Status)
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// PrivacyHandler represents the HTTP handler for data export and account
+// deletion requests
+type PrivacyHandler struct {
+	service  *services.PrivacyService
+	validate *validator.Validate
+}
+
+// NewPrivacyHandler creates a new PrivacyHandler instance
+func NewPrivacyHandler(svc *services.PrivacyService, vld *validator.Validate) *PrivacyHandler {
+	return &PrivacyHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// Export handles downloading an archive of the logged in user's data
+func (h *PrivacyHandler) Export(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var archive bytes.Buffer
+	if err := h.service.Export(c, userId, &archive); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="export-user-%d.zip"`, userId))
+	c.Data(http.StatusOK, "application/zip", archive.Bytes())
+}
+
+// DeleteAccount handles deleting the logged in user's account
+func (h *PrivacyHandler) DeleteAccount(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.DeleteAccountRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.service.DeleteAccount(c, userId, req.Confirmation); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Your account has been deleted", Data: nil})
+}
@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// RoleHandler represents the HTTP handler for roles and permissions
+type RoleHandler struct {
+	service  *services.RBACService
+	validate *validator.Validate
+}
+
+// NewRoleHandler creates a new RoleHandler instance
+func NewRoleHandler(svc *services.RBACService, vld *validator.Validate) *RoleHandler {
+	return &RoleHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// ListRoles handles fetching all roles with their permissions
+func (h *RoleHandler) ListRoles(c *gin.Context) {
+	roles, err := h.service.ListRoles(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Roles retrieved successfully", Data: roles})
+}
+
+// GetRole handles fetching a single role by name
+func (h *RoleHandler) GetRole(c *gin.Context) {
+	role, err := h.service.GetRole(c, c.Param("name"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Role retrieved successfully", Data: role})
+}
+
+// CreateRole handles creating a custom role
+func (h *RoleHandler) CreateRole(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.CreateRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	role, err := h.service.CreateRole(c, principal, &req)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Role created successfully", Data: role})
+}
+
+// UpdateRolePermissions handles replacing the permissions of a role
+func (h *RoleHandler) UpdateRolePermissions(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.UpdateRolePermissionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	role, err := h.service.UpdateRolePermissions(c, principal, c.Param("name"), req.Permissions)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Role permissions updated successfully", Data: role})
+}
+
+// ListPermissions handles fetching every permission that can be granted
+func (h *RoleHandler) ListPermissions(c *gin.Context) {
+	permissions, err := h.service.ListPermissions(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Permissions retrieved successfully", Data: permissions})
+}
@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// SocialLoginHandler represents the HTTP handler for signing in with Google
+// or Apple
+type SocialLoginHandler struct {
+	service  *services.SocialLoginService
+	validate *validator.Validate
+}
+
+// NewSocialLoginHandler creates a new SocialLoginHandler instance
+func NewSocialLoginHandler(svc *services.SocialLoginService, vld *validator.Validate) *SocialLoginHandler {
+	return &SocialLoginHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// Start handles beginning a login with a provider. The client sends the
+// user to the returned authorization URL.
+func (h *SocialLoginHandler) Start(c *gin.Context) {
+	start, err := h.service.Start(c, c.Param("provider"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownProvider) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Redirect the user to the authorization URL", Data: start})
+}
+
+// Callback handles finishing a login with the code and state the provider
+// redirected back with. They are accepted as query parameters, a form post
+// or JSON. Users with two-factor authentication get a challenge for
+// POST /login/2fa instead of tokens.
+func (h *SocialLoginHandler) Callback(c *gin.Context) {
+	var req models.SocialLoginCallback
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	tokens, challenge, err := h.service.Callback(c, c.Param("provider"), &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidLoginState), errors.Is(err, services.ErrSocialLoginFailed):
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if challenge != nil {
+		c.JSON(http.StatusOK, models.Response{Status: true, Message: "Two-factor authentication required", Data: challenge})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Login successful", Data: tokens})
+}
+
+// ListIdentities handles listing the providers linked to the logged in user
+func (h *SocialLoginHandler) ListIdentities(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	identities, err := h.service.ListIdentities(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Linked accounts retrieved successfully", Data: identities})
+}
+
+// StartReauth handles beginning to sign the logged in user in again with a
+// provider, to confirm a change such as deleting their account. The client
+// sends the user to the returned authorization URL.
+func (h *SocialLoginHandler) StartReauth(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	start, err := h.service.StartReauth(c, userId, c.Param("provider"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrUnknownProvider) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Redirect the user to the authorization URL", Data: start})
+}
+
+// FinishReauth handles finishing signing the logged in user in again with
+// the code and state the provider redirected back with. The reauth token
+// returned confirms one change.
+func (h *SocialLoginHandler) FinishReauth(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.SocialLoginCallback
+	if err := c.ShouldBind(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	reauth, err := h.service.FinishReauth(c, userId, c.Param("provider"), &req)
+	if err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, services.ErrUnknownProvider):
+			status = http.StatusNotFound
+		case errors.Is(err, services.ErrInvalidLoginState), errors.Is(err, services.ErrSocialLoginFailed):
+			status = http.StatusUnauthorized
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Signed in again successfully", Data: reauth})
+}
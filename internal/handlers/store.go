@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// StoreHandler represents the HTTP handler for stores and their delivery zones
+type StoreHandler struct {
+	service  *services.StoreService
+	validate *validator.Validate
+}
+
+// NewStoreHandler creates a new StoreHandler instance
+func NewStoreHandler(svc *services.StoreService, vld *validator.Validate) *StoreHandler {
+	return &StoreHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// CreateStore handles the creation of a new store
+func (h *StoreHandler) CreateStore(c *gin.Context) {
+	var req models.CreateStore
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	store, err := h.service.CreateStore(c, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Store created successfully", Data: store})
+}
+
+// ListStores handles fetching all stores with their delivery zones
+func (h *StoreHandler) ListStores(c *gin.Context) {
+	stores, err := h.service.ListStores(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Stores retrieved successfully", Data: stores})
+}
+
+// GetStore handles fetching a single store by ID
+func (h *StoreHandler) GetStore(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid store ID", Data: nil})
+		return
+	}
+
+	store, err := h.service.GetStore(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: "Store not found", Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Store retrieved successfully", Data: store})
+}
+
+// CreateZone handles adding a delivery zone to a store
+func (h *StoreHandler) CreateZone(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid store ID", Data: nil})
+		return
+	}
+
+	var req models.CreateDeliveryZone
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	zone, err := h.service.CreateZone(c, id, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Delivery zone created successfully", Data: zone})
+}
+
+// DeleteZone handles removing a delivery zone from a store
+func (h *StoreHandler) DeleteZone(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid store ID", Data: nil})
+		return
+	}
+
+	zoneId, err := strconv.ParseUint(c.Param("zoneId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid zone ID", Data: nil})
+		return
+	}
+
+	if err := h.service.DeleteZone(c, id, uint(zoneId)); err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, services.ErrZoneNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Delivery zone deleted successfully", Data: nil})
+}
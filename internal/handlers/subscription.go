@@ -0,0 +1,121 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// SubscriptionHandler represents the HTTP handler for recurring subscriptions
+type SubscriptionHandler struct {
+	service  *services.SubscriptionService
+	validate *validator.Validate
+}
+
+// NewSubscriptionHandler creates a new SubscriptionHandler instance
+func NewSubscriptionHandler(svc *services.SubscriptionService, vld *validator.Validate) *SubscriptionHandler {
+	return &SubscriptionHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// CreateSubscription handles subscribing to a recurring order
+func (h *SubscriptionHandler) CreateSubscription(c *gin.Context) {
+	var req models.CreateSubscriptionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	resp, err := h.service.CreateSubscription(c, userId, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Subscription created successfully, complete the first payment to activate it", Data: resp})
+}
+
+// ListSubscriptions handles fetching the subscriptions of the current user
+func (h *SubscriptionHandler) ListSubscriptions(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	subs, err := h.service.ListUserSubscriptions(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Subscriptions fetched successfully", Data: subs})
+}
+
+// GetSubscription handles fetching a single subscription by ID
+func (h *SubscriptionHandler) GetSubscription(c *gin.Context) {
+	h.act(c, "Subscription fetched successfully", h.service.GetSubscription)
+}
+
+// PauseSubscription handles pausing a subscription
+func (h *SubscriptionHandler) PauseSubscription(c *gin.Context) {
+	h.act(c, "Subscription paused successfully", h.service.PauseSubscription)
+}
+
+// ResumeSubscription handles resuming a paused subscription
+func (h *SubscriptionHandler) ResumeSubscription(c *gin.Context) {
+	h.act(c, "Subscription resumed successfully", h.service.ResumeSubscription)
+}
+
+// SkipSubscription handles skipping the next delivery of a subscription
+func (h *SubscriptionHandler) SkipSubscription(c *gin.Context) {
+	h.act(c, "Next delivery skipped successfully", h.service.SkipNextDelivery)
+}
+
+// CancelSubscription handles canceling a subscription
+func (h *SubscriptionHandler) CancelSubscription(c *gin.Context) {
+	h.act(c, "Subscription canceled successfully", h.service.CancelSubscription)
+}
+
+// act runs a service action on the subscription identified by the ":id"
+// path parameter on behalf of the current user.
+func (h *SubscriptionHandler) act(c *gin.Context, successMsg string, action func(ctx context.Context, p authz.Principal, id uint) (*models.Subscription, error)) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid subscription ID", Data: nil})
+		return
+	}
+
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	sub, err := action(c, principal, id)
+	if err != nil {
+		status, message := resourceError(err, http.StatusBadRequest, "subscription not found")
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: successMsg, Data: sub})
+}
@@ -1,7 +1,7 @@
 package handlers
 
 import (
-	"fmt"
+	"errors"
 	"io"
 	"net/http"
 	"strconv"
@@ -11,6 +11,7 @@ import (
 	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
+	"github.com/sirupsen/logrus"
 )
 
 type TransactionHandler struct {
@@ -48,23 +49,74 @@ func (h *TransactionHandler) InitiatePayment(c *gin.Context) {
 
 	trx, err := h.service.Initiate(c, uint(id), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		status, message := orderError(err, http.StatusInternalServerError)
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
 		return
 	}
 
 	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Transaction created successfully", Data: trx})
 }
 
+// ListPaymentMethods handles fetching the saved payment methods of the current user
+func (h *TransactionHandler) ListPaymentMethods(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	pms, err := h.service.ListPaymentMethods(c, userId)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Payment methods fetched successfully", Data: pms})
+}
+
+// DeletePaymentMethod handles removing a saved payment method of the current user
+func (h *TransactionHandler) DeletePaymentMethod(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid payment method ID", Data: nil})
+		return
+	}
+
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.service.DeletePaymentMethod(c, principal, id); err != nil {
+		status, message := resourceError(err, http.StatusInternalServerError, "payment method not found")
+		c.JSON(status, models.Response{Status: false, Message: message, Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Payment method deleted successfully", Data: nil})
+}
+
 func (h *TransactionHandler) HandlePaystackWebhook(c *gin.Context) {
 	body, err := io.ReadAll(c.Request.Body)
 	if err != nil {
-		fmt.Println(err)
+		logrus.Error("Error reading webhook body: ", err)
 		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "could not read request body", Data: nil})
 		return
 	}
 
-	fmt.Printf("%+v\n", string(body))
-
 	// Verify and process Paystack webhook
+	err = h.service.HandleWebhook(c, body, c.GetHeader("x-paystack-signature"))
+	if err != nil {
+		if errors.Is(err, services.ErrInvalidWebhookSignature) {
+			c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+			return
+		}
+
+		logrus.Error("Error processing webhook: ", err)
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: "could not process webhook", Data: nil})
+		return
+	}
+
 	c.JSON(http.StatusOK, models.Response{Status: true, Message: "webhook received successfully", Data: nil})
 }
@@ -36,7 +36,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 
 	retUser, err := h.UserService.CreateUser(c, &user)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		c.JSON(errorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
 		return
 	}
 
@@ -90,6 +90,12 @@ func (h *UserHandler) UpdateUser(c *gin.Context) {
 }
 
 func (h *UserHandler) DeleteUser(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
 	id := c.Param("id")
 
 	idInt, err := strconv.Atoi(id)
@@ -98,8 +104,8 @@ func (h *UserHandler) DeleteUser(c *gin.Context) {
 		return
 	}
 
-	if err := h.UserService.DeleteUser(c, uint(idInt)); err != nil {
-		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+	if err := h.UserService.DeleteUser(c, principal, uint(idInt)); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
 		return
 	}
 
@@ -116,23 +122,245 @@ func (h *UserHandler) ListUsers(c *gin.Context) {
 	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Users retrieved successfully", Data: users})
 }
 
-func (h *UserHandler) Login(c *gin.Context) {
-	var credentials models.LoginRequest
-	if err := c.ShouldBindJSON(&credentials); err != nil {
+// CreateStaffUser handles creating an account with a role other than user
+func (h *UserHandler) CreateStaffUser(c *gin.Context) {
+	principal, err := getPrincipal(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var user models.CreateUser
+	if err := c.ShouldBindJSON(&user); err != nil {
 		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
 		return
 	}
 
-	if err := h.Validator.Struct(credentials); err != nil {
+	if err := h.Validator.Struct(user); err != nil {
 		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
 		return
 	}
 
-	token, err := h.UserService.Login(c, credentials.Email, credentials.Password)
+	retUser, err := h.UserService.CreateStaffUser(c, principal, &user)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "User created successfully", Data: retUser})
+}
+
+// AssignRole handles changing the role of a user
+func (h *UserHandler) AssignRole(c *gin.Context) {
+	principal, err := getPrincipal(c)
 	if err != nil {
 		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
 		return
 	}
 
-	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Login successful", Data: gin.H{"token": token}})
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid user ID", Data: nil})
+		return
+	}
+
+	var req models.AssignRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	user, err := h.UserService.AssignRole(c, principal, id, req.Role)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "User role updated successfully", Data: user})
+}
+
+// VerifyEmail handles redeeming an email verification token
+func (h *UserHandler) VerifyEmail(c *gin.Context) {
+	var req models.VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	user, err := h.UserService.VerifyEmail(c, req.Token)
+	if err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Email verified successfully", Data: user})
+}
+
+// ResendVerification handles emailing the logged in user a new verification
+// link
+func (h *UserHandler) ResendVerification(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.UserService.ResendVerification(c, userId); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Verification email sent", Data: nil})
+}
+
+// ForgotPassword handles requesting a password reset link
+func (h *UserHandler) ForgotPassword(c *gin.Context) {
+	var req models.ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.UserService.ForgotPassword(c, req.Email, c.ClientIP()); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "If an account exists for this email, a password reset link has been sent", Data: nil})
+}
+
+// ResetPassword handles setting a new password with a password reset token
+func (h *UserHandler) ResetPassword(c *gin.Context) {
+	var req models.ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.UserService.ResetPassword(c, req.Token, req.Password); err != nil {
+		c.JSON(errorStatus(err, http.StatusInternalServerError), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Password reset successfully. Please log in again", Data: nil})
+}
+
+// GetMe handles getting the logged in user's profile
+func (h *UserHandler) GetMe(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	user, err := h.UserService.GetUserByID(c, userId)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Profile retrieved successfully", Data: user})
+}
+
+// UpdateMe handles changing the logged in user's profile
+func (h *UserHandler) UpdateMe(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.ProfileUpdate
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	user, err := h.UserService.UpdateProfile(c, userId, &req)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Profile updated successfully", Data: user})
+}
+
+// ChangePassword handles changing the logged in user's password
+func (h *UserHandler) ChangePassword(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.ChangePasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.UserService.ChangePassword(c, userId, getSessionIdFromClaims(c), &req); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Password changed successfully. Your other sessions have been signed out", Data: nil})
+}
+
+// ChangeEmail handles sending a verification link to the address the logged
+// in user wants to change to
+func (h *UserHandler) ChangeEmail(c *gin.Context) {
+	userId, err := getUserIdFromClaims(c)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	var req models.ChangeEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.Validator.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.UserService.ChangeEmail(c, userId, &req); err != nil {
+		c.JSON(errorStatus(err, http.StatusBadRequest), models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, models.Response{Status: true, Message: "A verification link has been sent to your new email address. Your email will change once it is verified", Data: nil})
 }
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+const testPassword = "roasted-beans-42"
+
+// recordingMailer keeps the addresses it is asked to email.
+type recordingMailer struct {
+	verified []string
+	changed  []string
+}
+
+func (m *recordingMailer) SendEmailVerification(ctx context.Context, user *models.User, email, verifyURL string, expiresIn time.Duration) error {
+	m.verified = append(m.verified, email)
+	return nil
+}
+
+func (m *recordingMailer) SendPasswordReset(ctx context.Context, user *models.User, resetURL string, expiresIn time.Duration) error {
+	return nil
+}
+
+func (m *recordingMailer) SendEmailChanged(ctx context.Context, user *models.User, oldEmail string) error {
+	m.changed = append(m.changed, oldEmail)
+	return nil
+}
+
+func newUserTestRouter(t *testing.T, db *gorm.DB, mailer services.AccountMailer) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	lockoutService := services.NewLockoutService(repository.NewLockoutRepository(db), userRepo)
+	twoFactorService := services.NewTwoFactorService(userRepo, repository.NewTwoFactorRepository(db))
+	authService := services.NewAuthService(userRepo, tokenRepo, twoFactorService, lockoutService)
+	userService := services.NewUserService(userRepo, tokenRepo, nil, authService, lockoutService, mailer)
+	userHandler := NewUserHandler(userService, util.NewValidator())
+
+	router := gin.New()
+	// Stands in for the auth middleware
+	router.Use(func(c *gin.Context) {
+		c.Set("claims", jwt.MapClaims{"user_id": c.GetHeader(testUserHeader), "role": models.ROLE_USER, "sid": "test-session"})
+	})
+	router.GET("/me", userHandler.GetMe)
+	router.PATCH("/me", userHandler.UpdateMe)
+	router.POST("/me/password", userHandler.ChangePassword)
+	router.POST("/me/email", userHandler.ChangeEmail)
+	return router
+}
+
+// createUserWithPassword stores a customer who signs in with testPassword.
+func createUserWithPassword(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(testPassword), bcrypt.MinCost)
+	require.NoError(t, err)
+
+	user := createTestUser(t, db, name)
+	user.Password = string(hash)
+	require.NoError(t, db.Save(user).Error)
+	return user
+}
+
+func TestMeEndpoints(t *testing.T) {
+	db := dbtest.Open(t)
+	mailer := &recordingMailer{}
+	router := newUserTestRouter(t, db, mailer)
+
+	t.Run("profile", func(t *testing.T) {
+		user := createUserWithPassword(t, db, "meprofile")
+
+		status, resp := serve(router, user.Id, http.MethodGet, "/me", "")
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, user.Email, resp.Data.(map[string]any)["email"])
+		require.NotContains(t, resp.Data, "password")
+
+		status, _ = serve(router, user.Id, http.MethodPatch, "/me", `{"first_name": "Renamed"}`)
+		require.Equal(t, http.StatusOK, status)
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.Id).Error)
+		require.Equal(t, "Renamed", stored.FirstName)
+	})
+
+	t.Run("password", func(t *testing.T) {
+		user := createUserWithPassword(t, db, "mepassword")
+
+		status, _ := serve(router, user.Id, http.MethodPost, "/me/password", `{"current_password": "wrong-password-1", "new_password": "ground-beans-77"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+
+		status, _ = serve(router, user.Id, http.MethodPost, "/me/password", `{"current_password": "`+testPassword+`", "new_password": "ground-beans-77"}`)
+		require.Equal(t, http.StatusOK, status)
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.Id).Error)
+		require.NoError(t, bcrypt.CompareHashAndPassword([]byte(stored.Password), []byte("ground-beans-77")))
+	})
+
+	t.Run("email", func(t *testing.T) {
+		user := createUserWithPassword(t, db, "meemail")
+
+		status, _ := serve(router, user.Id, http.MethodPost, "/me/email", `{"email": "meemail@new.example.com", "password": "wrong-password-1"}`)
+		require.Equal(t, http.StatusBadRequest, status)
+		require.Empty(t, mailer.verified)
+
+		status, _ = serve(router, user.Id, http.MethodPost, "/me/email", `{"email": "meemail@new.example.com", "password": "`+testPassword+`"}`)
+		require.Equal(t, http.StatusOK, status)
+		require.Equal(t, []string{"meemail@new.example.com"}, mailer.verified)
+
+		// The email only changes once the new address is verified
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.Id).Error)
+		require.Equal(t, user.Email, stored.Email)
+	})
+}
@@ -0,0 +1,159 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+)
+
+// WebhookHandler represents the HTTP handler for outbound webhook endpoints
+type WebhookHandler struct {
+	service  *services.WebhookService
+	validate *validator.Validate
+}
+
+// NewWebhookHandler creates a new WebhookHandler instance
+func NewWebhookHandler(svc *services.WebhookService, vld *validator.Validate) *WebhookHandler {
+	return &WebhookHandler{
+		service:  svc,
+		validate: vld,
+	}
+}
+
+// CreateEndpoint handles registering a new webhook endpoint
+func (h *WebhookHandler) CreateEndpoint(c *gin.Context) {
+	var req models.CreateWebhookEndpoint
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	endpoint, err := h.service.CreateEndpoint(c, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusCreated, models.Response{Status: true, Message: "Webhook endpoint created successfully", Data: endpoint})
+}
+
+// ListEndpoints handles fetching all webhook endpoints
+func (h *WebhookHandler) ListEndpoints(c *gin.Context) {
+	endpoints, err := h.service.ListEndpoints(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Webhook endpoints retrieved successfully", Data: endpoints})
+}
+
+// GetEndpoint handles fetching a single webhook endpoint by ID
+func (h *WebhookHandler) GetEndpoint(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid webhook endpoint ID", Data: nil})
+		return
+	}
+
+	endpoint, err := h.service.GetEndpoint(c, id)
+	if err != nil {
+		c.JSON(http.StatusNotFound, models.Response{Status: false, Message: "Webhook endpoint not found", Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Webhook endpoint retrieved successfully", Data: endpoint})
+}
+
+// UpdateEndpoint handles changing a webhook endpoint's URL, events or active flag
+func (h *WebhookHandler) UpdateEndpoint(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid webhook endpoint ID", Data: nil})
+		return
+	}
+
+	var req models.UpdateWebhookEndpoint
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	if err := h.validate.Struct(req); err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	endpoint, err := h.service.UpdateEndpoint(c, id, &req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Webhook endpoint updated successfully", Data: endpoint})
+}
+
+// DeleteEndpoint handles removing a webhook endpoint and its delivery log
+func (h *WebhookHandler) DeleteEndpoint(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid webhook endpoint ID", Data: nil})
+		return
+	}
+
+	if err := h.service.DeleteEndpoint(c, id); err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Webhook endpoint deleted successfully", Data: nil})
+}
+
+// ListDeliveries handles fetching the delivery log of a webhook endpoint
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid webhook endpoint ID", Data: nil})
+		return
+	}
+
+	deliveries, err := h.service.ListDeliveries(c, id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Webhook deliveries retrieved successfully", Data: deliveries})
+}
+
+// Redeliver handles manually resending a logged webhook delivery
+func (h *WebhookHandler) Redeliver(c *gin.Context) {
+	id, err := getIdParam(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid webhook endpoint ID", Data: nil})
+		return
+	}
+
+	deliveryId, err := strconv.ParseUint(c.Param("deliveryId"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: "Invalid delivery ID", Data: nil})
+		return
+	}
+
+	delivery, err := h.service.Redeliver(c, id, uint(deliveryId))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, models.Response{Status: false, Message: err.Error(), Data: nil})
+		return
+	}
+
+	c.JSON(http.StatusOK, models.Response{Status: true, Message: "Webhook redelivered", Data: delivery})
+}
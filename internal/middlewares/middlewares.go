@@ -1,11 +1,13 @@
 package middlewares
 
 import (
+	"context"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
 	"github.com/gin-gonic/gin"
 	"github.com/sirupsen/logrus"
 )
@@ -46,6 +48,49 @@ func IPWhitelistMiddleware() gin.HandlerFunc {
 	}
 }
 
+// RevocationList reports whether an access token was revoked before it
+// expired.
+type RevocationList interface {
+	IsRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error)
+}
+
+var revocations RevocationList
+
+// UseRevocationList makes the auth middlewares reject tokens on list.
+func UseRevocationList(list RevocationList) {
+	revocations = list
+}
+
+// PermissionStore resolves the permissions granted to a role.
+type PermissionStore interface {
+	RolePermissions(ctx context.Context, role string) ([]string, error)
+}
+
+var permissionStore PermissionStore
+
+// UsePermissions sets where the auth middleware loads role permissions from.
+func UsePermissions(store PermissionStore) {
+	permissionStore = store
+}
+
+// APIKeyStore authenticates API keys, returning the claims and scopes the
+// request is authenticated with. Claims are nil if the key is not valid.
+type APIKeyStore interface {
+	AuthenticateAPIKey(ctx context.Context, key, ip string) (jwt.MapClaims, []string, error)
+}
+
+var apiKeys APIKeyStore
+
+// UseAPIKeys makes the auth middleware accept API keys authenticated by
+// store.
+func UseAPIKeys(store APIKeyStore) {
+	apiKeys = store
+}
+
+// UserAuthMiddleware authenticates the bearer token, rejects revoked tokens
+// and loads the permissions of the caller's role for RequirePermissions.
+// The bearer token may also be an API key, which is granted only the
+// permissions in its scopes.
 func UserAuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		tokenString := getTokenFromHeader(c)
@@ -55,53 +100,173 @@ func UserAuthMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		claims, err := parseToken(tokenString)
-		if err != nil {
-			logrus.Error("Error parsing token: ", err)
-			c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Invalid token", Data: nil})
+		var (
+			claims jwt.MapClaims
+			scopes []string
+			ok     bool
+		)
+		if strings.HasPrefix(tokenString, models.API_KEY_PREFIX) {
+			claims, scopes, ok = authenticateAPIKey(c, tokenString)
+		} else {
+			claims, ok = authenticateToken(c, tokenString)
+		}
+		if !ok {
 			c.Abort()
 			return
 		}
 
-		if role, ok := claims["role"].(string); !ok || (role != "user" && role != "admin") {
-			c.JSON(http.StatusForbidden, Response{Status: false, Message: "Insufficient permissions", Data: nil})
+		role, ok := claims["role"].(string)
+		if !ok || role == "" {
+			c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Invalid token", Data: nil})
 			c.Abort()
 			return
 		}
 
+		var permissions []string
+		if permissionStore != nil {
+			var err error
+			permissions, err = permissionStore.RolePermissions(c, role)
+			if err != nil {
+				logrus.Error("Error loading role permissions: ", err)
+				c.JSON(http.StatusInternalServerError, Response{Status: false, Message: "Could not load permissions", Data: nil})
+				c.Abort()
+				return
+			}
+		}
+
+		if scopes != nil {
+			permissions = intersect(permissions, scopes)
+			c.Set("scopes", scopes)
+		}
+
 		c.Set("claims", claims)
+		c.Set("permissions", permissions)
 		c.Next()
 	}
 }
 
-func AdminAuthMiddleware() gin.HandlerFunc {
+// authenticateToken parses an access token and checks that it was not
+// revoked. It writes the response and returns false if the token is not
+// valid.
+func authenticateToken(c *gin.Context, tokenString string) (jwt.MapClaims, bool) {
+	claims, err := parseToken(tokenString)
+	if err != nil {
+		logrus.Error("Error parsing token: ", err)
+		c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Invalid token", Data: nil})
+		return nil, false
+	}
+
+	if revocations != nil {
+		revoked, err := revocations.IsRevoked(c, claims)
+		if err != nil {
+			logrus.Error("Error checking token revocation: ", err)
+			c.JSON(http.StatusInternalServerError, Response{Status: false, Message: "Could not verify token", Data: nil})
+			return nil, false
+		}
+		if revoked {
+			c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Token has been revoked", Data: nil})
+			return nil, false
+		}
+	}
+
+	return claims, true
+}
+
+// authenticateAPIKey looks up an API key. It writes the response and
+// returns false if the key is not valid.
+func authenticateAPIKey(c *gin.Context, key string) (jwt.MapClaims, []string, bool) {
+	if apiKeys == nil {
+		c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Invalid API key", Data: nil})
+		return nil, nil, false
+	}
+
+	claims, scopes, err := apiKeys.AuthenticateAPIKey(c, key, c.ClientIP())
+	if err != nil {
+		logrus.Error("Error authenticating API key: ", err)
+		c.JSON(http.StatusInternalServerError, Response{Status: false, Message: "Could not verify API key", Data: nil})
+		return nil, nil, false
+	}
+	if claims == nil {
+		c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Invalid API key", Data: nil})
+		return nil, nil, false
+	}
+
+	if scopes == nil {
+		scopes = []string{}
+	}
+	return claims, scopes, true
+}
+
+// RequirePermissions admits only callers whose role grants every one of
+// permissions. It must run after UserAuthMiddleware.
+func RequirePermissions(permissions ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		tokenString := getTokenFromHeader(c)
-		if tokenString == "" {
-			c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Authorization token not provided", Data: nil})
-			c.Abort()
-			return
+		granted := c.GetStringSlice("permissions")
+		for _, p := range permissions {
+			if !hasPermission(granted, p) {
+				c.JSON(http.StatusForbidden, Response{Status: false, Message: "Insufficient permissions", Data: nil})
+				c.Abort()
+				return
+			}
 		}
 
-		claims, err := parseToken(tokenString)
-		if err != nil {
-			logrus.Error("Error parsing token: ", err)
-			c.JSON(http.StatusUnauthorized, Response{Status: false, Message: "Invalid token", Data: nil})
-			c.Abort()
-			return
+		c.Next()
+	}
+}
+
+// RequireScopes admits access tokens, and API keys holding every one of
+// scopes. It must run after UserAuthMiddleware.
+func RequireScopes(scopes ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if granted, isAPIKey := c.Get("scopes"); isAPIKey {
+			for _, scope := range scopes {
+				if !hasPermission(granted.([]string), scope) {
+					c.JSON(http.StatusForbidden, Response{Status: false, Message: "API key is missing the " + scope + " scope", Data: nil})
+					c.Abort()
+					return
+				}
+			}
 		}
 
-		if role, ok := claims["role"].(string); !ok || role != "admin" {
-			c.JSON(http.StatusForbidden, Response{Status: false, Message: "Insufficient permissions", Data: nil})
+		c.Next()
+	}
+}
+
+// RejectAPIKeys admits access tokens only. Changing how a user signs in,
+// ending their sessions or deleting their account must be done by the user,
+// not by a client holding one of their keys. It must run after
+// UserAuthMiddleware.
+func RejectAPIKeys() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, isAPIKey := c.Get("scopes"); isAPIKey {
+			c.JSON(http.StatusForbidden, Response{Status: false, Message: "API keys can not be used for this endpoint", Data: nil})
 			c.Abort()
 			return
 		}
 
-		c.Set("claims", claims)
 		c.Next()
 	}
 }
 
+func intersect(permissions, scopes []string) []string {
+	granted := make([]string, 0, len(scopes))
+	for _, p := range permissions {
+		if hasPermission(scopes, p) {
+			granted = append(granted, p)
+		}
+	}
+	return granted
+}
+
+func hasPermission(granted []string, permission string) bool {
+	for _, p := range granted {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
 func getTokenFromHeader(c *gin.Context) string {
 	authHeader := c.GetHeader("Authorization")
 	if authHeader == "" {
@@ -0,0 +1,154 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/require"
+)
+
+type revokedSessions map[string]bool
+
+func (r revokedSessions) IsRevoked(_ context.Context, claims jwt.MapClaims) (bool, error) {
+	sid, _ := claims["sid"].(string)
+	return r[sid], nil
+}
+
+func signedToken(t *testing.T, sid string) string {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": "1",
+		"role":    "user",
+		"sid":     sid,
+		"exp":     time.Now().Add(time.Minute).Unix(),
+	}).SignedString([]byte("test-secret"))
+	require.NoError(t, err)
+	return token
+}
+
+func TestUserAuthMiddlewareRevocation(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SECRET", "test-secret")
+
+	UseRevocationList(revokedSessions{"stolen": true})
+	defer UseRevocationList(nil)
+
+	router := gin.New()
+	router.GET("/me", UserAuthMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(sid string) int {
+		req := httptest.NewRequest(http.MethodGet, "/me", nil)
+		req.Header.Set("Authorization", "Bearer "+signedToken(t, sid))
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, request("active"))
+	require.Equal(t, http.StatusUnauthorized, request("stolen"))
+}
+
+type rolePermissions map[string][]string
+
+func (r rolePermissions) RolePermissions(_ context.Context, role string) ([]string, error) {
+	return r[role], nil
+}
+
+func TestRequirePermissions(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SECRET", "test-secret")
+
+	UsePermissions(rolePermissions{
+		"support": {"orders:manage", "users:read"},
+		"user":    {},
+	})
+	defer UsePermissions(nil)
+
+	router := gin.New()
+	router.PATCH("/orders/:id", UserAuthMiddleware(), RequirePermissions("orders:manage"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/users", UserAuthMiddleware(), RequirePermissions("users:read", "users:write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method, path, role string) int {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"user_id": "1",
+			"role":    role,
+			"sid":     "session",
+			"exp":     time.Now().Add(time.Minute).Unix(),
+		}).SignedString([]byte("test-secret"))
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, request(http.MethodPatch, "/orders/1", "support"))
+	require.Equal(t, http.StatusForbidden, request(http.MethodPatch, "/orders/1", "user"))
+	require.Equal(t, http.StatusForbidden, request(http.MethodPatch, "/orders/1", "deleted-role"))
+
+	// Every listed permission is required
+	require.Equal(t, http.StatusForbidden, request(http.MethodGet, "/users", "support"))
+}
+
+type apiKeyScopes map[string][]string
+
+func (k apiKeyScopes) AuthenticateAPIKey(_ context.Context, key, _ string) (jwt.MapClaims, []string, error) {
+	scopes, ok := k[key]
+	if !ok {
+		return nil, nil, nil
+	}
+	return jwt.MapClaims{"user_id": "7", "role": "support", "api_key_id": "1"}, scopes, nil
+}
+
+func TestUserAuthMiddlewareAPIKeys(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	t.Setenv("SECRET", "test-secret")
+
+	UsePermissions(rolePermissions{"support": {"orders:manage", "users:read"}})
+	defer UsePermissions(nil)
+	UseAPIKeys(apiKeyScopes{
+		"cdk_pos00001_secret": {"orders:manage", "users:write"},
+		"cdk_partner1_secret": {"customer"},
+		"cdk_noscopes_secret": {},
+	})
+	defer UseAPIKeys(nil)
+
+	router := gin.New()
+	router.PATCH("/orders/:id", UserAuthMiddleware(), RequirePermissions("orders:manage"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/users/staff", UserAuthMiddleware(), RequirePermissions("users:write"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/users", UserAuthMiddleware(), RequirePermissions("users:read"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/orders", UserAuthMiddleware(), RequireScopes("customer"), func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.POST("/me/password", UserAuthMiddleware(), RequireScopes("customer"), RejectAPIKeys(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	request := func(method, path, key string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.Header.Set("Authorization", "Bearer "+key)
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	require.Equal(t, http.StatusOK, request(http.MethodPatch, "/orders/1", "cdk_pos00001_secret"))
+
+	// Keys hold only the permissions in their scopes that the role grants
+	require.Equal(t, http.StatusForbidden, request(http.MethodGet, "/users", "cdk_pos00001_secret"))
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/users/staff", "cdk_pos00001_secret"))
+
+	// Customer endpoints need the customer scope; access tokens always pass
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/orders", "cdk_partner1_secret"))
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/orders", "cdk_pos00001_secret"))
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/orders", "cdk_noscopes_secret"))
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/orders", signedToken(t, "session")))
+
+	require.Equal(t, http.StatusUnauthorized, request(http.MethodPost, "/orders", "cdk_unknown1_secret"))
+
+	// Account security is only for the user themselves
+	require.Equal(t, http.StatusForbidden, request(http.MethodPost, "/me/password", "cdk_partner1_secret"))
+	require.Equal(t, http.StatusOK, request(http.MethodPost, "/me/password", signedToken(t, "session")))
+}
@@ -0,0 +1,68 @@
+package notify
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSMSNotifierSend(t *testing.T) {
+	var auth string
+	var body map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer server.Close()
+
+	n := &SMSNotifier{URL: server.URL, APIKey: "sms-key", Sender: "Coffee"}
+	err := n.Send(context.Background(), Message{To: "+2348000000000", Text: "Your order is ready"})
+	require.NoError(t, err)
+
+	require.Equal(t, "Bearer sms-key", auth)
+	require.Equal(t, map[string]string{"to": "+2348000000000", "from": "Coffee", "message": "Your order is ready"}, body)
+}
+
+func TestPushNotifierSend(t *testing.T) {
+	var auth string
+	var body struct {
+		To           string            `json:"to"`
+		Notification map[string]string `json:"notification"`
+		Data         map[string]string `json:"data"`
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+	}))
+	defer server.Close()
+
+	n := &PushNotifier{URL: server.URL, ServerKey: "server-key"}
+	err := n.Send(context.Background(), Message{
+		To:      "device-token",
+		Subject: "Your order is ready",
+		Text:    "Order #42 is ready for pickup",
+		Data:    map[string]string{"order_id": "42"},
+	})
+	require.NoError(t, err)
+
+	require.Equal(t, "key=server-key", auth)
+	require.Equal(t, "device-token", body.To)
+	require.Equal(t, "Your order is ready", body.Notification["title"])
+	require.Equal(t, "42", body.Data["order_id"])
+}
+
+func TestGatewayErrorStatus(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "invalid number", http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	n := &SMSNotifier{URL: server.URL}
+	err := n.Send(context.Background(), Message{To: "123", Text: "hi"})
+	require.ErrorContains(t, err, "status 400")
+}
@@ -0,0 +1,100 @@
+// Package notify sends messages to users over email and other channels.
+package notify
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Message is a rendered message ready to be sent to one recipient. To is an
+// email address, phone number or device token depending on the channel.
+// Email uses HTML; SMS and push use Text, with Subject as the push title.
+type Message struct {
+	To      string
+	Subject string
+	HTML    string
+	Text    string
+
+	// Data is passed to the mobile app with push messages.
+	Data map[string]string
+
+	// Template and Version identify the template the message was rendered
+	// from, for the notification log.
+	Template string
+	Version  int
+}
+
+// Notifier delivers messages.
+type Notifier interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// FromEnv returns the email notifier configured by the environment: SMTP if
+// SMTP_HOST is set, otherwise files under MAIL_DIR if it is set, otherwise
+// the application log.
+func FromEnv() Notifier {
+	if host := os.Getenv("SMTP_HOST"); host != "" {
+		port, err := strconv.Atoi(os.Getenv("SMTP_PORT"))
+		if err != nil {
+			port = 587
+		}
+
+		return &SMTPNotifier{
+			Host:     host,
+			Port:     port,
+			Username: os.Getenv("SMTP_USERNAME"),
+			Password: os.Getenv("SMTP_PASSWORD"),
+			From:     os.Getenv("MAIL_FROM"),
+		}
+	}
+
+	if dir := os.Getenv("MAIL_DIR"); dir != "" {
+		return &FileNotifier{Dir: dir}
+	}
+
+	return LogNotifier{}
+}
+
+// LogNotifier writes messages to the application log instead of sending
+// them. It is meant for development.
+type LogNotifier struct{}
+
+func (LogNotifier) Send(ctx context.Context, msg Message) error {
+	logrus.WithFields(logrus.Fields{
+		"to":       msg.To,
+		"subject":  msg.Subject,
+		"template": msg.Template,
+		"version":  msg.Version,
+	}).Info("email not sent (log notifier)")
+	return nil
+}
+
+// FileNotifier writes each message to an HTML file in Dir so it can be
+// opened in a browser. It is meant for development.
+type FileNotifier struct {
+	Dir string
+}
+
+func (n *FileNotifier) Send(ctx context.Context, msg Message) error {
+	if err := os.MkdirAll(n.Dir, 0o755); err != nil {
+		return fmt.Errorf("error creating mail directory: %w", err)
+	}
+
+	name := fmt.Sprintf("%s-%s-%s.html",
+		time.Now().UTC().Format("20060102T150405.000000000"),
+		msg.Template,
+		strings.NewReplacer("@", "_at_", "/", "_", "\\", "_").Replace(msg.To))
+
+	content := fmt.Sprintf("<!-- To: %s -->\n<!-- Subject: %s -->\n%s", msg.To, msg.Subject, msg.HTML)
+	if err := os.WriteFile(filepath.Join(n.Dir, name), []byte(content), 0o644); err != nil {
+		return fmt.Errorf("error writing mail file: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package notify
+
+import (
+	"context"
+	"net/http"
+	"os"
+)
+
+const defaultPushURL = "https://fcm.googleapis.com/fcm/send"
+
+// PushNotifier sends mobile push notifications through an FCM-style HTTP
+// API: {"to": <device token>, "notification": {"title", "body"}, "data"}
+// authorised with "key=<server key>".
+type PushNotifier struct {
+	URL       string
+	ServerKey string
+	Client    *http.Client
+}
+
+// PushFromEnv returns the push notifier configured by PUSH_SERVER_KEY and,
+// optionally, PUSH_API_URL, or nil if no server key is configured.
+func PushFromEnv() Notifier {
+	key := os.Getenv("PUSH_SERVER_KEY")
+	if key == "" {
+		return nil
+	}
+
+	url := os.Getenv("PUSH_API_URL")
+	if url == "" {
+		url = defaultPushURL
+	}
+	return &PushNotifier{URL: url, ServerKey: key}
+}
+
+func (n *PushNotifier) Send(ctx context.Context, msg Message) error {
+	return postJSON(ctx, n.Client, n.URL, "key="+n.ServerKey, map[string]any{
+		"to": msg.To,
+		"notification": map[string]string{
+			"title": msg.Subject,
+			"body":  msg.Text,
+		},
+		"data": msg.Data,
+	})
+}
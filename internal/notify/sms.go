@@ -0,0 +1,78 @@
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const gatewayTimeout = 15 * time.Second
+
+// SMSNotifier sends text messages through a generic HTTP SMS gateway. It
+// posts {"to", "from", "message"} as JSON with the API key as a bearer token
+// and treats any 2xx response as accepted.
+type SMSNotifier struct {
+	URL    string
+	APIKey string
+	Sender string
+	Client *http.Client
+}
+
+// SMSFromEnv returns the SMS notifier configured by SMS_GATEWAY_URL,
+// SMS_API_KEY and SMS_SENDER, or nil if no gateway is configured.
+func SMSFromEnv() Notifier {
+	url := os.Getenv("SMS_GATEWAY_URL")
+	if url == "" {
+		return nil
+	}
+
+	return &SMSNotifier{
+		URL:    url,
+		APIKey: os.Getenv("SMS_API_KEY"),
+		Sender: os.Getenv("SMS_SENDER"),
+	}
+}
+
+func (n *SMSNotifier) Send(ctx context.Context, msg Message) error {
+	return postJSON(ctx, n.Client, n.URL, "Bearer "+n.APIKey, map[string]string{
+		"to":      msg.To,
+		"from":    n.Sender,
+		"message": msg.Text,
+	})
+}
+
+// postJSON posts body to url and fails on any non-2xx response.
+func postJSON(ctx context.Context, client *http.Client, url, authorization string, body any) error {
+	if client == nil {
+		client = &http.Client{Timeout: gatewayTimeout}
+	}
+
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", authorization)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("gateway responded with status %d: %s", resp.StatusCode, respBody)
+	}
+	return nil
+}
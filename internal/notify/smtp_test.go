@@ -0,0 +1,116 @@
+package notify
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSMTPServer is a minimal SMTP stand-in that accepts one message and
+// reports the envelope and data it received.
+type fakeSMTPServer struct {
+	listener net.Listener
+	received chan smtpMessage
+}
+
+type smtpMessage struct {
+	from, to, data string
+}
+
+func newFakeSMTPServer(t *testing.T) *fakeSMTPServer {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &fakeSMTPServer{listener: l, received: make(chan smtpMessage, 1)}
+	go s.serve()
+	t.Cleanup(func() { l.Close() })
+	return s
+}
+
+func (s *fakeSMTPServer) addr() (string, int) {
+	a := s.listener.Addr().(*net.TCPAddr)
+	return a.IP.String(), a.Port
+}
+
+func (s *fakeSMTPServer) serve() {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	r := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	var msg smtpMessage
+	reply("220 localhost ESMTP")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		cmd := strings.TrimSpace(line)
+
+		switch {
+		case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+			reply("250 localhost")
+		case strings.HasPrefix(cmd, "MAIL FROM:"):
+			msg.from = strings.Trim(strings.TrimPrefix(cmd, "MAIL FROM:"), "<>")
+			reply("250 OK")
+		case strings.HasPrefix(cmd, "RCPT TO:"):
+			msg.to = strings.Trim(strings.TrimPrefix(cmd, "RCPT TO:"), "<>")
+			reply("250 OK")
+		case cmd == "DATA":
+			reply("354 End data with <CR><LF>.<CR><LF>")
+			var data strings.Builder
+			for {
+				l, err := r.ReadString('\n')
+				if err != nil {
+					return
+				}
+				if l == ".\r\n" {
+					break
+				}
+				data.WriteString(l)
+			}
+			msg.data = data.String()
+			s.received <- msg
+			reply("250 OK")
+		case cmd == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("502 Command not implemented")
+		}
+	}
+}
+
+func TestSMTPNotifierSend(t *testing.T) {
+	server := newFakeSMTPServer(t)
+	host, port := server.addr()
+
+	n := &SMTPNotifier{Host: host, Port: port, From: "orders@coffee.test"}
+	err := n.Send(context.Background(), Message{
+		To:      "ada@example.com",
+		Subject: "Your order #1 has been received",
+		HTML:    "<p>Thanks</p>",
+	})
+	require.NoError(t, err)
+
+	msg := <-server.received
+	require.Equal(t, "orders@coffee.test", msg.from)
+	require.Equal(t, "ada@example.com", msg.to)
+	require.Contains(t, msg.data, "Subject: Your order #1 has been received")
+	require.Contains(t, msg.data, "Content-Type: text/html; charset=utf-8")
+	require.Contains(t, msg.data, "<p>Thanks</p>")
+}
+
+func TestSMTPNotifierRejectsHeaderInjection(t *testing.T) {
+	n := &SMTPNotifier{Host: "127.0.0.1", Port: 1, From: "orders@coffee.test"}
+	err := n.Send(context.Background(), Message{To: "ada@example.com\r\nBcc: eve@example.com"})
+	require.Error(t, err)
+}
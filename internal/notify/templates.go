@@ -0,0 +1,177 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	"html/template"
+	"strings"
+	texttemplate "text/template"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+)
+
+const (
+	TEMPLATE_ORDER_CONFIRMATION = "order_confirmation"
+	TEMPLATE_PAYMENT_RECEIPT    = "payment_receipt"
+	TEMPLATE_ORDER_CANCELLATION = "order_cancellation"
+	TEMPLATE_PASSWORD_RESET     = "password_reset"
+	TEMPLATE_ORDER_READY        = "order_ready"
+	TEMPLATE_EMAIL_VERIFICATION = "email_verification"
+	TEMPLATE_EMAIL_CHANGED      = "email_changed"
+)
+
+// currentVersions is the version of each template used for new messages.
+// Older versions stay in templates/ so that logged messages can be
+// re-rendered exactly as they were sent.
+var currentVersions = map[string]int{
+	TEMPLATE_ORDER_CONFIRMATION: 1,
+	TEMPLATE_PAYMENT_RECEIPT:    1,
+	TEMPLATE_ORDER_CANCELLATION: 1,
+	TEMPLATE_PASSWORD_RESET:     1,
+	TEMPLATE_ORDER_READY:        1,
+	TEMPLATE_EMAIL_VERIFICATION: 1,
+	TEMPLATE_EMAIL_CHANGED:      1,
+}
+
+//go:embed templates/*.html templates/*.txt
+var templateFS embed.FS
+
+// OrderEmail is the data for order related templates.
+type OrderEmail struct {
+	Name        string
+	Order       models.Order
+	Transaction *models.Transaction
+}
+
+// PasswordResetEmail is the data for the password reset template.
+type PasswordResetEmail struct {
+	Name      string
+	ResetURL  string
+	ExpiresIn string
+}
+
+// EmailVerificationEmail is the data for the email verification template.
+type EmailVerificationEmail struct {
+	Name      string
+	VerifyURL string
+	ExpiresIn string
+}
+
+// EmailChangedEmail is the data for the template telling a user's old
+// address that their email was changed.
+type EmailChangedEmail struct {
+	Name     string
+	NewEmail string
+}
+
+// Templates renders versioned message templates. Each HTML template defines
+// a "subject" and a "content" block and is rendered inside the shared email
+// layout. Each plain text template, used for SMS and push, defines a
+// "subject" and a "text" block.
+type Templates struct {
+	sets     map[string]*template.Template
+	textSets map[string]*texttemplate.Template
+}
+
+// LoadTemplates parses every embedded template.
+func LoadTemplates() (*Templates, error) {
+	files, err := templateFS.ReadDir("templates")
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Templates{
+		sets:     make(map[string]*template.Template),
+		textSets: make(map[string]*texttemplate.Template),
+	}
+	for _, f := range files {
+		if strings.HasSuffix(f.Name(), ".txt") {
+			set, err := texttemplate.ParseFS(templateFS, "templates/"+f.Name())
+			if err != nil {
+				return nil, fmt.Errorf("error parsing template %s: %w", f.Name(), err)
+			}
+			t.textSets[strings.TrimSuffix(f.Name(), ".txt")] = set
+			continue
+		}
+
+		name := strings.TrimSuffix(f.Name(), ".html")
+		if name == "layout" {
+			continue
+		}
+
+		set, err := template.ParseFS(templateFS, "templates/layout.html", "templates/"+f.Name())
+		if err != nil {
+			return nil, fmt.Errorf("error parsing template %s: %w", f.Name(), err)
+		}
+		t.sets[name] = set
+	}
+
+	for name, version := range currentVersions {
+		_, html := t.sets[versionedName(name, version)]
+		_, text := t.textSets[versionedName(name, version)]
+		if !html && !text {
+			return nil, fmt.Errorf("template %s version %d is missing", name, version)
+		}
+	}
+	return t, nil
+}
+
+// Render renders the current version of a template into a message for to.
+func (t *Templates) Render(name, to string, data any) (Message, error) {
+	return t.RenderVersion(name, currentVersions[name], to, data)
+}
+
+// RenderVersion renders a specific version of a template.
+func (t *Templates) RenderVersion(name string, version int, to string, data any) (Message, error) {
+	set, ok := t.sets[versionedName(name, version)]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown template %s version %d", name, version)
+	}
+
+	var subject, body bytes.Buffer
+	if err := set.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("error rendering subject of %s: %w", name, err)
+	}
+	if err := set.ExecuteTemplate(&body, "layout", data); err != nil {
+		return Message{}, fmt.Errorf("error rendering %s: %w", name, err)
+	}
+
+	return Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		HTML:     body.String(),
+		Template: name,
+		Version:  version,
+	}, nil
+}
+
+// RenderText renders the current version of a plain text template into a
+// message for to.
+func (t *Templates) RenderText(name, to string, data any) (Message, error) {
+	version := currentVersions[name]
+	set, ok := t.textSets[versionedName(name, version)]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown text template %s version %d", name, version)
+	}
+
+	var subject, text bytes.Buffer
+	if err := set.ExecuteTemplate(&subject, "subject", data); err != nil {
+		return Message{}, fmt.Errorf("error rendering subject of %s: %w", name, err)
+	}
+	if err := set.ExecuteTemplate(&text, "text", data); err != nil {
+		return Message{}, fmt.Errorf("error rendering %s: %w", name, err)
+	}
+
+	return Message{
+		To:       to,
+		Subject:  strings.TrimSpace(subject.String()),
+		Text:     strings.TrimSpace(text.String()),
+		Template: name,
+		Version:  version,
+	}, nil
+}
+
+func versionedName(name string, version int) string {
+	return fmt.Sprintf("%s.v%d", name, version)
+}
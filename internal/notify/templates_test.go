@@ -0,0 +1,67 @@
+package notify
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderTemplates(t *testing.T) {
+	templates, err := LoadTemplates()
+	require.NoError(t, err)
+
+	order := models.Order{
+		Id:             42,
+		Currency:       "NGN",
+		Subtotal:       "5000.00",
+		DiscountAmount: "0.00",
+		TaxRate:        "7.50",
+		TaxAmount:      "375.00",
+		ServiceCharge:  "0.00",
+		DeliveryFee:    "0.00",
+		TotalAmount:    "5375.00",
+		FulfilmentType: models.FULFILMENT_PICKUP,
+		OrderItems: []models.OrderItem{
+			{Name: "Flat <White>", Quantity: 2, UnitPrice: "2500.00", LineTotal: "5000.00"},
+		},
+	}
+	trx := &models.Transaction{Reference: "ref-1", Currency: "NGN", TotalAmount: "5375.00", UpdatedAt: time.Now()}
+
+	msg, err := templates.Render(TEMPLATE_ORDER_CONFIRMATION, "ada@example.com", OrderEmail{Name: "Ada", Order: order})
+	require.NoError(t, err)
+	require.Equal(t, "Your order #42 has been received", msg.Subject)
+	require.Equal(t, 1, msg.Version)
+	require.Contains(t, msg.HTML, "Flat &lt;White&gt;")
+	require.Contains(t, msg.HTML, "NGN 5375.00")
+
+	msg, err = templates.Render(TEMPLATE_PAYMENT_RECEIPT, "ada@example.com", OrderEmail{Name: "Ada", Order: order, Transaction: trx})
+	require.NoError(t, err)
+	require.Contains(t, msg.HTML, "ref-1")
+
+	_, err = templates.Render(TEMPLATE_ORDER_CANCELLATION, "ada@example.com", OrderEmail{Name: "Ada", Order: order})
+	require.NoError(t, err)
+
+	msg, err = templates.Render(TEMPLATE_PASSWORD_RESET, "ada@example.com", PasswordResetEmail{Name: "Ada", ResetURL: "https://app.test/reset?token=abc", ExpiresIn: "1 hour"})
+	require.NoError(t, err)
+	require.Contains(t, msg.HTML, "https://app.test/reset?token=abc")
+
+	msg, err = templates.Render(TEMPLATE_EMAIL_VERIFICATION, "ada@example.com", EmailVerificationEmail{Name: "Ada", VerifyURL: "https://app.test/verify-email?token=abc", ExpiresIn: "24 hours"})
+	require.NoError(t, err)
+	require.Equal(t, "Verify your email address", msg.Subject)
+	require.Contains(t, msg.HTML, "https://app.test/verify-email?token=abc")
+
+	msg, err = templates.Render(TEMPLATE_EMAIL_CHANGED, "ada@example.com", EmailChangedEmail{Name: "Ada", NewEmail: "ada@new.example.com"})
+	require.NoError(t, err)
+	require.Equal(t, "Your email address was changed", msg.Subject)
+	require.Contains(t, msg.HTML, "ada@new.example.com")
+
+	_, err = templates.RenderVersion(TEMPLATE_PASSWORD_RESET, 99, "ada@example.com", nil)
+	require.Error(t, err)
+
+	msg, err = templates.RenderText(TEMPLATE_ORDER_READY, "+2348000000000", OrderEmail{Name: "Ada & Co", Order: order})
+	require.NoError(t, err)
+	require.Equal(t, "Your order is ready", msg.Subject)
+	require.Equal(t, "Hi Ada & Co, your order #42 is ready for pickup. Show this order number at the counter.", msg.Text)
+}
@@ -0,0 +1,156 @@
+package oidc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/oidc/oidctest"
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	testClientID    = "coffee-delivery"
+	testRedirectURL = "http://localhost:3000/login/callback"
+)
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636, appendix B
+	require.Equal(t, "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM", Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk"))
+
+	verifier, err := NewVerifier()
+	require.NoError(t, err)
+	require.Len(t, verifier, 43)
+}
+
+// authorize follows the authorization URL to the mock provider and returns
+// the parameters it redirects back with.
+func authorize(t *testing.T, p *Provider, state, nonce, verifier string) url.Values {
+	authURL, err := p.AuthCodeURL(context.Background(), state, nonce, verifier, "")
+	require.NoError(t, err)
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(authURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+	require.Equal(t, http.StatusFound, resp.StatusCode)
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	require.Equal(t, testRedirectURL, location.Scheme+"://"+location.Host+location.Path)
+	return location.Query()
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	srv, mock := oidctest.NewServer(testClientID)
+	defer srv.Close()
+
+	p := &Provider{Name: "mock", Issuer: srv.URL, ClientID: testClientID, RedirectURL: testRedirectURL, Scopes: []string{"openid", "email"}}
+	ctx := context.Background()
+
+	verifier, err := NewVerifier()
+	require.NoError(t, err)
+
+	params := authorize(t, p, "the-state", "the-nonce", verifier)
+	require.Equal(t, "the-state", params.Get("state"))
+	require.NotEmpty(t, params.Get("code"))
+
+	tokens, err := p.Exchange(ctx, params.Get("code"), verifier)
+	require.NoError(t, err)
+
+	claims, err := p.Verify(ctx, tokens.IDToken, "the-nonce")
+	require.NoError(t, err)
+	require.Equal(t, &Claims{
+		Subject:       mock.User.Subject,
+		Email:         mock.User.Email,
+		EmailVerified: true,
+		GivenName:     mock.User.GivenName,
+		FamilyName:    mock.User.FamilyName,
+	}, claims)
+
+	// Codes can only be redeemed once
+	_, err = p.Exchange(ctx, params.Get("code"), verifier)
+	require.ErrorContains(t, err, "invalid_grant")
+
+	// A code can not be redeemed without the verifier it was requested with
+	params = authorize(t, p, "the-state", "the-nonce", verifier)
+	other, err := NewVerifier()
+	require.NoError(t, err)
+	_, err = p.Exchange(ctx, params.Get("code"), other)
+	require.ErrorContains(t, err, "invalid_grant")
+}
+
+func TestVerify(t *testing.T) {
+	srv, mock := oidctest.NewServer(testClientID)
+	defer srv.Close()
+
+	p := &Provider{Name: "mock", Issuer: srv.URL, ClientID: testClientID, RedirectURL: testRedirectURL}
+	ctx := context.Background()
+	exp := time.Now().Add(time.Hour)
+
+	token, err := mock.IDToken(mock.User, "nonce", exp)
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, token, "nonce")
+	require.NoError(t, err)
+
+	_, err = p.Verify(ctx, token, "other-nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+
+	expired, err := mock.IDToken(mock.User, "nonce", time.Now().Add(-time.Hour))
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, expired, "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Tokens for another client are rejected
+	mock.ClientID = "another-client"
+	otherAudience, err := mock.IDToken(mock.User, "nonce", exp)
+	require.NoError(t, err)
+	mock.ClientID = testClientID
+	_, err = p.Verify(ctx, otherAudience, "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+
+	// Tokens signed by another provider are rejected
+	otherSrv, otherMock := oidctest.NewServer(testClientID)
+	defer otherSrv.Close()
+	otherMock.Issuer = srv.URL
+	forged, err := otherMock.IDToken(mock.User, "nonce", exp)
+	require.NoError(t, err)
+	_, err = p.Verify(ctx, forged, "nonce")
+	require.ErrorIs(t, err, ErrInvalidIDToken)
+}
+
+func TestAppleClientSecret(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	secret, err := AppleClientSecret("TEAM123", "KEY123", "com.example.coffee", pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}))
+	require.NoError(t, err)
+
+	signed, err := secret()
+	require.NoError(t, err)
+
+	token, err := jwt.Parse(signed, func(*jwt.Token) (interface{}, error) { return &key.PublicKey, nil })
+	require.NoError(t, err)
+	require.Equal(t, "ES256", token.Method.Alg())
+	require.Equal(t, "KEY123", token.Header["kid"])
+
+	claims := token.Claims.(jwt.MapClaims)
+	require.Equal(t, "TEAM123", claims["iss"])
+	require.Equal(t, "com.example.coffee", claims["sub"])
+	require.Equal(t, appleIssuer, claims["aud"])
+
+	_, err = AppleClientSecret("TEAM123", "KEY123", "com.example.coffee", []byte("not a key"))
+	require.Error(t, err)
+}
@@ -0,0 +1,121 @@
+package oidc
+
+import (
+	"crypto/ecdsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+)
+
+const (
+	Google = "google"
+	Apple  = "apple"
+
+	googleIssuer = "https://accounts.google.com"
+	appleIssuer  = "https://appleid.apple.com"
+
+	appleClientSecretTTL = 5 * time.Minute
+)
+
+// ProvidersFromEnv configures the providers whose client ID is set.
+//
+//	Google: OIDC_GOOGLE_CLIENT_ID, OIDC_GOOGLE_CLIENT_SECRET, OIDC_GOOGLE_REDIRECT_URL
+//	Apple:  OIDC_APPLE_CLIENT_ID, OIDC_APPLE_TEAM_ID, OIDC_APPLE_KEY_ID,
+//	        OIDC_APPLE_PRIVATE_KEY (the PEM encoded .p8 key), OIDC_APPLE_REDIRECT_URL
+//
+// OIDC_GOOGLE_ISSUER and OIDC_APPLE_ISSUER override the issuer, to sign in
+// against a mock provider during development.
+func ProvidersFromEnv() (map[string]*Provider, error) {
+	providers := make(map[string]*Provider)
+
+	if clientID := os.Getenv("OIDC_GOOGLE_CLIENT_ID"); clientID != "" {
+		providers[Google] = &Provider{
+			Name:         Google,
+			Issuer:       envOr("OIDC_GOOGLE_ISSUER", googleIssuer),
+			ClientID:     clientID,
+			ClientSecret: os.Getenv("OIDC_GOOGLE_CLIENT_SECRET"),
+			RedirectURL:  os.Getenv("OIDC_GOOGLE_REDIRECT_URL"),
+			Scopes:       []string{"openid", "email", "profile"},
+		}
+	}
+
+	if clientID := os.Getenv("OIDC_APPLE_CLIENT_ID"); clientID != "" {
+		p := &Provider{
+			Name:        Apple,
+			Issuer:      envOr("OIDC_APPLE_ISSUER", appleIssuer),
+			ClientID:    clientID,
+			RedirectURL: os.Getenv("OIDC_APPLE_REDIRECT_URL"),
+			Scopes:      []string{"openid", "email", "name"},
+
+			// Apple only returns the user's name and email when the code is
+			// posted to the redirect URL
+			AuthParams: url.Values{"response_mode": {"form_post"}},
+		}
+
+		if key := os.Getenv("OIDC_APPLE_PRIVATE_KEY"); key != "" {
+			secret, err := AppleClientSecret(os.Getenv("OIDC_APPLE_TEAM_ID"), os.Getenv("OIDC_APPLE_KEY_ID"), clientID, []byte(key))
+			if err != nil {
+				return nil, fmt.Errorf("error configuring sign in with apple: %w", err)
+			}
+			p.ClientSecretFunc = secret
+		}
+		providers[Apple] = p
+	}
+
+	for name, p := range providers {
+		if p.RedirectURL == "" {
+			return nil, fmt.Errorf("no redirect url configured for %s", name)
+		}
+	}
+	return providers, nil
+}
+
+// AppleClientSecret returns a function generating the client secret Sign in
+// with Apple expects: a JWT signed with the ES256 private key of the team's
+// Sign in with Apple key.
+func AppleClientSecret(teamID, keyID, clientID string, privateKeyPEM []byte) (func() (string, error), error) {
+	if teamID == "" || keyID == "" {
+		return nil, errors.New("team id and key id are required")
+	}
+
+	block, _ := pem.Decode(privateKeyPEM)
+	if block == nil {
+		return nil, errors.New("private key is not PEM encoded")
+	}
+
+	var key *ecdsa.PrivateKey
+	if parsed, err := x509.ParsePKCS8PrivateKey(block.Bytes); err == nil {
+		var ok bool
+		if key, ok = parsed.(*ecdsa.PrivateKey); !ok {
+			return nil, errors.New("private key is not an ECDSA key")
+		}
+	} else if key, err = x509.ParseECPrivateKey(block.Bytes); err != nil {
+		return nil, fmt.Errorf("error parsing private key: %w", err)
+	}
+
+	return func() (string, error) {
+		now := time.Now()
+		token := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+			"iss": teamID,
+			"sub": clientID,
+			"aud": appleIssuer,
+			"iat": now.Unix(),
+			"exp": now.Add(appleClientSecretTTL).Unix(),
+		})
+		token.Header["kid"] = keyID
+		return token.SignedString(key)
+	}, nil
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,198 @@
+// Package outbox publishes events recorded in the transactional outbox to
+// in-process subscribers and external sinks.
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	dispatchBatch = 100
+
+	// dispatchLease is how long claimed events are hidden from other
+	// dispatchers. An event whose dispatch outlives it may be delivered twice.
+	dispatchLease = 2 * time.Minute
+
+	retryBase = 5 * time.Second
+	retryMax  = 10 * time.Minute
+
+	// maxAttempts is how many times an event is tried before it is marked
+	// dead. With retryBase doubling per attempt the last retry happens a
+	// little over two hours after the event.
+	maxAttempts = 20
+
+	// dispatchedRetention is how long dispatched events are kept before
+	// Prune removes them.
+	dispatchedRetention = 7 * 24 * time.Hour
+
+	// sinkPrefix keeps the delivery records of sinks apart from those of
+	// subscribers with the same name.
+	sinkPrefix = "sink:"
+)
+
+// Handler processes an outbox event. Delivery is at-least-once, so handlers
+// must tolerate seeing the same event (same Id) more than once.
+type Handler func(ctx context.Context, event models.OutboxEvent) error
+
+// Sink is an external destination for outbox events, such as a message
+// broker or log. Like handlers, sinks receive events at least once.
+type Sink interface {
+	Name() string
+	Publish(ctx context.Context, event models.OutboxEvent) error
+}
+
+type subscription struct {
+	name      string
+	eventType string
+	handler   Handler
+}
+
+// Dispatcher drains the outbox. An event is marked dispatched only after
+// every matching subscriber and every sink has accepted it; otherwise it is
+// retried with backoff, but only to the subscribers and sinks that have not
+// accepted it yet, until it is marked dead after maxAttempts.
+type Dispatcher struct {
+	repo *repository.OutboxRepository
+
+	mu            sync.RWMutex
+	subscriptions []subscription
+	sinks         []Sink
+}
+
+func NewDispatcher(repo *repository.OutboxRepository) *Dispatcher {
+	return &Dispatcher{repo: repo}
+}
+
+// Subscribe registers an in-process handler for events of a type. An empty
+// eventType subscribes to every event. The name identifies the subscriber
+// when recording which subscribers have accepted an event, so it must be
+// unique among the subscribers of an event type and stay the same across
+// restarts.
+func (d *Dispatcher) Subscribe(name, eventType string, handler Handler) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.subscriptions = append(d.subscriptions, subscription{name: name, eventType: eventType, handler: handler})
+}
+
+// AddSink registers a sink that receives every event.
+func (d *Dispatcher) AddSink(sink Sink) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	d.sinks = append(d.sinks, sink)
+}
+
+// Dispatch publishes every due event and returns how many were dispatched.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := util.CurrentTime()
+	events, err := d.repo.ClaimDue(ctx, now, now.Add(dispatchLease), dispatchBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	dispatched := 0
+	for _, event := range events {
+		done, err := d.repo.ListDeliveries(ctx, event.Id)
+		if err != nil {
+			return dispatched, err
+		}
+
+		accepted, err := d.deliver(ctx, event, done)
+		if recordErr := d.repo.RecordDeliveries(ctx, event.Id, accepted, util.CurrentTime()); recordErr != nil {
+			return dispatched, recordErr
+		}
+
+		if err != nil {
+			attempts := event.Attempts + 1
+			if attempts >= maxAttempts {
+				logrus.Errorf("outbox event %d (%s) is dead after %d attempts: %v", event.Id, event.Type, attempts, err)
+				if err := d.repo.MarkDead(ctx, event.Id, attempts, util.CurrentTime(), err.Error()); err != nil {
+					return dispatched, err
+				}
+				continue
+			}
+			logrus.Warnf("outbox event %d (%s) failed on attempt %d: %v", event.Id, event.Type, attempts, err)
+
+			next := util.CurrentTime().Add(retryBackoff(attempts))
+			if err := d.repo.MarkFailed(ctx, event.Id, attempts, next, err.Error()); err != nil {
+				return dispatched, err
+			}
+			continue
+		}
+
+		if err := d.repo.MarkDispatched(ctx, event.Id, util.CurrentTime()); err != nil {
+			return dispatched, err
+		}
+		dispatched++
+	}
+
+	return dispatched, nil
+}
+
+// Prune removes events dispatched more than dispatchedRetention ago and
+// returns how many were removed.
+func (d *Dispatcher) Prune(ctx context.Context) (int64, error) {
+	return d.repo.DeleteDispatchedBefore(ctx, util.CurrentTime().Add(-dispatchedRetention))
+}
+
+// deliver hands an event to every matching subscriber and every sink that
+// is not in done. It returns the names of those that accepted it, along
+// with the errors of the others.
+func (d *Dispatcher) deliver(ctx context.Context, event models.OutboxEvent, done map[string]bool) ([]string, error) {
+	d.mu.RLock()
+	defer d.mu.RUnlock()
+
+	var accepted, errs []string
+	for _, s := range d.subscriptions {
+		if s.eventType != "" && s.eventType != event.Type {
+			continue
+		}
+		if done[s.name] {
+			continue
+		}
+		if err := s.handler(ctx, event); err != nil {
+			errs = append(errs, fmt.Sprintf("%s: %v", s.name, err))
+			continue
+		}
+		accepted = append(accepted, s.name)
+	}
+
+	for _, sink := range d.sinks {
+		name := sinkPrefix + sink.Name()
+		if done[name] {
+			continue
+		}
+		if err := sink.Publish(ctx, event); err != nil {
+			errs = append(errs, fmt.Sprintf("sink %s: %v", sink.Name(), err))
+			continue
+		}
+		accepted = append(accepted, name)
+	}
+
+	if len(errs) > 0 {
+		return accepted, errors.New(strings.Join(errs, "; "))
+	}
+	return accepted, nil
+}
+
+// retryBackoff returns the wait before retrying an event that has failed
+// the given number of times.
+func retryBackoff(attempts uint) time.Duration {
+	wait := retryBase
+	for i := uint(1); i < attempts; i++ {
+		wait *= 2
+		if wait >= retryMax {
+			return retryMax
+		}
+	}
+	return wait
+}
@@ -0,0 +1,158 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDeliverFansOutByType(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	var orders, all, sunk int
+	d.Subscribe("orders", models.OUTBOX_ORDER_STATUS_CHANGED, func(ctx context.Context, e models.OutboxEvent) error {
+		orders++
+		return nil
+	})
+	d.Subscribe("all", "", func(ctx context.Context, e models.OutboxEvent) error {
+		all++
+		return nil
+	})
+	d.AddSink(SinkFunc{SinkName: "count", Func: func(ctx context.Context, e models.OutboxEvent) error {
+		sunk++
+		return nil
+	}})
+
+	accepted, err := d.deliver(context.Background(), models.OutboxEvent{Type: models.OUTBOX_ORDER_STATUS_CHANGED}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"orders", "all", "sink:count"}, accepted)
+
+	accepted, err = d.deliver(context.Background(), models.OutboxEvent{Type: models.OUTBOX_TRANSACTION_CREATED}, nil)
+	require.NoError(t, err)
+	require.Equal(t, []string{"all", "sink:count"}, accepted)
+
+	require.Equal(t, 1, orders)
+	require.Equal(t, 2, all)
+	require.Equal(t, 2, sunk)
+}
+
+func TestDeliverFailsIfAnySinkFails(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	delivered := false
+	d.AddSink(SinkFunc{SinkName: "ok", Func: func(ctx context.Context, e models.OutboxEvent) error {
+		delivered = true
+		return nil
+	}})
+	d.AddSink(SinkFunc{SinkName: "broken", Func: func(ctx context.Context, e models.OutboxEvent) error {
+		return errors.New("unavailable")
+	}})
+
+	accepted, err := d.deliver(context.Background(), models.OutboxEvent{Type: models.OUTBOX_TRANSACTION_CREATED}, nil)
+	require.ErrorContains(t, err, "sink broken: unavailable")
+	require.True(t, delivered, "healthy sinks still receive the event")
+	require.Equal(t, []string{"sink:ok"}, accepted)
+}
+
+func TestDeliverSkipsAcceptedSubscribers(t *testing.T) {
+	d := NewDispatcher(nil)
+
+	var first, second int
+	d.Subscribe("first", "", func(ctx context.Context, e models.OutboxEvent) error {
+		first++
+		return nil
+	})
+	d.Subscribe("second", "", func(ctx context.Context, e models.OutboxEvent) error {
+		second++
+		if second == 1 {
+			return errors.New("unavailable")
+		}
+		return nil
+	})
+
+	event := models.OutboxEvent{Type: models.OUTBOX_ORDER_CREATED}
+	accepted, err := d.deliver(context.Background(), event, nil)
+	require.ErrorContains(t, err, "second: unavailable")
+	require.Equal(t, []string{"first"}, accepted)
+
+	// The retry only goes to the subscriber that failed
+	accepted, err = d.deliver(context.Background(), event, map[string]bool{"first": true})
+	require.NoError(t, err)
+	require.Equal(t, []string{"second"}, accepted)
+	require.Equal(t, 1, first)
+	require.Equal(t, 2, second)
+}
+
+func TestRetryBackoff(t *testing.T) {
+	require.Equal(t, 5*time.Second, retryBackoff(1))
+	require.Equal(t, 20*time.Second, retryBackoff(3))
+	require.Equal(t, retryMax, retryBackoff(20))
+}
+
+func TestDispatchMarksEventDead(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	d := NewDispatcher(repository.NewOutboxRepository(db))
+	d.Subscribe("broken", "", func(ctx context.Context, e models.OutboxEvent) error {
+		return errors.New("unavailable")
+	})
+
+	now := util.CurrentTime()
+	event := models.OutboxEvent{Type: models.OUTBOX_ORDER_CREATED, AggregateType: "order", AggregateID: 1, Payload: "{}", Attempts: maxAttempts - 1, NextAttemptAt: now, CreatedAt: now}
+	require.NoError(t, db.Create(&event).Error)
+
+	dispatched, err := d.Dispatch(ctx)
+	require.NoError(t, err)
+	require.Zero(t, dispatched)
+
+	var stored models.OutboxEvent
+	require.NoError(t, db.First(&stored, event.Id).Error)
+	require.NotNil(t, stored.DeadAt)
+	require.Nil(t, stored.DispatchedAt)
+	require.EqualValues(t, maxAttempts, stored.Attempts)
+	require.Equal(t, "broken: unavailable", stored.LastError)
+
+	// Dead events are no longer claimed
+	require.NoError(t, db.Model(&stored).Update("next_attempt_at", now).Error)
+	events, err := d.repo.ClaimDue(ctx, util.CurrentTime(), util.CurrentTime().Add(dispatchLease), dispatchBatch)
+	require.NoError(t, err)
+	require.Empty(t, events)
+}
+
+func TestPruneRemovesOldDispatchedEvents(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	d := NewDispatcher(repository.NewOutboxRepository(db))
+
+	now := util.CurrentTime()
+	old := now.Add(-dispatchedRetention - time.Hour)
+	recent := now.Add(-time.Hour)
+	events := []models.OutboxEvent{
+		{Type: models.OUTBOX_ORDER_CREATED, AggregateType: "order", AggregateID: 1, Payload: "{}", NextAttemptAt: old, DispatchedAt: &old, CreatedAt: old},
+		{Type: models.OUTBOX_ORDER_CREATED, AggregateType: "order", AggregateID: 2, Payload: "{}", NextAttemptAt: recent, DispatchedAt: &recent, CreatedAt: recent},
+		{Type: models.OUTBOX_ORDER_CREATED, AggregateType: "order", AggregateID: 3, Payload: "{}", NextAttemptAt: old, DeadAt: &old, CreatedAt: old},
+		{Type: models.OUTBOX_ORDER_CREATED, AggregateType: "order", AggregateID: 4, Payload: "{}", NextAttemptAt: now, CreatedAt: old},
+	}
+	require.NoError(t, db.Create(&events).Error)
+	// A delivery recorded by a dispatcher that outlived its lease
+	require.NoError(t, db.Create(&models.OutboxDelivery{EventID: events[0].Id, Subscriber: "late", DeliveredAt: old}).Error)
+
+	deleted, err := d.Prune(ctx)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, deleted)
+
+	var remaining []uint
+	require.NoError(t, db.Model(&models.OutboxEvent{}).Order("id").Pluck("id", &remaining).Error)
+	require.Equal(t, []uint{events[1].Id, events[2].Id, events[3].Id}, remaining)
+
+	var deliveries int64
+	require.NoError(t, db.Model(&models.OutboxDelivery{}).Count(&deliveries).Error)
+	require.Zero(t, deliveries)
+}
@@ -0,0 +1,36 @@
+package outbox
+
+import (
+	"context"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/sirupsen/logrus"
+)
+
+// LogSink writes every event to the application log. It is useful in
+// development and as a record of what was published.
+type LogSink struct{}
+
+func (LogSink) Name() string { return "log" }
+
+func (LogSink) Publish(ctx context.Context, event models.OutboxEvent) error {
+	logrus.WithFields(logrus.Fields{
+		"outbox_id":      event.Id,
+		"type":           event.Type,
+		"aggregate_type": event.AggregateType,
+		"aggregate_id":   event.AggregateID,
+	}).Info(event.Payload)
+	return nil
+}
+
+// SinkFunc adapts a function to the Sink interface.
+type SinkFunc struct {
+	SinkName string
+	Func     func(ctx context.Context, event models.OutboxEvent) error
+}
+
+func (s SinkFunc) Name() string { return s.SinkName }
+
+func (s SinkFunc) Publish(ctx context.Context, event models.OutboxEvent) error {
+	return s.Func(ctx, event)
+}
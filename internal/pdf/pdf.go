@@ -0,0 +1,174 @@
+// Package pdf writes simple text documents as PDF files. It only supports
+// what receipts and invoices need: A4 pages, the standard Helvetica and
+// Courier fonts, text and horizontal rules. The standard fonts do not need
+// to be embedded, so documents stay small and no font files are required.
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// A4 page size in points.
+const (
+	PageWidth  = 595.28
+	PageHeight = 841.89
+)
+
+// Font is one of the standard PDF fonts.
+type Font string
+
+const (
+	Helvetica     Font = "Helvetica"
+	HelveticaBold Font = "Helvetica-Bold"
+	Courier       Font = "Courier"
+	CourierBold   Font = "Courier-Bold"
+)
+
+var fonts = []Font{Helvetica, HelveticaBold, Courier, CourierBold}
+
+// resource returns the name the font is registered under on every page.
+func (f Font) resource() string {
+	for i, font := range fonts {
+		if font == f {
+			return fmt.Sprintf("F%d", i+1)
+		}
+	}
+	return "F1"
+}
+
+// Document is a PDF document under construction. Coordinates are in points
+// from the bottom left corner of the page.
+type Document struct {
+	Title string
+
+	pages []*bytes.Buffer
+}
+
+// New creates an empty document.
+func New(title string) *Document {
+	return &Document{Title: title}
+}
+
+// AddPage starts a new page; subsequent drawing goes to it.
+func (d *Document) AddPage() {
+	d.pages = append(d.pages, &bytes.Buffer{})
+}
+
+func (d *Document) page() *bytes.Buffer {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+	return d.pages[len(d.pages)-1]
+}
+
+// Text draws s with its baseline starting at (x, y).
+func (d *Document) Text(x, y float64, font Font, size float64, s string) {
+	fmt.Fprintf(d.page(), "BT /%s %.2f Tf %.2f %.2f Td (%s) Tj ET\n", font.resource(), size, x, y, escape(s))
+}
+
+// TextRight draws s in a Courier font so that it ends at x. Only the
+// monospaced fonts can be aligned without font metrics.
+func (d *Document) TextRight(x, y float64, font Font, size float64, s string) {
+	if font != Courier && font != CourierBold {
+		font = Courier
+	}
+	width := float64(len([]rune(s))) * size * 0.6
+	d.Text(x-width, y, font, size, s)
+}
+
+// Line draws a straight line from (x1, y1) to (x2, y2).
+func (d *Document) Line(x1, y1, x2, y2, width float64) {
+	fmt.Fprintf(d.page(), "%.2f w %.2f %.2f m %.2f %.2f l S\n", width, x1, y1, x2, y2)
+}
+
+// WriteTo writes the document as a PDF file.
+func (d *Document) WriteTo(w io.Writer) (int64, error) {
+	if len(d.pages) == 0 {
+		d.AddPage()
+	}
+
+	var buf bytes.Buffer
+	var offsets []int
+
+	object := func(body string) int {
+		offsets = append(offsets, buf.Len())
+		n := len(offsets)
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", n, body)
+		return n
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// Objects 1 and 2 are the catalog and the page tree, which refers to
+	// pages that are written after it.
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+
+	firstFont := 3
+	firstPage := firstFont + len(fonts)
+	kids := make([]string, len(d.pages))
+	for i := range d.pages {
+		kids[i] = fmt.Sprintf("%d 0 R", firstPage+2*i)
+	}
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(d.pages)))
+
+	var fontRefs []string
+	for i, f := range fonts {
+		n := object(fmt.Sprintf("<< /Type /Font /Subtype /Type1 /BaseFont /%s /Encoding /WinAnsiEncoding >>", f))
+		fontRefs = append(fontRefs, fmt.Sprintf("/F%d %d 0 R", i+1, n))
+	}
+	resources := fmt.Sprintf("<< /Font << %s >> >>", strings.Join(fontRefs, " "))
+
+	for i, content := range d.pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.2f %.2f] /Resources %s /Contents %d 0 R >>",
+			PageWidth, PageHeight, resources, firstPage+2*i+1))
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", content.Len(), content.Bytes()))
+	}
+
+	info := object(fmt.Sprintf("<< /Title (%s) /Producer (coffee-delivery-api) >>", escape(d.Title)))
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R /Info %d 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, info, xref)
+
+	return buf.WriteTo(w)
+}
+
+// Bytes returns the document as a PDF file.
+func (d *Document) Bytes() []byte {
+	var buf bytes.Buffer
+	d.WriteTo(&buf)
+	return buf.Bytes()
+}
+
+// escape encodes s as the contents of a PDF string literal in
+// WinAnsiEncoding. Characters the encoding cannot represent are replaced
+// with '?'.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		case r == '€':
+			b.WriteString("\\200")
+		case r == '–' || r == '—':
+			b.WriteString("-")
+		case r == '\n' || r == '\t':
+			b.WriteByte(' ')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
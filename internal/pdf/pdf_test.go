@@ -0,0 +1,46 @@
+package pdf
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDocumentStructure(t *testing.T) {
+	doc := New("Receipt (test)")
+	doc.Text(40, 800, HelveticaBold, 18, "Receipt")
+	doc.Line(40, 790, 555, 790, 0.5)
+	doc.TextRight(555, 770, Courier, 10, "1,250.00")
+	doc.AddPage()
+	doc.Text(40, 800, Helvetica, 10, "Page two")
+
+	out := doc.Bytes()
+	require.True(t, bytes.HasPrefix(out, []byte("%PDF-1.4\n")))
+	require.True(t, bytes.HasSuffix(out, []byte("%%EOF\n")))
+	require.Contains(t, string(out), "/Count 2")
+	require.Contains(t, string(out), `(Receipt \(test\))`)
+
+	// startxref must point at the xref table, and every xref entry at the
+	// object it names.
+	m := regexp.MustCompile(`startxref\n(\d+)\n`).FindSubmatch(out)
+	require.NotNil(t, m)
+	xref, _ := strconv.Atoi(string(m[1]))
+	require.True(t, bytes.HasPrefix(out[xref:], []byte("xref\n")))
+
+	entries := regexp.MustCompile(`(\d{10}) 00000 n `).FindAllSubmatch(out[xref:], -1)
+	require.NotEmpty(t, entries)
+	for i, e := range entries {
+		off, _ := strconv.Atoi(string(e[1]))
+		require.True(t, bytes.HasPrefix(out[off:], []byte(fmt.Sprintf("%d 0 obj\n", i+1))), "object %d", i+1)
+	}
+}
+
+func TestEscape(t *testing.T) {
+	require.Equal(t, `a\(b\)\\c`, escape(`a(b)\c`))
+	require.Equal(t, `caf\351`, escape("café"))
+	require.Equal(t, "N?", escape("N₦"))
+}
@@ -3,5 +3,18 @@ package platform
 import "github.com/shopspring/decimal"
 
 type Provider interface {
-	InitiateTransaction(email string, amount decimal.Decimal, reference string) (InitTransactionResponse, error)
+	InitiateTransaction(email string, amount decimal.Decimal, currency, reference string) (InitTransactionResponse, error)
+
+	// ChargeAuthorization charges a reusable authorization obtained from an
+	// earlier successful payment, without redirecting the customer.
+	ChargeAuthorization(email string, amount decimal.Decimal, currency, reference, authorizationCode string) (ChargeResponse, error)
+
+	// VerifyTransaction returns the outcome of the transaction with
+	// reference. Status is false in the response if the provider does not
+	// know the reference.
+	VerifyTransaction(reference string) (ChargeResponse, error)
+
+	// VerifyWebhookSignature reports whether signature is a valid signature
+	// of a webhook body sent by the provider.
+	VerifyWebhookSignature(body []byte, signature string) bool
 }
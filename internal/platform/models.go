@@ -12,6 +12,7 @@ func (pp PaymentProvider) String() string {
 
 type InitTransactionRequest struct {
 	Amount    string `json:"amount,omitempty"`
+	Currency  string `json:"currency,omitempty"`
 	Email     string `json:"email,omitempty"`
 	Reference string `json:"reference,omitempty"`
 }
@@ -25,3 +26,58 @@ type InitTransactionResponse struct {
 		Reference        string `json:"reference"`
 	} `json:"data"`
 }
+
+const (
+	CHARGE_SUCCESS   = "success"
+	CHARGE_FAILED    = "failed"
+	CHARGE_ABANDONED = "abandoned"
+	CHARGE_REVERSED  = "reversed"
+
+	EVENT_CHARGE_SUCCESS = "charge.success"
+)
+
+type Authorization struct {
+	AuthorizationCode string `json:"authorization_code"`
+	Bin               string `json:"bin"`
+	Last4             string `json:"last4"`
+	ExpMonth          string `json:"exp_month"`
+	ExpYear           string `json:"exp_year"`
+	Channel           string `json:"channel"`
+	CardType          string `json:"card_type"`
+	Bank              string `json:"bank"`
+	CountryCode       string `json:"country_code"`
+	Brand             string `json:"brand"`
+	Reusable          bool   `json:"reusable"`
+	Signature         string `json:"signature"`
+}
+
+type ChargeAuthorizationRequest struct {
+	Amount            string `json:"amount,omitempty"`
+	Currency          string `json:"currency,omitempty"`
+	Email             string `json:"email,omitempty"`
+	Reference         string `json:"reference,omitempty"`
+	AuthorizationCode string `json:"authorization_code,omitempty"`
+}
+
+type ChargeResponse struct {
+	Status  bool   `json:"status"`
+	Message string `json:"message"`
+	Data    struct {
+		Status          string        `json:"status"`
+		Reference       string        `json:"reference"`
+		GatewayResponse string        `json:"gateway_response"`
+		Authorization   Authorization `json:"authorization"`
+	} `json:"data"`
+}
+
+type WebhookEvent struct {
+	Event string `json:"event"`
+	Data  struct {
+		Status        string        `json:"status"`
+		Reference     string        `json:"reference"`
+		Authorization Authorization `json:"authorization"`
+		Customer      struct {
+			Email string `json:"email"`
+		} `json:"customer"`
+	} `json:"data"`
+}
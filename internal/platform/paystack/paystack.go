@@ -1,8 +1,14 @@
 package paystack
 
 import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"net/http"
+	neturl "net/url"
 	"os"
 
 	"github.com/emmrys-jay/coffee-delivery-api/internal/platform"
@@ -24,17 +30,23 @@ func NewPaystackService() *PaystackService {
 	}
 }
 
-func (ps *PaystackService) InitiateTransaction(email string, amount decimal.Decimal, reference string) (platform.InitTransactionResponse, error) {
+func (ps *PaystackService) InitiateTransaction(email string, amount decimal.Decimal, currency, reference string) (platform.InitTransactionResponse, error) {
 	if ps.BaseUrl == "" || ps.SecretKey == "" {
 		return platform.InitTransactionResponse{}, errors.New("paystack is not configured")
 	}
 
+	subunit, err := convertToSubunit(amount, currency)
+	if err != nil {
+		return platform.InitTransactionResponse{}, err
+	}
+
 	url := ps.BaseUrl + "/transaction/initialize"
 	headers := map[string]string{
 		"Authorization": "Bearer " + ps.SecretKey,
 	}
 	body := platform.InitTransactionRequest{
-		Amount:    convertToSubunit(amount).String(),
+		Amount:    subunit.String(),
+		Currency:  currency,
 		Email:     email,
 		Reference: reference,
 	}
@@ -55,6 +67,104 @@ func (ps *PaystackService) InitiateTransaction(email string, amount decimal.Deci
 	return response, nil
 }
 
-func convertToSubunit(amount decimal.Decimal) decimal.Decimal {
-	return amount.Mul(decimal.New(100, 0)) // multiply by 100
+func (ps *PaystackService) ChargeAuthorization(email string, amount decimal.Decimal, currency, reference, authorizationCode string) (platform.ChargeResponse, error) {
+	if ps.BaseUrl == "" || ps.SecretKey == "" {
+		return platform.ChargeResponse{}, errors.New("paystack is not configured")
+	}
+
+	subunit, err := convertToSubunit(amount, currency)
+	if err != nil {
+		return platform.ChargeResponse{}, err
+	}
+
+	url := ps.BaseUrl + "/transaction/charge_authorization"
+	headers := map[string]string{
+		"Authorization": "Bearer " + ps.SecretKey,
+	}
+	body := platform.ChargeAuthorizationRequest{
+		Amount:            subunit.String(),
+		Currency:          currency,
+		Email:             email,
+		Reference:         reference,
+		AuthorizationCode: authorizationCode,
+	}
+
+	respBody, err := util.MakePOSTRequest(url, headers, body)
+	if err != nil {
+		logrus.Error("Error ChargeAuthorization: ", err)
+		return platform.ChargeResponse{}, err
+	}
+
+	var response platform.ChargeResponse
+	err = json.Unmarshal(respBody, &response)
+	if err != nil {
+		logrus.Error("Error ChargeAuthorization, unmarshaling result: ", err)
+		return platform.ChargeResponse{}, err
+	}
+
+	return response, nil
+}
+
+func (ps *PaystackService) VerifyTransaction(reference string) (platform.ChargeResponse, error) {
+	if ps.BaseUrl == "" || ps.SecretKey == "" {
+		return platform.ChargeResponse{}, errors.New("paystack is not configured")
+	}
+
+	url := ps.BaseUrl + "/transaction/verify/" + neturl.PathEscape(reference)
+	headers := map[string]string{
+		"Authorization": "Bearer " + ps.SecretKey,
+	}
+
+	var response platform.ChargeResponse
+	respBody, err := util.MakeGETRequest(url, headers)
+	if err != nil {
+		// Unknown references are answered with a client error and a false
+		// status
+		var httpErr *util.HTTPError
+		if errors.As(err, &httpErr) && (httpErr.StatusCode == http.StatusBadRequest || httpErr.StatusCode == http.StatusNotFound) &&
+			json.Unmarshal(httpErr.Body, &response) == nil && !response.Status {
+			return response, nil
+		}
+
+		logrus.Error("Error VerifyTransaction: ", err)
+		return platform.ChargeResponse{}, err
+	}
+
+	err = json.Unmarshal(respBody, &response)
+	if err != nil {
+		logrus.Error("Error VerifyTransaction, unmarshaling result: ", err)
+		return platform.ChargeResponse{}, err
+	}
+
+	return response, nil
+}
+
+// VerifyWebhookSignature checks the x-paystack-signature header, which is the
+// hex encoded HMAC-SHA512 of the request body keyed with the secret key.
+func (ps *PaystackService) VerifyWebhookSignature(body []byte, signature string) bool {
+	if ps.SecretKey == "" || signature == "" {
+		return false
+	}
+
+	mac := hmac.New(sha512.New, []byte(ps.SecretKey))
+	mac.Write(body)
+	expected := hex.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(expected), []byte(signature))
+}
+
+// supportedCurrencies are the currencies Paystack can charge in.
+var supportedCurrencies = map[string]bool{
+	"NGN": true,
+	"GHS": true,
+	"KES": true,
+	"USD": true,
+}
+
+func convertToSubunit(amount decimal.Decimal, currency string) (decimal.Decimal, error) {
+	if !supportedCurrencies[currency] {
+		return decimal.Decimal{}, fmt.Errorf("paystack does not support charging in %q", currency)
+	}
+
+	return util.ToSubunit(amount, currency)
 }
@@ -1,6 +1,9 @@
 package paystack
 
 import (
+	"crypto/hmac"
+	"crypto/sha512"
+	"encoding/hex"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -35,11 +38,94 @@ func TestInitiateTransaction(t *testing.T) {
 	amount := decimal.NewFromFloat(100.00)
 	reference := "test_reference"
 
-	response, err := ps.InitiateTransaction(email, amount, reference)
+	response, err := ps.InitiateTransaction(email, amount, "NGN", reference)
 	require.NoError(t, err)
 	require.Equal(t, "https://paystack.com/pay/abc123", response.Data.AuthorizationURL)
 }
 
+func TestChargeAuthorization(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "/transaction/charge_authorization", r.URL.Path)
+		require.Equal(t, "Bearer test_secret_key", r.Header.Get("Authorization"))
+
+		w.WriteHeader(http.StatusOK)
+		_, err := w.Write([]byte(`{"status": true, "message": "Charge attempted", "data": {"status": "success", "reference": "test_reference", "authorization": {"authorization_code": "AUTH_abc", "reusable": true}}}`))
+		require.NoError(t, err)
+	}))
+	defer server.Close()
+
+	ps := &PaystackService{
+		BaseUrl:   server.URL,
+		SecretKey: "test_secret_key",
+	}
+
+	response, err := ps.ChargeAuthorization("test@example.com", decimal.NewFromFloat(100.00), "NGN", "test_reference", "AUTH_abc")
+	require.NoError(t, err)
+	require.Equal(t, "success", response.Data.Status)
+	require.Equal(t, "AUTH_abc", response.Data.Authorization.AuthorizationCode)
+}
+
+func TestVerifyTransaction(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, "Bearer test_secret_key", r.Header.Get("Authorization"))
+
+		switch r.URL.Path {
+		case "/transaction/verify/known":
+			_, err := w.Write([]byte(`{"status": true, "message": "Verification successful", "data": {"status": "success", "reference": "known"}}`))
+			require.NoError(t, err)
+		case "/transaction/verify/unknown":
+			w.WriteHeader(http.StatusBadRequest)
+			_, err := w.Write([]byte(`{"status": false, "message": "Transaction reference not found"}`))
+			require.NoError(t, err)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	ps := &PaystackService{
+		BaseUrl:   server.URL,
+		SecretKey: "test_secret_key",
+	}
+
+	response, err := ps.VerifyTransaction("known")
+	require.NoError(t, err)
+	require.True(t, response.Status)
+	require.Equal(t, "success", response.Data.Status)
+
+	response, err = ps.VerifyTransaction("unknown")
+	require.NoError(t, err)
+	require.False(t, response.Status)
+
+	_, err = ps.VerifyTransaction("error")
+	require.Error(t, err)
+}
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	ps := &PaystackService{SecretKey: "test_secret_key"}
+	body := []byte(`{"event": "charge.success"}`)
+
+	mac := hmac.New(sha512.New, []byte("test_secret_key"))
+	mac.Write(body)
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	require.True(t, ps.VerifyWebhookSignature(body, signature))
+	require.False(t, ps.VerifyWebhookSignature(body, "invalid"))
+	require.False(t, ps.VerifyWebhookSignature([]byte(`{"event": "charge.failed"}`), signature))
+}
+
+func TestConvertToSubunit(t *testing.T) {
+	subunit, err := convertToSubunit(decimal.RequireFromString("1234.56"), "GHS")
+	require.NoError(t, err)
+	require.Equal(t, "123456", subunit.String())
+
+	_, err = convertToSubunit(decimal.RequireFromString("10.005"), "NGN")
+	require.Error(t, err)
+
+	_, err = convertToSubunit(decimal.RequireFromString("10"), "EUR")
+	require.Error(t, err)
+}
+
 func TestInitiateTransaction_Integration(t *testing.T) {
 	ps := &PaystackService{
 		BaseUrl:   "https://api.paystack.co",
@@ -50,7 +136,7 @@ func TestInitiateTransaction_Integration(t *testing.T) {
 	amount := decimal.NewFromFloat(100.00)
 	reference := uuid.New().String()
 
-	response, err := ps.InitiateTransaction(email, amount, reference)
+	response, err := ps.InitiateTransaction(email, amount, "NGN", reference)
 	require.NoError(t, err)
 
 	logrus.Info(response)
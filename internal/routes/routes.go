@@ -1,6 +1,7 @@
 package routes
 
 import (
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/handlers"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/middlewares"
 	"github.com/gin-gonic/gin"
@@ -12,40 +13,197 @@ func SetupRoutes(
 	userHandler *handlers.UserHandler,
 	orderHandler *handlers.OrderHandler,
 	trxHandler *handlers.TransactionHandler,
+	subHandler *handlers.SubscriptionHandler,
+	couponHandler *handlers.CouponHandler,
+	addressHandler *handlers.AddressHandler,
+	storeHandler *handlers.StoreHandler,
+	deliveryHandler *handlers.DeliveryHandler,
+	kitchenHandler *handlers.KitchenHandler,
+	webhookHandler *handlers.WebhookHandler,
+	notificationHandler *handlers.NotificationHandler,
+	invoiceHandler *handlers.InvoiceHandler,
+	authHandler *handlers.AuthHandler,
+	roleHandler *handlers.RoleHandler,
+	twoFactorHandler *handlers.TwoFactorHandler,
+	lockoutHandler *handlers.LockoutHandler,
+	privacyHandler *handlers.PrivacyHandler,
+	socialHandler *handlers.SocialLoginHandler,
+	apiKeyHandler *handlers.APIKeyHandler,
 ) {
 	// Public routes
-	router.POST("/login", userHandler.Login)
+	router.POST("/login", authHandler.Login)
+	router.POST("/login/2fa", authHandler.LoginTwoFactor)
+	router.POST("/login/2fa/setup", authHandler.SetupTwoFactor)
+	router.GET("/login/oauth/:provider", socialHandler.Start)
+	router.GET("/login/oauth/:provider/callback", socialHandler.Callback)
+	router.POST("/login/oauth/:provider/callback", socialHandler.Callback)
+	router.POST("/token/refresh", authHandler.RefreshToken)
 	router.POST("/users", userHandler.CreateUser)
+	router.POST("/email/verify", userHandler.VerifyEmail)
+	router.POST("/password/forgot", userHandler.ForgotPassword)
+	router.POST("/password/reset", userHandler.ResetPassword)
 
-	// Authenticated user routes
+	// Authenticated user routes. API keys need the customer scope.
 	auth := router.Group("/")
-	auth.Use(middlewares.UserAuthMiddleware())
+	auth.Use(middlewares.UserAuthMiddleware(), middlewares.RequireScopes(models.SCOPE_CUSTOMER))
 	{
+		auth.POST("/logout", authHandler.Logout)
+		auth.POST("/email/verify/resend", userHandler.ResendVerification)
+
 		auth.GET("/coffees", coffeeHandler.ListCoffees)
 		auth.GET("/coffees/:id", coffeeHandler.GetCoffee)
 
 		auth.POST("/orders", orderHandler.CreateOrder)
 		auth.GET("/orders/:id", orderHandler.GetOrder)
 		auth.GET("/orders", orderHandler.ListUsersOrders)
-		auth.PATCH("/orders/cancel", orderHandler.CancelOrder)
+		auth.PATCH("/orders/:id/cancel", orderHandler.CancelOrder)
+		auth.GET("/orders/:id/tracking", deliveryHandler.TrackOrder)
+		auth.GET("/orders/:id/events", orderHandler.StreamOrderEvents)
+		auth.GET("/orders/:id/receipt.pdf", invoiceHandler.DownloadReceipt)
 
 		auth.POST("/orders/pay", trxHandler.InitiatePayment)
+
+		auth.GET("/me", userHandler.GetMe)
+		auth.PATCH("/me", userHandler.UpdateMe)
+		auth.GET("/me/export", privacyHandler.Export)
+		auth.GET("/me/identities", socialHandler.ListIdentities)
+
+		auth.GET("/me/payment-methods", trxHandler.ListPaymentMethods)
+		auth.DELETE("/me/payment-methods/:id", trxHandler.DeletePaymentMethod)
+
+		auth.GET("/me/addresses", addressHandler.ListAddresses)
+		auth.POST("/me/addresses", addressHandler.CreateAddress)
+		auth.GET("/me/addresses/:id", addressHandler.GetAddress)
+		auth.PUT("/me/addresses/:id", addressHandler.UpdateAddress)
+		auth.DELETE("/me/addresses/:id", addressHandler.DeleteAddress)
+
+		auth.GET("/me/notifications", notificationHandler.GetPreferences)
+		auth.PUT("/me/notifications", notificationHandler.UpdatePreferences)
+
+		auth.POST("/subscriptions", subHandler.CreateSubscription)
+		auth.GET("/subscriptions", subHandler.ListSubscriptions)
+		auth.GET("/subscriptions/:id", subHandler.GetSubscription)
+		auth.PATCH("/subscriptions/:id/pause", subHandler.PauseSubscription)
+		auth.PATCH("/subscriptions/:id/resume", subHandler.ResumeSubscription)
+		auth.PATCH("/subscriptions/:id/skip", subHandler.SkipSubscription)
+		auth.PATCH("/subscriptions/:id/cancel", subHandler.CancelSubscription)
+	}
+
+	// How the user signs in, their sessions and their account itself can
+	// only be changed with an access token, never with an API key.
+	account := auth.Group("/", middlewares.RejectAPIKeys())
+	{
+		account.POST("/logout/all", authHandler.LogoutAll)
+
+		account.POST("/me/password", userHandler.ChangePassword)
+		account.POST("/me/email", userHandler.ChangeEmail)
+		account.POST("/me/reauth/:provider", socialHandler.StartReauth)
+		account.POST("/me/reauth/:provider/callback", socialHandler.FinishReauth)
+		account.DELETE("/me", privacyHandler.DeleteAccount)
+
+		account.POST("/me/2fa/enroll", twoFactorHandler.Enroll)
+		account.POST("/me/2fa/confirm", twoFactorHandler.Confirm)
+		account.POST("/me/2fa/disable", twoFactorHandler.Disable)
+		account.POST("/me/2fa/recovery-codes", twoFactorHandler.RegenerateRecoveryCodes)
+	}
+
+	// Staff routes. Every group requires the permission it is named after.
+	staff := router.Group("/")
+	staff.Use(middlewares.UserAuthMiddleware())
+
+	catalog := staff.Group("/", middlewares.RequirePermissions(models.PERM_CATALOG_WRITE))
+	{
+		catalog.POST("/coffees", coffeeHandler.CreateCoffee)
+		catalog.PUT("/coffees/:id", coffeeHandler.UpdateCoffee)
+		catalog.DELETE("/coffees/:id", coffeeHandler.DeleteCoffee)
+
+		catalog.POST("/coupons", couponHandler.CreateCoupon)
+		catalog.GET("/coupons", couponHandler.ListCoupons)
+		catalog.GET("/coupons/:id", couponHandler.GetCoupon)
+		catalog.PUT("/coupons/:id", couponHandler.UpdateCoupon)
+		catalog.DELETE("/coupons/:id", couponHandler.DeactivateCoupon)
 	}
 
-	// Admin routes
-	admin := router.Group("/")
-	admin.Use(middlewares.AdminAuthMiddleware())
+	usersRead := staff.Group("/", middlewares.RequirePermissions(models.PERM_USERS_READ))
 	{
-		admin.POST("/coffees", coffeeHandler.CreateCoffee)
-		admin.PUT("/coffees/:id", coffeeHandler.UpdateCoffee)
-		admin.DELETE("/coffees/:id", coffeeHandler.DeleteCoffee)
+		usersRead.GET("/users", userHandler.ListUsers)
+		usersRead.GET("/users/:id", userHandler.GetUser)
+		usersRead.GET("/audit/lockouts", lockoutHandler.ListLockouts)
+	}
 
-		admin.GET("/users", userHandler.ListUsers)
-		admin.GET("/users/:id", userHandler.GetUser)
-		admin.PUT("/users/:id", userHandler.UpdateUser)
-		admin.DELETE("/users/:id", userHandler.DeleteUser)
+	usersWrite := staff.Group("/", middlewares.RequirePermissions(models.PERM_USERS_WRITE))
+	{
+		usersWrite.POST("/users/staff", userHandler.CreateStaffUser)
+		usersWrite.PUT("/users/:id", userHandler.UpdateUser)
+		usersWrite.PUT("/users/:id/role", userHandler.AssignRole)
+		usersWrite.DELETE("/users/:id", userHandler.DeleteUser)
+		usersWrite.POST("/users/:id/unlock", lockoutHandler.UnlockUser)
+		usersWrite.POST("/lockouts/ip/unlock", lockoutHandler.UnlockIP)
+	}
 
-		admin.PATCH("/orders/:id", orderHandler.UpdateOrder)
+	roles := staff.Group("/", middlewares.RequirePermissions(models.PERM_ROLES_MANAGE))
+	{
+		roles.GET("/roles", roleHandler.ListRoles)
+		roles.POST("/roles", roleHandler.CreateRole)
+		roles.GET("/roles/:name", roleHandler.GetRole)
+		roles.PUT("/roles/:name/permissions", roleHandler.UpdateRolePermissions)
+		roles.GET("/permissions", roleHandler.ListPermissions)
+	}
+
+	orders := staff.Group("/", middlewares.RequirePermissions(models.PERM_ORDERS_MANAGE))
+	{
+		orders.PATCH("/orders/:id", orderHandler.UpdateOrder)
+
+		orders.GET("/deliveries", deliveryHandler.ListUnassigned)
+		orders.POST("/deliveries/:id/assign", deliveryHandler.AssignCourier)
+	}
+
+	stores := staff.Group("/", middlewares.RequirePermissions(models.PERM_STORES_MANAGE))
+	{
+		stores.POST("/stores", storeHandler.CreateStore)
+		stores.GET("/stores", storeHandler.ListStores)
+		stores.GET("/stores/:id", storeHandler.GetStore)
+		stores.POST("/stores/:id/zones", storeHandler.CreateZone)
+		stores.DELETE("/stores/:id/zones/:zoneId", storeHandler.DeleteZone)
+	}
+
+	webhooks := staff.Group("/", middlewares.RequirePermissions(models.PERM_WEBHOOKS_MANAGE))
+	{
+		webhooks.POST("/webhooks", webhookHandler.CreateEndpoint)
+		webhooks.GET("/webhooks", webhookHandler.ListEndpoints)
+		webhooks.GET("/webhooks/:id", webhookHandler.GetEndpoint)
+		webhooks.PATCH("/webhooks/:id", webhookHandler.UpdateEndpoint)
+		webhooks.DELETE("/webhooks/:id", webhookHandler.DeleteEndpoint)
+		webhooks.GET("/webhooks/:id/deliveries", webhookHandler.ListDeliveries)
+		webhooks.POST("/webhooks/:id/deliveries/:deliveryId/redeliver", webhookHandler.Redeliver)
+	}
+
+	apiKeys := staff.Group("/", middlewares.RequirePermissions(models.PERM_API_KEYS_MANAGE))
+	{
+		apiKeys.GET("/api-keys", apiKeyHandler.ListKeys)
+		apiKeys.POST("/api-keys", apiKeyHandler.CreateKey)
+		apiKeys.GET("/api-keys/:id", apiKeyHandler.GetKey)
+		apiKeys.POST("/api-keys/:id/rotate", apiKeyHandler.RotateKey)
+		apiKeys.DELETE("/api-keys/:id", apiKeyHandler.RevokeKey)
+	}
+
+	// Courier routes
+	courier := router.Group("/courier")
+	courier.Use(middlewares.UserAuthMiddleware(), middlewares.RequirePermissions(models.PERM_DELIVERIES_FULFIL))
+	{
+		courier.GET("/deliveries", deliveryHandler.ListCourierDeliveries)
+		courier.POST("/deliveries/:id/delivered", deliveryHandler.MarkDelivered)
+		courier.POST("/location", deliveryHandler.RecordLocation)
+	}
+
+	// Barista routes
+	kitchen := router.Group("/kitchen")
+	kitchen.Use(middlewares.UserAuthMiddleware(), middlewares.RequirePermissions(models.PERM_KITCHEN_OPERATE))
+	{
+		kitchen.GET("/queue", kitchenHandler.GetQueue)
+		kitchen.GET("/stream", kitchenHandler.StreamTickets)
+		kitchen.POST("/orders/:id/items/:itemId/start", kitchenHandler.StartItem)
+		kitchen.POST("/orders/:id/items/:itemId/done", kitchenHandler.CompleteItem)
 	}
 
 	// Webhook routes
@@ -0,0 +1,306 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultEmailVerificationTTL = 24 * time.Hour
+	defaultPasswordResetTTL     = time.Hour
+	defaultAppURL               = "http://localhost:3000"
+)
+
+// ErrInvalidToken is returned when an emailed token is unknown, expired or
+// has already been used.
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// AccountMailer sends the emails that let users verify their address and
+// reset their password. NotificationService implements it over the
+// configured email notifier.
+type AccountMailer interface {
+	SendEmailVerification(ctx context.Context, user *models.User, email, verifyURL string, expiresIn time.Duration) error
+	SendPasswordReset(ctx context.Context, user *models.User, resetURL string, expiresIn time.Duration) error
+	SendEmailChanged(ctx context.Context, user *models.User, oldEmail string) error
+}
+
+// ResendVerification emails a new verification link to the user. Links sent
+// earlier stop working.
+func (s *UserService) ResendVerification(ctx context.Context, userId uint) error {
+	user, err := s.repo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if user.EmailVerifiedAt != nil {
+		return errors.New("email address is already verified")
+	}
+	return s.sendVerification(ctx, user, models.TOKEN_EMAIL_VERIFICATION, user.Email)
+}
+
+// VerifyEmail redeems an email verification token and marks the address it
+// was sent to as verified. If the token was sent by ChangeEmail, the user's
+// email is changed to that address and the old address is told about it.
+func (s *UserService) VerifyEmail(ctx context.Context, token string) (*models.User, error) {
+	userToken, err := s.findToken(ctx, models.TOKEN_EMAIL_VERIFICATION, token)
+	if errors.Is(err, ErrInvalidToken) {
+		userToken, err = s.findToken(ctx, models.TOKEN_EMAIL_CHANGE, token)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := s.redeemToken(ctx, userToken); err != nil {
+		return nil, err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userToken.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	oldEmail := user.Email
+	if userToken.Purpose == models.TOKEN_EMAIL_CHANGE {
+		if err := s.checkEmailAvailable(ctx, user.Id, userToken.Email); err != nil {
+			return nil, err
+		}
+		user.Email = userToken.Email
+	} else if user.Email != userToken.Email {
+		// The user changed their email after the link was sent
+		return nil, ErrInvalidToken
+	}
+
+	now := util.CurrentTime()
+	user.EmailVerifiedAt = &now
+	user.UpdatedAt = now
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error verifying email, %w", err)
+	}
+
+	if user.Email != oldEmail {
+		if err := s.mailer.SendEmailChanged(ctx, user, oldEmail); err != nil {
+			logrus.Errorf("error notifying user %d of their email change: %v", user.Id, err)
+		}
+	}
+	return user, nil
+}
+
+// ChangePassword changes the user's password after checking their current
+// one, or another confirmation if they have none. Their other sessions are
+// revoked; sessionId is the session the request was made with and stays
+// signed in.
+func (s *UserService) ChangePassword(ctx context.Context, userId uint, sessionId string, req *models.ChangePasswordRequest) error {
+	user, err := s.repo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authService.Confirm(ctx, user, req.Confirmation()); err != nil {
+		return err
+	}
+	if err := checkPasswordStrength(req.NewPassword, user.FirstName, user.LastName, user.Email); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.NewPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(hashedPassword)
+	user.UpdatedAt = util.CurrentTime()
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("error updating password, %w", err)
+	}
+
+	if _, err := s.authService.LogoutOthers(ctx, user.Id, sessionId); err != nil {
+		return fmt.Errorf("error revoking sessions, %w", err)
+	}
+	return nil
+}
+
+// ChangeEmail emails a verification link to a new address after confirming
+// it is the user asking. The user's email only changes once the link is
+// followed, so a mistyped address can not lock them out.
+func (s *UserService) ChangeEmail(ctx context.Context, userId uint, req *models.ChangeEmailRequest) error {
+	user, err := s.repo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authService.Confirm(ctx, user, req.Confirmation); err != nil {
+		return err
+	}
+
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if strings.EqualFold(email, user.Email) {
+		return errors.New("this is already your email address")
+	}
+	if err := s.checkEmailAvailable(ctx, user.Id, email); err != nil {
+		return err
+	}
+
+	return s.sendVerification(ctx, user, models.TOKEN_EMAIL_CHANGE, email)
+}
+
+// ForgotPassword emails a password reset link if an account exists for the
+// email. It reports success either way so that it can not be used to find
+// out who has an account, and requests are limited per email and per client
+// ip.
+func (s *UserService) ForgotPassword(ctx context.Context, email, ip string) error {
+	if err := s.lockout.RecordResetRequest(ctx, email, ip); err != nil {
+		return err
+	}
+
+	// The account is looked up and the link sent after responding, so that
+	// the response takes as long whether or not the account exists
+	go func() {
+		if err := s.sendPasswordReset(context.Background(), email); err != nil {
+			logrus.Errorf("error sending password reset: %v", err)
+		}
+	}()
+	return nil
+}
+
+// sendPasswordReset emails a password reset link to the account with email,
+// if there is one.
+func (s *UserService) sendPasswordReset(ctx context.Context, email string) error {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	token, err := s.issueToken(ctx, user, models.TOKEN_PASSWORD_RESET, user.Email, s.resetTTL)
+	if err != nil {
+		return err
+	}
+
+	resetURL := s.appURL + "/reset-password?token=" + url.QueryEscape(token)
+	if err := s.mailer.SendPasswordReset(ctx, user, resetURL, s.resetTTL); err != nil {
+		return fmt.Errorf("error sending password reset to user %d, %w", user.Id, err)
+	}
+	return nil
+}
+
+// ResetPassword sets a new password using a password reset token. Every
+// session of the user is revoked, and since the token was emailed to them
+// their address is verified too. Tokens sent to an address the user has
+// since changed from no longer work.
+func (s *UserService) ResetPassword(ctx context.Context, token, password string) error {
+	userToken, err := s.findToken(ctx, models.TOKEN_PASSWORD_RESET, token)
+	if err != nil {
+		return err
+	}
+
+	user, err := s.repo.GetUserByID(ctx, userToken.UserID)
+	if err != nil || user.Email != userToken.Email {
+		return ErrInvalidToken
+	}
+
+	// Check the password before the token is used up so that the user can
+	// try again with a stronger one
+	if err := checkPasswordStrength(password, user.FirstName, user.LastName, user.Email); err != nil {
+		return err
+	}
+
+	if err := s.redeemToken(ctx, userToken); err != nil {
+		return err
+	}
+
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	now := util.CurrentTime()
+	user.Password = string(hashedPassword)
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+	}
+	user.UpdatedAt = now
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("error updating password, %w", err)
+	}
+
+	if _, err := s.authService.LogoutAll(ctx, user.Id); err != nil {
+		return fmt.Errorf("error revoking sessions, %w", err)
+	}
+	return nil
+}
+
+// sendVerification emails a verification link for email to the user.
+// purpose is TOKEN_EMAIL_VERIFICATION, or TOKEN_EMAIL_CHANGE if email is a
+// new address.
+func (s *UserService) sendVerification(ctx context.Context, user *models.User, purpose, email string) error {
+	token, err := s.issueToken(ctx, user, purpose, email, s.verifyTTL)
+	if err != nil {
+		return err
+	}
+
+	verifyURL := s.appURL + "/verify-email?token=" + url.QueryEscape(token)
+	if err := s.mailer.SendEmailVerification(ctx, user, email, verifyURL, s.verifyTTL); err != nil {
+		return fmt.Errorf("error sending verification email, %w", err)
+	}
+	return nil
+}
+
+func (s *UserService) checkEmailAvailable(ctx context.Context, userId uint, email string) error {
+	other, _ := s.repo.GetUserByEmail(ctx, email)
+	if other != nil && other.Id != userId {
+		return errors.New("email address is already in use")
+	}
+	return nil
+}
+
+func (s *UserService) issueToken(ctx context.Context, user *models.User, purpose, email string, ttl time.Duration) (string, error) {
+	token, err := util.GenerateToken(32)
+	if err != nil {
+		return "", fmt.Errorf("error generating token, %w", err)
+	}
+
+	now := util.CurrentTime()
+	err = s.tokenRepo.CreateUserToken(ctx, &models.UserToken{
+		UserID:    user.Id,
+		Purpose:   purpose,
+		Email:     email,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+func (s *UserService) findToken(ctx context.Context, purpose, token string) (*models.UserToken, error) {
+	userToken, err := s.tokenRepo.GetUserToken(ctx, purpose, util.HashToken(token))
+	if err != nil {
+		return nil, err
+	}
+	if userToken == nil {
+		return nil, ErrInvalidToken
+	}
+	return userToken, nil
+}
+
+// redeemToken uses up an emailed token. It fails if the token has expired or
+// was already used, including by a concurrent request.
+func (s *UserService) redeemToken(ctx context.Context, userToken *models.UserToken) error {
+	used, err := s.tokenRepo.UseUserToken(ctx, userToken.Id, util.CurrentTime())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidToken
+	}
+	return nil
+}
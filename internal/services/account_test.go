@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeMailer keeps the links it is asked to send.
+type fakeMailer struct {
+	verifyURLs []string
+	resetURLs  []string
+	changedTo  []string
+}
+
+func (m *fakeMailer) SendEmailVerification(ctx context.Context, user *models.User, email, verifyURL string, expiresIn time.Duration) error {
+	m.verifyURLs = append(m.verifyURLs, verifyURL)
+	return nil
+}
+
+func (m *fakeMailer) SendPasswordReset(ctx context.Context, user *models.User, resetURL string, expiresIn time.Duration) error {
+	m.resetURLs = append(m.resetURLs, resetURL)
+	return nil
+}
+
+func (m *fakeMailer) SendEmailChanged(ctx context.Context, user *models.User, oldEmail string) error {
+	m.changedTo = append(m.changedTo, oldEmail)
+	return nil
+}
+
+func newTestUserService(db *gorm.DB, mailer AccountMailer) *UserService {
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	lockout := NewLockoutService(repository.NewLockoutRepository(db), userRepo)
+	auth := NewAuthService(userRepo, tokenRepo, NewTwoFactorService(userRepo, repository.NewTwoFactorRepository(db)), lockout)
+	return NewUserService(userRepo, tokenRepo, nil, auth, lockout, mailer)
+}
+
+// linkToken returns the token of an emailed link.
+func linkToken(t *testing.T, link string) string {
+	t.Helper()
+
+	u, err := url.Parse(link)
+	require.NoError(t, err)
+	return u.Query().Get("token")
+}
+
+func TestPasswordReset(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	t.Run("a token can only be used once", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		us := newTestUserService(db, mailer)
+		user := createUser(t, db, "resetonce", models.ROLE_USER)
+
+		require.NoError(t, us.sendPasswordReset(ctx, user.Email))
+		require.Len(t, mailer.resetURLs, 1)
+		token := linkToken(t, mailer.resetURLs[0])
+
+		require.NoError(t, us.ResetPassword(ctx, token, "roasted-beans-42"))
+		require.ErrorIs(t, us.ResetPassword(ctx, token, "roasted-beans-43"), ErrInvalidToken)
+	})
+
+	t.Run("an expired token is refused", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		us := newTestUserService(db, mailer)
+		us.resetTTL = -time.Minute
+		user := createUser(t, db, "resetexpired", models.ROLE_USER)
+
+		require.NoError(t, us.sendPasswordReset(ctx, user.Email))
+		require.ErrorIs(t, us.ResetPassword(ctx, linkToken(t, mailer.resetURLs[0]), "roasted-beans-42"), ErrInvalidToken)
+	})
+
+	t.Run("a new link voids the earlier one", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		us := newTestUserService(db, mailer)
+		user := createUser(t, db, "resettwice", models.ROLE_USER)
+
+		require.NoError(t, us.sendPasswordReset(ctx, user.Email))
+		require.NoError(t, us.sendPasswordReset(ctx, user.Email))
+		require.ErrorIs(t, us.ResetPassword(ctx, linkToken(t, mailer.resetURLs[0]), "roasted-beans-42"), ErrInvalidToken)
+		require.NoError(t, us.ResetPassword(ctx, linkToken(t, mailer.resetURLs[1]), "roasted-beans-42"))
+	})
+
+	t.Run("a link sent before an email change is refused", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		us := newTestUserService(db, mailer)
+		user := createUser(t, db, "resetmoved", models.ROLE_USER)
+
+		require.NoError(t, us.sendPasswordReset(ctx, user.Email))
+		require.NoError(t, us.sendVerification(ctx, user, models.TOKEN_EMAIL_CHANGE, "resetmoved@new.example.com"))
+		_, err := us.VerifyEmail(ctx, linkToken(t, mailer.verifyURLs[0]))
+		require.NoError(t, err)
+		require.Equal(t, []string{user.Email}, mailer.changedTo)
+
+		require.ErrorIs(t, us.ResetPassword(ctx, linkToken(t, mailer.resetURLs[0]), "roasted-beans-42"), ErrInvalidToken)
+	})
+
+	t.Run("no link is sent for an unknown email", func(t *testing.T) {
+		mailer := &fakeMailer{}
+		us := newTestUserService(db, mailer)
+
+		require.NoError(t, us.sendPasswordReset(ctx, "nobody@example.com"))
+		require.Empty(t, mailer.resetURLs)
+	})
+}
+
+func TestForgotPasswordIsRateLimited(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	us := newTestUserService(db, &fakeMailer{})
+
+	for i := 0; i < resetsPerAccount; i++ {
+		require.NoError(t, us.lockout.RecordResetRequest(ctx, "limited@example.com", "203.0.113.7"))
+	}
+	require.ErrorIs(t, us.lockout.RecordResetRequest(ctx, "Limited@example.com", "203.0.113.8"), ErrTooManyRequests)
+
+	// Other addresses can still be reset from another client
+	require.NoError(t, us.lockout.RecordResetRequest(ctx, "other@example.com", "203.0.113.8"))
+}
@@ -0,0 +1,91 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"gorm.io/gorm"
+)
+
+type AddressService struct {
+	repo *repository.AddressRepository
+}
+
+func NewAddressService(repo *repository.AddressRepository) *AddressService {
+	return &AddressService{repo: repo}
+}
+
+func (s *AddressService) CreateAddress(ctx context.Context, userId uint, req *models.AddressRequest) (*models.Address, error) {
+	address := models.Address{UserID: userId, CreatedAt: util.CurrentTime()}
+	applyAddressRequest(&address, req)
+
+	if err := s.repo.Create(ctx, &address); err != nil {
+		return nil, err
+	}
+	return &address, nil
+}
+
+// GetAddress returns an address the principal may read.
+func (s *AddressService) GetAddress(ctx context.Context, p authz.Principal, id uint) (*models.Address, error) {
+	return s.getAddress(ctx, p, authz.ActionRead, id)
+}
+
+// getAddress returns an address the principal may perform action on.
+// Missing addresses are reported like hidden ones.
+func (s *AddressService) getAddress(ctx context.Context, p authz.Principal, action authz.Action, id uint) (*models.Address, error) {
+	address, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, authz.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := authz.Address(p, action, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (s *AddressService) ListAddresses(ctx context.Context, userId uint) ([]models.Address, error) {
+	return s.repo.ListUserAddresses(ctx, userId)
+}
+
+func (s *AddressService) UpdateAddress(ctx context.Context, p authz.Principal, id uint, req *models.AddressRequest) (*models.Address, error) {
+	address, err := s.getAddress(ctx, p, authz.ActionUpdate, id)
+	if err != nil {
+		return nil, err
+	}
+
+	applyAddressRequest(address, req)
+	if err := s.repo.Update(ctx, address); err != nil {
+		return nil, err
+	}
+	return address, nil
+}
+
+func (s *AddressService) DeleteAddress(ctx context.Context, p authz.Principal, id uint) error {
+	address, err := s.getAddress(ctx, p, authz.ActionDelete, id)
+	if err != nil {
+		return err
+	}
+	return s.repo.Delete(ctx, address.UserID, id)
+}
+
+func applyAddressRequest(address *models.Address, req *models.AddressRequest) {
+	address.Label = req.Label
+	address.Line1 = req.Line1
+	address.Line2 = req.Line2
+	address.City = req.City
+	address.State = req.State
+	address.Country = strings.ToUpper(req.Country)
+	address.PostalCode = req.PostalCode
+	address.Latitude = req.Latitude
+	address.Longitude = req.Longitude
+	address.UpdatedAt = util.CurrentTime()
+}
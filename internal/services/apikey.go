@@ -0,0 +1,311 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	defaultAPIKeyRotationGrace = 24 * time.Hour
+
+	// Use of a key is recorded at most once per apiKeyTouchInterval, unless
+	// it comes from another address
+	apiKeyTouchInterval = time.Minute
+)
+
+var ErrAPIKeyNotFound = errors.New("api key not found")
+
+// APIKeyService manages the API keys machine clients use instead of a
+// user's password, and authenticates requests made with them. Keys act as
+// the user who owns them, limited to their scopes.
+type APIKeyService struct {
+	repo     *repository.APIKeyRepository
+	userRepo *repository.UserRepository
+	rbac     *RBACService
+}
+
+func NewAPIKeyService(repo *repository.APIKeyRepository, userRepo *repository.UserRepository, rbac *RBACService) *APIKeyService {
+	return &APIKeyService{repo: repo, userRepo: userRepo, rbac: rbac}
+}
+
+// Create issues a key for a user. Every scope must be a permission the
+// user's role grants and the caller holds, or the customer scope.
+func (s *APIKeyService) Create(ctx context.Context, p authz.Principal, req *models.CreateAPIKeyRequest) (*models.NewAPIKey, error) {
+	owner, err := s.userRepo.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.AnonymizedAt != nil {
+		return nil, errors.New("the user has deleted their account")
+	}
+
+	scopes, err := s.checkScopes(ctx, owner, req.Scopes)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkCaller(p, owner, scopes); err != nil {
+		return nil, err
+	}
+
+	token, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("error generating api key, %w", err)
+	}
+
+	now := util.CurrentTime()
+	key := &models.APIKey{
+		Name:        req.Name,
+		Prefix:      prefix,
+		KeyHash:     util.HashToken(token),
+		UserID:      owner.Id,
+		Scopes:      scopes,
+		CreatedByID: p.UserID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if req.ExpiresInDays > 0 {
+		expiresAt := now.AddDate(0, 0, req.ExpiresInDays)
+		key.ExpiresAt = &expiresAt
+	}
+
+	audit := apiKeyAudit(models.AUDIT_API_KEY_CREATED, p, key, now)
+	audit.Detail = fmt.Sprintf("key %s (%s) with scopes %s", key.Prefix, key.Name, strings.Join(key.Scopes, ", "))
+	if err := s.repo.CreateAPIKey(ctx, key, audit); err != nil {
+		return nil, err
+	}
+	return &models.NewAPIKey{APIKey: *key, Key: token}, nil
+}
+
+// Rotate issues a replacement for a key, with the same owner, scopes and
+// lifetime. The old key keeps working for grace, so clients can switch
+// without downtime.
+func (s *APIKeyService) Rotate(ctx context.Context, p authz.Principal, id uint, grace *int) (*models.NewAPIKey, error) {
+	old, err := s.Get(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	now := util.CurrentTime()
+	if !old.Active(now) || old.ReplacedByID != nil {
+		return nil, errors.New("only active keys that have not been rotated can be rotated")
+	}
+
+	owner, err := s.userRepo.GetUserByID(ctx, old.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if owner.AnonymizedAt != nil {
+		return nil, errors.New("the user has deleted their account")
+	}
+	if err := checkCaller(p, owner, old.Scopes); err != nil {
+		return nil, err
+	}
+
+	token, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, fmt.Errorf("error generating api key, %w", err)
+	}
+
+	next := &models.APIKey{
+		Name:        old.Name,
+		Prefix:      prefix,
+		KeyHash:     util.HashToken(token),
+		UserID:      old.UserID,
+		Scopes:      old.Scopes,
+		CreatedByID: p.UserID,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	if old.ExpiresAt != nil {
+		expiresAt := now.Add(old.ExpiresAt.Sub(old.CreatedAt))
+		next.ExpiresAt = &expiresAt
+	}
+
+	period := defaultAPIKeyRotationGrace
+	if grace != nil {
+		period = time.Duration(*grace) * time.Hour
+	}
+	oldExpiresAt := now.Add(period)
+	if old.ExpiresAt != nil && old.ExpiresAt.Before(oldExpiresAt) {
+		oldExpiresAt = *old.ExpiresAt
+	}
+
+	audit := apiKeyAudit(models.AUDIT_API_KEY_ROTATED, p, old, now)
+	audit.Detail = fmt.Sprintf("key %s replaced by %s; old key expires %s", old.Prefix, next.Prefix, oldExpiresAt.Format(time.RFC3339))
+	rotated, err := s.repo.RotateAPIKey(ctx, old, next, oldExpiresAt, audit)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		return nil, errors.New("the key has already been rotated or revoked")
+	}
+	return &models.NewAPIKey{APIKey: *next, Key: token}, nil
+}
+
+// Revoke stops a key from working at once.
+func (s *APIKeyService) Revoke(ctx context.Context, p authz.Principal, id uint) error {
+	key, err := s.Get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	now := util.CurrentTime()
+	audit := apiKeyAudit(models.AUDIT_API_KEY_REVOKED, p, key, now)
+	audit.Detail = fmt.Sprintf("key %s (%s)", key.Prefix, key.Name)
+	_, err = s.repo.RevokeAPIKey(ctx, key.Id, now, audit)
+	return err
+}
+
+func (s *APIKeyService) List(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+func (s *APIKeyService) Get(ctx context.Context, id uint) (*models.APIKey, error) {
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrAPIKeyNotFound
+	}
+	return key, nil
+}
+
+// AuthenticateAPIKey returns the claims and scopes a request made with key
+// is authenticated with, and records that the key was used from ip. The
+// claims are those of the owner's access tokens plus "api_key_id". Claims
+// are nil if the key is unknown, expired or revoked.
+func (s *APIKeyService) AuthenticateAPIKey(ctx context.Context, token, ip string) (jwt.MapClaims, []string, error) {
+	prefix, ok := parseAPIKey(token)
+	if !ok {
+		return nil, nil, nil
+	}
+
+	key, err := s.repo.GetAPIKeyByPrefix(ctx, prefix)
+	if err != nil {
+		return nil, nil, err
+	}
+	if key == nil || subtle.ConstantTimeCompare([]byte(util.HashToken(token)), []byte(key.KeyHash)) != 1 {
+		return nil, nil, nil
+	}
+
+	now := util.CurrentTime()
+	if !key.Active(now) {
+		return nil, nil, nil
+	}
+
+	owner, err := s.userRepo.GetUserByID(ctx, key.UserID)
+	if err != nil || owner.AnonymizedAt != nil {
+		return nil, nil, nil
+	}
+
+	if err := s.repo.TouchAPIKey(ctx, key.Id, ip, now, now.Add(-apiKeyTouchInterval)); err != nil {
+		logrus.Errorf("error recording use of api key %s: %v", key.Prefix, err)
+	}
+
+	return jwt.MapClaims{
+		"user_id":    fmt.Sprint(owner.Id),
+		"role":       owner.Role,
+		"api_key_id": fmt.Sprint(key.Id),
+	}, key.Scopes, nil
+}
+
+// checkScopes returns scopes without duplicates, or an error if one can not
+// be given to a key of owner. Keys can never manage keys.
+func (s *APIKeyService) checkScopes(ctx context.Context, owner *models.User, scopes []string) ([]string, error) {
+	granted, err := s.rbac.RolePermissions(ctx, owner.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool, len(scopes))
+	checked := make([]string, 0, len(scopes))
+	for _, scope := range scopes {
+		if seen[scope] {
+			continue
+		}
+		seen[scope] = true
+
+		switch {
+		case scope == models.SCOPE_CUSTOMER:
+		case scope == models.PERM_API_KEYS_MANAGE:
+			return nil, fmt.Errorf("api keys can not be given the %q scope", scope)
+		case validatePermissions([]string{scope}) != nil:
+			return nil, fmt.Errorf("unknown scope %q", scope)
+		case !slices.Contains(granted, scope):
+			return nil, fmt.Errorf("the %s role does not grant %q", owner.Role, scope)
+		}
+		checked = append(checked, scope)
+	}
+	return checked, nil
+}
+
+// checkCaller returns an error if p may not issue a key with scopes for
+// owner. Like roles, a key can only be given permissions the caller holds,
+// and only admins can issue keys for staff other than themselves.
+func checkCaller(p authz.Principal, owner *models.User, scopes []string) error {
+	if p.Role != models.ROLE_ADMIN {
+		if owner.Role == models.ROLE_ADMIN {
+			return fmt.Errorf("%w: only admins can create keys for admins", authz.ErrForbidden)
+		}
+		if owner.Role != models.ROLE_USER && owner.Id != p.UserID {
+			return fmt.Errorf("%w: only admins can create keys for staff accounts", authz.ErrForbidden)
+		}
+	}
+
+	for _, scope := range scopes {
+		if scope != models.SCOPE_CUSTOMER && !p.Has(scope) {
+			return fmt.Errorf("%w: you do not hold the %s permission", authz.ErrForbidden, scope)
+		}
+	}
+	return nil
+}
+
+func apiKeyAudit(event string, p authz.Principal, key *models.APIKey, now time.Time) *models.AuditLog {
+	return &models.AuditLog{
+		Event:     event,
+		UserID:    &key.UserID,
+		ActorID:   &p.UserID,
+		CreatedAt: now,
+	}
+}
+
+// generateAPIKey returns a new key and its prefix. The prefix is 40 random
+// bits and the secret 160, e.g. "cdk_k3pd9xqa_ma2f7rtn...".
+func generateAPIKey() (key, prefix string, err error) {
+	b := make([]byte, 25)
+	if _, err := rand.Read(b); err != nil {
+		return "", "", err
+	}
+
+	s := strings.ToLower(base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b))
+	return models.API_KEY_PREFIX + s[:8] + "_" + s[8:], s[:8], nil
+}
+
+// parseAPIKey returns the prefix of key, or false if key is not shaped like
+// one.
+func parseAPIKey(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, models.API_KEY_PREFIX)
+	if !ok {
+		return "", false
+	}
+	prefix, secret, ok := strings.Cut(rest, "_")
+	if !ok || len(prefix) != 8 || secret == "" {
+		return "", false
+	}
+	return prefix, true
+}
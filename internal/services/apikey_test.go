@@ -0,0 +1,85 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyFormat(t *testing.T) {
+	key, prefix, err := generateAPIKey()
+	require.NoError(t, err)
+	require.Regexp(t, `^cdk_[a-z2-7]{8}_[a-z2-7]{32}$`, key)
+
+	parsed, ok := parseAPIKey(key)
+	require.True(t, ok)
+	require.Equal(t, prefix, parsed)
+
+	for _, bad := range []string{"", "cdk_", "cdk_short_secret", "cdk_abcdefgh_", "abc_abcdefgh_secret", "eyJhbGciOiJIUzI1NiJ9.e30.sig"} {
+		_, ok := parseAPIKey(bad)
+		require.False(t, ok, bad)
+	}
+}
+
+func TestAPIKeyActive(t *testing.T) {
+	now := time.Now()
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+
+	require.True(t, (&models.APIKey{}).Active(now))
+	require.True(t, (&models.APIKey{ExpiresAt: &later}).Active(now))
+	require.False(t, (&models.APIKey{ExpiresAt: &earlier}).Active(now))
+	require.False(t, (&models.APIKey{RevokedAt: &earlier}).Active(now))
+}
+
+func TestAPIKeyScopes(t *testing.T) {
+	s := &APIKeyService{rbac: &RBACService{cache: map[string]cachedPermissions{
+		"pos": {permissions: []string{models.PERM_KITCHEN_OPERATE, models.PERM_ORDERS_MANAGE}, loadedAt: time.Now()},
+	}}}
+	owner := &models.User{Role: "pos"}
+
+	scopes, err := s.checkScopes(context.Background(), owner, []string{models.PERM_KITCHEN_OPERATE, models.SCOPE_CUSTOMER, models.PERM_KITCHEN_OPERATE})
+	require.NoError(t, err)
+	require.Equal(t, []string{models.PERM_KITCHEN_OPERATE, models.SCOPE_CUSTOMER}, scopes)
+
+	// Keys get nothing the owner's role does not grant
+	_, err = s.checkScopes(context.Background(), owner, []string{models.PERM_REFUNDS_ISSUE})
+	require.Error(t, err)
+
+	_, err = s.checkScopes(context.Background(), owner, []string{"orders:delete"})
+	require.Error(t, err)
+
+	// Not even for admins can keys manage keys
+	s.rbac.cache[models.ROLE_ADMIN] = cachedPermissions{permissions: []string{models.PERM_API_KEYS_MANAGE}, loadedAt: time.Now()}
+	_, err = s.checkScopes(context.Background(), &models.User{Role: models.ROLE_ADMIN}, []string{models.PERM_API_KEYS_MANAGE})
+	require.Error(t, err)
+}
+
+func TestAPIKeyCaller(t *testing.T) {
+	manager := authz.Principal{UserID: 1, Role: "manager", Permissions: []string{models.PERM_API_KEYS_MANAGE, models.PERM_ORDERS_MANAGE}}
+	admin := authz.Principal{UserID: 2, Role: models.ROLE_ADMIN, Permissions: []string{models.PERM_API_KEYS_MANAGE, models.PERM_ORDERS_MANAGE, models.PERM_REFUNDS_ISSUE}}
+	customer := &models.User{Id: 3, Role: models.ROLE_USER}
+	barista := &models.User{Id: 4, Role: models.ROLE_BARISTA}
+	otherAdmin := &models.User{Id: 5, Role: models.ROLE_ADMIN}
+
+	t.Run("scopes must be held by the caller", func(t *testing.T) {
+		require.NoError(t, checkCaller(manager, customer, []string{models.SCOPE_CUSTOMER, models.PERM_ORDERS_MANAGE}))
+		require.ErrorIs(t, checkCaller(manager, customer, []string{models.PERM_REFUNDS_ISSUE}), authz.ErrForbidden)
+		require.ErrorIs(t, checkCaller(admin, customer, []string{models.PERM_KITCHEN_OPERATE}), authz.ErrForbidden)
+	})
+
+	t.Run("only admins issue keys for staff", func(t *testing.T) {
+		require.ErrorIs(t, checkCaller(manager, barista, nil), authz.ErrForbidden)
+		require.NoError(t, checkCaller(manager, &models.User{Id: manager.UserID, Role: manager.Role}, nil))
+		require.NoError(t, checkCaller(admin, barista, nil))
+	})
+
+	t.Run("only admins issue keys for admins", func(t *testing.T) {
+		require.ErrorIs(t, checkCaller(manager, otherAdmin, nil), authz.ErrForbidden)
+		require.NoError(t, checkCaller(admin, otherAdmin, nil))
+	})
+}
@@ -0,0 +1,478 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+	"golang.org/x/crypto/bcrypt"
+)
+
+const (
+	defaultAccessTokenTTL  = 15 * time.Minute
+	defaultRefreshTokenTTL = 30 * 24 * time.Hour
+
+	// A login waiting for its second factor must be finished within
+	// twoFactorChallengeTTL and allows maxTwoFactorAttempts wrong codes
+	twoFactorChallengeTTL = 5 * time.Minute
+	maxTwoFactorAttempts  = 5
+
+	// A reauth token must be used within reauthTokenTTL of signing in again
+	reauthTokenTTL = 5 * time.Minute
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+
+	// ErrRefreshTokenReused is returned when a refresh token is presented
+	// again after it was rotated. The session it belongs to is revoked.
+	ErrRefreshTokenReused = errors.New("refresh token has already been used; the session has been revoked")
+
+	ErrInvalidChallenge = errors.New("invalid or expired login challenge")
+
+	ErrConfirmationRequired = errors.New("confirm with your password, a two-factor code or by signing in again")
+	ErrInvalidConfirmation  = errors.New("invalid password, code or reauth token")
+)
+
+// AuthService signs users in and manages their sessions. A session is a
+// chain of refresh tokens started by a login. Access tokens are short lived
+// JWTs carrying the session ID ("sid"), so revoking the session also rejects
+// its access tokens before they expire.
+//
+// Users with two-factor authentication enabled sign in in two steps: Login
+// checks the password and returns a challenge, and LoginTwoFactor exchanges
+// the challenge and a code for a session.
+type AuthService struct {
+	userRepo  *repository.UserRepository
+	tokenRepo *repository.TokenRepository
+	twoFactor *TwoFactorService
+	lockout   *LockoutService
+
+	jwtSecret  string
+	accessTTL  time.Duration
+	refreshTTL time.Duration
+}
+
+func NewAuthService(
+	userRepo *repository.UserRepository,
+	tokenRepo *repository.TokenRepository,
+	twoFactor *TwoFactorService,
+	lockout *LockoutService,
+) *AuthService {
+	svc := &AuthService{
+		userRepo:   userRepo,
+		tokenRepo:  tokenRepo,
+		twoFactor:  twoFactor,
+		lockout:    lockout,
+		jwtSecret:  os.Getenv("SECRET"),
+		accessTTL:  defaultAccessTokenTTL,
+		refreshTTL: defaultRefreshTokenTTL,
+	}
+	if twoFactor != nil {
+		twoFactor.authService = svc
+	}
+
+	if v, err := strconv.Atoi(os.Getenv("ACCESS_TOKEN_TTL_MINUTES")); err == nil && v > 0 {
+		svc.accessTTL = time.Duration(v) * time.Minute
+	}
+	if v, err := strconv.Atoi(os.Getenv("REFRESH_TOKEN_TTL_DAYS")); err == nil && v > 0 {
+		svc.refreshTTL = time.Duration(v) * 24 * time.Hour
+	}
+
+	return svc
+}
+
+// Login checks the user's credentials and starts a new session. If the
+// user has two-factor authentication enabled, or their role requires it, a
+// challenge for the second step is returned instead. Logins for an account
+// or from an ip with recent failures are throttled.
+func (s *AuthService) Login(ctx context.Context, email, password, ip string) (*models.TokenPair, *models.TwoFactorChallenge, error) {
+	if err := s.lockout.Attempt(ctx, email, ip); err != nil {
+		return nil, nil, err
+	}
+
+	user, _ := s.userRepo.GetUserByEmail(ctx, email)
+	if user == nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password)) != nil {
+		if err := s.lockout.RecordFailure(ctx, email, ip, user); err != nil {
+			logrus.Errorf("error recording failed login: %v", err)
+		}
+		return nil, nil, errors.New("invalid credentials")
+	}
+
+	if err := s.lockout.RecordSuccess(ctx, ip); err != nil {
+		logrus.Errorf("error recording successful login: %v", err)
+	}
+
+	tokens, challenge, err := s.signIn(ctx, user)
+	if err != nil || challenge != nil {
+		return tokens, challenge, err
+	}
+
+	if err := s.lockout.RecordLogin(ctx, email); err != nil {
+		logrus.Errorf("error clearing failed logins: %v", err)
+	}
+	return tokens, nil, nil
+}
+
+// Confirm checks that a signed in user is who they say they are before a
+// sensitive change, with any one of the ways in c. Wrong attempts count
+// against the account like failed logins.
+func (s *AuthService) Confirm(ctx context.Context, user *models.User, c models.Confirmation) error {
+	if c.Password == "" && c.Code == "" && c.RecoveryCode == "" && c.ReauthToken == "" {
+		return ErrConfirmationRequired
+	}
+	if err := s.lockout.Attempt(ctx, user.Email, ""); err != nil {
+		return err
+	}
+
+	var err error
+	switch {
+	case c.Password != "":
+		if bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(c.Password)) != nil {
+			err = ErrInvalidConfirmation
+		}
+	case c.ReauthToken != "":
+		err = s.useReauthToken(ctx, user, c.ReauthToken)
+	case user.TwoFactorEnabled:
+		err = s.twoFactor.verify(ctx, user, c.Code, c.RecoveryCode)
+	default:
+		err = ErrInvalidConfirmation
+	}
+	if errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrInvalidTwoFactorCode) {
+		err = ErrInvalidConfirmation
+	}
+	if errors.Is(err, ErrInvalidConfirmation) {
+		if ferr := s.lockout.RecordFailure(ctx, user.Email, "", user); ferr != nil {
+			logrus.Errorf("error recording failed confirmation: %v", ferr)
+		}
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.lockout.RecordLogin(ctx, user.Email); err != nil {
+		logrus.Errorf("error clearing failed logins: %v", err)
+	}
+	return nil
+}
+
+// SetupTwoFactor starts two-factor enrollment during a login that requires
+// it. The code from the new secret is then passed to LoginTwoFactor.
+func (s *AuthService) SetupTwoFactor(ctx context.Context, challengeToken string) (*models.TwoFactorEnrollment, error) {
+	_, user, err := s.getChallenge(ctx, challengeToken)
+	if err != nil {
+		return nil, err
+	}
+	return s.twoFactor.enroll(ctx, user)
+}
+
+// LoginTwoFactor finishes a login with a TOTP or recovery code. A login
+// that had to enroll the user also enables two-factor authentication and
+// returns their recovery codes with the tokens.
+func (s *AuthService) LoginTwoFactor(ctx context.Context, req *models.TwoFactorLoginRequest, ip string) (*models.TokenPair, error) {
+	challenge, user, err := s.getChallenge(ctx, req.ChallengeToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Codes are guessed against the same count as passwords
+	if err := s.lockout.Attempt(ctx, user.Email, ip); err != nil {
+		return nil, err
+	}
+
+	var recoveryCodes []string
+	if user.TwoFactorEnabled {
+		err = s.twoFactor.verify(ctx, user, req.Code, req.RecoveryCode)
+	} else {
+		recoveryCodes, err = s.twoFactor.confirm(ctx, user, req.Code)
+	}
+	if err != nil {
+		if errors.Is(err, ErrInvalidTwoFactorCode) {
+			if ferr := s.tokenRepo.FailUserToken(ctx, challenge.Id, maxTwoFactorAttempts, util.CurrentTime()); ferr != nil {
+				return nil, ferr
+			}
+			if ferr := s.lockout.RecordFailure(ctx, user.Email, ip, user); ferr != nil {
+				logrus.Errorf("error recording failed login: %v", ferr)
+			}
+		}
+		return nil, err
+	}
+
+	used, err := s.tokenRepo.UseUserToken(ctx, challenge.Id, util.CurrentTime())
+	if err != nil {
+		return nil, err
+	}
+	if !used {
+		return nil, ErrInvalidChallenge
+	}
+
+	tokens, err := s.startSession(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+	tokens.RecoveryCodes = recoveryCodes
+
+	if err := s.lockout.RecordSuccess(ctx, ip); err != nil {
+		logrus.Errorf("error recording successful login: %v", err)
+	}
+	if err := s.lockout.RecordLogin(ctx, user.Email); err != nil {
+		logrus.Errorf("error clearing failed logins: %v", err)
+	}
+	return tokens, nil
+}
+
+// Refresh exchanges a refresh token for a new token pair. The presented
+// token can not be used again.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*models.TokenPair, error) {
+	current, err := s.tokenRepo.GetRefreshToken(ctx, util.HashToken(refreshToken))
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	now := util.CurrentTime()
+	if current.RevokedAt != nil || !now.Before(current.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+	if current.RotatedAt != nil {
+		return nil, s.revokeReusedSession(ctx, current)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, current.UserID)
+	if err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Sessions started before two-factor authentication became required
+	// for the user's role end at the next refresh
+	if !user.TwoFactorEnabled && s.twoFactor.Required(user) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	token, next, err := s.newRefreshToken(user.Id, current.SessionID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	rotated, err := s.tokenRepo.RotateRefreshToken(ctx, current, next)
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another request rotated the token first
+		return nil, s.revokeReusedSession(ctx, current)
+	}
+
+	return s.tokenPair(user, current.SessionID, token, now)
+}
+
+// Logout revokes the session the access token was issued for.
+func (s *AuthService) Logout(ctx context.Context, userId uint, sessionId string) error {
+	if sessionId == "" {
+		return errors.New("token is not tied to a session")
+	}
+	return s.tokenRepo.RevokeSession(ctx, userId, sessionId, models.REVOKE_LOGOUT, s.revokedUntil())
+}
+
+// LogoutAll revokes every session of the user, for example after a device
+// was lost. It returns the number of sessions revoked.
+func (s *AuthService) LogoutAll(ctx context.Context, userId uint) (int, error) {
+	return s.tokenRepo.RevokeUserSessions(ctx, userId, "", models.REVOKE_LOGOUT_ALL, s.revokedUntil())
+}
+
+// LogoutOthers revokes every session of the user except the current one,
+// after their password was changed.
+func (s *AuthService) LogoutOthers(ctx context.Context, userId uint, sessionId string) (int, error) {
+	return s.tokenRepo.RevokeUserSessions(ctx, userId, sessionId, models.REVOKE_PASSWORD, s.revokedUntil())
+}
+
+// IsRevoked reports whether the access token with the given claims may no
+// longer be used. Tokens without a session were issued before sessions
+// existed and are rejected.
+func (s *AuthService) IsRevoked(ctx context.Context, claims jwt.MapClaims) (bool, error) {
+	sessionId, _ := claims["sid"].(string)
+	if sessionId == "" {
+		return true, nil
+	}
+	return s.tokenRepo.IsSessionRevoked(ctx, sessionId)
+}
+
+// DeleteExpiredTokens removes refresh tokens, revocations, emailed tokens and
+// social login states that can no longer matter.
+func (s *AuthService) DeleteExpiredTokens(ctx context.Context) (int64, error) {
+	return s.tokenRepo.DeleteExpired(ctx, util.CurrentTime())
+}
+
+// signIn starts a session for a user whose first factor has been checked,
+// or returns a challenge for the second.
+func (s *AuthService) signIn(ctx context.Context, user *models.User) (*models.TokenPair, *models.TwoFactorChallenge, error) {
+	if user.TwoFactorEnabled || s.twoFactor.Required(user) {
+		challenge, err := s.newChallenge(ctx, user)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, challenge, nil
+	}
+
+	tokens, err := s.startSession(ctx, user)
+	return tokens, nil, err
+}
+
+func (s *AuthService) newChallenge(ctx context.Context, user *models.User) (*models.TwoFactorChallenge, error) {
+	token, err := util.GenerateToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating login challenge, %w", err)
+	}
+
+	now := util.CurrentTime()
+	err = s.tokenRepo.CreateUserToken(ctx, &models.UserToken{
+		UserID:    user.Id,
+		Purpose:   models.TOKEN_TWO_FACTOR,
+		Email:     user.Email,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: now.Add(twoFactorChallengeTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TwoFactorChallenge{
+		ChallengeToken: token,
+		SetupRequired:  !user.TwoFactorEnabled,
+		ExpiresIn:      int64(twoFactorChallengeTTL.Seconds()),
+	}, nil
+}
+
+// getChallenge returns an unused, unexpired login challenge and its user.
+func (s *AuthService) getChallenge(ctx context.Context, token string) (*models.UserToken, *models.User, error) {
+	challenge, err := s.tokenRepo.GetUserToken(ctx, models.TOKEN_TWO_FACTOR, util.HashToken(token))
+	if err != nil {
+		return nil, nil, err
+	}
+	if challenge == nil || challenge.UsedAt != nil || !util.CurrentTime().Before(challenge.ExpiresAt) {
+		return nil, nil, ErrInvalidChallenge
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, challenge.UserID)
+	if err != nil {
+		return nil, nil, ErrInvalidChallenge
+	}
+	return challenge, user, nil
+}
+
+// newReauthToken returns a token confirming that user has just signed in
+// again.
+func (s *AuthService) newReauthToken(ctx context.Context, user *models.User) (*models.ReauthToken, error) {
+	token, err := util.GenerateToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating reauth token, %w", err)
+	}
+
+	now := util.CurrentTime()
+	err = s.tokenRepo.CreateUserToken(ctx, &models.UserToken{
+		UserID:    user.Id,
+		Purpose:   models.TOKEN_REAUTH,
+		Email:     user.Email,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: now.Add(reauthTokenTTL),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &models.ReauthToken{ReauthToken: token, ExpiresIn: int64(reauthTokenTTL.Seconds())}, nil
+}
+
+// useReauthToken redeems a reauth token issued to user.
+func (s *AuthService) useReauthToken(ctx context.Context, user *models.User, token string) error {
+	reauth, err := s.tokenRepo.GetUserToken(ctx, models.TOKEN_REAUTH, util.HashToken(token))
+	if err != nil {
+		return err
+	}
+	if reauth == nil || reauth.UserID != user.Id {
+		return ErrInvalidToken
+	}
+
+	used, err := s.tokenRepo.UseUserToken(ctx, reauth.Id, util.CurrentTime())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func (s *AuthService) startSession(ctx context.Context, user *models.User) (*models.TokenPair, error) {
+	now := util.CurrentTime()
+	sessionId := util.GenerateReference()
+
+	token, refresh, err := s.newRefreshToken(user.Id, sessionId, now)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.tokenRepo.CreateRefreshToken(ctx, refresh); err != nil {
+		return nil, err
+	}
+
+	return s.tokenPair(user, sessionId, token, now)
+}
+
+func (s *AuthService) newRefreshToken(userId uint, sessionId string, now time.Time) (string, *models.RefreshToken, error) {
+	token, err := util.GenerateToken(32)
+	if err != nil {
+		return "", nil, fmt.Errorf("error generating refresh token, %w", err)
+	}
+
+	return token, &models.RefreshToken{
+		UserID:    userId,
+		SessionID: sessionId,
+		TokenHash: util.HashToken(token),
+		ExpiresAt: now.Add(s.refreshTTL),
+		CreatedAt: now,
+	}, nil
+}
+
+func (s *AuthService) tokenPair(user *models.User, sessionId, refreshToken string, now time.Time) (*models.TokenPair, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"user_id": fmt.Sprint(user.Id),
+		"role":    user.Role,
+		"sid":     sessionId,
+		"iat":     now.Unix(),
+		"exp":     now.Add(s.accessTTL).Unix(),
+	})
+
+	accessToken, err := token.SignedString([]byte(s.jwtSecret))
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(s.accessTTL.Seconds()),
+	}, nil
+}
+
+func (s *AuthService) revokeReusedSession(ctx context.Context, token *models.RefreshToken) error {
+	logrus.Warnf("refresh token reuse detected for user %d, revoking session %s", token.UserID, token.SessionID)
+	if err := s.tokenRepo.RevokeSession(ctx, token.UserID, token.SessionID, models.REVOKE_REUSE, s.revokedUntil()); err != nil {
+		return err
+	}
+	return ErrRefreshTokenReused
+}
+
+// revokedUntil is how long a revoked session must stay on the revocation
+// list: until the last access token issued for it has expired.
+func (s *AuthService) revokedUntil() time.Time {
+	return util.CurrentTime().Add(s.accessTTL)
+}
@@ -0,0 +1,39 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTokenPairClaims(t *testing.T) {
+	s := &AuthService{jwtSecret: "test-secret", accessTTL: 15 * time.Minute}
+	now := time.Now().UTC()
+
+	pair, err := s.tokenPair(&models.User{Id: 7, Role: models.ROLE_ADMIN}, "session-1", "refresh", now)
+	require.NoError(t, err)
+	require.Equal(t, "Bearer", pair.TokenType)
+	require.Equal(t, int64(900), pair.ExpiresIn)
+	require.Equal(t, "refresh", pair.RefreshToken)
+
+	token, err := jwt.Parse(pair.AccessToken, func(*jwt.Token) (interface{}, error) { return []byte("test-secret"), nil })
+	require.NoError(t, err)
+
+	claims := token.Claims.(jwt.MapClaims)
+	require.Equal(t, "7", claims["user_id"])
+	require.Equal(t, models.ROLE_ADMIN, claims["role"])
+	require.Equal(t, "session-1", claims["sid"])
+	require.Equal(t, float64(now.Add(15*time.Minute).Unix()), claims["exp"])
+}
+
+func TestIsRevokedWithoutSession(t *testing.T) {
+	// Tokens issued before sessions existed carry no sid and are rejected
+	// without a lookup.
+	revoked, err := (&AuthService{}).IsRevoked(context.Background(), jwt.MapClaims{"user_id": "7"})
+	require.NoError(t, err)
+	require.True(t, revoked)
+}
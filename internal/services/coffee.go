@@ -21,7 +21,9 @@ func (s *CoffeeService) CreateCoffee(ctx context.Context, req *models.CreateCoff
 		Brand:       req.Brand,
 		Name:        req.Name,
 		Description: req.Description,
+		Category:    req.Category,
 		Price:       req.Price,
+		Currency:    util.NormalizeCurrency(req.Currency),
 		Quantity:    req.Quantity,
 		CreatedAt:   util.CurrentTime(),
 		UpdatedAt:   util.CurrentTime(),
@@ -40,7 +42,9 @@ func (s *CoffeeService) UpdateCoffee(ctx context.Context, id uint, req *models.U
 		Brand:       req.Brand,
 		Name:        req.Name,
 		Description: req.Description,
+		Category:    req.Category,
 		Price:       req.Price,
+		Currency:    util.NormalizeCurrency(req.Currency),
 		Quantity:    req.Quantity,
 		UpdatedAt:   util.CurrentTime(),
 	}
@@ -0,0 +1,207 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/shopspring/decimal"
+	"gorm.io/gorm"
+)
+
+var errInvalidCoupon = errors.New("invalid coupon code")
+
+type CouponService struct {
+	repo *repository.CouponRepository
+}
+
+func NewCouponService(repo *repository.CouponRepository) *CouponService {
+	return &CouponService{repo: repo}
+}
+
+func (s *CouponService) CreateCoupon(ctx context.Context, req *models.CreateCoupon) (*models.Coupon, error) {
+	coupon := models.Coupon{CreatedAt: util.CurrentTime()}
+	if err := applyCouponRequest(&coupon, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Create(ctx, &coupon); err != nil {
+		return nil, err
+	}
+	return &coupon, nil
+}
+
+func (s *CouponService) GetCoupon(ctx context.Context, id uint) (*models.Coupon, error) {
+	return s.repo.GetByID(ctx, id)
+}
+
+func (s *CouponService) ListCoupons(ctx context.Context) ([]models.Coupon, error) {
+	return s.repo.List(ctx)
+}
+
+func (s *CouponService) UpdateCoupon(ctx context.Context, id uint, req *models.CreateCoupon) (*models.Coupon, error) {
+	coupon, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyCouponRequest(coupon, req); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.Update(ctx, coupon); err != nil {
+		return nil, fmt.Errorf("error updating coupon, %w", err)
+	}
+	return coupon, nil
+}
+
+// DeactivateCoupon disables a coupon. Coupons are never deleted so that past
+// redemptions keep pointing at them.
+func (s *CouponService) DeactivateCoupon(ctx context.Context, id uint) error {
+	coupon, err := s.repo.GetByID(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	coupon.Active = false
+	coupon.UpdatedAt = util.CurrentTime()
+	return s.repo.Update(ctx, coupon)
+}
+
+func applyCouponRequest(coupon *models.Coupon, req *models.CreateCoupon) error {
+	value, err := util.ParseDecimal(req.Value)
+	if err != nil {
+		return err
+	}
+
+	if req.Type == models.COUPON_PERCENTAGE && value.GreaterThan(decimal.NewFromInt(100)) {
+		return errors.New("a percentage coupon cannot be more than 100% off")
+	}
+
+	minBasket := decimal.Zero
+	if req.MinBasket != "" {
+		if minBasket, err = util.ParseDecimal(req.MinBasket); err != nil {
+			return err
+		}
+	}
+
+	if req.StartsAt != nil && req.EndsAt != nil && !req.EndsAt.After(*req.StartsAt) {
+		return errors.New("coupon must end after it starts")
+	}
+
+	coupon.Code = normalizeCouponCode(req.Code)
+	coupon.Type = req.Type
+	coupon.Value = value.StringFixed(2)
+	coupon.Currency = ""
+	if req.Type == models.COUPON_FIXED {
+		coupon.Currency = util.NormalizeCurrency(req.Currency)
+	}
+	coupon.MinBasket = minBasket.StringFixed(2)
+	coupon.MaxRedemptions = req.MaxRedemptions
+	coupon.PerUserLimit = req.PerUserLimit
+	coupon.StartsAt = req.StartsAt
+	coupon.EndsAt = req.EndsAt
+	coupon.CoffeeIDs = req.CoffeeIDs
+	coupon.Categories = req.Categories
+	coupon.Active = req.Active == nil || *req.Active
+	coupon.UpdatedAt = util.CurrentTime()
+
+	return nil
+}
+
+func normalizeCouponCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+// couponLine is a basket line as seen by a coupon.
+type couponLine struct {
+	CoffeeID uint
+	Category string
+	Amount   decimal.Decimal
+}
+
+// computeCouponDiscount checks that a coupon can be applied to a basket in
+// the given currency at the given time and returns the discount it gives.
+// Usage limits are enforced separately, when the redemption is recorded.
+func computeCouponDiscount(coupon *models.Coupon, lines []couponLine, currency string, now time.Time) (decimal.Decimal, error) {
+	if !coupon.Active {
+		return decimal.Zero, errInvalidCoupon
+	}
+
+	if coupon.Type == models.COUPON_FIXED && coupon.Currency != currency {
+		return decimal.Zero, fmt.Errorf("this coupon can only be used for orders in %s", coupon.Currency)
+	}
+
+	if coupon.StartsAt != nil && now.Before(*coupon.StartsAt) {
+		return decimal.Zero, errors.New("this coupon is not valid yet")
+	}
+
+	if coupon.EndsAt != nil && !now.Before(*coupon.EndsAt) {
+		return decimal.Zero, errors.New("this coupon has expired")
+	}
+
+	subtotal, eligible := decimal.Zero, decimal.Zero
+	for _, l := range lines {
+		subtotal = subtotal.Add(l.Amount)
+
+		if couponAppliesTo(coupon, l) {
+			eligible = eligible.Add(l.Amount)
+		}
+	}
+
+	minBasket, _ := util.ParseDecimal(coupon.MinBasket)
+	if subtotal.LessThan(minBasket) {
+		return decimal.Zero, fmt.Errorf("this coupon requires a minimum basket of %s", minBasket.StringFixed(2))
+	}
+
+	if eligible.IsZero() {
+		return decimal.Zero, errors.New("this coupon does not apply to any item in your order")
+	}
+
+	value, _ := util.ParseDecimal(coupon.Value)
+
+	var discount decimal.Decimal
+	switch coupon.Type {
+	case models.COUPON_PERCENTAGE:
+		discount = eligible.Mul(value).Div(decimal.NewFromInt(100)).Round(2)
+	case models.COUPON_FIXED:
+		discount = value
+	default:
+		return decimal.Zero, errInvalidCoupon
+	}
+
+	// A coupon can never take more off than the items it applies to
+	if discount.GreaterThan(eligible) {
+		discount = eligible
+	}
+
+	return discount, nil
+}
+
+// couponAppliesTo reports whether a basket line is covered by the coupon's
+// coffee and category restrictions.
+func couponAppliesTo(coupon *models.Coupon, l couponLine) bool {
+	if len(coupon.CoffeeIDs) == 0 && len(coupon.Categories) == 0 {
+		return true
+	}
+
+	return slices.Contains(coupon.CoffeeIDs, l.CoffeeID) || (l.Category != "" && slices.Contains(coupon.Categories, l.Category))
+}
+
+// lookupCoupon fetches an active coupon by its code.
+func lookupCoupon(ctx context.Context, repo *repository.CouponRepository, code string) (*models.Coupon, error) {
+	coupon, err := repo.GetByCode(ctx, normalizeCouponCode(code))
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errInvalidCoupon
+		}
+		return nil, fmt.Errorf("error fetching coupon, %w", err)
+	}
+	return coupon, nil
+}
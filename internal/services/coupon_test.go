@@ -0,0 +1,71 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeCouponDiscount(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+	lines := []couponLine{
+		{CoffeeID: 1, Category: "beans", Amount: decimal.RequireFromString("30.00")},
+		{CoffeeID: 2, Category: "drinks", Amount: decimal.RequireFromString("10.00")},
+	}
+
+	t.Run("percentage off whole basket", func(t *testing.T) {
+		coupon := &models.Coupon{Type: models.COUPON_PERCENTAGE, Value: "15", MinBasket: "0", Active: true}
+
+		discount, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.NoError(t, err)
+		require.Equal(t, "6.00", discount.StringFixed(2))
+	})
+
+	t.Run("fixed off capped at eligible items", func(t *testing.T) {
+		coupon := &models.Coupon{Type: models.COUPON_FIXED, Currency: "NGN", Value: "25", MinBasket: "0", CoffeeIDs: []uint{2}, Active: true}
+
+		discount, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.NoError(t, err)
+		require.Equal(t, "10.00", discount.StringFixed(2))
+	})
+
+	t.Run("category restriction", func(t *testing.T) {
+		coupon := &models.Coupon{Type: models.COUPON_PERCENTAGE, Value: "50", MinBasket: "0", Categories: []string{"beans"}, Active: true}
+
+		discount, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.NoError(t, err)
+		require.Equal(t, "15.00", discount.StringFixed(2))
+	})
+
+	t.Run("minimum basket not met", func(t *testing.T) {
+		coupon := &models.Coupon{Type: models.COUPON_FIXED, Currency: "NGN", Value: "5", MinBasket: "50", Active: true}
+
+		_, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.Error(t, err)
+	})
+
+	t.Run("outside validity window", func(t *testing.T) {
+		ended := now.Add(-time.Hour)
+		coupon := &models.Coupon{Type: models.COUPON_FIXED, Currency: "NGN", Value: "5", MinBasket: "0", EndsAt: &ended, Active: true}
+
+		_, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.Error(t, err)
+	})
+
+	t.Run("fixed coupon in another currency", func(t *testing.T) {
+		coupon := &models.Coupon{Type: models.COUPON_FIXED, Currency: "USD", Value: "5", MinBasket: "0", Active: true}
+
+		_, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.Error(t, err)
+	})
+
+	t.Run("inactive coupon", func(t *testing.T) {
+		coupon := &models.Coupon{Type: models.COUPON_FIXED, Currency: "NGN", Value: "5", MinBasket: "0", Active: false}
+
+		_, err := computeCouponDiscount(coupon, lines, "NGN", now)
+		require.ErrorIs(t, err, errInvalidCoupon)
+	})
+}
@@ -0,0 +1,47 @@
+package services
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createUser stores a user with role and returns it. The email is derived
+// from name.
+func createUser(t *testing.T, db *gorm.DB, name, role string) *models.User {
+	t.Helper()
+
+	now := util.CurrentTime()
+	user := &models.User{
+		FirstName: name,
+		LastName:  "Test",
+		Email:     fmt.Sprintf("%s@example.com", name),
+		Password:  "!",
+		Role:      role,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	require.NoError(t, db.Create(user).Error)
+	return user
+}
+
+// createOrder stores a pending order of userId.
+func createOrder(t *testing.T, db *gorm.DB, userId uint, total string) *models.Order {
+	t.Helper()
+
+	order := &models.Order{
+		UserID:         userId,
+		Status:         models.ORDER_STATUS_PENDING,
+		Currency:       "NGN",
+		TotalAmount:    total,
+		FulfilmentType: models.FULFILMENT_PICKUP,
+		CreatedAt:      util.CurrentTime(),
+		UpdatedAt:      util.CurrentTime(),
+	}
+	require.NoError(t, db.Create(order).Error)
+	return order
+}
@@ -0,0 +1,269 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// courierPingTTL is how recent a courier's last ping must be for their
+// position to be used when choosing the nearest courier.
+const courierPingTTL = 10 * time.Minute
+
+type DeliveryService struct {
+	repo         *repository.DeliveryRepository
+	storeRepo    *repository.StoreRepository
+	userRepo     *repository.UserRepository
+	orderService *OrderService
+}
+
+func NewDeliveryService(
+	repo *repository.DeliveryRepository,
+	storeRepo *repository.StoreRepository,
+	userRepo *repository.UserRepository,
+	orderService *OrderService,
+) *DeliveryService {
+	return &DeliveryService{
+		repo:         repo,
+		storeRepo:    storeRepo,
+		userRepo:     userRepo,
+		orderService: orderService,
+	}
+}
+
+// OpenDeliveries opens a delivery when an order goes out for delivery and
+// closes it when the order is canceled.
+func OpenDeliveries(ds *DeliveryService, dispatcher *outbox.Dispatcher) {
+	dispatcher.Subscribe("deliveries", models.OUTBOX_ORDER_STATUS_CHANGED, ds.handleOrderStatus)
+}
+
+func (ds *DeliveryService) handleOrderStatus(ctx context.Context, event models.OutboxEvent) error {
+	var change models.OrderStatusChanged
+	if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+		return fmt.Errorf("error decoding outbox payload, %w", err)
+	}
+
+	switch change.Status {
+	case models.ORDER_STATUS_OUT_FOR_DELIVERY:
+		order, err := ds.orderService.GetOrder(ctx, change.OrderID)
+		if err != nil {
+			return fmt.Errorf("error fetching order, %w", err)
+		}
+
+		delivery := models.Delivery{
+			OrderID:   order.Id,
+			StoreID:   order.StoreID,
+			Status:    models.DELIVERY_UNASSIGNED,
+			CreatedAt: util.CurrentTime(),
+			UpdatedAt: util.CurrentTime(),
+		}
+		if err := ds.repo.CreateIfMissing(ctx, &delivery); err != nil {
+			return fmt.Errorf("error opening delivery for order %d, %w", order.Id, err)
+		}
+
+		// Deliveries left unassigned are picked up by the dispatch worker
+		if _, err := ds.Dispatch(ctx); err != nil {
+			logrus.Errorf("error dispatching deliveries: %v", err)
+		}
+
+	case models.ORDER_STATUS_CANCELED:
+		delivery, err := ds.repo.GetByOrderID(ctx, change.OrderID)
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("error fetching delivery, %w", err)
+		}
+		if err := ds.repo.UpdateStatus(ctx, delivery.Id, models.DELIVERY_CANCELED, util.CurrentTime()); err != nil {
+			return fmt.Errorf("error canceling delivery %d, %w", delivery.Id, err)
+		}
+	}
+	return nil
+}
+
+// Dispatch assigns unassigned deliveries to available couriers, preferring
+// the courier whose latest ping is closest to the delivery's store. It
+// returns the number of deliveries assigned.
+func (ds *DeliveryService) Dispatch(ctx context.Context) (int, error) {
+	pending, err := ds.repo.ListByStatus(ctx, models.DELIVERY_UNASSIGNED)
+	if err != nil {
+		return 0, fmt.Errorf("error fetching unassigned deliveries, %w", err)
+	}
+	if len(pending) == 0 {
+		return 0, nil
+	}
+
+	now := util.CurrentTime()
+	couriers, pings, err := ds.repo.ListAvailableCouriers(ctx, now.Add(-courierPingTTL))
+	if err != nil {
+		return 0, err
+	}
+
+	assigned := 0
+	for _, delivery := range pending {
+		for len(couriers) > 0 {
+			idx := ds.nearestCourier(ctx, delivery, couriers, pings)
+			courier := couriers[idx]
+
+			err := ds.repo.Assign(ctx, delivery.Id, courier.Id, now)
+			if err == nil || errors.Is(err, repository.ErrCourierBusy) {
+				// Either way the courier is no longer available; a busy one
+				// was given a delivery by a concurrent dispatcher
+				couriers = append(couriers[:idx], couriers[idx+1:]...)
+			}
+			if errors.Is(err, repository.ErrCourierBusy) {
+				continue
+			}
+
+			if err != nil {
+				logrus.Warnf("could not assign delivery %d: %v", delivery.Id, err)
+			} else {
+				assigned++
+			}
+			break
+		}
+	}
+
+	return assigned, nil
+}
+
+// nearestCourier returns the index of the courier closest to the delivery's
+// store. Couriers without a recent ping are only chosen if none has one.
+func (ds *DeliveryService) nearestCourier(ctx context.Context, delivery models.Delivery, couriers []models.User, pings map[uint]models.CourierLocation) int {
+	if delivery.StoreID == nil {
+		return 0
+	}
+
+	store, err := ds.storeRepo.GetStore(ctx, *delivery.StoreID)
+	if err != nil {
+		return 0
+	}
+
+	best, bestDistance := 0, math.Inf(1)
+	for i, courier := range couriers {
+		ping, ok := pings[courier.Id]
+		if !ok {
+			continue
+		}
+
+		distance := util.DistanceKm(store.Location(), util.GeoPoint{Latitude: ping.Latitude, Longitude: ping.Longitude})
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
+
+// AssignCourier lets an admin hand an unassigned delivery to a specific courier.
+func (ds *DeliveryService) AssignCourier(ctx context.Context, deliveryId, courierId uint) (*models.Delivery, error) {
+	courier, err := ds.userRepo.GetUserByID(ctx, courierId)
+	if err != nil || courier.Role != models.ROLE_COURIER {
+		return nil, errors.New("courier not found")
+	}
+
+	if err := ds.repo.Assign(ctx, deliveryId, courierId, util.CurrentTime()); err != nil {
+		return nil, err
+	}
+
+	return ds.repo.GetByID(ctx, deliveryId)
+}
+
+func (ds *DeliveryService) ListUnassigned(ctx context.Context) ([]models.Delivery, error) {
+	return ds.repo.ListByStatus(ctx, models.DELIVERY_UNASSIGNED)
+}
+
+func (ds *DeliveryService) ListCourierDeliveries(ctx context.Context, courierId uint) ([]models.Delivery, error) {
+	return ds.repo.ListCourierActive(ctx, courierId)
+}
+
+// RecordLocation stores a courier's location ping.
+func (ds *DeliveryService) RecordLocation(ctx context.Context, courierId uint, req *models.LocationPing) error {
+	ping := models.CourierLocation{
+		CourierID:  courierId,
+		Latitude:   req.Latitude,
+		Longitude:  req.Longitude,
+		RecordedAt: util.CurrentTime(),
+	}
+
+	return ds.repo.RecordPing(ctx, &ping)
+}
+
+// MarkDelivered completes a delivery carried by the courier and marks its
+// order as delivered.
+func (ds *DeliveryService) MarkDelivered(ctx context.Context, courierId, deliveryId uint) (*models.Delivery, error) {
+	delivery, err := ds.repo.GetByID(ctx, deliveryId)
+	if err != nil {
+		return nil, errors.New("delivery not found")
+	}
+
+	if delivery.CourierID == nil || *delivery.CourierID != courierId || delivery.Status != models.DELIVERY_ASSIGNED {
+		return nil, repository.ErrDeliveryNotAssigned
+	}
+
+	order, err := ds.orderService.GetOrder(ctx, delivery.OrderID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order, %w", err)
+	}
+	if err := validateStatusChange(order, models.ORDER_STATUS_DELIVERED); err != nil {
+		return nil, err
+	}
+
+	now := util.CurrentTime()
+	if err := ds.repo.MarkDelivered(ctx, delivery.Id, courierId, now); err != nil {
+		return nil, err
+	}
+
+	delivery.Status = models.DELIVERY_DELIVERED
+	delivery.DeliveredAt = &now
+	return delivery, nil
+}
+
+// Track returns the delivery status and courier position of an order the
+// principal may track.
+func (ds *DeliveryService) Track(ctx context.Context, p authz.Principal, orderId uint) (*models.DeliveryTracking, error) {
+	order, err := ds.orderService.GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, authz.ErrNotFound
+	}
+
+	// delivery is nil until the order has gone out for delivery.
+	delivery, _ := ds.repo.GetByOrderID(ctx, orderId)
+
+	if err := authz.Tracking(p, order, delivery); err != nil {
+		return nil, err
+	}
+
+	tracking := models.DeliveryTracking{
+		OrderID:     order.Id,
+		OrderStatus: order.Status,
+		Status:      models.DELIVERY_UNASSIGNED,
+	}
+	if delivery == nil {
+		return &tracking, nil
+	}
+
+	tracking.Status = delivery.Status
+	tracking.Latitude = delivery.LastLatitude
+	tracking.Longitude = delivery.LastLongitude
+	tracking.LastUpdatedAt = delivery.LastPingAt
+	tracking.DeliveredAt = delivery.DeliveredAt
+
+	if delivery.CourierID != nil {
+		if courier, err := ds.userRepo.GetUserByID(ctx, *delivery.CourierID); err == nil {
+			tracking.CourierName = courier.FirstName
+		}
+	}
+
+	return &tracking, nil
+}
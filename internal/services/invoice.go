@@ -0,0 +1,350 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/pdf"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+// SellerDetails identifies the business on invoices of orders whose store
+// has no seller details of its own.
+type SellerDetails struct {
+	Name      string
+	Address   string
+	VATNumber string
+}
+
+// LoadSellerDetails reads the default seller details from the environment.
+func LoadSellerDetails() SellerDetails {
+	seller := SellerDetails{
+		Name:      os.Getenv("INVOICE_SELLER_NAME"),
+		Address:   os.Getenv("INVOICE_SELLER_ADDRESS"),
+		VATNumber: os.Getenv("INVOICE_VAT_NUMBER"),
+	}
+	if seller.Name == "" {
+		seller.Name = "Coffee Delivery"
+	}
+	return seller
+}
+
+// InvoiceService issues invoices for paid orders and renders them as PDF
+// receipts.
+type InvoiceService struct {
+	repo      *repository.InvoiceRepository
+	orderRepo *repository.OrderRepository
+	userRepo  *repository.UserRepository
+	trxRepo   *repository.TransactionRepository
+	storeRepo *repository.StoreRepository
+
+	seller SellerDetails
+}
+
+func NewInvoiceService(
+	repo *repository.InvoiceRepository,
+	orderRepo *repository.OrderRepository,
+	userRepo *repository.UserRepository,
+	trxRepo *repository.TransactionRepository,
+	storeRepo *repository.StoreRepository,
+) *InvoiceService {
+	return &InvoiceService{
+		repo:      repo,
+		orderRepo: orderRepo,
+		userRepo:  userRepo,
+		trxRepo:   trxRepo,
+		storeRepo: storeRepo,
+		seller:    LoadSellerDetails(),
+	}
+}
+
+// PublishInvoices issues the invoice of an order as soon as its payment is
+// committed, so invoice numbers follow the order in which orders were paid.
+func PublishInvoices(is *InvoiceService, dispatcher *outbox.Dispatcher) {
+	dispatcher.Subscribe("invoices", models.OUTBOX_TRANSACTION_STATUS_CHANGED, func(ctx context.Context, event models.OutboxEvent) error {
+		var change models.TransactionChanged
+		if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+			return fmt.Errorf("error decoding outbox payload, %w", err)
+		}
+
+		if change.PaymentStatus != models.PAYMENT_COMPLETED {
+			return nil
+		}
+
+		_, err := is.Issue(ctx, change.OrderID)
+		return err
+	})
+}
+
+// Issue returns the invoice of a paid order, issuing it if it has not been
+// issued yet.
+func (is *InvoiceService) Issue(ctx context.Context, orderId uint) (*models.Invoice, error) {
+	invoice, err := is.repo.GetByOrderID(ctx, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching invoice, %w", err)
+	}
+	if invoice != nil {
+		return invoice, nil
+	}
+
+	order, err := is.orderRepo.GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching order, %w", err)
+	}
+
+	trx, err := is.trxRepo.GetCompletedTransaction(ctx, orderId)
+	if err != nil {
+		return nil, errors.New("order has not been paid")
+	}
+
+	user, err := is.userRepo.GetUserByID(ctx, order.UserID)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching user, %w", err)
+	}
+
+	now := util.CurrentTime()
+	invoice = &models.Invoice{
+		OrderID:          order.Id,
+		TransactionID:    trx.ID,
+		UserID:           order.UserID,
+		SellerName:       is.seller.Name,
+		SellerAddress:    is.seller.Address,
+		SellerVATNumber:  is.seller.VATNumber,
+		BuyerName:        strings.TrimSpace(user.FirstName + " " + user.LastName),
+		BuyerEmail:       user.Email,
+		BuyerCompany:     order.BillingCompany,
+		BuyerTaxID:       order.BillingTaxID,
+		BuyerAddress:     order.BillingAddress,
+		Currency:         order.Currency,
+		Subtotal:         order.Subtotal,
+		DiscountAmount:   order.DiscountAmount,
+		TaxRate:          order.TaxRate,
+		TaxInclusive:     order.TaxInclusive,
+		TaxAmount:        order.TaxAmount,
+		ServiceCharge:    order.ServiceCharge,
+		DeliveryFee:      order.DeliveryFee,
+		TotalAmount:      order.TotalAmount,
+		PaymentReference: trx.Reference,
+		IssuedAt:         now,
+		CreatedAt:        now,
+	}
+
+	if order.StoreID != nil {
+		store, err := is.storeRepo.GetStore(ctx, *order.StoreID)
+		if err != nil {
+			return nil, fmt.Errorf("error fetching store, %w", err)
+		}
+
+		invoice.StoreID = store.Id
+		if store.LegalName != "" {
+			invoice.SellerName = store.LegalName
+		}
+		if store.Address != "" {
+			invoice.SellerAddress = store.Address
+		}
+		if store.VATNumber != "" {
+			invoice.SellerVATNumber = store.VATNumber
+		}
+	}
+
+	return is.repo.Issue(ctx, invoice)
+}
+
+// ReceiptPDF returns the invoice of a paid order rendered as a PDF, provided
+// the principal may read the order.
+func (is *InvoiceService) ReceiptPDF(ctx context.Context, p authz.Principal, orderId uint) (*models.Invoice, []byte, error) {
+	order, err := is.orderRepo.GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, nil, errors.New("order not found")
+	}
+
+	if err := authz.Receipt(p, order); err != nil {
+		return nil, nil, err
+	}
+
+	invoice, err := is.Issue(ctx, orderId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return invoice, renderInvoice(invoice, order.OrderItems), nil
+}
+
+// renderInvoice lays out an invoice on A4 pages.
+func renderInvoice(invoice *models.Invoice, items []models.OrderItem) []byte {
+	const (
+		left   = 50.0
+		right  = pdf.PageWidth - 50
+		top    = pdf.PageHeight - 60
+		bottom = 80.0
+	)
+
+	doc := pdf.New("Invoice " + invoice.Number)
+	y := top
+
+	doc.Text(left, y, pdf.HelveticaBold, 20, "TAX INVOICE / RECEIPT")
+	y -= 30
+
+	// Seller on the left, invoice details on the right
+	sellerY := y
+	doc.Text(left, y, pdf.HelveticaBold, 11, invoice.SellerName)
+	if invoice.SellerAddress != "" {
+		y -= 14
+		doc.Text(left, y, pdf.Helvetica, 10, invoice.SellerAddress)
+	}
+	if invoice.SellerVATNumber != "" {
+		y -= 14
+		doc.Text(left, y, pdf.Helvetica, 10, "VAT No: "+invoice.SellerVATNumber)
+	}
+
+	detailsY := sellerY
+	for _, d := range [][2]string{
+		{"Invoice No:", invoice.Number},
+		{"Date:", invoice.IssuedAt.Format("02 Jan 2006")},
+		{"Order:", fmt.Sprintf("#%d", invoice.OrderID)},
+		{"Payment Ref:", invoice.PaymentReference},
+	} {
+		doc.Text(330, detailsY, pdf.Helvetica, 10, d[0])
+		doc.TextRight(right, detailsY, pdf.Courier, 10, d[1])
+		detailsY -= 14
+	}
+	y = min(y, detailsY) - 20
+
+	doc.Text(left, y, pdf.HelveticaBold, 11, "Bill to")
+	billTo := []string{invoice.BuyerCompany, invoice.BuyerName, invoice.BuyerEmail, invoice.BuyerAddress}
+	if invoice.BuyerTaxID != "" {
+		billTo = append(billTo, "Tax ID: "+invoice.BuyerTaxID)
+	}
+	for _, line := range billTo {
+		if line != "" {
+			y -= 14
+			doc.Text(left, y, pdf.Helvetica, 10, line)
+		}
+	}
+	y -= 30
+
+	// Column right edges of the item table
+	const (
+		colQty      = 300.0
+		colPrice    = 375.0
+		colDiscount = 435.0
+		colVAT      = 490.0
+	)
+	header := func() {
+		doc.Text(left, y, pdf.HelveticaBold, 10, "Item")
+		doc.TextRight(colQty, y, pdf.CourierBold, 10, "Qty")
+		doc.TextRight(colPrice, y, pdf.CourierBold, 10, "Price")
+		doc.TextRight(colDiscount, y, pdf.CourierBold, 10, "Disc.")
+		doc.TextRight(colVAT, y, pdf.CourierBold, 10, "VAT")
+		doc.TextRight(right, y, pdf.CourierBold, 10, "Total")
+		y -= 6
+		doc.Line(left, y, right, y, 0.5)
+		y -= 14
+	}
+	header()
+
+	for _, item := range items {
+		if y < bottom {
+			doc.AddPage()
+			y = top
+			header()
+		}
+
+		doc.Text(left, y, pdf.Helvetica, 10, truncate(item.Name, 40))
+		doc.TextRight(colQty, y, pdf.Courier, 10, fmt.Sprint(item.Quantity))
+		doc.TextRight(colPrice, y, pdf.Courier, 10, formatAmount(item.UnitPrice))
+		doc.TextRight(colDiscount, y, pdf.Courier, 10, formatAmount(item.Discount))
+		doc.TextRight(colVAT, y, pdf.Courier, 10, formatAmount(item.TaxAmount))
+		doc.TextRight(right, y, pdf.Courier, 10, formatAmount(item.LineTotal))
+		y -= 16
+	}
+
+	doc.Line(left, y+8, right, y+8, 0.5)
+	y -= 8
+	if y < bottom+140 {
+		doc.AddPage()
+		y = top
+	}
+
+	totals := [][2]string{
+		{"Subtotal", formatAmount(invoice.Subtotal)},
+		{"Discount", "-" + formatAmount(invoice.DiscountAmount)},
+		{"Taxable amount", formatAmount(taxableAmount(invoice, items))},
+		{fmt.Sprintf("VAT @ %s%%", invoice.TaxRate), formatAmount(invoice.TaxAmount)},
+		{"Service charge", formatAmount(invoice.ServiceCharge)},
+		{"Delivery fee", formatAmount(invoice.DeliveryFee)},
+	}
+	for _, t := range totals {
+		doc.Text(330, y, pdf.Helvetica, 10, t[0])
+		doc.TextRight(right, y, pdf.Courier, 10, t[1])
+		y -= 14
+	}
+
+	doc.Line(330, y+8, right, y+8, 0.5)
+	y -= 6
+	doc.Text(330, y, pdf.HelveticaBold, 12, "Total paid ("+invoice.Currency+")")
+	doc.TextRight(right, y, pdf.CourierBold, 12, formatAmount(invoice.TotalAmount))
+	y -= 30
+
+	if invoice.TaxInclusive {
+		doc.Text(left, y, pdf.Helvetica, 9, "Prices include VAT. Item totals are shown after discount.")
+	} else {
+		doc.Text(left, y, pdf.Helvetica, 9, "VAT is charged on the discounted item prices. Item totals include VAT.")
+	}
+	y -= 12
+	doc.Text(left, y, pdf.Helvetica, 9, "Paid in full. Thank you for your order.")
+
+	return doc.Bytes()
+}
+
+// taxableAmount is the total of the items excluding VAT.
+func taxableAmount(invoice *models.Invoice, items []models.OrderItem) string {
+	total, _ := util.ParseDecimal(invoice.TaxAmount)
+	total = total.Neg()
+	for _, item := range items {
+		line, _ := util.ParseDecimal(item.LineTotal)
+		total = total.Add(line)
+	}
+	return total.StringFixed(moneyPlaces)
+}
+
+// formatAmount formats a decimal amount with two decimal places and
+// thousands separators.
+func formatAmount(amount string) string {
+	d, err := util.ParseDecimal(amount)
+	if err != nil {
+		return amount
+	}
+
+	s := d.Abs().StringFixed(moneyPlaces)
+	whole, frac := s[:len(s)-3], s[len(s)-3:]
+
+	var b strings.Builder
+	if d.IsNegative() {
+		b.WriteByte('-')
+	}
+	for i, r := range whole {
+		if i > 0 && (len(whole)-i)%3 == 0 {
+			b.WriteByte(',')
+		}
+		b.WriteRune(r)
+	}
+	b.WriteString(frac)
+	return b.String()
+}
+
+func truncate(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[:n-3]) + "..."
+}
@@ -0,0 +1,52 @@
+package services
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFormatAmount(t *testing.T) {
+	require.Equal(t, "0.00", formatAmount("0"))
+	require.Equal(t, "999.50", formatAmount("999.5"))
+	require.Equal(t, "1,000.00", formatAmount("1000"))
+	require.Equal(t, "1,234,567.89", formatAmount("1234567.89"))
+	require.Equal(t, "-12,500.00", formatAmount("-12500"))
+}
+
+func TestRenderInvoice(t *testing.T) {
+	invoice := &models.Invoice{
+		Number:          models.InvoiceNumber(3, 42),
+		OrderID:         7,
+		SellerName:      "Bean There Ltd",
+		SellerVATNumber: "NG-123456",
+		BuyerName:       "Ada Obi",
+		BuyerCompany:    "Acme Corp",
+		BuyerTaxID:      "TIN-998877",
+		Currency:        "NGN",
+		Subtotal:        "3000.00",
+		DiscountAmount:  "0.00",
+		TaxRate:         "7.50",
+		TaxAmount:       "225.00",
+		ServiceCharge:   "0.00",
+		DeliveryFee:     "0.00",
+		TotalAmount:     "3225.00",
+		IssuedAt:        time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC),
+	}
+
+	var items []models.OrderItem
+	for i := 0; i < 60; i++ {
+		items = append(items, models.OrderItem{Name: "Flat White", Quantity: 1, UnitPrice: "50.00", Discount: "0.00", TaxAmount: "3.75", LineTotal: "53.75"})
+	}
+
+	out := renderInvoice(invoice, items)
+	require.True(t, bytes.HasPrefix(out, []byte("%PDF-")))
+	require.Contains(t, string(out), "INV-003-000042")
+	require.Contains(t, string(out), "VAT No: NG-123456")
+	require.Contains(t, string(out), "Tax ID: TIN-998877")
+	require.Contains(t, string(out), "/Count 2")
+	require.Equal(t, "3000.00", taxableAmount(invoice, items))
+}
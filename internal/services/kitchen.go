@@ -0,0 +1,132 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+// KitchenService drives the barista queue: paid orders are prepared item by
+// item, and an order becomes READY once all its items are done.
+type KitchenService struct {
+	orderRepo *repository.OrderRepository
+	trxRepo   *repository.TransactionRepository
+}
+
+func NewKitchenService(orderRepo *repository.OrderRepository, trxRepo *repository.TransactionRepository) *KitchenService {
+	return &KitchenService{
+		orderRepo: orderRepo,
+		trxRepo:   trxRepo,
+	}
+}
+
+// Queue returns paid orders that are not yet ready, soonest promised first.
+func (ks *KitchenService) Queue(ctx context.Context, storeId *uint) ([]models.OrderResponse, error) {
+	orders, err := ks.orderRepo.ListKitchenQueue(ctx, storeId)
+	if err != nil {
+		return nil, err
+	}
+
+	tickets := make([]models.OrderResponse, 0, len(orders))
+	for _, order := range orders {
+		tickets = append(tickets, order.ToOrderResponse())
+	}
+	return tickets, nil
+}
+
+// GetTicket returns an order if it belongs in the barista queue.
+func (ks *KitchenService) GetTicket(ctx context.Context, orderId uint) (*models.Order, error) {
+	order, err := ks.orderRepo.GetOrder(ctx, orderId)
+	if err != nil {
+		return nil, errors.New("order not found")
+	}
+
+	if order.Status != models.ORDER_STATUS_PENDING && order.Status != models.ORDER_STATUS_PREPARING {
+		return nil, repository.ErrNotAwaitingPrep
+	}
+
+	paid, err := ks.trxRepo.HasCompletedTransaction(ctx, orderId)
+	if err != nil {
+		return nil, fmt.Errorf("error checking payment, %w", err)
+	}
+	if !paid {
+		return nil, errors.New("order has not been paid")
+	}
+
+	return order, nil
+}
+
+// StartItem marks an item as being prepared. The first started item moves
+// the order to PREPARING. The item is only updated if it is still queued, so
+// two baristas can not both start it.
+func (ks *KitchenService) StartItem(ctx context.Context, orderId, itemId uint) (*models.Order, error) {
+	order, item, err := ks.ticketItem(ctx, orderId, itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := util.CurrentTime()
+	previous, err := ks.orderRepo.StartOrderItem(ctx, order.Id, item.Id, now)
+	if errors.Is(err, repository.ErrItemAlreadyStarted) || errors.Is(err, repository.ErrNotAwaitingPrep) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating item, %w", err)
+	}
+
+	item.PrepStatus = models.PREP_STARTED
+	item.StartedAt = &now
+	if previous == models.ORDER_STATUS_PENDING {
+		order.Status = models.ORDER_STATUS_PREPARING
+	}
+
+	return order, nil
+}
+
+// CompleteItem marks an item as done. Completing the last item moves the
+// order to READY.
+func (ks *KitchenService) CompleteItem(ctx context.Context, orderId, itemId uint) (*models.Order, error) {
+	order, item, err := ks.ticketItem(ctx, orderId, itemId)
+	if err != nil {
+		return nil, err
+	}
+
+	now := util.CurrentTime()
+	ready, err := ks.orderRepo.CompleteOrderItem(ctx, order.Id, item.Id, now)
+	if errors.Is(err, repository.ErrItemAlreadyDone) || errors.Is(err, repository.ErrNotAwaitingPrep) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error updating item, %w", err)
+	}
+
+	if item.StartedAt == nil {
+		item.StartedAt = &now
+	}
+	item.PrepStatus = models.PREP_DONE
+	item.DoneAt = &now
+	if ready {
+		order.Status = models.ORDER_STATUS_READY
+	}
+
+	return order, nil
+}
+
+// ticketItem loads a queued order and a pointer to one of its items.
+func (ks *KitchenService) ticketItem(ctx context.Context, orderId, itemId uint) (*models.Order, *models.OrderItem, error) {
+	order, err := ks.GetTicket(ctx, orderId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for i := range order.OrderItems {
+		if order.OrderItems[i].Id == itemId {
+			return order, &order.OrderItems[i], nil
+		}
+	}
+	return nil, nil, errors.New("item not found on order")
+}
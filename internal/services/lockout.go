@@ -0,0 +1,310 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// Failures older than throttleWindow no longer count
+	throttleWindow = time.Hour
+
+	auditLogLimit = 200
+
+	// Password resets may be requested a few times an hour for one email,
+	// and more often from one client for the same reason as logins
+	resetsPerAccount = 5
+	resetsPerIP      = 30
+)
+
+// ErrTooManyRequests is returned when password resets are requested too
+// often.
+var ErrTooManyRequests = errors.New("too many requests; please try again later")
+
+// lockoutPolicy describes how failed logins are throttled. After DelayAfter
+// failures each attempt must wait BaseDelay, doubling with every further
+// failure up to MaxDelay, and after LockAfter failures logins are refused
+// for LockFor.
+type lockoutPolicy struct {
+	DelayAfter int
+	BaseDelay  time.Duration
+	MaxDelay   time.Duration
+	LockAfter  int
+	LockFor    time.Duration
+}
+
+var (
+	accountPolicy = lockoutPolicy{DelayAfter: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockAfter: 10, LockFor: 15 * time.Minute}
+
+	// Clients are allowed many more failures than a single account, since
+	// several users may share an address
+	ipPolicy = lockoutPolicy{DelayAfter: 20, BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockAfter: 100, LockFor: 15 * time.Minute}
+)
+
+// ThrottledError is returned when a login is refused without checking the
+// password because of earlier failures.
+type ThrottledError struct {
+	RetryAfter time.Duration
+	Locked     bool
+}
+
+func (e *ThrottledError) Error() string {
+	if e.Locked {
+		minutes := (e.RetryAfter + time.Minute - 1).Truncate(time.Minute)
+		return fmt.Sprintf("too many failed logins; try again in %s", formatExpiry(minutes))
+	}
+	return "too many failed logins; please wait before trying again"
+}
+
+// LockoutService protects logins against password guessing. Login attempts
+// are counted per account and per client IP until they succeed; each
+// further attempt must wait longer, until logins are locked for a while.
+// Lockouts and unlocks are recorded in the audit log.
+type LockoutService struct {
+	repo     *repository.LockoutRepository
+	userRepo *repository.UserRepository
+}
+
+func NewLockoutService(repo *repository.LockoutRepository, userRepo *repository.UserRepository) *LockoutService {
+	return &LockoutService{repo: repo, userRepo: userRepo}
+}
+
+// Attempt counts a login attempt for email from ip before the password or
+// code is checked, and returns a ThrottledError instead if the account or
+// the IP must wait. Counting first means parallel guesses can not all be
+// let through on the same count. ip is empty when a signed in user confirms
+// a change, and only the account is counted.
+func (s *LockoutService) Attempt(ctx context.Context, email, ip string) error {
+	now := util.CurrentTime()
+	for _, k := range []struct {
+		key    string
+		policy lockoutPolicy
+	}{
+		{accountKey(email), accountPolicy},
+		{ipKey(ip), ipPolicy},
+	} {
+		if k.key == ipKey("") {
+			continue
+		}
+
+		throttle, err := s.repo.RecordAttempt(ctx, k.key, now, now.Add(-throttleWindow), k.policy.DelayAfter, k.policy.BaseDelay, k.policy.MaxDelay)
+		if err != nil {
+			return err
+		}
+		if throttle != nil {
+			continue
+		}
+
+		throttle, err = s.repo.GetThrottle(ctx, k.key)
+		if err != nil {
+			return err
+		}
+		if throttle == nil {
+			continue
+		}
+		wait, locked := k.policy.wait(throttle, now)
+		return &ThrottledError{RetryAfter: max(wait, time.Second), Locked: locked}
+	}
+	return nil
+}
+
+// RecordFailure locks the account or the IP if the attempt that just
+// failed has taken it to the limit. user is nil if there is no account for
+// email.
+func (s *LockoutService) RecordFailure(ctx context.Context, email, ip string, user *models.User) error {
+	now := util.CurrentTime()
+
+	throttle, err := s.repo.GetThrottle(ctx, accountKey(email))
+	if err != nil {
+		return err
+	}
+	if throttle != nil && throttle.Failures >= accountPolicy.LockAfter {
+		log := &models.AuditLog{Event: models.AUDIT_ACCOUNT_LOCKED, Email: email, IP: ip}
+		if user != nil {
+			log.UserID = &user.Id
+		}
+		if err := s.lock(ctx, throttle, accountPolicy, now, log); err != nil {
+			return err
+		}
+	}
+
+	if ip == "" {
+		return nil
+	}
+	throttle, err = s.repo.GetThrottle(ctx, ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if throttle != nil && throttle.Failures >= ipPolicy.LockAfter {
+		log := &models.AuditLog{Event: models.AUDIT_IP_LOCKED, IP: ip}
+		if err := s.lock(ctx, throttle, ipPolicy, now, log); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// RecordSuccess takes an attempt whose password or code was right back from
+// the count of the IP. The account keeps the attempt until RecordLogin, so
+// that a guessed password does not reset the count for guessing the second
+// factor.
+func (s *LockoutService) RecordSuccess(ctx context.Context, ip string) error {
+	return s.repo.Forgive(ctx, ipKey(ip))
+}
+
+// RecordLogin clears the attempts of the account once a login has passed
+// every step. Attempts of the IP are kept, so that an attacker can not
+// reset them by signing in to an account of their own.
+func (s *LockoutService) RecordLogin(ctx context.Context, email string) error {
+	_, err := s.repo.Reset(ctx, accountKey(email))
+	return err
+}
+
+// RecordResetRequest counts a password reset requested for email from ip,
+// and returns ErrTooManyRequests once either has asked too often within the
+// throttle window. The email is counted whether or not it has an account,
+// and is stored hashed since it may be anybody's address.
+func (s *LockoutService) RecordResetRequest(ctx context.Context, email, ip string) error {
+	now := util.CurrentTime()
+	for _, k := range []struct {
+		key   string
+		limit int
+	}{
+		{resetAccountKey(email), resetsPerAccount},
+		{resetIPKey(ip), resetsPerIP},
+	} {
+		// Past the limit, the next request must wait out the window
+		throttle, err := s.repo.RecordAttempt(ctx, k.key, now, now.Add(-throttleWindow), k.limit, throttleWindow, throttleWindow)
+		if err != nil {
+			return err
+		}
+		if throttle == nil {
+			return ErrTooManyRequests
+		}
+	}
+	return nil
+}
+
+// UnlockUser clears the failures and lock of a user's account.
+func (s *LockoutService) UnlockUser(ctx context.Context, p authz.Principal, userId uint) error {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	reset, err := s.repo.Reset(ctx, accountKey(user.Email))
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return nil
+	}
+
+	return s.repo.CreateAuditLog(ctx, &models.AuditLog{
+		Event:     models.AUDIT_ACCOUNT_UNLOCKED,
+		UserID:    &user.Id,
+		Email:     user.Email,
+		ActorID:   &p.UserID,
+		CreatedAt: util.CurrentTime(),
+	})
+}
+
+// UnlockIP clears the failures and lock of a client IP.
+func (s *LockoutService) UnlockIP(ctx context.Context, p authz.Principal, ip string) error {
+	reset, err := s.repo.Reset(ctx, ipKey(ip))
+	if err != nil {
+		return err
+	}
+	if !reset {
+		return nil
+	}
+
+	return s.repo.CreateAuditLog(ctx, &models.AuditLog{
+		Event:     models.AUDIT_IP_UNLOCKED,
+		IP:        ip,
+		ActorID:   &p.UserID,
+		CreatedAt: util.CurrentTime(),
+	})
+}
+
+// ListLockouts returns the most recent lockouts and unlocks.
+func (s *LockoutService) ListLockouts(ctx context.Context) ([]models.AuditLog, error) {
+	return s.repo.ListAuditLogs(ctx, []string{
+		models.AUDIT_ACCOUNT_LOCKED,
+		models.AUDIT_ACCOUNT_UNLOCKED,
+		models.AUDIT_IP_LOCKED,
+		models.AUDIT_IP_UNLOCKED,
+	}, auditLogLimit)
+}
+
+// DeleteStale removes throttles whose failures no longer count.
+func (s *LockoutService) DeleteStale(ctx context.Context) (int64, error) {
+	now := util.CurrentTime()
+	return s.repo.DeleteStale(ctx, now.Add(-throttleWindow), now)
+}
+
+func (s *LockoutService) lock(ctx context.Context, throttle *models.LoginThrottle, policy lockoutPolicy, now time.Time, log *models.AuditLog) error {
+	until := now.Add(policy.LockFor)
+	locked, err := s.repo.Lock(ctx, throttle.Key, now, until)
+	if err != nil {
+		return err
+	}
+	if !locked {
+		return nil
+	}
+
+	logrus.Warnf("locked logins for %s until %s after %d failures", throttle.Key, until.Format(time.RFC3339), throttle.Failures)
+
+	log.Detail = fmt.Sprintf("%d failed logins; locked until %s", throttle.Failures, until.Format(time.RFC3339))
+	log.CreatedAt = now
+	return s.repo.CreateAuditLog(ctx, log)
+}
+
+// wait returns how long the next attempt must wait, and whether that is
+// because of a lockout.
+func (p lockoutPolicy) wait(throttle *models.LoginThrottle, now time.Time) (time.Duration, bool) {
+	if throttle.LockedUntil != nil && now.Before(*throttle.LockedUntil) {
+		return throttle.LockedUntil.Sub(now), true
+	}
+	if now.Sub(throttle.LastFailureAt) >= throttleWindow || throttle.Failures < p.DelayAfter {
+		return 0, false
+	}
+
+	delay := p.BaseDelay
+	for i := p.DelayAfter; i < throttle.Failures && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+
+	if wait := throttle.LastFailureAt.Add(delay).Sub(now); wait > 0 {
+		return wait, false
+	}
+	return 0, false
+}
+
+func accountKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+func ipKey(ip string) string {
+	return "ip:" + ip
+}
+
+func resetAccountKey(email string) string {
+	return "reset:" + util.HashToken(strings.ToLower(strings.TrimSpace(email)))
+}
+
+func resetIPKey(ip string) string {
+	return "reset-ip:" + ip
+}
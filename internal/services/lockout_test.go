@@ -0,0 +1,66 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockoutPolicyWait(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	p := lockoutPolicy{DelayAfter: 3, BaseDelay: time.Second, MaxDelay: 30 * time.Second, LockAfter: 10, LockFor: 15 * time.Minute}
+
+	wait := func(failures int, ago time.Duration) time.Duration {
+		d, locked := p.wait(&models.LoginThrottle{Failures: failures, LastFailureAt: now.Add(-ago)}, now)
+		require.False(t, locked)
+		return d
+	}
+
+	require.Zero(t, wait(2, 0))
+	require.Equal(t, time.Second, wait(3, 0))
+	require.Equal(t, 4*time.Second, wait(5, 0))
+	require.Equal(t, 3*time.Second, wait(5, time.Second))
+	require.Equal(t, 30*time.Second, wait(9, 0))
+	require.Zero(t, wait(9, time.Minute))
+
+	// Failures outside the window no longer count
+	require.Zero(t, wait(9, throttleWindow))
+
+	until := now.Add(10 * time.Minute)
+	d, locked := p.wait(&models.LoginThrottle{Failures: 10, LastFailureAt: now, LockedUntil: &until}, now)
+	require.True(t, locked)
+	require.Equal(t, 10*time.Minute, d)
+}
+
+func TestThrottledErrorMessage(t *testing.T) {
+	err := &ThrottledError{RetryAfter: 14*time.Minute + 10*time.Second, Locked: true}
+	require.Equal(t, "too many failed logins; try again in 15 minutes", err.Error())
+}
+
+func TestLockoutAttempt(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	ls := NewLockoutService(repository.NewLockoutRepository(db), repository.NewUserRepository(db))
+
+	// Attempts count before the password is checked, so the attempt after
+	// the free ones must wait even if none has failed yet
+	for i := 0; i < accountPolicy.DelayAfter; i++ {
+		require.NoError(t, ls.Attempt(ctx, "guessed@example.com", "203.0.113.9"))
+	}
+	var throttled *ThrottledError
+	require.ErrorAs(t, ls.Attempt(ctx, "guessed@example.com", "203.0.113.9"), &throttled)
+	require.False(t, throttled.Locked)
+
+	// A right password takes the attempt back from the IP, but the account
+	// is only cleared once the login is complete
+	require.NoError(t, ls.RecordSuccess(ctx, "203.0.113.9"))
+	require.ErrorAs(t, ls.Attempt(ctx, "guessed@example.com", "203.0.113.10"), &throttled)
+
+	require.NoError(t, ls.RecordLogin(ctx, "guessed@example.com"))
+	require.NoError(t, ls.Attempt(ctx, "guessed@example.com", "203.0.113.10"))
+}
@@ -0,0 +1,381 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/notify"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+)
+
+// NotificationService renders and sends customer messages over email, SMS
+// and push according to each user's preferences, and keeps a log of what
+// was sent.
+type NotificationService struct {
+	repo      *repository.NotificationRepository
+	orderRepo *repository.OrderRepository
+	userRepo  *repository.UserRepository
+	trxRepo   *repository.TransactionRepository
+
+	templates *notify.Templates
+	channels  map[string]notify.Notifier
+}
+
+// NewNotificationService creates a NotificationService. sms and push may be
+// nil if those channels are not configured.
+func NewNotificationService(
+	repo *repository.NotificationRepository,
+	orderRepo *repository.OrderRepository,
+	userRepo *repository.UserRepository,
+	trxRepo *repository.TransactionRepository,
+	templates *notify.Templates,
+	email, sms, push notify.Notifier,
+) *NotificationService {
+	channels := map[string]notify.Notifier{models.CHANNEL_EMAIL: email}
+	if sms != nil {
+		channels[models.CHANNEL_SMS] = sms
+	}
+	if push != nil {
+		channels[models.CHANNEL_PUSH] = push
+	}
+
+	return &NotificationService{
+		repo:      repo,
+		orderRepo: orderRepo,
+		userRepo:  userRepo,
+		trxRepo:   trxRepo,
+		templates: templates,
+		channels:  channels,
+	}
+}
+
+// PublishNotifications sends order messages for committed changes recorded
+// in the outbox.
+func PublishNotifications(ns *NotificationService, dispatcher *outbox.Dispatcher) {
+	dispatcher.Subscribe("notifications", models.OUTBOX_ORDER_CREATED, ns.onOrderEvent)
+	dispatcher.Subscribe("notifications", models.OUTBOX_ORDER_STATUS_CHANGED, ns.onOrderEvent)
+	dispatcher.Subscribe("notifications", models.OUTBOX_TRANSACTION_CREATED, ns.onTransactionEvent)
+	dispatcher.Subscribe("notifications", models.OUTBOX_TRANSACTION_STATUS_CHANGED, ns.onTransactionEvent)
+}
+
+func (ns *NotificationService) onOrderEvent(ctx context.Context, event models.OutboxEvent) error {
+	var change models.OrderStatusChanged
+	if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+		return fmt.Errorf("error decoding outbox payload, %w", err)
+	}
+
+	var template string
+	switch {
+	case event.Type == models.OUTBOX_ORDER_CREATED:
+		template = notify.TEMPLATE_ORDER_CONFIRMATION
+	case change.Status == models.ORDER_STATUS_CANCELED:
+		template = notify.TEMPLATE_ORDER_CANCELLATION
+	case change.Status == models.ORDER_STATUS_READY:
+		template = notify.TEMPLATE_ORDER_READY
+	default:
+		return nil
+	}
+
+	order, err := ns.orderRepo.GetOrder(ctx, change.OrderID)
+	if err != nil {
+		return fmt.Errorf("error fetching order, %w", err)
+	}
+
+	// Delivery customers are not waiting at the counter
+	if template == notify.TEMPLATE_ORDER_READY && order.FulfilmentType != models.FULFILMENT_PICKUP {
+		return nil
+	}
+
+	user, err := ns.userRepo.GetUserByID(ctx, order.UserID)
+	if err != nil {
+		return fmt.Errorf("error fetching user, %w", err)
+	}
+
+	key := outboxEventId(event) + "_" + template
+	data := notify.OrderEmail{Name: user.FirstName, Order: *order}
+
+	var errs []error
+	if user.NotifyEmail {
+		errs = append(errs, ns.sendEmail(ctx, key, user, template, data))
+	}
+
+	if template == notify.TEMPLATE_ORDER_READY {
+		pushData := map[string]string{"order_id": strconv.FormatUint(uint64(order.Id), 10), "status": order.Status}
+		if user.NotifySMS && user.Phone != "" {
+			errs = append(errs, ns.sendText(ctx, key, user, models.CHANNEL_SMS, user.Phone, template, data, nil))
+		}
+		if user.NotifyPush && user.PushToken != "" {
+			errs = append(errs, ns.sendText(ctx, key, user, models.CHANNEL_PUSH, user.PushToken, template, data, pushData))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func (ns *NotificationService) onTransactionEvent(ctx context.Context, event models.OutboxEvent) error {
+	var change models.TransactionChanged
+	if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+		return fmt.Errorf("error decoding outbox payload, %w", err)
+	}
+
+	if change.PaymentStatus != models.PAYMENT_COMPLETED {
+		return nil
+	}
+
+	trx, err := ns.trxRepo.GetTransactionById(ctx, change.TransactionID)
+	if err != nil {
+		return fmt.Errorf("error fetching transaction, %w", err)
+	}
+
+	order, err := ns.orderRepo.GetOrder(ctx, change.OrderID)
+	if err != nil {
+		return fmt.Errorf("error fetching order, %w", err)
+	}
+
+	user, err := ns.userRepo.GetUserByID(ctx, order.UserID)
+	if err != nil {
+		return fmt.Errorf("error fetching user, %w", err)
+	}
+
+	// Receipts are always emailed, and only once per transaction however
+	// many events report it as paid
+	key := fmt.Sprintf("trx_%d_%s", trx.ID, notify.TEMPLATE_PAYMENT_RECEIPT)
+	return ns.sendEmail(ctx, key, user, notify.TEMPLATE_PAYMENT_RECEIPT, notify.OrderEmail{
+		Name:        user.FirstName,
+		Order:       *order,
+		Transaction: trx,
+	})
+}
+
+// SendPasswordReset emails a password reset link to the user.
+func (ns *NotificationService) SendPasswordReset(ctx context.Context, user *models.User, resetURL string, expiresIn time.Duration) error {
+	key := "password_reset_" + util.GenerateReference()
+	return ns.sendEmail(ctx, key, user, notify.TEMPLATE_PASSWORD_RESET, notify.PasswordResetEmail{
+		Name:      user.FirstName,
+		ResetURL:  resetURL,
+		ExpiresIn: formatExpiry(expiresIn),
+	})
+}
+
+// SendEmailVerification emails a verification link to email, which is the
+// user's current address or the one they are changing to.
+func (ns *NotificationService) SendEmailVerification(ctx context.Context, user *models.User, email, verifyURL string, expiresIn time.Duration) error {
+	key := "email_verification_" + util.GenerateReference()
+	return ns.sendEmailTo(ctx, key, user, email, notify.TEMPLATE_EMAIL_VERIFICATION, notify.EmailVerificationEmail{
+		Name:      user.FirstName,
+		VerifyURL: verifyURL,
+		ExpiresIn: formatExpiry(expiresIn),
+	})
+}
+
+// SendEmailChanged tells the user's old address that their email has been
+// changed, so that they find out if someone else changed it.
+func (ns *NotificationService) SendEmailChanged(ctx context.Context, user *models.User, oldEmail string) error {
+	key := "email_changed_" + util.GenerateReference()
+	return ns.sendEmailTo(ctx, key, user, oldEmail, notify.TEMPLATE_EMAIL_CHANGED, notify.EmailChangedEmail{
+		Name:     user.FirstName,
+		NewEmail: user.Email,
+	})
+}
+
+// GetPreferences returns the user's notification preferences.
+func (ns *NotificationService) GetPreferences(ctx context.Context, userId uint) (*models.NotificationPreferences, error) {
+	user, err := ns.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return preferencesOf(user), nil
+}
+
+// UpdatePreferences changes the user's notification channels and quiet hours.
+func (ns *NotificationService) UpdatePreferences(ctx context.Context, userId uint, req *models.NotificationPreferences) (*models.NotificationPreferences, error) {
+	user, err := ns.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if req.Email != nil {
+		user.NotifyEmail = *req.Email
+	}
+	if req.SMS != nil {
+		user.NotifySMS = *req.SMS
+	}
+	if req.Push != nil {
+		user.NotifyPush = *req.Push
+	}
+	if req.Phone != nil {
+		user.Phone = *req.Phone
+	}
+	if req.PushToken != nil {
+		user.PushToken = *req.PushToken
+	}
+	if req.QuietHoursStart != nil {
+		user.QuietHoursStart = *req.QuietHoursStart
+	}
+	if req.QuietHoursEnd != nil {
+		user.QuietHoursEnd = *req.QuietHoursEnd
+	}
+	if req.TimeZone != nil {
+		user.TimeZone = *req.TimeZone
+	}
+
+	if (user.QuietHoursStart == "") != (user.QuietHoursEnd == "") {
+		return nil, errors.New("quiet hours need both a start and an end")
+	}
+	if user.NotifySMS && user.Phone == "" {
+		return nil, errors.New("a phone number is required for SMS notifications")
+	}
+
+	user.UpdatedAt = util.CurrentTime()
+	if err := ns.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error updating preferences, %w", err)
+	}
+	return preferencesOf(user), nil
+}
+
+func preferencesOf(user *models.User) *models.NotificationPreferences {
+	return &models.NotificationPreferences{
+		Email:           &user.NotifyEmail,
+		SMS:             &user.NotifySMS,
+		Push:            &user.NotifyPush,
+		Phone:           &user.Phone,
+		QuietHoursStart: &user.QuietHoursStart,
+		QuietHoursEnd:   &user.QuietHoursEnd,
+		TimeZone:        &user.TimeZone,
+	}
+}
+
+func (ns *NotificationService) sendEmail(ctx context.Context, key string, user *models.User, template string, data any) error {
+	return ns.sendEmailTo(ctx, key, user, user.Email, template, data)
+}
+
+func (ns *NotificationService) sendEmailTo(ctx context.Context, key string, user *models.User, to, template string, data any) error {
+	msg, err := ns.templates.Render(template, to, data)
+	if err != nil {
+		return err
+	}
+	return ns.deliver(ctx, key, user, models.CHANNEL_EMAIL, msg)
+}
+
+// sendText sends a plain text template over SMS or push. Nothing is sent if
+// the channel is not configured, and messages during the user's quiet hours
+// are logged as suppressed.
+func (ns *NotificationService) sendText(ctx context.Context, key string, user *models.User, channel, to, template string, data any, pushData map[string]string) error {
+	if _, ok := ns.channels[channel]; !ok {
+		return nil
+	}
+
+	msg, err := ns.templates.RenderText(template, to, data)
+	if err != nil {
+		return err
+	}
+	msg.Data = pushData
+
+	return ns.deliver(ctx, key+"_"+channel, user, channel, msg)
+}
+
+// deliver sends a message on a channel unless a message with the same key
+// was already sent, and logs the outcome. Failures are returned so the
+// caller can retry.
+func (ns *NotificationService) deliver(ctx context.Context, key string, user *models.User, channel string, msg notify.Message) error {
+	notification, err := ns.repo.GetByKey(ctx, key)
+	if err != nil {
+		return fmt.Errorf("error checking notification log, %w", err)
+	}
+	if notification != nil && notification.Status != models.NOTIFICATION_FAILED {
+		return nil
+	}
+
+	now := util.CurrentTime()
+	if notification == nil {
+		notification = &models.Notification{Key: key, CreatedAt: now}
+	}
+	notification.UserID = user.Id
+	notification.Channel = channel
+	notification.Template = msg.Template
+	notification.Version = msg.Version
+	notification.Recipient = msg.To
+	notification.UpdatedAt = now
+
+	var sendErr error
+	switch {
+	case channel != models.CHANNEL_EMAIL && inQuietHours(user, now):
+		notification.Status = models.NOTIFICATION_SUPPRESSED
+	default:
+		sendErr = ns.channels[channel].Send(ctx, msg)
+		if sendErr != nil {
+			notification.Status = models.NOTIFICATION_FAILED
+			notification.Error = sendErr.Error()
+		} else {
+			notification.Status = models.NOTIFICATION_SENT
+			notification.Error = ""
+			notification.SentAt = &now
+		}
+	}
+
+	if err := ns.repo.Save(ctx, notification); err != nil {
+		return fmt.Errorf("error logging notification, %w", err)
+	}
+
+	if sendErr != nil {
+		return fmt.Errorf("error sending %s %s, %w", msg.Template, channel, sendErr)
+	}
+	return nil
+}
+
+// formatExpiry formats how long a link stays valid for an email, e.g.
+// "1 hour" or "30 minutes".
+func formatExpiry(d time.Duration) string {
+	unit, n := "minute", int(d.Round(time.Minute)/time.Minute)
+	if n >= 60 && n%60 == 0 {
+		unit, n = "hour", n/60
+	}
+	if n == 1 {
+		return "1 " + unit
+	}
+	return fmt.Sprintf("%d %ss", n, unit)
+}
+
+// inQuietHours reports whether now falls inside the user's quiet hours. The
+// window may wrap past midnight, e.g. 22:00 to 07:00.
+func inQuietHours(user *models.User, now time.Time) bool {
+	if user.QuietHoursStart == "" || user.QuietHoursEnd == "" {
+		return false
+	}
+
+	start, err := time.Parse("15:04", user.QuietHoursStart)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse("15:04", user.QuietHoursEnd)
+	if err != nil {
+		return false
+	}
+
+	loc := time.UTC
+	if user.TimeZone != "" {
+		if l, err := time.LoadLocation(user.TimeZone); err == nil {
+			loc = l
+		} else {
+			logrus.Warnf("invalid time zone %q for user %d", user.TimeZone, user.Id)
+		}
+	}
+
+	local := now.In(loc)
+	minute := local.Hour()*60 + local.Minute()
+	startMinute := start.Hour()*60 + start.Minute()
+	endMinute := end.Hour()*60 + end.Minute()
+
+	if startMinute <= endMinute {
+		return minute >= startMinute && minute < endMinute
+	}
+	return minute >= startMinute || minute < endMinute
+}
@@ -0,0 +1,40 @@
+package services
+
+import (
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInQuietHours(t *testing.T) {
+	at := func(hhmm string) time.Time {
+		tm, err := time.Parse("2006-01-02 15:04", "2024-05-01 "+hhmm)
+		require.NoError(t, err)
+		return tm
+	}
+
+	overnight := &models.User{QuietHoursStart: "22:00", QuietHoursEnd: "07:00"}
+	require.True(t, inQuietHours(overnight, at("23:30")))
+	require.True(t, inQuietHours(overnight, at("06:59")))
+	require.False(t, inQuietHours(overnight, at("07:00")))
+	require.False(t, inQuietHours(overnight, at("12:00")))
+
+	daytime := &models.User{QuietHoursStart: "13:00", QuietHoursEnd: "14:00"}
+	require.True(t, inQuietHours(daytime, at("13:15")))
+	require.False(t, inQuietHours(daytime, at("14:00")))
+
+	// 21:30 UTC is 22:30 in Lagos (UTC+1)
+	lagos := &models.User{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", TimeZone: "Africa/Lagos"}
+	require.True(t, inQuietHours(lagos, at("21:30")))
+
+	require.False(t, inQuietHours(&models.User{}, at("03:00")))
+}
+
+func TestFormatExpiry(t *testing.T) {
+	require.Equal(t, "1 hour", formatExpiry(time.Hour))
+	require.Equal(t, "24 hours", formatExpiry(24*time.Hour))
+	require.Equal(t, "90 minutes", formatExpiry(90*time.Minute))
+	require.Equal(t, "1 minute", formatExpiry(time.Minute))
+}
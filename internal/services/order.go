@@ -4,18 +4,41 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
 	"github.com/emmrys-jay/coffee-delivery-api/util"
 	"github.com/shopspring/decimal"
 )
 
+const (
+	// orderSlotLength is the size of the window scheduled orders are booked into.
+	orderSlotLength = 15 * time.Minute
+
+	// maxScheduleHorizon is how far ahead an order may be scheduled.
+	maxScheduleHorizon = 7 * 24 * time.Hour
+
+	defaultSlotCapacity = 20
+	defaultReleaseLead  = 20 * time.Minute
+)
+
 type OrderService struct {
 	repo        *repository.OrderRepository
 	userRepo    *repository.UserRepository
 	coffeeRepo  *repository.CoffeeRepository
 	reserveRepo *repository.ReservationRepository
+	couponRepo  *repository.CouponRepository
+	addressRepo *repository.AddressRepository
+	storeRepo   *repository.StoreRepository
+
+	slotCapacity int64
+	releaseLead  time.Duration
+	pricing      PricingConfig
 }
 
 func NewOrderService(
@@ -23,13 +46,31 @@ func NewOrderService(
 	userRepo *repository.UserRepository,
 	coffeeRepo *repository.CoffeeRepository,
 	reserveRepo *repository.ReservationRepository,
+	couponRepo *repository.CouponRepository,
+	addressRepo *repository.AddressRepository,
+	storeRepo *repository.StoreRepository,
 ) *OrderService {
-	return &OrderService{
-		repo:        repo,
-		userRepo:    userRepo,
-		coffeeRepo:  coffeeRepo,
-		reserveRepo: reserveRepo,
+	svc := &OrderService{
+		repo:         repo,
+		userRepo:     userRepo,
+		coffeeRepo:   coffeeRepo,
+		reserveRepo:  reserveRepo,
+		couponRepo:   couponRepo,
+		addressRepo:  addressRepo,
+		storeRepo:    storeRepo,
+		slotCapacity: defaultSlotCapacity,
+		releaseLead:  defaultReleaseLead,
+		pricing:      LoadPricingConfig(),
+	}
+
+	if v, err := strconv.ParseInt(os.Getenv("ORDER_SLOT_CAPACITY"), 10, 64); err == nil && v > 0 {
+		svc.slotCapacity = v
 	}
+	if v, err := strconv.Atoi(os.Getenv("SCHEDULED_ORDER_LEAD_MINUTES")); err == nil && v >= 0 {
+		svc.releaseLead = time.Duration(v) * time.Minute
+	}
+
+	return svc
 }
 
 func (os *OrderService) PlaceOrder(ctx context.Context, userId uint, req *models.CreateOrderRequest) (*models.OrderResponse, error) {
@@ -38,6 +79,12 @@ func (os *OrderService) PlaceOrder(ctx context.Context, userId uint, req *models
 		return nil, fmt.Errorf("error fetching user by id, %w", err)
 	}
 
+	if req.ScheduledFor != nil {
+		if err := validateScheduledTime(*req.ScheduledFor, util.CurrentTime()); err != nil {
+			return nil, err
+		}
+	}
+
 	var idMap = make(map[uint]uint)
 	var ids = make([]uint, 0, len(req.Coffees))
 	for _, v := range req.Coffees {
@@ -54,11 +101,25 @@ func (os *OrderService) PlaceOrder(ctx context.Context, userId uint, req *models
 		return nil, errors.New("none of the coffee products specified was found")
 	}
 
+	// An order is charged in a single currency
+	currency := util.NormalizeCurrency(coffees[0].Currency)
+	for _, v := range coffees[1:] {
+		if util.NormalizeCurrency(v.Currency) != currency {
+			return nil, errors.New("all coffee products in an order must be priced in the same currency")
+		}
+	}
+
+	fulfilment, err := os.resolveFulfilment(ctx, userId, req, currency)
+	if err != nil {
+		return nil, err
+	}
+
 	// Check for the integrity of order quantity with quantity in stock
-	// Calculate total order amount
+	// Collect the lines to be priced
 	// Populate order items
-	var totalAmount = decimal.Zero
 	var orderItems = make([]models.OrderItem, 0, len(coffees))
+	var priceLines = make([]PriceLine, 0, len(coffees))
+	var couponLines = make([]couponLine, 0, len(coffees))
 
 	for _, v := range coffees {
 		quantityOrdered := idMap[v.Id]
@@ -70,25 +131,106 @@ func (os *OrderService) PlaceOrder(ctx context.Context, userId uint, req *models
 		}
 
 		price, _ := util.ParseDecimal(v.Price)
-		totalAmount = totalAmount.Add(price.Mul(decimal.NewFromUint64(uint64(quantityOrdered))))
+		priceLines = append(priceLines, PriceLine{UnitPrice: price, Quantity: quantityOrdered})
+		couponLines = append(couponLines, couponLine{
+			CoffeeID: v.Id,
+			Category: v.Category,
+			Amount:   price.Mul(decimal.NewFromUint64(uint64(quantityOrdered))),
+		})
 
 		orderItems = append(orderItems, models.OrderItem{
-			CoffeeID:  v.Id,
-			Name:      v.Name,
-			Quantity:  quantityOrdered,
-			UnitPrice: v.Price,
-			CreatedAt: util.CurrentTime(),
+			CoffeeID:   v.Id,
+			Name:       v.Name,
+			Quantity:   quantityOrdered,
+			UnitPrice:  v.Price,
+			PrepStatus: models.PREP_QUEUED,
+			CreatedAt:  util.CurrentTime(),
 		})
 	}
 
+	// Apply the coupon, if any. Its usage limits are checked when the
+	// redemption is recorded together with the order.
+	var redemption *models.CouponRedemption
+	var couponCode string
+	var lineDiscounts []decimal.Decimal
+	if req.CouponCode != "" {
+		coupon, err := lookupCoupon(ctx, os.couponRepo, req.CouponCode)
+		if err != nil {
+			return nil, err
+		}
+
+		discount, err := computeCouponDiscount(coupon, couponLines, currency, util.CurrentTime())
+		if err != nil {
+			return nil, err
+		}
+
+		amounts := make([]decimal.Decimal, len(couponLines))
+		eligible := make([]bool, len(couponLines))
+		for i, l := range couponLines {
+			amounts[i] = l.Amount
+			eligible[i] = couponAppliesTo(coupon, l)
+		}
+		lineDiscounts = allocateDiscount(discount, amounts, eligible)
+
+		redemption = &models.CouponRedemption{
+			CouponID:  coupon.Id,
+			UserID:    userId,
+			Discount:  discount.StringFixed(2),
+			CreatedAt: util.CurrentTime(),
+		}
+		couponCode = coupon.Code
+	}
+
+	breakdown := PriceOrder(os.pricing, priceLines, lineDiscounts, fulfilment.DeliveryFee)
+	for i, l := range breakdown.Lines {
+		orderItems[i].Discount = l.Discount.StringFixed(moneyPlaces)
+		orderItems[i].TaxAmount = l.Tax.StringFixed(moneyPlaces)
+		orderItems[i].LineTotal = l.Total.StringFixed(moneyPlaces)
+	}
+
 	order := models.Order{
-		UserID:      userId,
-		TotalAmount: totalAmount.String(),
-		OrderItems:  orderItems,
-		Status:      models.ORDER_STATUS_PENDING,
+		UserID:         userId,
+		Currency:       currency,
+		Subtotal:       breakdown.Subtotal.StringFixed(moneyPlaces),
+		CouponCode:     couponCode,
+		DiscountAmount: breakdown.Discount.StringFixed(moneyPlaces),
+		TaxRate:        os.pricing.VATRate.StringFixed(moneyPlaces),
+		TaxInclusive:   os.pricing.VATInclusive,
+		TaxAmount:      breakdown.Tax.StringFixed(moneyPlaces),
+		ServiceCharge:  breakdown.ServiceCharge.StringFixed(moneyPlaces),
+		DeliveryFee:    breakdown.DeliveryFee.StringFixed(moneyPlaces),
+		TotalAmount:    breakdown.Total.StringFixed(moneyPlaces),
+		OrderItems:     orderItems,
+		Status:         models.ORDER_STATUS_PENDING,
+		FulfilmentType: fulfilment.Type,
+		StoreID:        fulfilment.StoreID,
 	}
 
-	_, err = os.repo.CreateOrder(ctx, &order)
+	if fulfilment.Address != nil {
+		address := fulfilment.Address
+		order.DeliveryZoneID = &fulfilment.Zone.Id
+		order.DeliveryAddressID = &address.Id
+		order.DeliveryAddress = address.String()
+		order.DeliveryLatitude = &address.Latitude
+		order.DeliveryLongitude = &address.Longitude
+	}
+
+	if req.Billing != nil {
+		order.BillingCompany = req.Billing.Company
+		order.BillingTaxID = req.Billing.TaxID
+		order.BillingAddress = req.Billing.Address
+	}
+
+	if req.ScheduledFor != nil {
+		scheduledFor := req.ScheduledFor.UTC()
+		order.ScheduledFor = &scheduledFor
+		order.Status = models.ORDER_STATUS_SCHEDULED
+
+		slotStart := scheduledFor.Truncate(orderSlotLength)
+		_, err = os.repo.CreateScheduledOrder(ctx, &order, redemption, slotStart, orderSlotLength, os.slotCapacity)
+	} else {
+		_, err = os.repo.CreateOrder(ctx, &order, redemption)
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error creating order, %w", err)
 	}
@@ -118,6 +260,19 @@ func (os *OrderService) GetOrder(ctx context.Context, id uint) (*models.Order, e
 	return os.repo.GetOrder(ctx, id)
 }
 
+// GetOrderFor returns an order the principal is allowed to read.
+func (os *OrderService) GetOrderFor(ctx context.Context, p authz.Principal, id uint) (*models.Order, error) {
+	order, err := os.repo.GetOrder(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := authz.Order(p, authz.ActionRead, order); err != nil {
+		return nil, err
+	}
+	return order, nil
+}
+
 func (os *OrderService) ListUserOrders(ctx context.Context, userId uint) ([]models.Order, error) {
 	return os.repo.ListUserOrders(ctx, userId)
 }
@@ -128,11 +283,14 @@ func (os *OrderService) UpdateOrderStatus(ctx context.Context, orderId uint, sta
 		return nil, fmt.Errorf("error fetching order, %w", err)
 	}
 
-	if !models.IsValidStatus(status) {
-		return nil, errors.New("invalid status")
+	if err := validateStatusChange(retOrder, status); err != nil {
+		return nil, err
 	}
 
-	err = os.repo.UpdateOrderStatus(ctx, orderId, status)
+	err = os.repo.UpdateOrderStatus(ctx, orderId, retOrder.Status, status)
+	if errors.Is(err, repository.ErrOrderChanged) {
+		return nil, err
+	}
 	if err != nil {
 		return nil, fmt.Errorf("error updating status, %w", err)
 	}
@@ -141,17 +299,23 @@ func (os *OrderService) UpdateOrderStatus(ctx context.Context, orderId uint, sta
 	return retOrder, nil
 }
 
-func (os *OrderService) CancelOrder(ctx context.Context, id uint) (*models.Order, error) {
+// CancelOrder cancels an order on behalf of p, provided it has not been
+// processed yet.
+func (os *OrderService) CancelOrder(ctx context.Context, p authz.Principal, id uint) (*models.Order, error) {
 	retOrder, err := os.GetOrder(ctx, id)
 	if err != nil {
 		return nil, fmt.Errorf("error fetching order, %w", err)
 	}
 
-	if retOrder.Status != models.ORDER_STATUS_PENDING {
-		return nil, errors.New("You cannot cancel this order again since it has already been processed. Please contact admin")
+	if err := authz.Order(p, authz.ActionCancel, retOrder); err != nil {
+		return nil, err
 	}
 
-	err = os.repo.UpdateOrderStatus(ctx, id, models.ORDER_STATUS_CANCELED)
+	// The coupon redemption, if any, is given back with the cancellation
+	err = os.repo.CancelOrder(ctx, id, []string{models.ORDER_STATUS_PENDING, models.ORDER_STATUS_SCHEDULED})
+	if errors.Is(err, repository.ErrOrderNotCancelable) {
+		return nil, errors.New("You cannot cancel this order again since it has already been processed. Please contact admin")
+	}
 	if err != nil {
 		return nil, err
 	}
@@ -159,3 +323,128 @@ func (os *OrderService) CancelOrder(ctx context.Context, id uint) (*models.Order
 	retOrder.Status = models.ORDER_STATUS_CANCELED // Add the updated status to the order struct to be returned
 	return retOrder, nil
 }
+
+// ReleaseScheduledOrders moves scheduled orders whose slot begins within the
+// configured lead time into the pending queue so they can be prepared. Each
+// release is recorded in the outbox like any other status change.
+func (os *OrderService) ReleaseScheduledOrders(ctx context.Context) (int64, error) {
+	ids, err := os.repo.ReleaseScheduledOrders(ctx, util.CurrentTime().Add(os.releaseLead))
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(ids)), nil
+}
+
+// validateScheduledTime checks that a requested fulfilment time is in the
+// future and within the scheduling horizon.
+func validateScheduledTime(scheduledFor, now time.Time) error {
+	if !scheduledFor.After(now) {
+		return errors.New("scheduled time must be in the future")
+	}
+
+	if scheduledFor.Sub(now) > maxScheduleHorizon {
+		return fmt.Errorf("orders cannot be scheduled more than %v days ahead", maxScheduleHorizon/(24*time.Hour))
+	}
+
+	return nil
+}
+
+// validateStatusChange checks that staff may move order to status.
+func validateStatusChange(order *models.Order, status string) error {
+	if !models.IsValidStatus(status) {
+		return errors.New("invalid status")
+	}
+
+	// Fulfilled and canceled orders are not reopened
+	if models.IsFinalStatus(order.Status) &&
+		!(order.Status == models.ORDER_STATUS_DELIVERED && status == models.ORDER_STATUS_COMPLETED) {
+		return fmt.Errorf("the order is %s and can no longer be changed", strings.ToLower(order.Status))
+	}
+
+	// Orders are only scheduled when they are placed
+	if status == models.ORDER_STATUS_SCHEDULED {
+		return errors.New("an order can not be moved back to scheduled")
+	}
+
+	if (status == models.ORDER_STATUS_OUT_FOR_DELIVERY || status == models.ORDER_STATUS_DELIVERED) &&
+		order.FulfilmentType != models.FULFILMENT_DELIVERY {
+		return errors.New("only delivery orders can be sent out for delivery")
+	}
+
+	return nil
+}
+
+// fulfilment describes how an order reaches the customer.
+type fulfilment struct {
+	Type        string
+	StoreID     *uint
+	Zone        *models.DeliveryZone
+	Address     *models.Address
+	DeliveryFee decimal.Decimal
+}
+
+// resolveFulfilment validates the pickup or delivery details of an order
+// request in currency. Delivery addresses must fall inside an active store's
+// delivery zone; the zone's flat fee is used if it has one, otherwise the fee
+// is based on the distance from the store. Fees are not converted, so a fee
+// in another currency than the order's is refused.
+func (os *OrderService) resolveFulfilment(ctx context.Context, userId uint, req *models.CreateOrderRequest, currency string) (fulfilment, error) {
+	f := fulfilment{Type: models.FULFILMENT_PICKUP, DeliveryFee: decimal.Zero}
+
+	if req.FulfilmentType != models.FULFILMENT_DELIVERY {
+		if req.StoreID != nil {
+			store, err := os.storeRepo.GetStore(ctx, *req.StoreID)
+			if err != nil || !store.Active {
+				return f, errors.New("store not found")
+			}
+			f.StoreID = &store.Id
+		}
+		return f, nil
+	}
+
+	if req.AddressID == nil {
+		return f, errors.New("an address is required for delivery orders")
+	}
+
+	address, err := os.addressRepo.GetUserAddress(ctx, userId, *req.AddressID)
+	if err != nil {
+		return f, err
+	}
+
+	zones, err := os.storeRepo.ListActiveZones(ctx, req.StoreID)
+	if err != nil {
+		return f, fmt.Errorf("error fetching delivery zones, %w", err)
+	}
+
+	zone, ok := findDeliveryZone(zones, address.Location())
+	if !ok {
+		return f, errors.New("we do not deliver to this address yet")
+	}
+
+	store, err := os.storeRepo.GetStore(ctx, zone.StoreID)
+	if err != nil {
+		return f, fmt.Errorf("error fetching store, %w", err)
+	}
+
+	f.Type = models.FULFILMENT_DELIVERY
+	f.StoreID = &store.Id
+	f.Zone = zone
+	f.Address = address
+
+	feeCurrency := os.pricing.DeliveryFeeCurrency
+	if zone.DeliveryFee != nil {
+		f.DeliveryFee, err = util.ParseDecimal(*zone.DeliveryFee)
+		if err != nil {
+			return f, err
+		}
+		feeCurrency = zone.Currency
+	} else {
+		f.DeliveryFee = os.pricing.DeliveryFeeForDistance(util.DistanceKm(store.Location(), address.Location()))
+	}
+
+	if !f.DeliveryFee.IsZero() && util.NormalizeCurrency(feeCurrency) != currency {
+		return f, fmt.Errorf("delivery to this address is charged in %s and can not be added to an order in %s", util.NormalizeCurrency(feeCurrency), currency)
+	}
+
+	return f, nil
+}
@@ -0,0 +1,108 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/events"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+)
+
+// PublishOrderEvents feeds order status changes, kitchen progress and
+// confirmed payments from the outbox into the broker. Events are published
+// once the change they describe is committed.
+func PublishOrderEvents(broker *events.Broker, dispatcher *outbox.Dispatcher) {
+	onOrderEvent := func(ctx context.Context, event models.OutboxEvent) error {
+		var change models.OrderStatusChanged
+		if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+			return fmt.Errorf("error decoding outbox payload, %w", err)
+		}
+
+		eventType := orderEventType(change.Status, change.PreviousStatus)
+		if eventType == "" {
+			return nil
+		}
+
+		broker.Publish(events.Event{
+			Type:       eventType,
+			OrderID:    change.OrderID,
+			UserID:     change.UserID,
+			Status:     change.Status,
+			OccurredAt: event.CreatedAt,
+		})
+		return nil
+	}
+	dispatcher.Subscribe("order-events", models.OUTBOX_ORDER_CREATED, onOrderEvent)
+	dispatcher.Subscribe("order-events", models.OUTBOX_ORDER_STATUS_CHANGED, onOrderEvent)
+
+	dispatcher.Subscribe("order-events", models.OUTBOX_ORDER_ITEM_CHANGED, func(ctx context.Context, event models.OutboxEvent) error {
+		var change models.OrderItemChanged
+		if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+			return fmt.Errorf("error decoding outbox payload, %w", err)
+		}
+
+		eventType := events.ORDER_ITEM_STARTED
+		if change.PrepStatus == models.PREP_DONE {
+			eventType = events.ORDER_ITEM_DONE
+		}
+
+		broker.Publish(events.Event{
+			Type:       eventType,
+			OrderID:    change.OrderID,
+			UserID:     change.UserID,
+			Status:     change.OrderStatus,
+			ItemID:     &change.ItemID,
+			OccurredAt: event.CreatedAt,
+		})
+		return nil
+	})
+
+	dispatcher.Subscribe("order-events", models.OUTBOX_TRANSACTION_STATUS_CHANGED, func(ctx context.Context, event models.OutboxEvent) error {
+		var change models.TransactionChanged
+		if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+			return fmt.Errorf("error decoding outbox payload, %w", err)
+		}
+
+		if change.PaymentStatus != models.PAYMENT_COMPLETED {
+			return nil
+		}
+
+		broker.Publish(events.Event{
+			Type:       events.ORDER_PAID,
+			OrderID:    change.OrderID,
+			UserID:     change.UserID,
+			OccurredAt: event.CreatedAt,
+		})
+		return nil
+	})
+}
+
+// orderEventType maps an order status change to the event streamed to
+// customers. It returns an empty string for changes that are not streamed.
+func orderEventType(status, previous string) string {
+	if previous == "" {
+		return events.ORDER_PLACED
+	}
+
+	switch status {
+	case models.ORDER_STATUS_PENDING:
+		if previous == models.ORDER_STATUS_SCHEDULED {
+			return events.ORDER_RELEASED
+		}
+	case models.ORDER_STATUS_PREPARING:
+		return events.ORDER_PREPARING
+	case models.ORDER_STATUS_READY:
+		return events.ORDER_READY
+	case models.ORDER_STATUS_OUT_FOR_DELIVERY:
+		return events.ORDER_OUT_FOR_DELIVERY
+	case models.ORDER_STATUS_DELIVERED:
+		return events.ORDER_DELIVERED
+	case models.ORDER_STATUS_COMPLETED:
+		return events.ORDER_COMPLETED
+	case models.ORDER_STATUS_CANCELED:
+		return events.ORDER_CANCELLED
+	}
+	return ""
+}
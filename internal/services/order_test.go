@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/events"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+func TestValidateScheduledTime(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	require.NoError(t, validateScheduledTime(now.Add(time.Minute), now))
+	require.NoError(t, validateScheduledTime(now.Add(maxScheduleHorizon), now))
+
+	require.Error(t, validateScheduledTime(now, now))
+	require.Error(t, validateScheduledTime(now.Add(-time.Hour), now))
+	require.Error(t, validateScheduledTime(now.Add(maxScheduleHorizon+time.Second), now))
+}
+
+func TestValidateStatusChange(t *testing.T) {
+	pickup := &models.Order{Status: models.ORDER_STATUS_PREPARING, FulfilmentType: models.FULFILMENT_PICKUP}
+	delivery := &models.Order{Status: models.ORDER_STATUS_READY, FulfilmentType: models.FULFILMENT_DELIVERY}
+
+	require.NoError(t, validateStatusChange(pickup, models.ORDER_STATUS_READY))
+	require.NoError(t, validateStatusChange(delivery, models.ORDER_STATUS_OUT_FOR_DELIVERY))
+
+	require.Error(t, validateStatusChange(pickup, "BREWING"))
+	require.Error(t, validateStatusChange(pickup, models.ORDER_STATUS_SCHEDULED))
+	require.Error(t, validateStatusChange(pickup, models.ORDER_STATUS_OUT_FOR_DELIVERY))
+
+	// Final orders are not reopened, though a delivered order can be completed
+	canceled := &models.Order{Status: models.ORDER_STATUS_CANCELED, FulfilmentType: models.FULFILMENT_PICKUP}
+	require.Error(t, validateStatusChange(canceled, models.ORDER_STATUS_PENDING))
+	require.Error(t, validateStatusChange(&models.Order{Status: models.ORDER_STATUS_COMPLETED}, models.ORDER_STATUS_CANCELED))
+	delivered := &models.Order{Status: models.ORDER_STATUS_DELIVERED, FulfilmentType: models.FULFILMENT_DELIVERY}
+	require.Error(t, validateStatusChange(delivered, models.ORDER_STATUS_CANCELED))
+	require.NoError(t, validateStatusChange(delivered, models.ORDER_STATUS_COMPLETED))
+}
+
+func TestOrderEventType(t *testing.T) {
+	require.Equal(t, events.ORDER_PLACED, orderEventType(models.ORDER_STATUS_PENDING, ""))
+	require.Equal(t, events.ORDER_RELEASED, orderEventType(models.ORDER_STATUS_PENDING, models.ORDER_STATUS_SCHEDULED))
+	require.Equal(t, events.ORDER_READY, orderEventType(models.ORDER_STATUS_READY, models.ORDER_STATUS_PREPARING))
+	require.Empty(t, orderEventType(models.ORDER_STATUS_PENDING, models.ORDER_STATUS_PREPARING))
+}
+
+func newTestOrderService(db *gorm.DB) *OrderService {
+	return NewOrderService(
+		repository.NewOrderRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewCoffeeRepository(db),
+		repository.NewReservationRepository(db),
+		repository.NewCouponRepository(db),
+		repository.NewAddressRepository(db),
+		repository.NewStoreRepository(db),
+	)
+}
+
+func TestOrderStock(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	os := newTestOrderService(db)
+
+	user := createUser(t, db, "stock", models.ROLE_USER)
+	coffee := &models.Coffee{Name: "Flat White", Price: "1500.00", Currency: "NGN", Quantity: 3, CreatedAt: util.CurrentTime()}
+	require.NoError(t, db.Create(coffee).Error)
+
+	stock := func() uint {
+		var c models.Coffee
+		require.NoError(t, db.First(&c, coffee.Id).Error)
+		return c.Quantity
+	}
+
+	req := &models.CreateOrderRequest{Coffees: []models.CoffeeInfo{{CoffeeID: coffee.Id, Quantity: 2}}}
+	order, err := os.PlaceOrder(ctx, user.Id, req)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stock())
+
+	_, err = os.PlaceOrder(ctx, user.Id, req)
+	require.Error(t, err)
+	require.EqualValues(t, 1, stock())
+
+	_, err = os.CancelOrder(ctx, authz.User(user.Id), order.Id)
+	require.NoError(t, err)
+	require.EqualValues(t, 3, stock())
+
+	var changes int64
+	require.NoError(t, db.Model(&models.OutboxEvent{}).Where("type = ?", models.OUTBOX_COFFEE_STOCK_CHANGED).Count(&changes).Error)
+	require.EqualValues(t, 1, changes)
+
+	// A canceled order can not be reopened and canceled again
+	_, err = os.UpdateOrderStatus(ctx, order.Id, models.ORDER_STATUS_PENDING)
+	require.Error(t, err)
+	require.EqualValues(t, 3, stock())
+
+	// Staff canceling an order being prepared do not put it back in stock
+	order, err = os.PlaceOrder(ctx, user.Id, req)
+	require.NoError(t, err)
+	_, err = os.UpdateOrderStatus(ctx, order.Id, models.ORDER_STATUS_PREPARING)
+	require.NoError(t, err)
+	_, err = os.UpdateOrderStatus(ctx, order.Id, models.ORDER_STATUS_CANCELED)
+	require.NoError(t, err)
+	require.EqualValues(t, 1, stock())
+}
+
+func TestStaffCancelReleasesCoupon(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	os := newTestOrderService(db)
+
+	now := util.CurrentTime()
+	user := createUser(t, db, "staffcancel", models.ROLE_USER)
+	coffee := &models.Coffee{Name: "Mocha", Price: "2000.00", Currency: "NGN", Quantity: 5, CreatedAt: now}
+	require.NoError(t, db.Create(coffee).Error)
+	coupon := &models.Coupon{Code: "ONCE", Type: models.COUPON_PERCENTAGE, Value: "10", MinBasket: "0", PerUserLimit: 1, Active: true, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(coupon).Error)
+
+	req := &models.CreateOrderRequest{Coffees: []models.CoffeeInfo{{CoffeeID: coffee.Id, Quantity: 1}}, CouponCode: "ONCE"}
+	order, err := os.PlaceOrder(ctx, user.Id, req)
+	require.NoError(t, err)
+
+	_, err = os.UpdateOrderStatus(ctx, order.Id, models.ORDER_STATUS_CANCELED)
+	require.NoError(t, err)
+
+	var stored models.Coupon
+	require.NoError(t, db.First(&stored, coupon.Id).Error)
+	require.EqualValues(t, 0, stored.Redemptions)
+	var redemptions int64
+	require.NoError(t, db.Model(&models.CouponRedemption{}).Where("order_id = ?", order.Id).Count(&redemptions).Error)
+	require.EqualValues(t, 0, redemptions)
+
+	// The user can use the coupon again
+	_, err = os.PlaceOrder(ctx, user.Id, req)
+	require.NoError(t, err)
+}
+
+func TestDeliveryFeeCurrency(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	os := newTestOrderService(db)
+	os.pricing = PricingConfig{DeliveryBaseFee: decimal.NewFromInt(500), DeliveryFeeCurrency: "NGN"}
+
+	now := util.CurrentTime()
+	user := createUser(t, db, "feecurrency", models.ROLE_USER)
+	store := &models.Store{Name: "Accra Central", Latitude: 5.55, Longitude: -0.2, Active: true, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(store).Error)
+	address := &models.Address{UserID: user.Id, Line1: "1 Oxford St", City: "Accra", Country: "GH", Latitude: 5.56, Longitude: -0.19, CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(address).Error)
+	coffee := &models.Coffee{Name: "Espresso", Price: "20.00", Currency: "GHS", Quantity: 10, CreatedAt: now}
+	require.NoError(t, db.Create(coffee).Error)
+
+	zone := &models.DeliveryZone{
+		StoreID:   store.Id,
+		Name:      "Osu",
+		Polygon:   []util.GeoPoint{{Latitude: 5.5, Longitude: -0.3}, {Latitude: 5.6, Longitude: -0.3}, {Latitude: 5.6, Longitude: -0.1}, {Latitude: 5.5, Longitude: -0.1}},
+		Active:    true,
+		CreatedAt: now,
+		UpdatedAt: now,
+	}
+	require.NoError(t, db.Create(zone).Error)
+
+	req := &models.CreateOrderRequest{
+		Coffees:        []models.CoffeeInfo{{CoffeeID: coffee.Id, Quantity: 1}},
+		FulfilmentType: models.FULFILMENT_DELIVERY,
+		AddressID:      &address.Id,
+		StoreID:        &store.Id,
+	}
+
+	// The distance fee is in naira, so it can not be added to a cedi order
+	_, err := os.PlaceOrder(ctx, user.Id, req)
+	require.ErrorContains(t, err, "charged in NGN")
+
+	// A flat fee in the order's currency is
+	fee := "15.00"
+	require.NoError(t, db.Model(zone).Updates(map[string]any{"delivery_fee": fee, "currency": "GHS"}).Error)
+	order, err := os.PlaceOrder(ctx, user.Id, req)
+	require.NoError(t, err)
+	require.Equal(t, "GHS", order.Currency)
+	require.Equal(t, "15.00", order.DeliveryFee)
+	require.Equal(t, "35.00", order.TotalAmount)
+}
+
+func TestKitchenRefusesCanceledOrder(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	os := newTestOrderService(db)
+
+	user := createUser(t, db, "kitchencancel", models.ROLE_USER)
+	coffee := &models.Coffee{Name: "Cortado", Price: "1800.00", Currency: "NGN", Quantity: 5, CreatedAt: util.CurrentTime()}
+	require.NoError(t, db.Create(coffee).Error)
+
+	placed, err := os.PlaceOrder(ctx, user.Id, &models.CreateOrderRequest{Coffees: []models.CoffeeInfo{{CoffeeID: coffee.Id, Quantity: 1}}})
+	require.NoError(t, err)
+	order, err := os.repo.GetOrder(ctx, placed.Id)
+	require.NoError(t, err)
+
+	// The order is canceled after the barista's ticket was read
+	_, err = os.CancelOrder(ctx, authz.User(user.Id), order.Id)
+	require.NoError(t, err)
+
+	_, err = os.repo.StartOrderItem(ctx, order.Id, order.OrderItems[0].Id, util.CurrentTime())
+	require.ErrorIs(t, err, repository.ErrNotAwaitingPrep)
+	_, err = os.repo.CompleteOrderItem(ctx, order.Id, order.OrderItems[0].Id, util.CurrentTime())
+	require.ErrorIs(t, err, repository.ErrNotAwaitingPrep)
+
+	stored, err := os.repo.GetOrder(ctx, order.Id)
+	require.NoError(t, err)
+	require.Equal(t, models.ORDER_STATUS_CANCELED, stored.Status)
+	require.Equal(t, models.PREP_QUEUED, stored.OrderItems[0].PrepStatus)
+}
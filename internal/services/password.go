@@ -0,0 +1,81 @@
+package services
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	minPasswordLength = 10
+
+	// bcrypt ignores everything after the first 72 bytes
+	maxPasswordBytes = 72
+)
+
+// ErrWeakPassword is returned when a new password does not meet the password
+// policy.
+var ErrWeakPassword = errors.New("password is too weak")
+
+// commonPasswords are rejected whatever else they contain. They are the most
+// common passwords that are long enough to pass the other rules.
+var commonPasswords = map[string]bool{
+	"1234567890":   true,
+	"0987654321":   true,
+	"1q2w3e4r5t":   true,
+	"qwertyuiop":   true,
+	"password1":    true,
+	"password12":   true,
+	"password123":  true,
+	"password1234": true,
+	"passw0rd123":  true,
+	"iloveyou123":  true,
+	"qwerty12345":  true,
+	"qwerty123456": true,
+	"abcd123456":   true,
+	"abc1234567":   true,
+	"letmein123":   true,
+	"welcome123":   true,
+	"coffee1234":   true,
+	"espresso123":  true,
+}
+
+// checkPasswordStrength enforces the password policy: at least 10 characters
+// and at most 72 bytes, at least one letter and one digit or symbol, not a
+// common password and not built from the user's own details.
+func checkPasswordStrength(password string, personal ...string) error {
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Errorf("%w: it must be at least %d characters long", ErrWeakPassword, minPasswordLength)
+	}
+	if len(password) > maxPasswordBytes {
+		return fmt.Errorf("%w: it must be at most %d bytes long", ErrWeakPassword, maxPasswordBytes)
+	}
+
+	var letter, other bool
+	for _, r := range password {
+		if unicode.IsLetter(r) {
+			letter = true
+		} else if !unicode.IsSpace(r) {
+			other = true
+		}
+	}
+	if !letter || !other {
+		return fmt.Errorf("%w: it must contain a letter and a digit or symbol", ErrWeakPassword)
+	}
+
+	lower := strings.ToLower(password)
+	if commonPasswords[lower] {
+		return fmt.Errorf("%w: it is too common", ErrWeakPassword)
+	}
+
+	for _, p := range personal {
+		// For email addresses only the name before the @ is checked
+		p, _, _ = strings.Cut(strings.ToLower(p), "@")
+		if len(p) >= 3 && strings.Contains(lower, p) {
+			return fmt.Errorf("%w: it must not contain your name or email", ErrWeakPassword)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package services
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckPasswordStrength(t *testing.T) {
+	require.NoError(t, checkPasswordStrength("correct horse 7", "Ada", "ada@example.com"))
+	require.NoError(t, checkPasswordStrength("grüße-über-alles"))
+
+	weak := []string{
+		"short1!",                // too short
+		"onlyletterspassword",    // no digit or symbol
+		"12345678901",            // no letter
+		"Password123",            // common
+		strings.Repeat("a1", 40), // longer than bcrypt accepts
+		"lovelace-2024",          // contains the last name
+		"my-ada.lovelace-1",      // contains the email name
+	}
+	for _, password := range weak {
+		err := checkPasswordStrength(password, "Ada", "Lovelace", "ada.lovelace@example.com")
+		require.ErrorIs(t, err, ErrWeakPassword, password)
+	}
+}
@@ -0,0 +1,169 @@
+package services
+
+import (
+	"os"
+	"strings"
+
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/shopspring/decimal"
+)
+
+// Rounding rules used by the pricing pipeline:
+//
+//   - line amounts (unit price * quantity) are exact, since prices are stored
+//     with two decimal places;
+//   - every derived amount (allocated discount, VAT, service charge, delivery
+//     fee) is rounded to two decimal places, half away from zero, at the line
+//     where it is computed;
+//   - order totals are sums of already rounded amounts, so the breakdown always
+//     adds up to the total exactly.
+const moneyPlaces = 2
+
+var hundred = decimal.NewFromInt(100)
+
+// PricingConfig holds the configurable parts of order pricing. Rates are
+// percentages. The distance based delivery fee is in DeliveryFeeCurrency.
+type PricingConfig struct {
+	VATRate             decimal.Decimal
+	VATInclusive        bool
+	ServiceChargeRate   decimal.Decimal
+	DeliveryBaseFee     decimal.Decimal
+	DeliveryFeePerKm    decimal.Decimal
+	DeliveryFeeCurrency string
+}
+
+// LoadPricingConfig reads the pricing configuration from the environment.
+// Missing or invalid values default to zero, and the currency to the
+// default currency.
+func LoadPricingConfig() PricingConfig {
+	return PricingConfig{
+		VATRate:             envDecimal("VAT_RATE"),
+		VATInclusive:        strings.EqualFold(os.Getenv("VAT_INCLUSIVE"), "true"),
+		ServiceChargeRate:   envDecimal("SERVICE_CHARGE_RATE"),
+		DeliveryBaseFee:     envDecimal("DELIVERY_BASE_FEE"),
+		DeliveryFeePerKm:    envDecimal("DELIVERY_FEE_PER_KM"),
+		DeliveryFeeCurrency: util.NormalizeCurrency(os.Getenv("DELIVERY_FEE_CURRENCY")),
+	}
+}
+
+// DeliveryFeeForDistance returns the distance based delivery fee.
+func (pc PricingConfig) DeliveryFeeForDistance(km float64) decimal.Decimal {
+	return pc.DeliveryBaseFee.Add(pc.DeliveryFeePerKm.Mul(decimal.NewFromFloat(km))).Round(moneyPlaces)
+}
+
+// PriceLine is a basket line going through the pricing pipeline.
+type PriceLine struct {
+	UnitPrice decimal.Decimal
+	Quantity  uint
+
+	Gross    decimal.Decimal // UnitPrice * Quantity
+	Discount decimal.Decimal // share of the order discount
+	Net      decimal.Decimal // Gross - Discount
+	Tax      decimal.Decimal // VAT on Net
+	Total    decimal.Decimal // amount payable for the line
+}
+
+// PriceBreakdown is the itemised result of pricing an order.
+type PriceBreakdown struct {
+	Lines         []PriceLine
+	Subtotal      decimal.Decimal
+	Discount      decimal.Decimal
+	Tax           decimal.Decimal
+	ServiceCharge decimal.Decimal
+	DeliveryFee   decimal.Decimal
+	Total         decimal.Decimal
+}
+
+// PriceOrder runs the pricing pipeline over the given lines. lineDiscounts
+// holds the discount applied to each line (it may be nil), and deliveryFee is
+// added to the order as is.
+func PriceOrder(cfg PricingConfig, lines []PriceLine, lineDiscounts []decimal.Decimal, deliveryFee decimal.Decimal) PriceBreakdown {
+	b := PriceBreakdown{
+		Lines:       make([]PriceLine, len(lines)),
+		DeliveryFee: deliveryFee.Round(moneyPlaces),
+	}
+
+	vatRate := cfg.VATRate.Div(hundred)
+	netTotal := decimal.Zero
+
+	for i, l := range lines {
+		l.Gross = l.UnitPrice.Mul(decimal.NewFromUint64(uint64(l.Quantity)))
+		l.Discount = decimal.Zero
+		if i < len(lineDiscounts) {
+			l.Discount = lineDiscounts[i]
+		}
+		l.Net = l.Gross.Sub(l.Discount)
+
+		if cfg.VATInclusive {
+			// The net amount already contains VAT; extract it
+			l.Tax = l.Net.Sub(l.Net.Div(decimal.NewFromInt(1).Add(vatRate))).Round(moneyPlaces)
+			l.Total = l.Net
+		} else {
+			l.Tax = l.Net.Mul(vatRate).Round(moneyPlaces)
+			l.Total = l.Net.Add(l.Tax)
+		}
+
+		b.Lines[i] = l
+		b.Subtotal = b.Subtotal.Add(l.Gross)
+		b.Discount = b.Discount.Add(l.Discount)
+		b.Tax = b.Tax.Add(l.Tax)
+		b.Total = b.Total.Add(l.Total)
+		netTotal = netTotal.Add(l.Net)
+	}
+
+	b.ServiceCharge = netTotal.Mul(cfg.ServiceChargeRate).Div(hundred).Round(moneyPlaces)
+	b.Total = b.Total.Add(b.ServiceCharge).Add(b.DeliveryFee)
+
+	return b
+}
+
+// allocateDiscount spreads discount over the eligible lines in proportion to
+// their amounts. Rounding leftovers go to the last eligible line so that the
+// allocations always add up to discount.
+func allocateDiscount(discount decimal.Decimal, amounts []decimal.Decimal, eligible []bool) []decimal.Decimal {
+	allocations := make([]decimal.Decimal, len(amounts))
+
+	base, last := decimal.Zero, -1
+	for i, a := range amounts {
+		allocations[i] = decimal.Zero
+		if eligible[i] {
+			base = base.Add(a)
+			last = i
+		}
+	}
+
+	if last < 0 || base.IsZero() {
+		return allocations
+	}
+
+	remaining := discount
+	for i, a := range amounts {
+		if !eligible[i] {
+			continue
+		}
+
+		if i == last {
+			allocations[i] = remaining
+			break
+		}
+
+		share := discount.Mul(a).Div(base).Round(moneyPlaces)
+		allocations[i] = share
+		remaining = remaining.Sub(share)
+	}
+
+	return allocations
+}
+
+func envDecimal(key string) decimal.Decimal {
+	v := os.Getenv(key)
+	if v == "" {
+		return decimal.Zero
+	}
+
+	d, err := util.ParseDecimal(v)
+	if err != nil || d.IsNegative() {
+		return decimal.Zero
+	}
+	return d
+}
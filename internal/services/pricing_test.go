@@ -0,0 +1,52 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+)
+
+func dec(s string) decimal.Decimal {
+	return decimal.RequireFromString(s)
+}
+
+func TestPriceOrder(t *testing.T) {
+	lines := []PriceLine{
+		{UnitPrice: dec("1500.00"), Quantity: 2},
+		{UnitPrice: dec("999.99"), Quantity: 1},
+	}
+
+	t.Run("exclusive VAT with discount, service charge and delivery", func(t *testing.T) {
+		cfg := PricingConfig{VATRate: dec("7.5"), ServiceChargeRate: dec("2.5")}
+
+		b := PriceOrder(cfg, lines, []decimal.Decimal{dec("300.00"), decimal.Zero}, dec("500"))
+		require.Equal(t, "3999.99", b.Subtotal.StringFixed(2))
+		require.Equal(t, "300.00", b.Discount.StringFixed(2))
+		require.Equal(t, "202.50", b.Lines[0].Tax.StringFixed(2))
+		require.Equal(t, "75.00", b.Lines[1].Tax.StringFixed(2))
+		require.Equal(t, "277.50", b.Tax.StringFixed(2))
+		require.Equal(t, "92.50", b.ServiceCharge.StringFixed(2))
+		require.Equal(t, "4569.99", b.Total.StringFixed(2))
+	})
+
+	t.Run("inclusive VAT does not change the total", func(t *testing.T) {
+		cfg := PricingConfig{VATRate: dec("7.5"), VATInclusive: true}
+
+		b := PriceOrder(cfg, lines, nil, decimal.Zero)
+		require.Equal(t, "3999.99", b.Total.StringFixed(2))
+		require.Equal(t, "209.30", b.Lines[0].Tax.StringFixed(2))
+		require.Equal(t, "69.77", b.Lines[1].Tax.StringFixed(2))
+	})
+}
+
+func TestAllocateDiscount(t *testing.T) {
+	amounts := []decimal.Decimal{dec("10.00"), dec("10.00"), dec("10.00"), dec("5.00")}
+	eligible := []bool{true, true, true, false}
+
+	allocations := allocateDiscount(dec("10.00"), amounts, eligible)
+	require.Equal(t, "3.33", allocations[0].StringFixed(2))
+	require.Equal(t, "3.33", allocations[1].StringFixed(2))
+	require.Equal(t, "3.34", allocations[2].StringFixed(2))
+	require.True(t, allocations[3].IsZero())
+}
@@ -0,0 +1,236 @@
+package services
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+const exportFormatVersion = 1
+
+// PrivacyService handles data subject requests: exporting everything held
+// about a user, and erasing their personal data when they delete their
+// account.
+type PrivacyService struct {
+	userRepo    *repository.UserRepository
+	orderRepo   *repository.OrderRepository
+	trxRepo     *repository.TransactionRepository
+	addressRepo *repository.AddressRepository
+	pmRepo      *repository.PaymentMethodRepository
+	subRepo     *repository.SubscriptionRepository
+	socialRepo  *repository.SocialRepository
+	authService *AuthService
+}
+
+func NewPrivacyService(
+	userRepo *repository.UserRepository,
+	orderRepo *repository.OrderRepository,
+	trxRepo *repository.TransactionRepository,
+	addressRepo *repository.AddressRepository,
+	pmRepo *repository.PaymentMethodRepository,
+	subRepo *repository.SubscriptionRepository,
+	socialRepo *repository.SocialRepository,
+	authService *AuthService,
+) *PrivacyService {
+	return &PrivacyService{
+		userRepo:    userRepo,
+		orderRepo:   orderRepo,
+		trxRepo:     trxRepo,
+		addressRepo: addressRepo,
+		pmRepo:      pmRepo,
+		subRepo:     subRepo,
+		socialRepo:  socialRepo,
+		authService: authService,
+	}
+}
+
+// exportManifest describes the files of an export archive.
+type exportManifest struct {
+	Version     int       `json:"version"`
+	UserID      uint      `json:"user_id"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Files       []string  `json:"files"`
+}
+
+// exportedOrder leaves out the empty user that orders are loaded with; the
+// user is exported once, in profile.json.
+type exportedOrder struct {
+	models.Order
+	User *struct{} `json:"user,omitempty"`
+}
+
+// Export writes a zip archive of the user's personal data to w, with one
+// JSON file for each kind of record and a manifest.json listing them.
+func (s *PrivacyService) Export(ctx context.Context, userId uint, w io.Writer) error {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	orders, err := s.orderRepo.ListUserOrders(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching orders, %w", err)
+	}
+	exportedOrders := make([]exportedOrder, 0, len(orders))
+	for _, o := range orders {
+		exportedOrders = append(exportedOrders, exportedOrder{Order: o})
+	}
+
+	transactions, err := s.trxRepo.ListUserTransactions(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching transactions, %w", err)
+	}
+
+	addresses, err := s.addressRepo.ListUserAddresses(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching addresses, %w", err)
+	}
+
+	paymentMethods, err := s.pmRepo.ListUserPaymentMethods(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching payment methods, %w", err)
+	}
+
+	subscriptions, err := s.subRepo.ListUserSubscriptions(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching subscriptions, %w", err)
+	}
+
+	identities, err := s.socialRepo.ListUserIdentities(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching linked accounts, %w", err)
+	}
+
+	files := []struct {
+		name string
+		data any
+	}{
+		{"profile.json", user},
+		{"orders.json", exportedOrders},
+		{"transactions.json", transactions},
+		{"addresses.json", addresses},
+		{"payment_methods.json", paymentMethods},
+		{"subscriptions.json", subscriptions},
+		{"linked_accounts.json", identities},
+	}
+
+	manifest := exportManifest{Version: exportFormatVersion, UserID: user.Id, GeneratedAt: util.CurrentTime()}
+	for _, f := range files {
+		manifest.Files = append(manifest.Files, f.name)
+	}
+
+	zw := zip.NewWriter(w)
+	if err := writeJSONFile(zw, "manifest.json", manifest); err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err := writeJSONFile(zw, f.name, f.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
+
+// DeleteAccount erases the user's personal data after confirming it is the
+// user asking. Orders and transactions are kept for as long as the law
+// requires, attached to the anonymised user. Orders still being fulfilled
+// must be finished or canceled first.
+func (s *PrivacyService) DeleteAccount(ctx context.Context, userId uint, confirm models.Confirmation) error {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if err := s.authService.Confirm(ctx, user, confirm); err != nil {
+		return err
+	}
+
+	if user.Role == models.ROLE_ADMIN {
+		admins, err := s.userRepo.CountByRole(ctx, models.ROLE_ADMIN)
+		if err != nil {
+			return fmt.Errorf("error counting admins, %w", err)
+		}
+		if admins <= 1 {
+			return errors.New("the last admin account can not be deleted")
+		}
+	}
+
+	orders, err := s.orderRepo.ListUserOrders(ctx, userId)
+	if err != nil {
+		return fmt.Errorf("error fetching orders, %w", err)
+	}
+	for _, o := range orders {
+		if !models.IsFinalStatus(o.Status) {
+			return fmt.Errorf("order #%d is still open; cancel it or wait until it is completed", o.Id)
+		}
+	}
+
+	now := util.CurrentTime()
+	oldEmail := user.Email
+	anonymize(user, now)
+
+	audit := &models.AuditLog{
+		Event:     models.AUDIT_ACCOUNT_DELETED,
+		UserID:    &user.Id,
+		ActorID:   &user.Id,
+		CreatedAt: now,
+	}
+	throttleKeys := []string{accountKey(oldEmail), resetAccountKey(oldEmail)}
+	if err := s.userRepo.Anonymize(ctx, user, oldEmail, throttleKeys, audit); err != nil {
+		return fmt.Errorf("error deleting account, %w", err)
+	}
+
+	if _, err := s.authService.LogoutAll(ctx, user.Id); err != nil {
+		return fmt.Errorf("error revoking sessions, %w", err)
+	}
+	return nil
+}
+
+// anonymize replaces the personal data of user. The password is replaced by
+// a value no bcrypt hash can match, so the account can never sign in again.
+func anonymize(user *models.User, now time.Time) {
+	user.FirstName = "Deleted"
+	user.LastName = "User"
+	user.Email = fmt.Sprintf("deleted-%d@deleted.invalid", user.Id)
+	user.Password = "!"
+	user.Role = models.ROLE_USER
+	user.EmailVerifiedAt = nil
+
+	user.TwoFactorEnabled = false
+	user.TOTPSecret = ""
+	user.TOTPLastStep = 0
+
+	user.Phone = ""
+	user.PushToken = ""
+	user.NotifyEmail = false
+	user.NotifySMS = false
+	user.NotifyPush = false
+	user.QuietHoursStart = ""
+	user.QuietHoursEnd = ""
+	user.TimeZone = ""
+
+	user.AnonymizedAt = &now
+	user.UpdatedAt = now
+}
+
+func writeJSONFile(zw *zip.Writer, name string, data any) error {
+	f, err := zw.Create(name)
+	if err != nil {
+		return fmt.Errorf("error adding %s to export, %w", name, err)
+	}
+
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(data); err != nil {
+		return fmt.Errorf("error writing %s to export, %w", name, err)
+	}
+	return nil
+}
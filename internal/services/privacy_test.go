@@ -0,0 +1,149 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestAnonymize(t *testing.T) {
+	now := time.Now().UTC()
+	user := &models.User{
+		Id:               12,
+		FirstName:        "Ada",
+		LastName:         "Lovelace",
+		Email:            "ada@example.com",
+		Password:         "$2a$10$hash",
+		Role:             models.ROLE_BARISTA,
+		EmailVerifiedAt:  &now,
+		TwoFactorEnabled: true,
+		TOTPSecret:       "JBSWY3DPEHPK3PXP",
+		Phone:            "+2348000000000",
+		PushToken:        "device-token",
+		NotifyEmail:      true,
+		TimeZone:         "Africa/Lagos",
+	}
+
+	anonymize(user, now)
+
+	require.Equal(t, "deleted-12@deleted.invalid", user.Email)
+	require.Equal(t, models.ROLE_USER, user.Role)
+	require.Empty(t, user.Phone)
+	require.Empty(t, user.PushToken)
+	require.Empty(t, user.TOTPSecret)
+	require.Empty(t, user.TimeZone)
+	require.False(t, user.TwoFactorEnabled)
+	require.False(t, user.NotifyEmail)
+	require.Nil(t, user.EmailVerifiedAt)
+	require.Equal(t, &now, user.AnonymizedAt)
+
+	// No password can sign in again
+	require.Error(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("")))
+	require.Error(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("!")))
+}
+
+func TestExportedOrderOmitsUser(t *testing.T) {
+	out, err := json.Marshal(exportedOrder{Order: models.Order{Id: 3, Status: models.ORDER_STATUS_COMPLETED}})
+	require.NoError(t, err)
+
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal(out, &fields))
+	require.NotContains(t, fields, "user")
+	require.Equal(t, float64(3), fields["id"])
+}
+
+func newTestPrivacyService(db *gorm.DB) (*PrivacyService, *LockoutService) {
+	userRepo := repository.NewUserRepository(db)
+	tokenRepo := repository.NewTokenRepository(db)
+	lockout := NewLockoutService(repository.NewLockoutRepository(db), userRepo)
+	ps := NewPrivacyService(userRepo, repository.NewOrderRepository(db), repository.NewTransactionRepository(db),
+		repository.NewAddressRepository(db), repository.NewPaymentMethodRepository(db), repository.NewSubscriptionRepository(db),
+		repository.NewSocialRepository(db), NewAuthService(userRepo, tokenRepo, nil, lockout))
+	return ps, lockout
+}
+
+// createErasableUser stores a user who can confirm with a known password.
+func createErasableUser(t *testing.T, db *gorm.DB, name string) *models.User {
+	t.Helper()
+
+	user := createUser(t, db, name, models.ROLE_USER)
+	hash, err := bcrypt.GenerateFromPassword([]byte("roasted-beans-42"), bcrypt.MinCost)
+	require.NoError(t, err)
+	require.NoError(t, db.Model(user).Update("password", string(hash)).Error)
+	return user
+}
+
+func TestDeleteAccountScrubsLoginRecords(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	ps, lockout := newTestPrivacyService(db)
+	user := createErasableUser(t, db, "erased")
+
+	// A lockout logged before the account was matched, and throttles kept
+	// for the address
+	require.NoError(t, db.Create(&models.AuditLog{Event: models.AUDIT_ACCOUNT_LOCKED, Email: "Erased@example.com", CreatedAt: time.Now()}).Error)
+	require.NoError(t, lockout.Attempt(ctx, user.Email, "203.0.113.20"))
+	require.NoError(t, lockout.RecordResetRequest(ctx, user.Email, "203.0.113.20"))
+
+	require.NoError(t, ps.DeleteAccount(ctx, user.Id, models.Confirmation{Password: "roasted-beans-42"}))
+
+	var logs int64
+	require.NoError(t, db.Model(&models.AuditLog{}).Where("LOWER(email) = ?", "erased@example.com").Count(&logs).Error)
+	require.Zero(t, logs)
+
+	for _, key := range []string{accountKey(user.Email), resetAccountKey(user.Email)} {
+		var throttles int64
+		require.NoError(t, db.Model(&models.LoginThrottle{}).Where("key = ?", key).Count(&throttles).Error)
+		require.Zero(t, throttles, key)
+	}
+}
+
+func TestDeleteAccountScrubsCardDetails(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	ps, _ := newTestPrivacyService(db)
+	user := createErasableUser(t, db, "erasedcard")
+
+	now := time.Now().UTC()
+	pm := &models.PaymentMethod{UserID: user.Id, Provider: "paystack", AuthorizationCode: "AUTH_erased", Brand: "visa", Last4: "4081", ExpMonth: "12", ExpYear: "2030", CreatedAt: now, UpdatedAt: now}
+	require.NoError(t, db.Create(pm).Error)
+	order := createOrder(t, db, user.Id, "2500.00")
+	trx := &models.Transaction{
+		UserID:          user.Id,
+		OrderID:         order.Id,
+		Reference:       "ref-erased",
+		PaymentStatus:   models.PAYMENT_COMPLETED,
+		TotalAmount:     "2500.00",
+		PaymentMethodID: &pm.Id,
+		CardBrand:       "visa",
+		CardLast4:       "4081",
+		CardExpMonth:    "12",
+		CardExpYear:     "2030",
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+	require.NoError(t, db.Create(trx).Error)
+
+	require.NoError(t, ps.DeleteAccount(ctx, user.Id, models.Confirmation{Password: "roasted-beans-42"}))
+
+	var stored models.Transaction
+	require.NoError(t, db.First(&stored, trx.ID).Error)
+	require.Nil(t, stored.PaymentMethodID)
+	require.Empty(t, stored.CardBrand)
+	require.Empty(t, stored.CardLast4)
+	require.Empty(t, stored.CardExpMonth)
+	require.Empty(t, stored.CardExpYear)
+	require.Equal(t, "2500.00", stored.TotalAmount, "the transaction itself is kept")
+
+	var pms int64
+	require.NoError(t, db.Model(&models.PaymentMethod{}).Where("user_id = ?", user.Id).Count(&pms).Error)
+	require.Zero(t, pms)
+}
@@ -0,0 +1,217 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"gorm.io/gorm"
+)
+
+// rolePermissionsTTL is how long a role's permissions are cached. Changes
+// made on another instance take effect within this time.
+const rolePermissionsTTL = 30 * time.Second
+
+type cachedPermissions struct {
+	permissions []string
+	loadedAt    time.Time
+}
+
+// RBACService manages roles and their permissions, and resolves the
+// permissions of a role for the auth middleware.
+type RBACService struct {
+	repo *repository.RoleRepository
+
+	mu    sync.Mutex
+	cache map[string]cachedPermissions
+}
+
+func NewRBACService(repo *repository.RoleRepository) *RBACService {
+	return &RBACService{
+		repo:  repo,
+		cache: make(map[string]cachedPermissions),
+	}
+}
+
+// Seed creates the known permissions and the system roles.
+func (s *RBACService) Seed(ctx context.Context) error {
+	return s.repo.Seed(ctx, models.AllPermissions, models.DefaultRoles)
+}
+
+// RolePermissions returns the permissions granted to a role. Unknown roles
+// have no permissions.
+func (s *RBACService) RolePermissions(ctx context.Context, role string) ([]string, error) {
+	s.mu.Lock()
+	cached, ok := s.cache[role]
+	s.mu.Unlock()
+	if ok && time.Since(cached.loadedAt) < rolePermissionsTTL {
+		return cached.permissions, nil
+	}
+
+	var permissions []string
+	r, err := s.repo.GetRole(ctx, role)
+	if err == nil {
+		permissions = r.PermissionNames()
+	} else if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, fmt.Errorf("error fetching role permissions, %w", err)
+	}
+
+	s.mu.Lock()
+	s.cache[role] = cachedPermissions{permissions: permissions, loadedAt: time.Now()}
+	s.mu.Unlock()
+	return permissions, nil
+}
+
+func (s *RBACService) ListRoles(ctx context.Context) ([]models.Role, error) {
+	return s.repo.ListRoles(ctx)
+}
+
+func (s *RBACService) GetRole(ctx context.Context, name string) (*models.Role, error) {
+	role, err := s.repo.GetRole(ctx, name)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, errors.New("role not found")
+		}
+		return nil, err
+	}
+	return role, nil
+}
+
+func (s *RBACService) ListPermissions(ctx context.Context) ([]models.Permission, error) {
+	return s.repo.ListPermissions(ctx)
+}
+
+// adminOnlyPermissions may only be given to a role by an admin, since their
+// holders could use them to grant themselves more.
+var adminOnlyPermissions = []string{
+	models.PERM_ROLES_MANAGE,
+	models.PERM_USERS_WRITE,
+	models.PERM_API_KEYS_MANAGE,
+}
+
+// CreateRole creates a custom role on behalf of p.
+func (s *RBACService) CreateRole(ctx context.Context, p authz.Principal, req *models.CreateRoleRequest) (*models.Role, error) {
+	if err := validatePermissions(req.Permissions); err != nil {
+		return nil, err
+	}
+	if err := checkRolePermissions(p, req.Name, req.Permissions); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.repo.GetRole(ctx, req.Name); err == nil {
+		return nil, errors.New("a role with this name already exists")
+	}
+
+	role := models.Role{
+		Name:        req.Name,
+		Description: req.Description,
+		Permissions: permissionList(req.Permissions),
+		CreatedAt:   util.CurrentTime(),
+		UpdatedAt:   util.CurrentTime(),
+	}
+	if err := s.repo.CreateRole(ctx, &role); err != nil {
+		return nil, err
+	}
+	return &role, nil
+}
+
+// UpdateRolePermissions replaces the permissions of a role on behalf of p.
+// The admin role always keeps every permission so that it cannot lock itself
+// out.
+func (s *RBACService) UpdateRolePermissions(ctx context.Context, p authz.Principal, name string, permissions []string) (*models.Role, error) {
+	if name == models.ROLE_ADMIN {
+		return nil, errors.New("the permissions of the admin role cannot be changed")
+	}
+	if err := validatePermissions(permissions); err != nil {
+		return nil, err
+	}
+
+	role, err := s.GetRole(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	// Taking permissions away is as sensitive as granting them
+	if err := checkRolePermissions(p, name, append(role.PermissionNames(), permissions...)); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.SetPermissions(ctx, role, permissions); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	delete(s.cache, name)
+	s.mu.Unlock()
+
+	role.Permissions = permissionList(permissions)
+	return role, nil
+}
+
+// CanGrant reports whether a caller holding the given permissions may give
+// a user the role. Only admins may create admins, and nobody may grant a
+// permission they do not hold themselves.
+func (s *RBACService) CanGrant(callerRole string, callerPermissions []string, role *models.Role) bool {
+	if role.Name == models.ROLE_ADMIN {
+		return callerRole == models.ROLE_ADMIN
+	}
+
+	held := make(map[string]bool, len(callerPermissions))
+	for _, p := range callerPermissions {
+		held[p] = true
+	}
+	for _, p := range role.Permissions {
+		if !held[p.Name] {
+			return false
+		}
+	}
+	return true
+}
+
+// checkRolePermissions reports whether p may give or take the permissions of
+// a role. Nobody may change their own role or a permission they do not hold,
+// and only admins may handle the admin-only permissions.
+func checkRolePermissions(p authz.Principal, role string, permissions []string) error {
+	if role == p.Role {
+		return fmt.Errorf("%w: you cannot change your own role", authz.ErrForbidden)
+	}
+
+	for _, permission := range permissions {
+		if !p.Has(permission) {
+			return fmt.Errorf("%w: you do not hold the %s permission", authz.ErrForbidden, permission)
+		}
+		if p.Role != models.ROLE_ADMIN && slices.Contains(adminOnlyPermissions, permission) {
+			return fmt.Errorf("%w: only admins can grant the %s permission", authz.ErrForbidden, permission)
+		}
+	}
+	return nil
+}
+
+func validatePermissions(names []string) error {
+	known := make(map[string]bool, len(models.AllPermissions))
+	for _, p := range models.AllPermissions {
+		known[p.Name] = true
+	}
+
+	for _, n := range names {
+		if !known[n] {
+			return fmt.Errorf("unknown permission %q", n)
+		}
+	}
+	return nil
+}
+
+func permissionList(names []string) []models.Permission {
+	list := make([]models.Permission, 0, len(names))
+	for _, n := range names {
+		list = append(list, models.Permission{Name: n})
+	}
+	return list
+}
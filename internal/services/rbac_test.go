@@ -0,0 +1,58 @@
+package services
+
+import (
+	"testing"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanGrant(t *testing.T) {
+	s := &RBACService{}
+
+	support := &models.Role{Name: "support", Permissions: []models.Permission{
+		{Name: models.PERM_ORDERS_MANAGE},
+		{Name: models.PERM_USERS_READ},
+	}}
+	admin := &models.Role{Name: models.ROLE_ADMIN}
+	user := &models.Role{Name: models.ROLE_USER}
+
+	manager := []string{models.PERM_USERS_WRITE, models.PERM_ORDERS_MANAGE, models.PERM_USERS_READ}
+	hr := []string{models.PERM_USERS_WRITE}
+
+	require.True(t, s.CanGrant("manager", manager, support))
+	require.True(t, s.CanGrant("manager", manager, user))
+
+	// Nobody can hand out permissions they do not hold
+	require.False(t, s.CanGrant("hr", hr, support))
+
+	// Only admins create admins, whatever else the caller holds
+	require.False(t, s.CanGrant("manager", manager, admin))
+	require.True(t, s.CanGrant(models.ROLE_ADMIN, nil, admin))
+}
+
+func TestValidatePermissions(t *testing.T) {
+	require.NoError(t, validatePermissions([]string{models.PERM_CATALOG_WRITE, models.PERM_REFUNDS_ISSUE}))
+	require.Error(t, validatePermissions([]string{"catalog:delete"}))
+}
+
+func TestCheckRolePermissions(t *testing.T) {
+	manager := authz.Principal{Role: "manager", Permissions: []string{
+		models.PERM_ROLES_MANAGE, models.PERM_USERS_WRITE, models.PERM_ORDERS_MANAGE, models.PERM_USERS_READ,
+	}}
+	admin := authz.Principal{Role: models.ROLE_ADMIN, Permissions: []string{models.PERM_USERS_WRITE}}
+
+	require.NoError(t, checkRolePermissions(manager, "support", []string{models.PERM_ORDERS_MANAGE}))
+	require.NoError(t, checkRolePermissions(admin, "support", []string{models.PERM_USERS_WRITE}))
+
+	// Nobody can hand out permissions they do not hold
+	require.ErrorIs(t, checkRolePermissions(manager, "support", []string{models.PERM_REFUNDS_ISSUE}), authz.ErrForbidden)
+
+	// Nobody can change their own role
+	require.ErrorIs(t, checkRolePermissions(manager, "manager", []string{models.PERM_ORDERS_MANAGE}), authz.ErrForbidden)
+
+	// Only admins hand out the permissions that lead to more permissions
+	require.ErrorIs(t, checkRolePermissions(manager, "support", []string{models.PERM_USERS_WRITE}), authz.ErrForbidden)
+	require.ErrorIs(t, checkRolePermissions(manager, "support", []string{models.PERM_ROLES_MANAGE}), authz.ErrForbidden)
+}
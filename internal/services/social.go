@@ -0,0 +1,296 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/oidc"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+)
+
+// A social login must be finished within socialLoginStateTTL of starting it
+const socialLoginStateTTL = 10 * time.Minute
+
+var (
+	ErrUnknownProvider   = errors.New("unknown login provider")
+	ErrInvalidLoginState = errors.New("invalid or expired login state")
+
+	// ErrSocialLoginFailed is returned when the provider did not sign the
+	// user in, or what it returned could not be verified.
+	ErrSocialLoginFailed = errors.New("social login failed")
+)
+
+// SocialLoginService signs users in with Google or Apple, using the OpenID
+// Connect authorization code flow with PKCE. A provider account is linked
+// to the user with the same verified email the first time it is used, and
+// a user is created if there is none. Users with two-factor authentication
+// still need their second factor.
+type SocialLoginService struct {
+	repo        *repository.SocialRepository
+	userRepo    *repository.UserRepository
+	authService *AuthService
+	providers   map[string]*oidc.Provider
+}
+
+func NewSocialLoginService(
+	repo *repository.SocialRepository,
+	userRepo *repository.UserRepository,
+	authService *AuthService,
+	providers map[string]*oidc.Provider,
+) *SocialLoginService {
+	return &SocialLoginService{
+		repo:        repo,
+		userRepo:    userRepo,
+		authService: authService,
+		providers:   providers,
+	}
+}
+
+// Start begins a login with provider and returns the URL to send the user
+// to. The provider redirects back to its configured redirect URL with a
+// code and the state, which are passed to Callback.
+func (s *SocialLoginService) Start(ctx context.Context, provider string) (*models.SocialLoginStart, error) {
+	return s.start(ctx, provider, nil)
+}
+
+// StartReauth begins signing a signed in user in again with provider, to
+// confirm a sensitive change. The provider is asked to make the user sign
+// in even if they have a session there. The code and state it redirects
+// back with are passed to FinishReauth.
+func (s *SocialLoginService) StartReauth(ctx context.Context, userId uint, provider string) (*models.SocialLoginStart, error) {
+	return s.start(ctx, provider, &userId)
+}
+
+func (s *SocialLoginService) start(ctx context.Context, provider string, userId *uint) (*models.SocialLoginStart, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+
+	state, err := util.GenerateToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating login state, %w", err)
+	}
+	nonce, err := util.GenerateToken(32)
+	if err != nil {
+		return nil, fmt.Errorf("error generating nonce, %w", err)
+	}
+	verifier, err := oidc.NewVerifier()
+	if err != nil {
+		return nil, fmt.Errorf("error generating code verifier, %w", err)
+	}
+
+	var prompt string
+	if userId != nil {
+		prompt = "login"
+	}
+	authURL, err := p.AuthCodeURL(ctx, state, nonce, verifier, prompt)
+	if err != nil {
+		return nil, err
+	}
+
+	now := util.CurrentTime()
+	err = s.repo.CreateState(ctx, &models.OAuthState{
+		StateHash:    util.HashToken(state),
+		Provider:     provider,
+		CodeVerifier: verifier,
+		Nonce:        nonce,
+		UserID:       userId,
+		ExpiresAt:    now.Add(socialLoginStateTTL),
+		CreatedAt:    now,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &models.SocialLoginStart{
+		AuthorizationURL: authURL,
+		State:            state,
+		ExpiresIn:        int64(socialLoginStateTTL.Seconds()),
+	}, nil
+}
+
+// Callback finishes a login with what the provider sent back. Like Login,
+// it returns a challenge instead of tokens if the user needs a second
+// factor.
+func (s *SocialLoginService) Callback(ctx context.Context, provider string, req *models.SocialLoginCallback) (*models.TokenPair, *models.TwoFactorChallenge, error) {
+	claims, err := s.finish(ctx, provider, req, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	user, err := s.findOrCreateUser(ctx, provider, claims, req.User)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.authService.signIn(ctx, user)
+}
+
+// FinishReauth finishes signing a user in again with what the provider sent
+// back, and returns a reauth token to confirm a change with. The provider
+// account must already be linked to the user.
+func (s *SocialLoginService) FinishReauth(ctx context.Context, userId uint, provider string, req *models.SocialLoginCallback) (*models.ReauthToken, error) {
+	claims, err := s.finish(ctx, provider, req, &userId)
+	if err != nil {
+		return nil, err
+	}
+
+	identity, err := s.repo.GetIdentity(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity == nil || identity.UserID != userId {
+		return nil, fmt.Errorf("%w: this %s account is not linked to yours", ErrSocialLoginFailed, provider)
+	}
+
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return s.authService.newReauthToken(ctx, user)
+}
+
+// finish takes the state of a login started for userId, or a plain login
+// if userId is nil, and returns the verified claims of the provider
+// account.
+func (s *SocialLoginService) finish(ctx context.Context, provider string, req *models.SocialLoginCallback, userId *uint) (*oidc.Claims, error) {
+	p, ok := s.providers[provider]
+	if !ok {
+		return nil, ErrUnknownProvider
+	}
+	if req.Error != "" {
+		return nil, fmt.Errorf("%w: %s", ErrSocialLoginFailed, req.Error)
+	}
+
+	state, err := s.repo.TakeState(ctx, provider, util.HashToken(req.State), util.CurrentTime())
+	if err != nil {
+		return nil, err
+	}
+	if state == nil || (state.UserID == nil) != (userId == nil) || (userId != nil && *state.UserID != *userId) {
+		return nil, ErrInvalidLoginState
+	}
+
+	tokens, err := p.Exchange(ctx, req.Code, state.CodeVerifier)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSocialLoginFailed, err)
+	}
+	claims, err := p.Verify(ctx, tokens.IDToken, state.Nonce)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrSocialLoginFailed, err)
+	}
+	return claims, nil
+}
+
+// ListIdentities returns the providers linked to the user.
+func (s *SocialLoginService) ListIdentities(ctx context.Context, userId uint) ([]models.UserIdentity, error) {
+	return s.repo.ListUserIdentities(ctx, userId)
+}
+
+// findOrCreateUser returns the user linked to the provider account, linking
+// or creating one by the account's email if it is new. Only emails the
+// provider has verified are trusted.
+func (s *SocialLoginService) findOrCreateUser(ctx context.Context, provider string, claims *oidc.Claims, appleUser string) (*models.User, error) {
+	now := util.CurrentTime()
+
+	identity, err := s.repo.GetIdentity(ctx, provider, claims.Subject)
+	if err != nil {
+		return nil, err
+	}
+	if identity != nil {
+		if err := s.repo.TouchIdentity(ctx, identity.Id, now); err != nil {
+			logrus.Errorf("error recording login for identity %d: %v", identity.Id, err)
+		}
+		return s.userRepo.GetUserByID(ctx, identity.UserID)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return nil, fmt.Errorf("%w: your %s account has no verified email address", ErrSocialLoginFailed, provider)
+	}
+
+	email := strings.ToLower(strings.TrimSpace(claims.Email))
+	identity = &models.UserIdentity{
+		Provider:    provider,
+		Subject:     claims.Subject,
+		Email:       email,
+		CreatedAt:   now,
+		LastLoginAt: now,
+	}
+
+	user, _ := s.userRepo.GetUserByEmail(ctx, email)
+	if user == nil {
+		user = newSocialUser(email, claims, appleUser, now)
+		if err := s.repo.CreateUserWithIdentity(ctx, user, identity); err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	if err := s.link(ctx, user, identity, now); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// link adds identity to an existing user with the same email. If the user
+// never verified their email, whoever chose their password did not prove
+// they own it, so the password is discarded and their sessions are ended:
+// from now on the account belongs to the owner of the email.
+func (s *SocialLoginService) link(ctx context.Context, user *models.User, identity *models.UserIdentity, now time.Time) error {
+	identity.UserID = user.Id
+
+	if user.EmailVerifiedAt == nil {
+		user.EmailVerifiedAt = &now
+		user.Password = "!"
+		user.UpdatedAt = now
+		if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+			return fmt.Errorf("error verifying email, %w", err)
+		}
+		if _, err := s.authService.LogoutAll(ctx, user.Id); err != nil {
+			return fmt.Errorf("error revoking sessions, %w", err)
+		}
+	}
+
+	return s.repo.CreateIdentity(ctx, identity)
+}
+
+// newSocialUser returns a customer for a provider account. They have no
+// password, which no bcrypt hash can match; they can choose one with the
+// password reset flow.
+func newSocialUser(email string, claims *oidc.Claims, appleUser string, now time.Time) *models.User {
+	firstName, lastName := claims.GivenName, claims.FamilyName
+
+	// Apple leaves the name out of the ID token, and posts it only the first
+	// time the user signs in
+	if firstName == "" && appleUser != "" {
+		var u struct {
+			Name struct {
+				FirstName string `json:"firstName"`
+				LastName  string `json:"lastName"`
+			} `json:"name"`
+		}
+		if json.Unmarshal([]byte(appleUser), &u) == nil {
+			firstName, lastName = u.Name.FirstName, u.Name.LastName
+		}
+	}
+	if firstName == "" {
+		firstName, _, _ = strings.Cut(email, "@")
+	}
+
+	return &models.User{
+		FirstName:       firstName,
+		LastName:        lastName,
+		Email:           email,
+		Password:        "!",
+		Role:            models.ROLE_USER,
+		EmailVerifiedAt: &now,
+		CreatedAt:       now,
+		UpdatedAt:       now,
+	}
+}
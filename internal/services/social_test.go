@@ -0,0 +1,171 @@
+package services
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/oidc"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/oidc/oidctest"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
+)
+
+func TestNewSocialUser(t *testing.T) {
+	now := time.Now().UTC()
+
+	user := newSocialUser("ada@example.com", &oidc.Claims{GivenName: "Ada", FamilyName: "Lovelace"}, "", now)
+	require.Equal(t, "Ada", user.FirstName)
+	require.Equal(t, "Lovelace", user.LastName)
+	require.Equal(t, models.ROLE_USER, user.Role)
+	require.Equal(t, &now, user.EmailVerifiedAt)
+
+	// No password can sign in
+	require.Error(t, bcrypt.CompareHashAndPassword([]byte(user.Password), []byte("!")))
+
+	// Apple posts the name separately
+	user = newSocialUser("x7p2@privaterelay.appleid.com", &oidc.Claims{}, `{"name":{"firstName":"Grace","lastName":"Hopper"}}`, now)
+	require.Equal(t, "Grace", user.FirstName)
+	require.Equal(t, "Hopper", user.LastName)
+
+	user = newSocialUser("alan@example.com", &oidc.Claims{}, "not json", now)
+	require.Equal(t, "alan", user.FirstName)
+	require.Empty(t, user.LastName)
+}
+
+func newTestSocialLoginService(t *testing.T, db *gorm.DB) (*SocialLoginService, *oidctest.Provider) {
+	t.Helper()
+
+	srv, mock := oidctest.NewServer("coffee-delivery")
+	t.Cleanup(srv.Close)
+
+	provider := &oidc.Provider{
+		Name:        "mock",
+		Issuer:      srv.URL,
+		ClientID:    "coffee-delivery",
+		RedirectURL: "http://localhost:3000/login/callback",
+		Scopes:      []string{"openid", "email", "profile"},
+	}
+	us := newTestUserService(db, &fakeMailer{})
+	ss := NewSocialLoginService(repository.NewSocialRepository(db), us.repo, us.authService, map[string]*oidc.Provider{"mock": provider})
+	return ss, mock
+}
+
+// signInAtProvider follows the authorization URL of start to the mock
+// provider and returns what it redirects back with.
+func signInAtProvider(t *testing.T, start *models.SocialLoginStart) *models.SocialLoginCallback {
+	t.Helper()
+
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error {
+		return http.ErrUseLastResponse
+	}}
+	resp, err := client.Get(start.AuthorizationURL)
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	location, err := url.Parse(resp.Header.Get("Location"))
+	require.NoError(t, err)
+	return &models.SocialLoginCallback{Code: location.Query().Get("code"), State: location.Query().Get("state")}
+}
+
+func TestSocialLoginLinksByVerifiedEmail(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	ss, mock := newTestSocialLoginService(t, db)
+
+	login := func(t *testing.T) (*models.TokenPair, error) {
+		start, err := ss.Start(ctx, "mock")
+		require.NoError(t, err)
+		tokens, _, err := ss.Callback(ctx, "mock", signInAtProvider(t, start))
+		return tokens, err
+	}
+	identities := func(t *testing.T, userId uint) []models.UserIdentity {
+		list, err := ss.ListIdentities(ctx, userId)
+		require.NoError(t, err)
+		return list
+	}
+
+	t.Run("a verified user keeps their password", func(t *testing.T) {
+		user := createUser(t, db, "linkverified", models.ROLE_USER)
+		require.NoError(t, db.Model(user).Updates(map[string]any{"password": "$2a$10$hash", "email_verified_at": time.Now()}).Error)
+		mock.User = oidctest.User{Subject: "linkverified", Email: user.Email, EmailVerified: true}
+
+		tokens, err := login(t)
+		require.NoError(t, err)
+		require.NotEmpty(t, tokens.AccessToken)
+		require.Len(t, identities(t, user.Id), 1)
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.Id).Error)
+		require.Equal(t, "$2a$10$hash", stored.Password)
+	})
+
+	t.Run("an unverified user loses their password", func(t *testing.T) {
+		user := createUser(t, db, "linkunverified", models.ROLE_USER)
+		require.NoError(t, db.Model(user).Update("password", "$2a$10$hash").Error)
+		mock.User = oidctest.User{Subject: "linkunverified", Email: user.Email, EmailVerified: true}
+
+		_, err := login(t)
+		require.NoError(t, err)
+		require.Len(t, identities(t, user.Id), 1)
+
+		var stored models.User
+		require.NoError(t, db.First(&stored, user.Id).Error)
+		require.Equal(t, "!", stored.Password)
+		require.NotNil(t, stored.EmailVerifiedAt)
+	})
+
+	t.Run("an unverified provider email is not linked", func(t *testing.T) {
+		user := createUser(t, db, "linkrefused", models.ROLE_USER)
+		mock.User = oidctest.User{Subject: "linkrefused", Email: user.Email, EmailVerified: false}
+
+		_, err := login(t)
+		require.ErrorIs(t, err, ErrSocialLoginFailed)
+		require.Empty(t, identities(t, user.Id))
+	})
+}
+
+func TestSocialReauth(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	ss, mock := newTestSocialLoginService(t, db)
+	us := newTestUserService(db, &fakeMailer{})
+
+	// A user who only signs in with the provider has no password
+	mock.User = oidctest.User{Subject: "reauth", Email: "reauth@example.com", EmailVerified: true}
+	start, err := ss.Start(ctx, "mock")
+	require.NoError(t, err)
+	_, _, err = ss.Callback(ctx, "mock", signInAtProvider(t, start))
+	require.NoError(t, err)
+	user, err := us.repo.GetUserByEmail(ctx, "reauth@example.com")
+	require.NoError(t, err)
+
+	change := &models.ChangePasswordRequest{NewPassword: "roasted-beans-42"}
+	require.ErrorIs(t, us.ChangePassword(ctx, user.Id, "", change), ErrConfirmationRequired)
+
+	start, err = ss.StartReauth(ctx, user.Id, "mock")
+	require.NoError(t, err)
+	require.Contains(t, start.AuthorizationURL, "prompt=login")
+
+	// The state only finishes a reauth, and only for the user who started it
+	callback := signInAtProvider(t, start)
+	_, err = ss.FinishReauth(ctx, user.Id+1, "mock", callback)
+	require.ErrorIs(t, err, ErrInvalidLoginState)
+
+	start, err = ss.StartReauth(ctx, user.Id, "mock")
+	require.NoError(t, err)
+	reauth, err := ss.FinishReauth(ctx, user.Id, "mock", signInAtProvider(t, start))
+	require.NoError(t, err)
+
+	change.ReauthToken = reauth.ReauthToken
+	require.NoError(t, us.ChangePassword(ctx, user.Id, "", change))
+
+	// A reauth token confirms one change
+	require.ErrorIs(t, us.ChangePassword(ctx, user.Id, "", change), ErrInvalidConfirmation)
+}
@@ -0,0 +1,96 @@
+package services
+
+import (
+	"context"
+	"errors"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"gorm.io/gorm"
+)
+
+var ErrZoneNotFound = errors.New("delivery zone not found")
+
+type StoreService struct {
+	repo *repository.StoreRepository
+}
+
+func NewStoreService(repo *repository.StoreRepository) *StoreService {
+	return &StoreService{repo: repo}
+}
+
+func (s *StoreService) CreateStore(ctx context.Context, req *models.CreateStore) (*models.Store, error) {
+	store := models.Store{
+		Name:      req.Name,
+		Address:   req.Address,
+		LegalName: req.LegalName,
+		VATNumber: req.VATNumber,
+		Latitude:  req.Latitude,
+		Longitude: req.Longitude,
+		Active:    true,
+		CreatedAt: util.CurrentTime(),
+		UpdatedAt: util.CurrentTime(),
+	}
+
+	if err := s.repo.CreateStore(ctx, &store); err != nil {
+		return nil, err
+	}
+	return &store, nil
+}
+
+func (s *StoreService) GetStore(ctx context.Context, id uint) (*models.Store, error) {
+	return s.repo.GetStore(ctx, id)
+}
+
+func (s *StoreService) ListStores(ctx context.Context) ([]models.Store, error) {
+	return s.repo.ListStores(ctx)
+}
+
+func (s *StoreService) CreateZone(ctx context.Context, storeId uint, req *models.CreateDeliveryZone) (*models.DeliveryZone, error) {
+	if _, err := s.repo.GetStore(ctx, storeId); err != nil {
+		return nil, errors.New("store not found")
+	}
+
+	zone := models.DeliveryZone{
+		StoreID:   storeId,
+		Name:      req.Name,
+		Polygon:   req.Polygon,
+		Active:    true,
+		CreatedAt: util.CurrentTime(),
+		UpdatedAt: util.CurrentTime(),
+	}
+
+	if req.DeliveryFee != "" {
+		fee, err := util.ParseDecimal(req.DeliveryFee)
+		if err != nil {
+			return nil, err
+		}
+		feeStr := fee.StringFixed(moneyPlaces)
+		zone.DeliveryFee = &feeStr
+		zone.Currency = util.NormalizeCurrency(req.Currency)
+	}
+
+	if err := s.repo.CreateZone(ctx, &zone); err != nil {
+		return nil, err
+	}
+	return &zone, nil
+}
+
+func (s *StoreService) DeleteZone(ctx context.Context, storeId, zoneId uint) error {
+	err := s.repo.DeleteZone(ctx, storeId, zoneId)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrZoneNotFound
+	}
+	return err
+}
+
+// findDeliveryZone returns the first zone whose polygon contains p.
+func findDeliveryZone(zones []models.DeliveryZone, p util.GeoPoint) (*models.DeliveryZone, bool) {
+	for i := range zones {
+		if util.PointInPolygon(p, zones[i].Polygon) {
+			return &zones[i], true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,377 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+)
+
+// subscriptionLease is how long a claimed run is hidden from other workers
+// while it is being processed.
+const subscriptionLease = time.Hour
+
+// errSubscriptionChanged is returned by a run when the user paused or
+// canceled the subscription while it was being processed.
+var errSubscriptionChanged = errors.New("subscription was changed during its run")
+
+// dunningSchedule holds the delay before each retry of a failed recurring
+// charge. The subscription is canceled once every retry has failed.
+var dunningSchedule = []time.Duration{
+	24 * time.Hour,
+	3 * 24 * time.Hour,
+	5 * 24 * time.Hour,
+}
+
+type SubscriptionService struct {
+	repo         *repository.SubscriptionRepository
+	orderService *OrderService
+	trxService   *TransactionService
+}
+
+func NewSubscriptionService(
+	repo *repository.SubscriptionRepository,
+	orderService *OrderService,
+	trxService *TransactionService,
+) *SubscriptionService {
+	return &SubscriptionService{
+		repo:         repo,
+		orderService: orderService,
+		trxService:   trxService,
+	}
+}
+
+// ActivateSubscriptions activates each new subscription once the payment of
+// its first order succeeds.
+func ActivateSubscriptions(ss *SubscriptionService, dispatcher *outbox.Dispatcher) {
+	ss.trxService.OnPaymentSuccess(dispatcher, "subscriptions", ss.activateOnFirstPayment)
+}
+
+// CreateSubscription places the first order of a new subscription and starts
+// its payment. The subscription becomes active once that payment succeeds
+// with a reusable authorization.
+func (ss *SubscriptionService) CreateSubscription(ctx context.Context, userId uint, req *models.CreateSubscriptionRequest) (*models.CreateSubscriptionResponse, error) {
+	order, err := ss.orderService.PlaceOrder(ctx, userId, &models.CreateOrderRequest{Coffees: req.Coffees})
+	if err != nil {
+		return nil, fmt.Errorf("error placing first order, %w", err)
+	}
+
+	trx, err := ss.trxService.Initiate(ctx, userId, &models.TransactionRequest{OrderID: order.Id})
+	if err != nil {
+		return nil, fmt.Errorf("error initiating first payment, %w", err)
+	}
+
+	now := util.CurrentTime()
+	sub := models.Subscription{
+		UserID:       userId,
+		Status:       models.SUBSCRIPTION_INCOMPLETE,
+		IntervalDays: req.IntervalDays,
+		NextRunAt:    now.Add(intervalDuration(req.IntervalDays)),
+		FirstOrderID: order.Id,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+	for _, v := range req.Coffees {
+		sub.Items = append(sub.Items, models.SubscriptionItem{CoffeeID: v.CoffeeID, Quantity: v.Quantity})
+	}
+
+	if err := ss.repo.Create(ctx, &sub); err != nil {
+		return nil, err
+	}
+
+	return &models.CreateSubscriptionResponse{Subscription: sub, Transaction: trx}, nil
+}
+
+func (ss *SubscriptionService) ListUserSubscriptions(ctx context.Context, userId uint) ([]models.Subscription, error) {
+	return ss.repo.ListUserSubscriptions(ctx, userId)
+}
+
+// GetSubscription returns a subscription the principal may read.
+func (ss *SubscriptionService) GetSubscription(ctx context.Context, p authz.Principal, id uint) (*models.Subscription, error) {
+	return ss.getSubscription(ctx, p, authz.ActionRead, id)
+}
+
+// getSubscription returns a subscription the principal may perform action
+// on. Missing subscriptions are reported like hidden ones.
+func (ss *SubscriptionService) getSubscription(ctx context.Context, p authz.Principal, action authz.Action, id uint) (*models.Subscription, error) {
+	sub, err := ss.repo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, authz.ErrNotFound
+		}
+		return nil, err
+	}
+
+	if err := authz.Subscription(p, action, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+// PauseSubscription stops generating orders until the subscription is resumed.
+func (ss *SubscriptionService) PauseSubscription(ctx context.Context, p authz.Principal, id uint) (*models.Subscription, error) {
+	sub, err := ss.getSubscription(ctx, p, authz.ActionUpdate, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.Status != models.SUBSCRIPTION_ACTIVE {
+		return nil, errors.New("only active subscriptions can be paused")
+	}
+
+	sub.Status = models.SUBSCRIPTION_PAUSED
+	return sub, ss.save(ctx, sub, "status")
+}
+
+// ResumeSubscription reactivates a paused subscription. If its next run was
+// missed while paused, the next order is generated straight away.
+func (ss *SubscriptionService) ResumeSubscription(ctx context.Context, p authz.Principal, id uint) (*models.Subscription, error) {
+	sub, err := ss.getSubscription(ctx, p, authz.ActionUpdate, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.Status != models.SUBSCRIPTION_PAUSED {
+		return nil, errors.New("only paused subscriptions can be resumed")
+	}
+
+	now := util.CurrentTime()
+	if sub.NextRunAt.Before(now) {
+		sub.NextRunAt = now
+	}
+
+	sub.Status = models.SUBSCRIPTION_ACTIVE
+	return sub, ss.save(ctx, sub, "status", "next_run_at")
+}
+
+// SkipNextDelivery pushes the next run back by one interval.
+func (ss *SubscriptionService) SkipNextDelivery(ctx context.Context, p authz.Principal, id uint) (*models.Subscription, error) {
+	sub, err := ss.getSubscription(ctx, p, authz.ActionUpdate, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.Status != models.SUBSCRIPTION_ACTIVE && sub.Status != models.SUBSCRIPTION_PAUSED {
+		return nil, errors.New("only active or paused subscriptions can be skipped")
+	}
+
+	sub.NextRunAt = sub.NextRunAt.Add(intervalDuration(sub.IntervalDays))
+	return sub, ss.save(ctx, sub, "next_run_at")
+}
+
+func (ss *SubscriptionService) CancelSubscription(ctx context.Context, p authz.Principal, id uint) (*models.Subscription, error) {
+	sub, err := ss.getSubscription(ctx, p, authz.ActionCancel, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if sub.Status == models.SUBSCRIPTION_CANCELED {
+		return nil, errors.New("subscription has already been canceled")
+	}
+
+	// The order of a run that has not been paid for yet is not delivered
+	if sub.PendingOrderID != nil {
+		if _, err := ss.orderService.CancelOrder(ctx, authz.User(sub.UserID), *sub.PendingOrderID); err != nil {
+			logrus.Errorf("error canceling pending order %d of subscription %d: %v", *sub.PendingOrderID, sub.Id, err)
+		}
+		sub.PendingOrderID = nil
+	}
+
+	sub.Status = models.SUBSCRIPTION_CANCELED
+	return sub, ss.save(ctx, sub, "status", "pending_order_id")
+}
+
+// RunDueSubscriptions generates and charges the orders of every subscription
+// that is due, and returns the number of runs processed.
+func (ss *SubscriptionService) RunDueSubscriptions(ctx context.Context) (int, error) {
+	now := util.CurrentTime()
+
+	subs, err := ss.repo.ListDue(ctx, now)
+	if err != nil {
+		return 0, fmt.Errorf("error listing due subscriptions, %w", err)
+	}
+
+	processed := 0
+	for i := range subs {
+		sub := &subs[i]
+
+		claimed, err := ss.repo.Claim(ctx, sub.Id, sub.NextRunAt, now.Add(subscriptionLease))
+		if err != nil {
+			return processed, err
+		}
+		if !claimed {
+			continue
+		}
+
+		if err := ss.run(ctx, sub, now); err != nil {
+			logrus.Errorf("error running subscription %d: %v", sub.Id, err)
+			continue
+		}
+		processed++
+	}
+
+	return processed, nil
+}
+
+// run charges a single due subscription. On success the next run is
+// scheduled one interval later; on failure the subscription is dunned.
+func (ss *SubscriptionService) run(ctx context.Context, sub *models.Subscription, now time.Time) error {
+	status := sub.Status
+
+	if sub.PendingOrderID == nil {
+		req := models.CreateOrderRequest{}
+		for _, v := range sub.Items {
+			req.Coffees = append(req.Coffees, models.CoffeeInfo{CoffeeID: v.CoffeeID, Quantity: v.Quantity})
+		}
+
+		// A failure here is not a payment failure, so the run is simply
+		// retried once the lease expires.
+		order, err := ss.orderService.PlaceOrder(ctx, sub.UserID, &req)
+		if err != nil {
+			return fmt.Errorf("error placing order, %w", err)
+		}
+
+		// The order is recorded before it is charged, so that a later run
+		// verifies a charge whose outcome is not known instead of placing
+		// and charging another order
+		recorded, err := ss.repo.SetPendingOrder(ctx, sub.Id, status, order.Id)
+		if err != nil {
+			return err
+		}
+		if !recorded {
+			if _, err := ss.orderService.CancelOrder(ctx, authz.User(sub.UserID), order.Id); err != nil {
+				logrus.Errorf("error canceling order %d of changed subscription %d: %v", order.Id, sub.Id, err)
+			}
+			return errSubscriptionChanged
+		}
+		sub.PendingOrderID = &order.Id
+	}
+
+	// A charge made on an earlier run may have gone through without its
+	// outcome being stored, or with its outcome not known yet, so the order
+	// is checked and verified before it is charged again
+	trx, err := ss.trxService.CompletedCharge(ctx, *sub.PendingOrderID)
+	if err != nil {
+		return err
+	}
+	if trx == nil {
+		trx, err = ss.trxService.VerifyCharge(ctx, *sub.PendingOrderID)
+		if err != nil {
+			return fmt.Errorf("error verifying earlier charge, %w", err)
+		}
+	}
+
+	if trx == nil {
+		charged, err := ss.trxService.ChargeAuthorization(ctx, sub.UserID, *sub.PendingOrderID, sub.AuthorizationCode)
+		if errors.Is(err, ErrChargeUnconfirmed) {
+			return err
+		}
+		if err != nil {
+			logrus.Warnf("recurring charge for subscription %d failed: %v", sub.Id, err)
+			return ss.dun(ctx, sub, status, now)
+		}
+		trx = &charged
+	}
+
+	switch trx.PaymentStatus {
+	case models.PAYMENT_FAILED:
+		return ss.dun(ctx, sub, status, now)
+	case models.PAYMENT_COMPLETED:
+	default:
+		// Verified again once the lease expires
+		return fmt.Errorf("charge %s is still processing", trx.Reference)
+	}
+
+	next := sub.NextRunAt.Add(intervalDuration(sub.IntervalDays))
+	if sub.Status == models.SUBSCRIPTION_PAST_DUE || next.Before(now) {
+		next = now.Add(intervalDuration(sub.IntervalDays))
+	}
+
+	sub.Status = models.SUBSCRIPTION_ACTIVE
+	sub.PendingOrderID = nil
+	sub.FailedAttempts = 0
+	sub.LastFailureAt = nil
+	sub.NextRunAt = next
+
+	return ss.saveRun(ctx, sub, status)
+}
+
+// dun records a failed charge and schedules a retry, or cancels the
+// subscription and its pending order once the retries are exhausted. status
+// is the status the run started with.
+func (ss *SubscriptionService) dun(ctx context.Context, sub *models.Subscription, status string, now time.Time) error {
+	sub.FailedAttempts++
+	sub.LastFailureAt = &now
+
+	if int(sub.FailedAttempts) > len(dunningSchedule) {
+		if _, err := ss.orderService.CancelOrder(ctx, authz.User(sub.UserID), *sub.PendingOrderID); err != nil {
+			logrus.Errorf("error canceling pending order %d of subscription %d: %v", *sub.PendingOrderID, sub.Id, err)
+		}
+
+		sub.Status = models.SUBSCRIPTION_CANCELED
+		sub.PendingOrderID = nil
+		return ss.saveRun(ctx, sub, status)
+	}
+
+	sub.Status = models.SUBSCRIPTION_PAST_DUE
+	sub.NextRunAt = now.Add(dunningSchedule[sub.FailedAttempts-1])
+	return ss.saveRun(ctx, sub, status)
+}
+
+// activateOnFirstPayment stores the reusable authorization from the first
+// order's payment and activates the subscription.
+func (ss *SubscriptionService) activateOnFirstPayment(ctx context.Context, trx models.Transaction, pm *models.PaymentMethod) error {
+	sub, err := ss.repo.GetAwaitingAuthorization(ctx, trx.OrderID)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil
+		}
+		return fmt.Errorf("error fetching subscription for order %d, %w", trx.OrderID, err)
+	}
+
+	if pm == nil {
+		logrus.Warnf("subscription %d was paid with a non-reusable authorization", sub.Id)
+		return nil
+	}
+
+	sub.AuthorizationCode = pm.AuthorizationCode
+	sub.Status = models.SUBSCRIPTION_ACTIVE
+	return ss.save(ctx, sub, "authorization_code", "status")
+}
+
+// save stores the given columns of sub. Only the columns a change is about
+// are written, so that it does not undo a concurrent run of the worker.
+func (ss *SubscriptionService) save(ctx context.Context, sub *models.Subscription, columns ...string) error {
+	sub.UpdatedAt = util.CurrentTime()
+	if err := ss.repo.Update(ctx, sub, columns...); err != nil {
+		return fmt.Errorf("error updating subscription, %w", err)
+	}
+	return nil
+}
+
+// saveRun stores the outcome of a run that started with status. If the user
+// changed the subscription in the meantime, their change is kept; a paid
+// pending order is picked up again by the run after they resume.
+func (ss *SubscriptionService) saveRun(ctx context.Context, sub *models.Subscription, status string) error {
+	sub.UpdatedAt = util.CurrentTime()
+	saved, err := ss.repo.UpdateRun(ctx, sub, status)
+	if err != nil {
+		return err
+	}
+	if !saved {
+		return errSubscriptionChanged
+	}
+	return nil
+}
+
+func intervalDuration(days uint) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
@@ -0,0 +1,139 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/platform"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// createDueSubscription stores an active subscription of userId for one
+// coffee whose next run is due.
+func createDueSubscription(t *testing.T, db *gorm.DB, userId uint) *models.Subscription {
+	t.Helper()
+
+	coffee := &models.Coffee{Name: "Cold Brew", Price: "2000.00", Currency: "NGN", Quantity: 10, CreatedAt: util.CurrentTime()}
+	require.NoError(t, db.Create(coffee).Error)
+
+	now := util.CurrentTime()
+	sub := &models.Subscription{
+		UserID:            userId,
+		Status:            models.SUBSCRIPTION_ACTIVE,
+		IntervalDays:      7,
+		Items:             []models.SubscriptionItem{{CoffeeID: coffee.Id, Quantity: 1}},
+		NextRunAt:         now.Add(-time.Minute),
+		AuthorizationCode: "AUTH_test",
+		CreatedAt:         now,
+		UpdatedAt:         now,
+	}
+	require.NoError(t, db.Create(sub).Error)
+	return sub
+}
+
+func TestSubscriptionRun(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	newService := func(provider platform.Provider) *SubscriptionService {
+		return NewSubscriptionService(repository.NewSubscriptionRepository(db), newTestOrderService(db), newTestTransactionService(t, db, provider))
+	}
+	reload := func(sub *models.Subscription) *models.Subscription {
+		var stored models.Subscription
+		require.NoError(t, db.First(&stored, sub.Id).Error)
+		return &stored
+	}
+	countOrders := func(userId uint) int64 {
+		var n int64
+		require.NoError(t, db.Model(&models.Order{}).Where("user_id = ?", userId).Count(&n).Error)
+		return n
+	}
+
+	t.Run("an unconfirmed charge is verified, not repeated", func(t *testing.T) {
+		provider := &fakeProvider{status: platform.CHARGE_SUCCESS, chargeErr: errors.New("connection reset")}
+		ss := newService(provider)
+		user := createUser(t, db, "subunconfirmed", models.ROLE_USER)
+		sub := createDueSubscription(t, db, user.Id)
+
+		require.Error(t, ss.run(ctx, sub, util.CurrentTime()))
+		require.Equal(t, 1, provider.charges)
+		require.NotNil(t, reload(sub).PendingOrderID)
+
+		// The next run, once the lease expires, finds the charge went through
+		provider.chargeErr = nil
+		var again models.Subscription
+		require.NoError(t, db.Preload("Items").First(&again, sub.Id).Error)
+		require.NoError(t, ss.run(ctx, &again, util.CurrentTime()))
+
+		require.Equal(t, 1, provider.charges)
+		require.EqualValues(t, 1, countOrders(user.Id))
+		stored := reload(sub)
+		require.Nil(t, stored.PendingOrderID)
+		require.Equal(t, models.SUBSCRIPTION_ACTIVE, stored.Status)
+	})
+
+	t.Run("a cancel made during a run is kept", func(t *testing.T) {
+		provider := &fakeProvider{status: platform.CHARGE_SUCCESS}
+		ss := newService(provider)
+		user := createUser(t, db, "subcanceled", models.ROLE_USER)
+		sub := createDueSubscription(t, db, user.Id)
+
+		// The user cancels after the worker read the subscription as active
+		require.NoError(t, db.Model(&models.Subscription{}).Where("id = ?", sub.Id).Update("status", models.SUBSCRIPTION_CANCELED).Error)
+		require.ErrorIs(t, ss.run(ctx, sub, util.CurrentTime()), errSubscriptionChanged)
+
+		require.Zero(t, provider.charges)
+		require.Equal(t, models.SUBSCRIPTION_CANCELED, reload(sub).Status)
+	})
+
+	t.Run("a failed charge does not revive a canceled subscription", func(t *testing.T) {
+		ss := newService(&fakeProvider{status: platform.CHARGE_FAILED})
+		user := createUser(t, db, "subdunned", models.ROLE_USER)
+		sub := createDueSubscription(t, db, user.Id)
+
+		// The user cancels after the worker read the subscription as active
+		require.NoError(t, db.Model(&models.Subscription{}).Where("id = ?", sub.Id).Update("status", models.SUBSCRIPTION_CANCELED).Error)
+		require.ErrorIs(t, ss.dun(ctx, sub, models.SUBSCRIPTION_ACTIVE, util.CurrentTime()), errSubscriptionChanged)
+
+		stored := reload(sub)
+		require.Equal(t, models.SUBSCRIPTION_CANCELED, stored.Status)
+		require.Zero(t, stored.FailedAttempts)
+	})
+
+	t.Run("a paid pending order is not charged again", func(t *testing.T) {
+		provider := &fakeProvider{status: platform.CHARGE_SUCCESS}
+		ss := newService(provider)
+		user := createUser(t, db, "subpaid", models.ROLE_USER)
+		sub := createDueSubscription(t, db, user.Id)
+
+		// The charge went through on a run that was paused before it could
+		// store the outcome
+		order := createOrder(t, db, user.Id, "2000.00")
+		require.NoError(t, db.Create(&models.Transaction{
+			OrderID:          order.Id,
+			UserID:           user.Id,
+			Reference:        "subpaid-1",
+			PaymentReference: "subpaid-1",
+			PaymentStatus:    models.PAYMENT_COMPLETED,
+			Currency:         "NGN",
+			TotalAmount:      "2000.00",
+			CreatedAt:        util.CurrentTime(),
+			UpdatedAt:        util.CurrentTime(),
+		}).Error)
+		require.NoError(t, db.Model(&models.Subscription{}).Where("id = ?", sub.Id).Update("pending_order_id", order.Id).Error)
+
+		var due models.Subscription
+		require.NoError(t, db.Preload("Items").First(&due, sub.Id).Error)
+		require.NoError(t, ss.run(ctx, &due, util.CurrentTime()))
+
+		require.Zero(t, provider.charges)
+		require.Nil(t, reload(sub).PendingOrderID)
+	})
+}
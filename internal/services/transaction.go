@@ -2,23 +2,47 @@ package services
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/platform"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/platform/paystack"
 	"github.com/emmrys-jay/coffee-delivery-api/util"
 	"github.com/shopspring/decimal"
+	"github.com/sirupsen/logrus"
 	"gorm.io/gorm"
 )
 
+var (
+	// ErrInvalidWebhookSignature is returned when a webhook body does not
+	// carry a valid signature from the payment provider.
+	ErrInvalidWebhookSignature = errors.New("invalid webhook signature")
+
+	// ErrChargeUnconfirmed is returned when a charge was sent to the provider
+	// but its outcome is not known, e.g. because the connection failed. The
+	// charge may still have gone through, so it must be verified with
+	// VerifyCharge before the order is charged again.
+	ErrChargeUnconfirmed = errors.New("the outcome of the charge is not known yet")
+)
+
+// PaymentListener is notified after a transaction has been paid successfully,
+// along with the payment method saved from the charge, which is nil if its
+// authorization can not be reused or the customer has since deleted it.
+// Like any outbox subscriber it may see the same transaction more than once,
+// and a returned error has it called again later.
+type PaymentListener func(ctx context.Context, trx models.Transaction, pm *models.PaymentMethod) error
+
 type TransactionService struct {
 	orderRepo   *repository.OrderRepository
 	userRepo    *repository.UserRepository
 	reserveRepo *repository.ReservationRepository
 	trxRepo     *repository.TransactionRepository
+	pmRepo      *repository.PaymentMethodRepository
 
 	provider        platform.PaymentProvider
 	paymentPlatform platform.Provider
@@ -30,6 +54,7 @@ func NewTransactionService(
 	userRepo *repository.UserRepository,
 	reserveRepo *repository.ReservationRepository,
 	trxRepo *repository.TransactionRepository,
+	pmRepo *repository.PaymentMethodRepository,
 ) (*TransactionService, error) {
 
 	ps := &TransactionService{
@@ -37,6 +62,7 @@ func NewTransactionService(
 		userRepo:    userRepo,
 		reserveRepo: reserveRepo,
 		trxRepo:     trxRepo,
+		pmRepo:      pmRepo,
 	}
 
 	switch provider {
@@ -62,9 +88,31 @@ func (ps *TransactionService) Initiate(ctx context.Context, userId uint, req *mo
 		return models.Transaction{}, fmt.Errorf("error getting order, %w", err)
 	}
 
-	totalAmount, err := decimal.NewFromString(order.TotalAmount)
+	if err := authz.Order(authz.User(user.Id), authz.ActionPay, order); err != nil {
+		return models.Transaction{}, err
+	}
+
+	paid, err := ps.trxRepo.HasCompletedTransaction(ctx, order.Id)
 	if err != nil {
-		return models.Transaction{}, fmt.Errorf("error parsing total amount, %w", err)
+		return models.Transaction{}, fmt.Errorf("error checking order payments, %w", err)
+	}
+	if paid {
+		return models.Transaction{}, errors.New("this order has already been paid for")
+	}
+
+	// A charge of a saved payment method that is still processing may yet
+	// go through, so the order is not charged again
+	processing, err := ps.VerifyCharge(ctx, order.Id)
+	if err != nil {
+		return models.Transaction{}, err
+	}
+	if processing != nil {
+		switch processing.PaymentStatus {
+		case models.PAYMENT_COMPLETED:
+			return models.Transaction{}, errors.New("this order has already been paid for")
+		case models.PAYMENT_PROCESSING:
+			return *processing, nil
+		}
 	}
 
 	pendingTransaction, err := ps.trxRepo.GetPendingTransaction(ctx, order.Id, user.Id)
@@ -76,10 +124,25 @@ func (ps *TransactionService) Initiate(ctx context.Context, userId uint, req *mo
 		return *pendingTransaction, nil
 	}
 
+	if req.PaymentMethodID != nil {
+		pm, err := ps.pmRepo.GetByID(ctx, *req.PaymentMethodID)
+		if err != nil || authz.PaymentMethod(authz.User(user.Id), authz.ActionPay, pm) != nil {
+			return models.Transaction{}, errors.New("payment method not found")
+		}
+
+		return ps.ChargeAuthorization(ctx, user.Id, order.Id, pm.AuthorizationCode)
+	}
+
+	totalAmount, err := decimal.NewFromString(order.TotalAmount)
+	if err != nil {
+		return models.Transaction{}, fmt.Errorf("error parsing total amount, %w", err)
+	}
+
 	reference := util.GenerateReference()
+	currency := util.NormalizeCurrency(order.Currency)
 
 	// Initiate payment transaction
-	resp, err := ps.paymentPlatform.InitiateTransaction(user.Email, totalAmount, reference)
+	resp, err := ps.paymentPlatform.InitiateTransaction(user.Email, totalAmount, currency, reference)
 	if err != nil {
 		return models.Transaction{}, fmt.Errorf("error initiating payment transaction, %w", err)
 	}
@@ -93,6 +156,7 @@ func (ps *TransactionService) Initiate(ctx context.Context, userId uint, req *mo
 		PaymentStatus:    models.PAYMENT_PENDING,
 		CreatedAt:        util.CurrentTime(),
 		UpdatedAt:        util.CurrentTime(),
+		Currency:         currency,
 		TotalAmount:      totalAmount.String(),
 		AuthorizationURL: resp.Data.AuthorizationURL,
 	}
@@ -103,3 +167,258 @@ func (ps *TransactionService) Initiate(ctx context.Context, userId uint, req *mo
 
 	return trx, nil
 }
+
+// OnPaymentSuccess subscribes a listener, under name, to every transaction
+// that is confirmed as paid.
+func (ps *TransactionService) OnPaymentSuccess(dispatcher *outbox.Dispatcher, name string, listener PaymentListener) {
+	dispatcher.Subscribe(name, models.OUTBOX_TRANSACTION_STATUS_CHANGED, func(ctx context.Context, event models.OutboxEvent) error {
+		var change models.TransactionChanged
+		if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+			return fmt.Errorf("error decoding outbox payload, %w", err)
+		}
+
+		if change.PaymentStatus != models.PAYMENT_COMPLETED {
+			return nil
+		}
+
+		trx, err := ps.trxRepo.GetTransactionById(ctx, change.TransactionID)
+		if err != nil {
+			return fmt.Errorf("error getting transaction, %w", err)
+		}
+
+		var pm *models.PaymentMethod
+		if trx.PaymentMethodID != nil {
+			pm, err = ps.pmRepo.GetByID(ctx, *trx.PaymentMethodID)
+			if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+				return fmt.Errorf("error getting payment method, %w", err)
+			}
+		}
+
+		return listener(ctx, *trx, pm)
+	})
+}
+
+// ChargeAuthorization pays for an order by charging a reusable authorization
+// directly, without redirecting the customer to the provider.
+func (ps *TransactionService) ChargeAuthorization(ctx context.Context, userId, orderId uint, authorizationCode string) (models.Transaction, error) {
+	user, err := ps.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return models.Transaction{}, fmt.Errorf("error getting user, %w", err)
+	}
+
+	order, err := ps.orderRepo.GetOrder(ctx, orderId)
+	if err != nil {
+		return models.Transaction{}, fmt.Errorf("error getting order, %w", err)
+	}
+
+	if err := authz.Order(authz.User(user.Id), authz.ActionPay, order); err != nil {
+		return models.Transaction{}, err
+	}
+
+	totalAmount, err := decimal.NewFromString(order.TotalAmount)
+	if err != nil {
+		return models.Transaction{}, fmt.Errorf("error parsing total amount, %w", err)
+	}
+
+	reference := util.GenerateReference()
+	currency := util.NormalizeCurrency(order.Currency)
+
+	// The transaction is recorded before the charge is sent, so a charge
+	// whose response is lost can still be verified by its reference
+	trx := models.Transaction{
+		OrderID:          order.Id,
+		UserID:           user.Id,
+		Reference:        reference,
+		PaymentReference: reference,
+		PaymentStatus:    models.PAYMENT_PROCESSING,
+		CreatedAt:        util.CurrentTime(),
+		UpdatedAt:        util.CurrentTime(),
+		Currency:         currency,
+		TotalAmount:      totalAmount.String(),
+	}
+
+	if err = ps.trxRepo.CreateTransaction(ctx, &trx); err != nil {
+		return models.Transaction{}, fmt.Errorf("error creating transaction, %w", err)
+	}
+
+	resp, err := ps.paymentPlatform.ChargeAuthorization(user.Email, totalAmount, currency, reference, authorizationCode)
+	if err != nil {
+		return trx, fmt.Errorf("error charging authorization, %w: %w", ErrChargeUnconfirmed, err)
+	}
+
+	return ps.settleCharge(ctx, trx, chargeStatusToPaymentStatus(resp.Data.Status), resp.Data.Authorization)
+}
+
+// CompletedCharge returns the transaction that paid for an order, or nil if
+// the order has not been paid for.
+func (ps *TransactionService) CompletedCharge(ctx context.Context, orderId uint) (*models.Transaction, error) {
+	trx, err := ps.trxRepo.GetCompletedTransaction(ctx, orderId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting completed transaction, %w", err)
+	}
+	return trx, nil
+}
+
+// VerifyCharge asks the provider for the outcome of the latest charge of an
+// order that is still processing, and records it. It returns nil if the
+// order has no such charge.
+func (ps *TransactionService) VerifyCharge(ctx context.Context, orderId uint) (*models.Transaction, error) {
+	trx, err := ps.trxRepo.GetProcessingTransaction(ctx, orderId)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("error getting processing transaction, %w", err)
+	}
+
+	resp, err := ps.paymentPlatform.VerifyTransaction(trx.Reference)
+	if err != nil {
+		return nil, fmt.Errorf("error verifying transaction, %w", err)
+	}
+
+	// The provider never received a charge it does not know
+	status := models.PAYMENT_FAILED
+	if resp.Status {
+		status = chargeStatusToPaymentStatus(resp.Data.Status)
+	}
+
+	settled, err := ps.settleCharge(ctx, *trx, status, resp.Data.Authorization)
+	if err != nil {
+		return nil, err
+	}
+	return &settled, nil
+}
+
+// settleCharge records the outcome of a charge that was processing.
+func (ps *TransactionService) settleCharge(ctx context.Context, trx models.Transaction, status string, auth platform.Authorization) (models.Transaction, error) {
+	if status == models.PAYMENT_PROCESSING {
+		return trx, nil
+	}
+
+	if status == models.PAYMENT_COMPLETED {
+		if err := ps.completeTransaction(ctx, &trx, auth); err != nil {
+			return trx, err
+		}
+		return trx, nil
+	}
+
+	if err := ps.trxRepo.UpdateTransactionStatus(ctx, trx.ID, status); err != nil {
+		return trx, fmt.Errorf("error updating transaction status, %w", err)
+	}
+
+	trx.PaymentStatus = status
+	return trx, nil
+}
+
+// completeTransaction records a transaction as paid with the masked details
+// of the card used. A reusable authorization is saved as a payment method,
+// so the customer can be charged again without a redirect; it is not kept
+// on the transaction.
+func (ps *TransactionService) completeTransaction(ctx context.Context, trx *models.Transaction, auth platform.Authorization) error {
+	trx.CardBrand = auth.Brand
+	trx.CardLast4 = auth.Last4
+	trx.CardExpMonth = auth.ExpMonth
+	trx.CardExpYear = auth.ExpYear
+
+	var pm *models.PaymentMethod
+	if auth.Reusable && auth.AuthorizationCode != "" {
+		now := util.CurrentTime()
+		pm = &models.PaymentMethod{
+			UserID:            trx.UserID,
+			Provider:          ps.provider.String(),
+			AuthorizationCode: auth.AuthorizationCode,
+			Signature:         auth.Signature,
+			Channel:           auth.Channel,
+			CardType:          auth.CardType,
+			Brand:             auth.Brand,
+			Bank:              auth.Bank,
+			Last4:             auth.Last4,
+			ExpMonth:          auth.ExpMonth,
+			ExpYear:           auth.ExpYear,
+			CreatedAt:         now,
+			UpdatedAt:         now,
+		}
+	}
+
+	if err := ps.trxRepo.CompleteTransaction(ctx, trx, pm); err != nil {
+		return fmt.Errorf("error updating transaction status, %w", err)
+	}
+
+	trx.PaymentStatus = models.PAYMENT_COMPLETED
+	if pm != nil {
+		trx.PaymentMethodID = &pm.Id
+	}
+	return nil
+}
+
+// HandleWebhook verifies and processes a webhook sent by the payment provider.
+func (ps *TransactionService) HandleWebhook(ctx context.Context, body []byte, signature string) error {
+	if !ps.paymentPlatform.VerifyWebhookSignature(body, signature) {
+		return ErrInvalidWebhookSignature
+	}
+
+	var event platform.WebhookEvent
+	if err := json.Unmarshal(body, &event); err != nil {
+		return fmt.Errorf("error parsing webhook body, %w", err)
+	}
+
+	switch event.Event {
+	case platform.EVENT_CHARGE_SUCCESS:
+		trx, err := ps.trxRepo.GetTransactionByReference(ctx, event.Data.Reference)
+		if err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				logrus.Warnf("webhook received for unknown transaction reference %s", event.Data.Reference)
+				return nil
+			}
+			return fmt.Errorf("error getting transaction, %w", err)
+		}
+
+		// Providers may deliver the same event more than once
+		if trx.PaymentStatus == models.PAYMENT_COMPLETED {
+			return nil
+		}
+
+		if err := ps.completeTransaction(ctx, trx, event.Data.Authorization); err != nil {
+			return err
+		}
+	default:
+		logrus.Infof("ignoring webhook event %s", event.Event)
+	}
+
+	return nil
+}
+
+func (ps *TransactionService) ListPaymentMethods(ctx context.Context, userId uint) ([]models.PaymentMethod, error) {
+	return ps.pmRepo.ListUserPaymentMethods(ctx, userId)
+}
+
+// DeletePaymentMethod removes a saved card the principal may delete. Missing
+// cards are reported like hidden ones.
+func (ps *TransactionService) DeletePaymentMethod(ctx context.Context, p authz.Principal, id uint) error {
+	pm, err := ps.pmRepo.GetByID(ctx, id)
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return authz.ErrNotFound
+		}
+		return err
+	}
+
+	if err := authz.PaymentMethod(p, authz.ActionDelete, pm); err != nil {
+		return err
+	}
+	return ps.pmRepo.Delete(ctx, pm.UserID, id)
+}
+
+func chargeStatusToPaymentStatus(status string) string {
+	switch status {
+	case platform.CHARGE_SUCCESS:
+		return models.PAYMENT_COMPLETED
+	case platform.CHARGE_FAILED, platform.CHARGE_ABANDONED, platform.CHARGE_REVERSED:
+		return models.PAYMENT_FAILED
+	default:
+		return models.PAYMENT_PROCESSING
+	}
+}
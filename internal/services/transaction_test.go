@@ -0,0 +1,194 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/platform"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/shopspring/decimal"
+	"github.com/stretchr/testify/require"
+	"gorm.io/gorm"
+)
+
+// fakeProvider answers every charge of a saved authorization with status and
+// counts the charges. If chargeErr is set, charges fail with it as if the
+// response was lost.
+type fakeProvider struct {
+	status    string
+	chargeErr error
+	charges   int
+}
+
+func (p *fakeProvider) InitiateTransaction(email string, amount decimal.Decimal, currency, reference string) (platform.InitTransactionResponse, error) {
+	var resp platform.InitTransactionResponse
+	resp.Status = true
+	resp.Data.Reference = reference
+	resp.Data.AuthorizationURL = "https://checkout.example.com/" + reference
+	return resp, nil
+}
+
+func (p *fakeProvider) ChargeAuthorization(email string, amount decimal.Decimal, currency, reference, authorizationCode string) (platform.ChargeResponse, error) {
+	p.charges++
+	if p.chargeErr != nil {
+		return platform.ChargeResponse{}, p.chargeErr
+	}
+
+	var resp platform.ChargeResponse
+	resp.Status = true
+	resp.Data.Status = p.status
+	resp.Data.Reference = reference
+	resp.Data.Authorization = platform.Authorization{AuthorizationCode: authorizationCode, Reusable: true, Signature: "SIG_test", Last4: "4081"}
+	return resp, nil
+}
+
+func (p *fakeProvider) VerifyTransaction(reference string) (platform.ChargeResponse, error) {
+	var resp platform.ChargeResponse
+	resp.Status = true
+	resp.Data.Status = p.status
+	resp.Data.Reference = reference
+	return resp, nil
+}
+
+func (p *fakeProvider) VerifyWebhookSignature(body []byte, signature string) bool {
+	return false
+}
+
+func newTestTransactionService(t *testing.T, db *gorm.DB, provider platform.Provider) *TransactionService {
+	t.Helper()
+
+	ts, err := NewTransactionService(
+		platform.PAYSTACK.String(),
+		repository.NewOrderRepository(db),
+		repository.NewUserRepository(db),
+		repository.NewReservationRepository(db),
+		repository.NewTransactionRepository(db),
+		repository.NewPaymentMethodRepository(db),
+	)
+	require.NoError(t, err)
+	ts.paymentPlatform = provider
+	return ts
+}
+
+func createPaymentMethod(t *testing.T, db *gorm.DB, userId uint) *models.PaymentMethod {
+	t.Helper()
+
+	pm := &models.PaymentMethod{
+		UserID:            userId,
+		Provider:          platform.PAYSTACK.String(),
+		AuthorizationCode: "AUTH_test",
+		CreatedAt:         util.CurrentTime(),
+		UpdatedAt:         util.CurrentTime(),
+	}
+	require.NoError(t, db.Create(pm).Error)
+	return pm
+}
+
+func TestInitiateWithSavedPaymentMethod(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+
+	t.Run("a processing charge is not repeated", func(t *testing.T) {
+		provider := &fakeProvider{status: "pending"}
+		ts := newTestTransactionService(t, db, provider)
+
+		user := createUser(t, db, "processing", models.ROLE_USER)
+		order := createOrder(t, db, user.Id, "2500.00")
+		pm := createPaymentMethod(t, db, user.Id)
+
+		first, err := ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.NoError(t, err)
+		require.Equal(t, models.PAYMENT_PROCESSING, first.PaymentStatus)
+
+		second, err := ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.NoError(t, err)
+		require.Equal(t, first.ID, second.ID)
+		require.Equal(t, 1, provider.charges)
+
+		// Once the charge fails, the order can be charged again
+		provider.status = platform.CHARGE_FAILED
+		third, err := ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.NoError(t, err)
+		require.NotEqual(t, first.ID, third.ID)
+		require.Equal(t, 2, provider.charges)
+	})
+
+	t.Run("a pending checkout is not charged again", func(t *testing.T) {
+		provider := &fakeProvider{status: platform.CHARGE_SUCCESS}
+		ts := newTestTransactionService(t, db, provider)
+
+		user := createUser(t, db, "checkout", models.ROLE_USER)
+		order := createOrder(t, db, user.Id, "2500.00")
+		pm := createPaymentMethod(t, db, user.Id)
+
+		checkout, err := ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id})
+		require.NoError(t, err)
+		require.Equal(t, models.PAYMENT_PENDING, checkout.PaymentStatus)
+
+		trx, err := ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.NoError(t, err)
+		require.Equal(t, checkout.ID, trx.ID)
+		require.Zero(t, provider.charges)
+	})
+
+	t.Run("a paid order is not charged again", func(t *testing.T) {
+		provider := &fakeProvider{status: platform.CHARGE_SUCCESS}
+		ts := newTestTransactionService(t, db, provider)
+
+		user := createUser(t, db, "paid", models.ROLE_USER)
+		order := createOrder(t, db, user.Id, "2500.00")
+		pm := createPaymentMethod(t, db, user.Id)
+
+		trx, err := ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.NoError(t, err)
+		require.Equal(t, models.PAYMENT_COMPLETED, trx.PaymentStatus)
+
+		_, err = ts.Initiate(ctx, user.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.ErrorContains(t, err, "already been paid")
+		require.Equal(t, 1, provider.charges)
+
+		// The card used is saved for the next order, and only its masked
+		// details are kept on the transaction
+		methods, err := ts.ListPaymentMethods(ctx, user.Id)
+		require.NoError(t, err)
+		require.Len(t, methods, 2)
+
+		var stored models.Transaction
+		require.NoError(t, db.First(&stored, trx.ID).Error)
+		require.Equal(t, "4081", stored.CardLast4)
+		require.Equal(t, methods[0].Id, *stored.PaymentMethodID)
+		require.False(t, db.Migrator().HasColumn(&models.Transaction{}, "authorization"))
+
+		// Listeners get the saved payment method
+		var received *models.PaymentMethod
+		dispatcher := outbox.NewDispatcher(repository.NewOutboxRepository(db))
+		ts.OnPaymentSuccess(dispatcher, "test-listener", func(ctx context.Context, trx models.Transaction, pm *models.PaymentMethod) error {
+			if trx.ID == stored.ID {
+				received = pm
+			}
+			return nil
+		})
+		_, err = dispatcher.Dispatch(ctx)
+		require.NoError(t, err)
+		require.NotNil(t, received)
+		require.Equal(t, "AUTH_test", received.AuthorizationCode)
+	})
+
+	t.Run("another user's payment method can not be used", func(t *testing.T) {
+		provider := &fakeProvider{status: platform.CHARGE_SUCCESS}
+		ts := newTestTransactionService(t, db, provider)
+
+		owner := createUser(t, db, "cardowner", models.ROLE_USER)
+		other := createUser(t, db, "cardthief", models.ROLE_USER)
+		order := createOrder(t, db, other.Id, "2500.00")
+		pm := createPaymentMethod(t, db, owner.Id)
+
+		_, err := ts.Initiate(ctx, other.Id, &models.TransactionRequest{OrderID: order.Id, PaymentMethodID: &pm.Id})
+		require.ErrorContains(t, err, "payment method not found")
+		require.Zero(t, provider.charges)
+	})
+}
@@ -0,0 +1,261 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/totp"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+const (
+	defaultTOTPIssuer = "Coffee Delivery"
+	recoveryCodeCount = 10
+
+	// totpSkew is how many 30 second steps of clock drift are accepted
+	totpSkew = 1
+)
+
+// ErrInvalidTwoFactorCode is returned when a TOTP or recovery code is wrong
+// or was already used.
+var ErrInvalidTwoFactorCode = errors.New("invalid two-factor code")
+
+// TwoFactorService enrolls users in TOTP two-factor authentication and
+// checks their codes. Everything runs locally: the secret is shared with
+// the user's authenticator app once, at enrollment.
+type TwoFactorService struct {
+	userRepo *repository.UserRepository
+	repo     *repository.TwoFactorRepository
+
+	// authService confirms that it is the user turning two-factor
+	// authentication off. It is set by NewAuthService, which depends on
+	// this service.
+	authService *AuthService
+
+	issuer string
+
+	// requireForAdmins makes two-factor authentication mandatory for admins,
+	// set with REQUIRE_ADMIN_2FA=true
+	requireForAdmins bool
+}
+
+func NewTwoFactorService(userRepo *repository.UserRepository, repo *repository.TwoFactorRepository) *TwoFactorService {
+	svc := &TwoFactorService{
+		userRepo:         userRepo,
+		repo:             repo,
+		issuer:           defaultTOTPIssuer,
+		requireForAdmins: os.Getenv("REQUIRE_ADMIN_2FA") == "true",
+	}
+
+	if v := os.Getenv("TOTP_ISSUER"); v != "" {
+		svc.issuer = v
+	}
+	return svc
+}
+
+// Required reports whether the user must use two-factor authentication to
+// sign in.
+func (s *TwoFactorService) Required(user *models.User) bool {
+	return s.requireForAdmins && user.Role == models.ROLE_ADMIN
+}
+
+// Enroll starts two-factor enrollment for the user. It is not enabled until
+// a code from the new secret is confirmed.
+func (s *TwoFactorService) Enroll(ctx context.Context, userId uint) (*models.TwoFactorEnrollment, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+	return s.enroll(ctx, user)
+}
+
+// Confirm enables two-factor authentication with a code from the secret
+// returned by Enroll, and returns the user's recovery codes. They are not
+// shown again.
+func (s *TwoFactorService) Confirm(ctx context.Context, userId uint, code string) (*models.RecoveryCodes, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	codes, err := s.confirm(ctx, user, code)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RecoveryCodes{Codes: codes}, nil
+}
+
+// Disable turns two-factor authentication off once the user has confirmed
+// who they are. Users whose role requires it can not.
+func (s *TwoFactorService) Disable(ctx context.Context, userId uint, req *models.DisableTwoFactorRequest) error {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	if !user.TwoFactorEnabled {
+		return errors.New("two-factor authentication is not enabled")
+	}
+	if s.Required(user) {
+		return fmt.Errorf("%w: two-factor authentication is required for your role", authz.ErrForbidden)
+	}
+	if err := s.authService.Confirm(ctx, user, req.Confirmation); err != nil {
+		return err
+	}
+
+	user.TwoFactorEnabled = false
+	user.TOTPSecret = ""
+	user.UpdatedAt = util.CurrentTime()
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return fmt.Errorf("error disabling two-factor authentication, %w", err)
+	}
+	return s.repo.DeleteRecoveryCodes(ctx, user.Id)
+}
+
+// RegenerateRecoveryCodes replaces the user's recovery codes after checking
+// a current code.
+func (s *TwoFactorService) RegenerateRecoveryCodes(ctx context.Context, userId uint, code string) (*models.RecoveryCodes, error) {
+	user, err := s.userRepo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is not enabled")
+	}
+	if err := s.verify(ctx, user, code, ""); err != nil {
+		return nil, err
+	}
+
+	codes, err := s.newRecoveryCodes(ctx, user.Id)
+	if err != nil {
+		return nil, err
+	}
+	return &models.RecoveryCodes{Codes: codes}, nil
+}
+
+func (s *TwoFactorService) enroll(ctx context.Context, user *models.User) (*models.TwoFactorEnrollment, error) {
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return nil, fmt.Errorf("error generating totp secret, %w", err)
+	}
+
+	user.TOTPSecret = secret
+	user.UpdatedAt = util.CurrentTime()
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error saving totp secret, %w", err)
+	}
+
+	return &models.TwoFactorEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, user.Email, secret),
+	}, nil
+}
+
+func (s *TwoFactorService) confirm(ctx context.Context, user *models.User, code string) ([]string, error) {
+	if user.TwoFactorEnabled {
+		return nil, errors.New("two-factor authentication is already enabled")
+	}
+	if user.TOTPSecret == "" {
+		return nil, errors.New("two-factor enrollment has not been started")
+	}
+	if err := s.checkCode(ctx, user, code); err != nil {
+		return nil, err
+	}
+
+	user.TwoFactorEnabled = true
+	user.UpdatedAt = util.CurrentTime()
+	if err := s.userRepo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error enabling two-factor authentication, %w", err)
+	}
+	return s.newRecoveryCodes(ctx, user.Id)
+}
+
+// verify checks a TOTP code, or a recovery code if code is empty. Either
+// can only be used once.
+func (s *TwoFactorService) verify(ctx context.Context, user *models.User, code, recoveryCode string) error {
+	if code != "" {
+		return s.checkCode(ctx, user, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, user.Id, util.HashToken(normalizeRecoveryCode(recoveryCode)), util.CurrentTime())
+	if err != nil {
+		return err
+	}
+	if !used {
+		return ErrInvalidTwoFactorCode
+	}
+	return nil
+}
+
+func (s *TwoFactorService) checkCode(ctx context.Context, user *models.User, code string) error {
+	step, ok := totp.Validate(user.TOTPSecret, strings.TrimSpace(code), util.CurrentTime(), totpSkew)
+	if !ok {
+		return ErrInvalidTwoFactorCode
+	}
+
+	fresh, err := s.repo.UseTOTPStep(ctx, user.Id, step)
+	if err != nil {
+		return err
+	}
+	if !fresh {
+		return ErrInvalidTwoFactorCode
+	}
+
+	user.TOTPLastStep = step
+	return nil
+}
+
+func (s *TwoFactorService) newRecoveryCodes(ctx context.Context, userId uint) ([]string, error) {
+	now := util.CurrentTime()
+	codes := make([]string, 0, recoveryCodeCount)
+	stored := make([]models.RecoveryCode, 0, recoveryCodeCount)
+	for i := 0; i < recoveryCodeCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("error generating recovery code, %w", err)
+		}
+
+		codes = append(codes, code)
+		stored = append(stored, models.RecoveryCode{
+			UserID:    userId,
+			CodeHash:  util.HashToken(normalizeRecoveryCode(code)),
+			CreatedAt: now,
+		})
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userId, stored); err != nil {
+		return nil, err
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode returns 80 random bits as four groups of four
+// characters, e.g. "k3pd-9xqa-ma2f-7rtn".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 10)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	s := strings.ToLower(base32.StdEncoding.EncodeToString(b))
+	return s[0:4] + "-" + s[4:8] + "-" + s[8:12] + "-" + s[12:16], nil
+}
+
+// normalizeRecoveryCode lets users type codes without dashes or in upper
+// case.
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
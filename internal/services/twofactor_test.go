@@ -0,0 +1,62 @@
+package services
+
+import (
+	"context"
+	"regexp"
+	"testing"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/dbtest"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/totp"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecoveryCodes(t *testing.T) {
+	code, err := generateRecoveryCode()
+	require.NoError(t, err)
+	require.Regexp(t, regexp.MustCompile(`^[a-z2-7]{4}(-[a-z2-7]{4}){3}$`), code)
+
+	// Codes match however the user types them
+	upper := "K3PD 9XQA-MA2F7RTN"
+	require.Equal(t, util.HashToken(normalizeRecoveryCode("k3pd-9xqa-ma2f-7rtn")), util.HashToken(normalizeRecoveryCode(upper)))
+}
+
+func TestTwoFactorRequired(t *testing.T) {
+	s := &TwoFactorService{requireForAdmins: true}
+	require.True(t, s.Required(&models.User{Role: models.ROLE_ADMIN}))
+	require.False(t, s.Required(&models.User{Role: models.ROLE_BARISTA}))
+
+	require.False(t, (&TwoFactorService{}).Required(&models.User{Role: models.ROLE_ADMIN}))
+}
+
+func TestDisableTwoFactor(t *testing.T) {
+	db := dbtest.Open(t)
+	ctx := context.Background()
+	us := newTestUserService(db, &fakeMailer{})
+	s := us.authService.twoFactor
+
+	// The user signed up with a social login and has no password
+	secret, err := totp.GenerateSecret()
+	require.NoError(t, err)
+	user := createUser(t, db, "disable2fa", models.ROLE_USER)
+	require.NoError(t, db.Model(user).Updates(map[string]any{"two_factor_enabled": true, "totp_secret": secret}).Error)
+
+	require.ErrorIs(t, s.Disable(ctx, user.Id, &models.DisableTwoFactorRequest{}), ErrConfirmationRequired)
+
+	wrong := &models.DisableTwoFactorRequest{Confirmation: models.Confirmation{Code: "000000"}}
+	require.ErrorIs(t, s.Disable(ctx, user.Id, wrong), ErrInvalidConfirmation)
+	var stored models.User
+	require.NoError(t, db.First(&stored, user.Id).Error)
+	require.True(t, stored.TwoFactorEnabled)
+	var throttle models.LoginThrottle
+	require.NoError(t, db.First(&throttle, "key = ?", accountKey(user.Email)).Error)
+	require.Equal(t, 1, throttle.Failures, "wrong codes count like failed logins")
+
+	code, err := totp.Code(secret, util.CurrentTime())
+	require.NoError(t, err)
+	require.NoError(t, s.Disable(ctx, user.Id, &models.DisableTwoFactorRequest{Confirmation: models.Confirmation{Code: code}}))
+	require.NoError(t, db.First(&stored, user.Id).Error)
+	require.False(t, stored.TwoFactorEnabled)
+	require.Empty(t, stored.TOTPSecret)
+}
@@ -5,40 +5,196 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 
-	"github.com/dgrijalva/jwt-go"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/authz"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
 	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
 	"github.com/emmrys-jay/coffee-delivery-api/util"
+	"github.com/sirupsen/logrus"
 	"golang.org/x/crypto/bcrypt"
 )
 
 type UserService struct {
-	repo      *repository.UserRepository
-	jwtSecret string
+	repo        *repository.UserRepository
+	tokenRepo   *repository.TokenRepository
+	rbacService *RBACService
+	authService *AuthService
+	lockout     *LockoutService
+	mailer      AccountMailer
+
+	// appURL is the base of the links in emails, e.g. the password reset
+	// page of the web app
+	appURL    string
+	verifyTTL time.Duration
+	resetTTL  time.Duration
 }
 
-func NewUserService(repo *repository.UserRepository) *UserService {
-	return &UserService{repo: repo, jwtSecret: os.Getenv("SECRET")}
+func NewUserService(
+	repo *repository.UserRepository,
+	tokenRepo *repository.TokenRepository,
+	rbacService *RBACService,
+	authService *AuthService,
+	lockout *LockoutService,
+	mailer AccountMailer,
+) *UserService {
+	svc := &UserService{
+		repo:        repo,
+		tokenRepo:   tokenRepo,
+		rbacService: rbacService,
+		authService: authService,
+		lockout:     lockout,
+		mailer:      mailer,
+		appURL:      defaultAppURL,
+		verifyTTL:   defaultEmailVerificationTTL,
+		resetTTL:    defaultPasswordResetTTL,
+	}
+
+	if v := os.Getenv("APP_URL"); v != "" {
+		svc.appURL = strings.TrimSuffix(v, "/")
+	}
+	if v, err := strconv.Atoi(os.Getenv("EMAIL_VERIFICATION_TTL_HOURS")); err == nil && v > 0 {
+		svc.verifyTTL = time.Duration(v) * time.Hour
+	}
+	if v, err := strconv.Atoi(os.Getenv("PASSWORD_RESET_TTL_MINUTES")); err == nil && v > 0 {
+		svc.resetTTL = time.Duration(v) * time.Minute
+	}
+
+	return svc
 }
 
+// CreateUser signs up a customer and emails them a link to verify their
+// address. Accounts with any other role are created by staff through
+// CreateStaffUser.
 func (s *UserService) CreateUser(ctx context.Context, req *models.CreateUser) (*models.User, error) {
-	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if req.Role != "" && req.Role != models.ROLE_USER {
+		return nil, fmt.Errorf("%w: sign-ups can only create customer accounts", authz.ErrForbidden)
+	}
+
+	user, err := s.createUser(ctx, req, models.ROLE_USER)
+	if err != nil {
+		return nil, err
+	}
+
+	// The account is usable without a verified address, and the user can
+	// ask for another link
+	if err := s.sendVerification(ctx, user, models.TOKEN_EMAIL_VERIFICATION, user.Email); err != nil {
+		logrus.Errorf("error sending verification email to user %d: %v", user.Id, err)
+	}
+	return user, nil
+}
+
+// CreateStaffUser creates an account with any role the principal is allowed
+// to grant.
+func (s *UserService) CreateStaffUser(ctx context.Context, p authz.Principal, req *models.CreateUser) (*models.User, error) {
+	if req.Role == "" {
+		return nil, errors.New("a role is required")
+	}
+
+	if err := s.checkCanGrant(ctx, p, req.Role); err != nil {
+		return nil, err
+	}
+
+	user, err := s.createUser(ctx, req, req.Role)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.sendVerification(ctx, user, models.TOKEN_EMAIL_VERIFICATION, user.Email); err != nil {
+		logrus.Errorf("error sending verification email to user %d: %v", user.Id, err)
+	}
+	return user, nil
+}
+
+// AssignRole changes the role of a user. The user's sessions are revoked so
+// that the new role applies to their next login.
+func (s *UserService) AssignRole(ctx context.Context, p authz.Principal, userId uint, roleName string) (*models.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if user.Role == models.ROLE_ADMIN && p.Role != models.ROLE_ADMIN {
+		return nil, fmt.Errorf("%w: only admins can change the role of an admin", authz.ErrForbidden)
+	}
+	if err := s.checkCanGrant(ctx, p, roleName); err != nil {
+		return nil, err
+	}
+
+	user.Role = roleName
+	user.UpdatedAt = util.CurrentTime()
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error updating user role, %w", err)
+	}
+
+	if _, err := s.authService.LogoutAll(ctx, user.Id); err != nil {
+		return nil, fmt.Errorf("error revoking sessions, %w", err)
+	}
+	return user, nil
+}
+
+// BootstrapAdmin creates the first admin from the ADMIN_EMAIL and
+// ADMIN_PASSWORD environment variables. It does nothing if they are not set
+// or an admin already exists.
+func (s *UserService) BootstrapAdmin(ctx context.Context) error {
+	email, password := os.Getenv("ADMIN_EMAIL"), os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return nil
+	}
+
+	admins, err := s.repo.CountByRole(ctx, models.ROLE_ADMIN)
 	if err != nil {
+		return fmt.Errorf("error counting admins, %w", err)
+	}
+	if admins > 0 {
+		return nil
+	}
+
+	_, err = s.createUser(ctx, &models.CreateUser{
+		FirstName: "Admin",
+		LastName:  "Admin",
+		Email:     email,
+		Password:  password,
+	}, models.ROLE_ADMIN)
+	if err != nil {
+		return fmt.Errorf("error creating first admin, %w", err)
+	}
+
+	logrus.Infof("created admin account %s", email)
+	return nil
+}
+
+func (s *UserService) checkCanGrant(ctx context.Context, p authz.Principal, roleName string) error {
+	role, err := s.rbacService.GetRole(ctx, roleName)
+	if err != nil {
+		return err
+	}
+
+	if !s.rbacService.CanGrant(p.Role, p.Permissions, role) {
+		return fmt.Errorf("%w: you cannot grant the %s role", authz.ErrForbidden, role.Name)
+	}
+	return nil
+}
+
+func (s *UserService) createUser(ctx context.Context, req *models.CreateUser, role string) (*models.User, error) {
+	email := strings.ToLower(strings.TrimSpace(req.Email))
+	if err := checkPasswordStrength(req.Password, req.FirstName, req.LastName, email); err != nil {
 		return nil, err
 	}
 
-	if req.Role != "user" && req.Role != "admin" {
-		return nil, errors.New("invalid role entered")
+	hashedPassword, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, err
 	}
 
 	user := models.User{
 		FirstName: req.FirstName,
 		LastName:  req.LastName,
-		Email:     req.Email,
+		Email:     email,
 		Password:  string(hashedPassword),
-		Role:      req.Role,
+		Role:      role,
 		CreatedAt: util.CurrentTime(),
 		UpdatedAt: util.CurrentTime(),
 	}
@@ -61,33 +217,38 @@ func (s *UserService) UpdateUser(ctx context.Context, id uint, updateRequest *mo
 	return s.repo.UpdateUser(ctx, user)
 }
 
-func (s *UserService) DeleteUser(ctx context.Context, id uint) error {
-	return s.repo.DeleteUser(ctx, id)
-}
-
-func (s *UserService) Login(ctx context.Context, email, password string) (string, error) {
-	user, err := s.repo.GetUserByEmail(ctx, email)
+// UpdateProfile changes the user's own profile.
+func (s *UserService) UpdateProfile(ctx context.Context, userId uint, req *models.ProfileUpdate) (*models.User, error) {
+	user, err := s.repo.GetUserByID(ctx, userId)
 	if err != nil {
-		return "", err
+		return nil, err
 	}
 
-	err = bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(password))
-	if err != nil {
-		return "", errors.New("invalid credentials")
+	if req.FirstName != nil {
+		user.FirstName = *req.FirstName
+	}
+	if req.LastName != nil {
+		user.LastName = *req.LastName
 	}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
-		"user_id": fmt.Sprint(user.Id),
-		"exp":     time.Now().Add(time.Hour * 72).Unix(),
-		"role":    user.Role,
-	})
+	user.UpdatedAt = util.CurrentTime()
+	if err := s.repo.UpdateUser(ctx, user); err != nil {
+		return nil, fmt.Errorf("error updating profile, %w", err)
+	}
+	return user, nil
+}
 
-	tokenString, err := token.SignedString([]byte(s.jwtSecret))
+// DeleteUser deletes a user on behalf of p. Only admins may delete admins.
+func (s *UserService) DeleteUser(ctx context.Context, p authz.Principal, id uint) error {
+	user, err := s.repo.GetUserByID(ctx, id)
 	if err != nil {
-		return "", err
+		return err
 	}
 
-	return tokenString, nil
+	if user.Role == models.ROLE_ADMIN && p.Role != models.ROLE_ADMIN {
+		return fmt.Errorf("%w: only admins can delete an admin", authz.ErrForbidden)
+	}
+	return s.repo.DeleteUser(ctx, id)
 }
 
 func (s *UserService) GetAllUsers(ctx context.Context) ([]*models.User, error) {
@@ -0,0 +1,465 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/repository"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/util"
+)
+
+const (
+	// webhookMaxAttempts is how many times a delivery is tried before it is
+	// marked FAILED. With webhookRetryBase doubling per attempt the last
+	// retry happens roughly a day after the event.
+	webhookMaxAttempts = 10
+	webhookRetryBase   = time.Minute
+	webhookRetryMax    = 6 * time.Hour
+
+	// webhookLease is how long a claimed delivery is hidden from other workers.
+	webhookLease   = 5 * time.Minute
+	webhookTimeout = 10 * time.Second
+	webhookBatch   = 100
+
+	webhookLogLimit = 100
+
+	defaultLowStockThreshold = 10
+
+	WebhookSignatureHeader = "X-Webhook-Signature"
+	WebhookEventHeader     = "X-Webhook-Event"
+	WebhookIdHeader        = "X-Webhook-Id"
+)
+
+// WebhookPayload is the JSON body posted to webhook endpoints.
+type WebhookPayload struct {
+	Id        string    `json:"id"`
+	Event     string    `json:"event"`
+	CreatedAt time.Time `json:"created_at"`
+	Data      any       `json:"data"`
+}
+
+type WebhookService struct {
+	repo      *repository.WebhookRepository
+	orderRepo *repository.OrderRepository
+	client    *http.Client
+
+	lowStockThreshold uint
+}
+
+func NewWebhookService(repo *repository.WebhookRepository, orderRepo *repository.OrderRepository) *WebhookService {
+	ws := &WebhookService{
+		repo:              repo,
+		orderRepo:         orderRepo,
+		client:            &http.Client{Timeout: webhookTimeout},
+		lowStockThreshold: defaultLowStockThreshold,
+	}
+
+	if v, err := strconv.ParseUint(os.Getenv("LOW_STOCK_THRESHOLD"), 10, 32); err == nil {
+		ws.lowStockThreshold = uint(v)
+	}
+
+	return ws
+}
+
+// PublishWebhookEvents enqueues webhook events from the outbox, so that an
+// event is sent to partners if and only if the change it describes was
+// committed.
+func PublishWebhookEvents(ws *WebhookService, dispatcher *outbox.Dispatcher) {
+	dispatcher.Subscribe("webhooks", models.OUTBOX_ORDER_CREATED, ws.onOrderEvent)
+	dispatcher.Subscribe("webhooks", models.OUTBOX_ORDER_STATUS_CHANGED, ws.onOrderEvent)
+	dispatcher.Subscribe("webhooks", models.OUTBOX_TRANSACTION_CREATED, ws.onTransactionEvent)
+	dispatcher.Subscribe("webhooks", models.OUTBOX_TRANSACTION_STATUS_CHANGED, ws.onTransactionEvent)
+	dispatcher.Subscribe("webhooks", models.OUTBOX_COFFEE_STOCK_CHANGED, ws.onStockChanged)
+}
+
+func (ws *WebhookService) onOrderEvent(ctx context.Context, event models.OutboxEvent) error {
+	var change models.OrderStatusChanged
+	if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+		return fmt.Errorf("error decoding outbox payload, %w", err)
+	}
+
+	var webhookEvent string
+	switch {
+	case event.Type == models.OUTBOX_ORDER_CREATED:
+		webhookEvent = models.WEBHOOK_ORDER_CREATED
+	case change.Status == models.ORDER_STATUS_CANCELED:
+		webhookEvent = models.WEBHOOK_ORDER_CANCELLED
+	default:
+		return nil
+	}
+
+	order, err := ws.orderRepo.GetOrder(ctx, change.OrderID)
+	if err != nil {
+		return fmt.Errorf("error fetching order, %w", err)
+	}
+
+	return ws.enqueue(ctx, outboxEventId(event), webhookEvent, order.ToOrderResponse())
+}
+
+func (ws *WebhookService) onTransactionEvent(ctx context.Context, event models.OutboxEvent) error {
+	var change models.TransactionChanged
+	if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+		return fmt.Errorf("error decoding outbox payload, %w", err)
+	}
+
+	if change.PaymentStatus != models.PAYMENT_COMPLETED {
+		return nil
+	}
+
+	return ws.enqueue(ctx, outboxEventId(event), models.WEBHOOK_ORDER_PAID, map[string]any{
+		"order_id":  change.OrderID,
+		"user_id":   change.UserID,
+		"reference": change.Reference,
+		"amount":    change.Amount,
+		"currency":  change.Currency,
+	})
+}
+
+// outboxEventId derives a stable webhook event ID from an outbox event, so
+// that redelivered outbox events map to the same webhook event.
+func outboxEventId(event models.OutboxEvent) string {
+	return "evt_" + strconv.FormatUint(uint64(event.Id), 10)
+}
+
+func (ws *WebhookService) CreateEndpoint(ctx context.Context, req *models.CreateWebhookEndpoint) (*models.CreateWebhookEndpointResponse, error) {
+	if err := validateWebhookEndpoint(req.URL, req.Events); err != nil {
+		return nil, err
+	}
+
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return nil, err
+	}
+
+	endpoint := models.WebhookEndpoint{
+		URL:         req.URL,
+		Description: req.Description,
+		Events:      req.Events,
+		Secret:      secret,
+		Active:      true,
+		CreatedAt:   util.CurrentTime(),
+		UpdatedAt:   util.CurrentTime(),
+	}
+
+	if err := ws.repo.CreateEndpoint(ctx, &endpoint); err != nil {
+		return nil, fmt.Errorf("error creating webhook endpoint, %w", err)
+	}
+
+	return &models.CreateWebhookEndpointResponse{WebhookEndpoint: endpoint, Secret: secret}, nil
+}
+
+func (ws *WebhookService) GetEndpoint(ctx context.Context, id uint) (*models.WebhookEndpoint, error) {
+	return ws.repo.GetEndpoint(ctx, id)
+}
+
+func (ws *WebhookService) ListEndpoints(ctx context.Context) ([]models.WebhookEndpoint, error) {
+	return ws.repo.ListEndpoints(ctx)
+}
+
+func (ws *WebhookService) UpdateEndpoint(ctx context.Context, id uint, req *models.UpdateWebhookEndpoint) (*models.WebhookEndpoint, error) {
+	endpoint, err := ws.repo.GetEndpoint(ctx, id)
+	if err != nil {
+		return nil, errors.New("webhook endpoint not found")
+	}
+
+	if req.URL != "" {
+		endpoint.URL = req.URL
+	}
+	if req.Events != nil {
+		endpoint.Events = req.Events
+	}
+	if req.Description != nil {
+		endpoint.Description = *req.Description
+	}
+	if req.Active != nil {
+		endpoint.Active = *req.Active
+	}
+
+	if err := validateWebhookEndpoint(endpoint.URL, endpoint.Events); err != nil {
+		return nil, err
+	}
+
+	endpoint.UpdatedAt = util.CurrentTime()
+	if err := ws.repo.UpdateEndpoint(ctx, endpoint); err != nil {
+		return nil, fmt.Errorf("error updating webhook endpoint, %w", err)
+	}
+	return endpoint, nil
+}
+
+func (ws *WebhookService) DeleteEndpoint(ctx context.Context, id uint) error {
+	return ws.repo.DeleteEndpoint(ctx, id)
+}
+
+// ListDeliveries returns the delivery log of an endpoint, newest first.
+func (ws *WebhookService) ListDeliveries(ctx context.Context, endpointId uint) ([]models.WebhookDelivery, error) {
+	return ws.repo.ListEndpointDeliveries(ctx, endpointId, webhookLogLimit)
+}
+
+// Redeliver sends a previously delivered event to its endpoint again. The
+// attempt is made immediately and recorded as a new delivery, which is
+// retried like any other if it fails.
+func (ws *WebhookService) Redeliver(ctx context.Context, endpointId, deliveryId uint) (*models.WebhookDelivery, error) {
+	endpoint, err := ws.repo.GetEndpoint(ctx, endpointId)
+	if err != nil {
+		return nil, errors.New("webhook endpoint not found")
+	}
+
+	original, err := ws.repo.GetDelivery(ctx, endpointId, deliveryId)
+	if err != nil {
+		return nil, errors.New("webhook delivery not found")
+	}
+
+	now := util.CurrentTime()
+	delivery := models.WebhookDelivery{
+		EndpointID:    endpoint.Id,
+		EventID:       original.EventID,
+		RedeliveryOf:  &original.Id,
+		Event:         original.Event,
+		Payload:       original.Payload,
+		Status:        models.WEBHOOK_DELIVERY_PENDING,
+		NextAttemptAt: &now,
+		CreatedAt:     now,
+		UpdatedAt:     now,
+	}
+	if err := ws.repo.CreateDelivery(ctx, &delivery); err != nil {
+		return nil, fmt.Errorf("error creating webhook delivery, %w", err)
+	}
+
+	if err := ws.attempt(ctx, endpoint, &delivery); err != nil {
+		return nil, err
+	}
+	return &delivery, nil
+}
+
+// DeliverDue attempts every pending delivery that is due and returns how many
+// were attempted.
+func (ws *WebhookService) DeliverDue(ctx context.Context) (int, error) {
+	now := util.CurrentTime()
+	due, err := ws.repo.ListDueDeliveries(ctx, now, webhookBatch)
+	if err != nil {
+		return 0, err
+	}
+
+	endpoints := make(map[uint]*models.WebhookEndpoint)
+	attempted := 0
+	for i := range due {
+		delivery := &due[i]
+
+		claimed, err := ws.repo.ClaimDelivery(ctx, delivery.Id, *delivery.NextAttemptAt, now.Add(webhookLease))
+		if err != nil {
+			return attempted, err
+		}
+		if !claimed {
+			continue
+		}
+
+		endpoint, ok := endpoints[delivery.EndpointID]
+		if !ok {
+			if endpoint, err = ws.repo.GetEndpoint(ctx, delivery.EndpointID); err != nil {
+				return attempted, fmt.Errorf("error fetching webhook endpoint, %w", err)
+			}
+			endpoints[delivery.EndpointID] = endpoint
+		}
+
+		if err := ws.attempt(ctx, endpoint, delivery); err != nil {
+			return attempted, err
+		}
+		attempted++
+	}
+
+	return attempted, nil
+}
+
+// enqueue records a delivery of the event for every active endpoint that
+// subscribes to it, unless the event was already enqueued for the endpoint.
+// Deliveries are sent by the webhook worker.
+func (ws *WebhookService) enqueue(ctx context.Context, eventId, event string, data any) error {
+	endpoints, err := ws.repo.ListActiveEndpoints(ctx)
+	if err != nil {
+		return fmt.Errorf("error listing webhook endpoints, %w", err)
+	}
+
+	now := util.CurrentTime()
+	payload, err := json.Marshal(WebhookPayload{
+		Id:        eventId,
+		Event:     event,
+		CreatedAt: now,
+		Data:      data,
+	})
+	if err != nil {
+		return fmt.Errorf("error encoding webhook payload, %w", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, endpoint := range endpoints {
+		if !endpoint.Subscribes(event) {
+			continue
+		}
+
+		deliveries = append(deliveries, models.WebhookDelivery{
+			EndpointID:    endpoint.Id,
+			EventID:       eventId,
+			Event:         event,
+			Payload:       string(payload),
+			Status:        models.WEBHOOK_DELIVERY_PENDING,
+			NextAttemptAt: &now,
+			CreatedAt:     now,
+			UpdatedAt:     now,
+		})
+	}
+
+	if err := ws.repo.CreateDeliveries(ctx, deliveries); err != nil {
+		return fmt.Errorf("error recording webhook deliveries, %w", err)
+	}
+	return nil
+}
+
+// onStockChanged enqueues stock.low when an order takes a coffee's stock
+// from above the threshold to at or below it.
+func (ws *WebhookService) onStockChanged(ctx context.Context, event models.OutboxEvent) error {
+	var change models.StockChanged
+	if err := json.Unmarshal([]byte(event.Payload), &change); err != nil {
+		return fmt.Errorf("error decoding outbox payload, %w", err)
+	}
+
+	if !crossesThreshold(change.Previous, change.Remaining, ws.lowStockThreshold) {
+		return nil
+	}
+
+	return ws.enqueue(ctx, outboxEventId(event), models.WEBHOOK_STOCK_LOW, map[string]any{
+		"coffee_id": change.CoffeeID,
+		"name":      change.Name,
+		"remaining": change.Remaining,
+		"threshold": ws.lowStockThreshold,
+	})
+}
+
+func crossesThreshold(previous, remaining, threshold uint) bool {
+	return previous > threshold && remaining <= threshold
+}
+
+// attempt posts a delivery to its endpoint and records the outcome,
+// scheduling a retry with exponential backoff if it failed.
+func (ws *WebhookService) attempt(ctx context.Context, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery) error {
+	now := util.CurrentTime()
+	status, err := postWebhook(ctx, ws.client, endpoint, delivery, now)
+
+	delivery.Attempts++
+	delivery.LastAttemptAt = &now
+	delivery.ResponseStatus = status
+	delivery.UpdatedAt = now
+
+	switch {
+	case err == nil:
+		delivery.Status = models.WEBHOOK_DELIVERY_SUCCEEDED
+		delivery.LastError = ""
+		delivery.NextAttemptAt = nil
+	case delivery.Attempts >= webhookMaxAttempts:
+		delivery.Status = models.WEBHOOK_DELIVERY_FAILED
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = nil
+	default:
+		next := now.Add(webhookBackoff(delivery.Attempts))
+		delivery.LastError = err.Error()
+		delivery.NextAttemptAt = &next
+	}
+
+	if err := ws.repo.UpdateDelivery(ctx, delivery); err != nil {
+		return fmt.Errorf("error updating webhook delivery, %w", err)
+	}
+	return nil
+}
+
+// postWebhook sends a delivery's payload to the endpoint. Any response other
+// than 2xx is an error.
+func postWebhook(ctx context.Context, client *http.Client, endpoint *models.WebhookEndpoint, delivery *models.WebhookDelivery, now time.Time) (int, error) {
+	body := []byte(delivery.Payload)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(WebhookEventHeader, delivery.Event)
+	req.Header.Set(WebhookIdHeader, delivery.EventID)
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(endpoint.Secret, now, body))
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("endpoint responded with status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+// SignWebhook returns the signature header value for a webhook body:
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>">". Including
+// the timestamp lets receivers reject replayed deliveries.
+func SignWebhook(secret string, at time.Time, body []byte) string {
+	timestamp := strconv.FormatInt(at.Unix(), 10)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+
+	return "t=" + timestamp + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff returns the wait before the next attempt after the given
+// number of failed attempts.
+func webhookBackoff(attempts uint) time.Duration {
+	wait := webhookRetryBase
+	for i := uint(1); i < attempts; i++ {
+		wait *= 2
+		if wait >= webhookRetryMax {
+			return webhookRetryMax
+		}
+	}
+	return wait
+}
+
+func validateWebhookEndpoint(rawURL string, events []string) error {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return errors.New("webhook URL must be an absolute https URL")
+	}
+
+	if len(events) == 0 {
+		return errors.New("at least one event is required")
+	}
+	for _, event := range events {
+		if !models.IsValidWebhookEvent(event) {
+			return fmt.Errorf("unknown webhook event %q", event)
+		}
+	}
+	return nil
+}
+
+func generateWebhookSecret() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating webhook secret, %w", err)
+	}
+	return "whsec_" + hex.EncodeToString(b), nil
+}
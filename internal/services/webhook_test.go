@@ -0,0 +1,82 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/database/models"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignWebhook(t *testing.T) {
+	at := time.Unix(1700000000, 0)
+	body := []byte(`{"event":"order.paid"}`)
+
+	mac := hmac.New(sha256.New, []byte("whsec_test"))
+	mac.Write([]byte("1700000000." + string(body)))
+	want := "t=1700000000,v1=" + hex.EncodeToString(mac.Sum(nil))
+
+	require.Equal(t, want, SignWebhook("whsec_test", at, body))
+	require.NotEqual(t, want, SignWebhook("whsec_other", at, body))
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	require.Equal(t, time.Minute, webhookBackoff(1))
+	require.Equal(t, 2*time.Minute, webhookBackoff(2))
+	require.Equal(t, 8*time.Minute, webhookBackoff(4))
+	require.Equal(t, webhookRetryMax, webhookBackoff(webhookMaxAttempts))
+}
+
+func TestCrossesThreshold(t *testing.T) {
+	require.True(t, crossesThreshold(12, 10, 10))
+	require.True(t, crossesThreshold(11, 0, 10))
+
+	require.False(t, crossesThreshold(15, 11, 10))
+	require.False(t, crossesThreshold(10, 8, 10))
+}
+
+func TestPostWebhook(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	endpoint := &models.WebhookEndpoint{Secret: "whsec_test"}
+	delivery := &models.WebhookDelivery{EventID: "evt_1", Event: models.WEBHOOK_ORDER_CREATED, Payload: `{"id":"evt_1"}`}
+
+	var gotSignature, gotEvent string
+	var gotBody []byte
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotSignature = r.Header.Get(WebhookSignatureHeader)
+		gotEvent = r.Header.Get(WebhookEventHeader)
+		gotBody, _ = io.ReadAll(r.Body)
+		if strings.HasSuffix(r.URL.Path, "/fail") {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	endpoint.URL = server.URL + "/ok"
+	status, err := postWebhook(context.Background(), server.Client(), endpoint, delivery, now)
+	require.NoError(t, err)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, models.WEBHOOK_ORDER_CREATED, gotEvent)
+	require.Equal(t, delivery.Payload, string(gotBody))
+	require.Equal(t, SignWebhook("whsec_test", now, gotBody), gotSignature)
+
+	endpoint.URL = server.URL + "/fail"
+	status, err = postWebhook(context.Background(), server.Client(), endpoint, delivery, now)
+	require.Error(t, err)
+	require.Equal(t, http.StatusInternalServerError, status)
+}
+
+func TestValidateWebhookEndpoint(t *testing.T) {
+	require.NoError(t, validateWebhookEndpoint("https://pos.example.com/hooks", []string{models.WEBHOOK_ORDER_PAID}))
+	require.Error(t, validateWebhookEndpoint("http://pos.example.com/hooks", []string{models.WEBHOOK_ORDER_PAID}))
+	require.Error(t, validateWebhookEndpoint("https://pos.example.com/hooks", []string{"order.refunded"}))
+	require.Error(t, validateWebhookEndpoint("https://pos.example.com/hooks", nil))
+}
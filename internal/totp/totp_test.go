@@ -0,0 +1,65 @@
+package totp
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// The SHA-1 test vectors of RFC 6238, appendix B.
+func TestRFC6238Vectors(t *testing.T) {
+	key := []byte("12345678901234567890")
+	vectors := map[int64]string{
+		59:          "94287082",
+		1111111109:  "07081804",
+		1111111111:  "14050471",
+		1234567890:  "89005924",
+		2000000000:  "69279037",
+		20000000000: "65353130",
+	}
+	for unix, want := range vectors {
+		require.Equal(t, want, hotp(key, uint64(Step(time.Unix(unix, 0))), 8), unix)
+	}
+
+	code, err := Code(encoding.EncodeToString(key), time.Unix(59, 0))
+	require.NoError(t, err)
+	require.Equal(t, "287082", code)
+}
+
+func TestValidate(t *testing.T) {
+	secret, err := GenerateSecret()
+	require.NoError(t, err)
+	require.Len(t, secret, 32)
+
+	now := time.Unix(1700000000, 0)
+	code, err := Code(secret, now)
+	require.NoError(t, err)
+
+	step, ok := Validate(secret, code, now, 1)
+	require.True(t, ok)
+	require.Equal(t, Step(now), step)
+
+	// One step of drift either way is accepted, two are not
+	_, ok = Validate(secret, code, now.Add(Period), 1)
+	require.True(t, ok)
+	_, ok = Validate(secret, code, now.Add(2*Period), 1)
+	require.False(t, ok)
+
+	_, ok = Validate(secret, "12345", now, 1)
+	require.False(t, ok)
+	_, ok = Validate("not base32!", code, now, 1)
+	require.False(t, ok)
+}
+
+func TestURI(t *testing.T) {
+	u, err := url.Parse(URI("Coffee Delivery", "ada@example.com", "JBSWY3DPEHPK3PXP"))
+	require.NoError(t, err)
+	require.Equal(t, "otpauth", u.Scheme)
+	require.Equal(t, "totp", u.Host)
+	require.Equal(t, "/Coffee Delivery:ada@example.com", u.Path)
+	require.Equal(t, "JBSWY3DPEHPK3PXP", u.Query().Get("secret"))
+	require.Equal(t, "Coffee Delivery", u.Query().Get("issuer"))
+	require.Equal(t, "6", u.Query().Get("digits"))
+}
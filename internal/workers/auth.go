@@ -0,0 +1,40 @@
+package workers
+
+import (
+	"context"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/sirupsen/logrus"
+)
+
+// DeleteExpiredTokens returns a job that removes expired refresh tokens and
+// revocation list entries.
+func DeleteExpiredTokens(authService *services.AuthService) Job {
+	return func(ctx context.Context) error {
+		deleted, err := authService.DeleteExpiredTokens(ctx)
+		if err != nil {
+			return err
+		}
+
+		if deleted > 0 {
+			logrus.Infof("deleted %d expired tokens", deleted)
+		}
+		return nil
+	}
+}
+
+// DeleteStaleLoginThrottles returns a job that removes failed login counts
+// that no longer count towards a lockout.
+func DeleteStaleLoginThrottles(lockoutService *services.LockoutService) Job {
+	return func(ctx context.Context) error {
+		deleted, err := lockoutService.DeleteStale(ctx)
+		if err != nil {
+			return err
+		}
+
+		if deleted > 0 {
+			logrus.Infof("deleted %d stale login throttles", deleted)
+		}
+		return nil
+	}
+}
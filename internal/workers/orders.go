@@ -0,0 +1,97 @@
+package workers
+
+import (
+	"context"
+
+	"github.com/emmrys-jay/coffee-delivery-api/internal/outbox"
+	"github.com/emmrys-jay/coffee-delivery-api/internal/services"
+	"github.com/sirupsen/logrus"
+)
+
+// ReleaseScheduledOrders returns a job that moves scheduled orders into the
+// preparation queue shortly before their slot.
+func ReleaseScheduledOrders(orderService *services.OrderService) Job {
+	return func(ctx context.Context) error {
+		released, err := orderService.ReleaseScheduledOrders(ctx)
+		if err != nil {
+			return err
+		}
+
+		if released > 0 {
+			logrus.Infof("released %d scheduled orders", released)
+		}
+		return nil
+	}
+}
+
+// RunSubscriptions returns a job that generates and charges the orders of
+// subscriptions that are due.
+func RunSubscriptions(subscriptionService *services.SubscriptionService) Job {
+	return func(ctx context.Context) error {
+		processed, err := subscriptionService.RunDueSubscriptions(ctx)
+		if err != nil {
+			return err
+		}
+
+		if processed > 0 {
+			logrus.Infof("processed %d subscription runs", processed)
+		}
+		return nil
+	}
+}
+
+// DispatchDeliveries returns a job that assigns waiting deliveries to
+// couriers as they become available.
+func DispatchDeliveries(deliveryService *services.DeliveryService) Job {
+	return func(ctx context.Context) error {
+		assigned, err := deliveryService.Dispatch(ctx)
+		if err != nil {
+			return err
+		}
+
+		if assigned > 0 {
+			logrus.Infof("assigned %d deliveries to couriers", assigned)
+		}
+		return nil
+	}
+}
+
+// DeliverWebhooks returns a job that sends due webhook deliveries, including
+// retries of failed ones.
+func DeliverWebhooks(webhookService *services.WebhookService) Job {
+	return func(ctx context.Context) error {
+		attempted, err := webhookService.DeliverDue(ctx)
+		if err != nil {
+			return err
+		}
+
+		if attempted > 0 {
+			logrus.Infof("attempted %d webhook deliveries", attempted)
+		}
+		return nil
+	}
+}
+
+// DispatchOutbox returns a job that publishes committed outbox events to
+// their subscribers and sinks.
+func DispatchOutbox(dispatcher *outbox.Dispatcher) Job {
+	return func(ctx context.Context) error {
+		_, err := dispatcher.Dispatch(ctx)
+		return err
+	}
+}
+
+// PruneOutbox returns a job that removes outbox events dispatched long ago.
+func PruneOutbox(dispatcher *outbox.Dispatcher) Job {
+	return func(ctx context.Context) error {
+		deleted, err := dispatcher.Prune(ctx)
+		if err != nil {
+			return err
+		}
+
+		if deleted > 0 {
+			logrus.Infof("deleted %d dispatched outbox events", deleted)
+		}
+		return nil
+	}
+}
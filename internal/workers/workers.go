@@ -0,0 +1,33 @@
+package workers
+
+import (
+	"context"
+	"time"
+
+	"github.com/sirupsen/logrus"
+)
+
+// Job is a unit of background work run periodically by a worker.
+type Job func(ctx context.Context) error
+
+// Start runs job every interval in its own goroutine until ctx is cancelled.
+// Errors are logged and the job is retried on the next tick.
+func Start(ctx context.Context, name string, interval time.Duration, job Job) {
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		logrus.Infof("worker %s started, running every %v", name, interval)
+		for {
+			select {
+			case <-ctx.Done():
+				logrus.Infof("worker %s stopped", name)
+				return
+			case <-ticker.C:
+				if err := job(ctx); err != nil {
+					logrus.Errorf("worker %s: %v", name, err)
+				}
+			}
+		}
+	}()
+}
@@ -0,0 +1,51 @@
+package util
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/shopspring/decimal"
+)
+
+const DefaultCurrency = "NGN"
+
+// currencyMinorUnits holds the number of decimal places of the minor unit of
+// every supported currency, e.g. 2 for NGN since 1 NGN = 100 kobo.
+var currencyMinorUnits = map[string]int32{
+	"NGN": 2,
+	"GHS": 2,
+	"KES": 2,
+	"USD": 2,
+}
+
+func IsSupportedCurrency(currency string) bool {
+	_, ok := currencyMinorUnits[currency]
+	return ok
+}
+
+// NormalizeCurrency upper-cases a currency code, defaulting to DefaultCurrency
+// when it is empty.
+func NormalizeCurrency(currency string) string {
+	currency = strings.ToUpper(strings.TrimSpace(currency))
+	if currency == "" {
+		return DefaultCurrency
+	}
+	return currency
+}
+
+// ToSubunit converts an amount in major units (e.g. naira) to the currency's
+// minor unit (e.g. kobo). Amounts with more precision than the minor unit are
+// rejected rather than silently rounded.
+func ToSubunit(amount decimal.Decimal, currency string) (decimal.Decimal, error) {
+	places, ok := currencyMinorUnits[currency]
+	if !ok {
+		return decimal.Decimal{}, fmt.Errorf("unsupported currency %q", currency)
+	}
+
+	subunit := amount.Shift(places)
+	if !subunit.Equal(subunit.Truncate(0)) {
+		return decimal.Decimal{}, fmt.Errorf("amount %s has more precision than %s allows", amount.String(), currency)
+	}
+
+	return subunit, nil
+}
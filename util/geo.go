@@ -0,0 +1,50 @@
+package util
+
+import "math"
+
+const earthRadiusKm = 6371.0
+
+// GeoPoint is a WGS84 coordinate in decimal degrees.
+type GeoPoint struct {
+	Latitude  float64 `json:"latitude" validate:"gte=-90,lte=90"`
+	Longitude float64 `json:"longitude" validate:"gte=-180,lte=180"`
+}
+
+// PointInPolygon reports whether p lies inside the polygon given by its
+// vertices, using the even-odd ray casting rule. The polygon is treated as
+// planar, which is accurate enough for delivery zones spanning a city. Points
+// exactly on an edge may fall on either side.
+func PointInPolygon(p GeoPoint, polygon []GeoPoint) bool {
+	if len(polygon) < 3 {
+		return false
+	}
+
+	inside := false
+	for i, j := 0, len(polygon)-1; i < len(polygon); j, i = i, i+1 {
+		a, b := polygon[i], polygon[j]
+
+		// Does the edge a-b cross the horizontal ray going east from p?
+		if (a.Latitude > p.Latitude) != (b.Latitude > p.Latitude) {
+			crossLng := a.Longitude + (p.Latitude-a.Latitude)*(b.Longitude-a.Longitude)/(b.Latitude-a.Latitude)
+			if p.Longitude < crossLng {
+				inside = !inside
+			}
+		}
+	}
+
+	return inside
+}
+
+// DistanceKm returns the great-circle distance between two points using the
+// haversine formula.
+func DistanceKm(a, b GeoPoint) float64 {
+	toRad := func(deg float64) float64 { return deg * math.Pi / 180 }
+
+	dLat := toRad(b.Latitude - a.Latitude)
+	dLng := toRad(b.Longitude - a.Longitude)
+
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) +
+		math.Cos(toRad(a.Latitude))*math.Cos(toRad(b.Latitude))*math.Sin(dLng/2)*math.Sin(dLng/2)
+
+	return 2 * earthRadiusKm * math.Asin(math.Sqrt(h))
+}
@@ -0,0 +1,45 @@
+package util
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPointInPolygon(t *testing.T) {
+	// A rough square over Lekki, Lagos
+	square := []GeoPoint{
+		{Latitude: 6.42, Longitude: 3.45},
+		{Latitude: 6.42, Longitude: 3.55},
+		{Latitude: 6.48, Longitude: 3.55},
+		{Latitude: 6.48, Longitude: 3.45},
+	}
+
+	require.True(t, PointInPolygon(GeoPoint{Latitude: 6.45, Longitude: 3.50}, square))
+	require.False(t, PointInPolygon(GeoPoint{Latitude: 6.50, Longitude: 3.50}, square))
+	require.False(t, PointInPolygon(GeoPoint{Latitude: 6.45, Longitude: 3.40}, square))
+
+	// A concave "L" shape whose notch must be outside
+	l := []GeoPoint{
+		{Latitude: 0, Longitude: 0},
+		{Latitude: 0, Longitude: 2},
+		{Latitude: 1, Longitude: 2},
+		{Latitude: 1, Longitude: 1},
+		{Latitude: 2, Longitude: 1},
+		{Latitude: 2, Longitude: 0},
+	}
+
+	require.True(t, PointInPolygon(GeoPoint{Latitude: 0.5, Longitude: 1.5}, l))
+	require.True(t, PointInPolygon(GeoPoint{Latitude: 1.5, Longitude: 0.5}, l))
+	require.False(t, PointInPolygon(GeoPoint{Latitude: 1.5, Longitude: 1.5}, l))
+
+	require.False(t, PointInPolygon(GeoPoint{Latitude: 0.5, Longitude: 0.5}, l[:2]))
+}
+
+func TestDistanceKm(t *testing.T) {
+	lagos := GeoPoint{Latitude: 6.5244, Longitude: 3.3792}
+	abuja := GeoPoint{Latitude: 9.0765, Longitude: 7.3986}
+
+	require.InDelta(t, 524, DistanceKm(lagos, abuja), 5)
+	require.Zero(t, DistanceKm(lagos, lagos))
+}
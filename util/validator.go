@@ -20,6 +20,10 @@ func amountValidator(fl validator.FieldLevel) bool {
 	return true
 }
 
+func currencyValidator(fl validator.FieldLevel) bool {
+	return IsSupportedCurrency(NormalizeCurrency(fl.Field().String()))
+}
+
 func NewValidator() *validator.Validate {
 	validate := validator.New()
 
@@ -29,5 +33,11 @@ func NewValidator() *validator.Validate {
 		fmt.Println("Error registering custom validation :", err.Error())
 	}
 
+	// Registering the new rule "currency" for supported currency codes
+	err = validate.RegisterValidation("currency", currencyValidator)
+	if err != nil {
+		fmt.Println("Error registering custom validation :", err.Error())
+	}
+
 	return validate
 }